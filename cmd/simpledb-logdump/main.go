@@ -0,0 +1,109 @@
+// Command simpledb-logdump prints the records of a database log from the newest to the oldest, as text or as JSON lines.
+//
+// It stops with status 1 at the first record that cannot be decoded, such as a torn record at the tail of the log
+// of a database that has not been restarted since a crash. The database must not be in use while it runs.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/kj455/simple-db/pkg/file"
+	simplelog "github.com/kj455/simple-db/pkg/log"
+	"github.com/kj455/simple-db/pkg/tx"
+)
+
+// record is the JSON form of a log record. The fields that do not apply to the record type are omitted.
+type record struct {
+	LSN       int     `json:"lsn"`
+	Op        string  `json:"op"`
+	TxNum     *int    `json:"txnum,omitempty"`
+	File      string  `json:"file,omitempty"`
+	Block     *int    `json:"block,omitempty"`
+	Offset    *int    `json:"offset,omitempty"`
+	Value     any     `json:"value,omitempty"`
+	Savepoint *string `json:"savepoint,omitempty"`
+}
+
+func main() {
+	dir := flag.String("dir", "", "database directory")
+	blockSize := flag.Int("block-size", 4096, "block size of the database")
+	logFile := flag.String("log", "simpledb-log", "name of the log in the database directory, without the segment number")
+	segmentBlocks := flag.Int("segment-blocks", simplelog.DEFAULT_SEGMENT_BLOCKS, "blocks per log segment, 0 if the log is a single file")
+	format := flag.String("format", "text", "output format: text or json")
+	flag.Parse()
+	if *dir == "" || (*format != "text" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if _, err := os.Stat(*dir); err != nil {
+		log.Fatalln("Failed to open database directory:", err)
+	}
+
+	fm := file.NewFileMgr(*dir, *blockSize, file.WithSyncPolicy(file.SYNC_POLICY_NONE))
+	if err := dump(os.Stdout, fm, *logFile, *segmentBlocks, *format); err != nil {
+		log.Fatalln("Failed to dump log:", err)
+	}
+}
+
+func dump(w io.Writer, fm file.FileMgr, logFile string, segmentBlocks int, format string) error {
+	iter, err := simplelog.OpenLogIterator(fm, logFile, segmentBlocks)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		rec, err := tx.NewLogRecord(bytes)
+		if err != nil {
+			return err
+		}
+		if format == "json" {
+			if err := enc.Encode(toJSON(iter.LSN(), rec)); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "%d\t%s\n", iter.LSN(), rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toJSON(lsn int, rec tx.LogRecord) record {
+	r := record{LSN: lsn, Op: rec.Op().String()}
+	if rec.Op() != tx.OP_CHECKPOINT {
+		txNum := rec.TxNum()
+		r.TxNum = &txNum
+	}
+	switch rec := rec.(type) {
+	case *tx.SetIntRecord:
+		r.setBlock(rec.Block(), rec.Offset())
+		r.Value = rec.Value()
+	case *tx.SetStringRecord:
+		r.setBlock(rec.Block(), rec.Offset())
+		r.Value = rec.Value()
+	case *tx.SetValueRecord:
+		r.setBlock(rec.Block(), rec.Offset())
+		r.Value = rec.Value()
+	case *tx.SavepointRecord:
+		name := rec.Name()
+		r.Savepoint = &name
+	}
+	return r
+}
+
+func (r *record) setBlock(block file.BlockId, offset int) {
+	num := block.Number()
+	r.File = block.Filename()
+	r.Block = &num
+	r.Offset = &offset
+}
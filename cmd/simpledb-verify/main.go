@@ -0,0 +1,37 @@
+// Command simpledb-verify checks the checksum of every block in a database directory and reports the bad blocks.
+//
+// It exits with status 1 if a block is corrupt. It opens the database read-only, and refuses a directory that has no format marker.
+// The database must not be in use while it runs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
+
+func main() {
+	dir := flag.String("dir", "", "database directory")
+	blockSize := flag.Int("block-size", 4096, "block size of the database")
+	flag.Parse()
+	if *dir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	fm := file.NewFileMgr(*dir, *blockSize, file.WithReadOnly(), file.WithSyncPolicy(file.SYNC_POLICY_NONE))
+	corrupted, err := file.Verify(fm)
+	if err != nil {
+		log.Fatalln("Failed to verify database:", err)
+	}
+	for _, c := range corrupted {
+		fmt.Printf("%s\t%d\t%s\n", c.Filename, c.BlockNum, c.Reason)
+	}
+	if len(corrupted) > 0 {
+		fmt.Fprintf(os.Stderr, "%d corrupt blocks\n", len(corrupted))
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
@@ -2,6 +2,8 @@ package buffer
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/log"
@@ -13,13 +15,21 @@ const (
 )
 
 type ReadPage interface {
-	GetInt(offset int) uint32
+	GetInt(offset int) int32
+	GetInt64(offset int) int64
+	GetFloat64(offset int) float64
+	GetBool(offset int) bool
+	GetTime(offset int) time.Time
 	GetBytes(offset int) []byte
 	GetString(offset int) string
 }
 
 type WritePage interface {
-	SetInt(offset int, value uint32)
+	SetInt(offset int, value int32)
+	SetInt64(offset int, value int64)
+	SetFloat64(offset int, value float64)
+	SetBool(offset int, value bool)
+	SetTime(offset int, value time.Time)
 	SetBytes(offset int, value []byte)
 	SetString(offset int, value string)
 }
@@ -37,6 +47,8 @@ type BufferImpl struct {
 	pins     int
 	txNum    int
 	lsn      int
+	// latch guards the contents and the block while they are modified, assigned or written to disk, and while a caller holds it with Latch
+	latch sync.Mutex
 }
 
 func NewBuffer(fm file.FileMgr, lm log.LogMgr, blockSize int) *BufferImpl {
@@ -54,11 +66,24 @@ func (b *BufferImpl) Contents() ReadPage {
 	return b.contents
 }
 
+// WriteContents calls write with the contents, latched for the call, and marks the buffer modified by the transaction.
 func (b *BufferImpl) WriteContents(txNum, lsn int, write func(p ReadWritePage)) {
+	b.latch.Lock()
+	defer b.latch.Unlock()
 	b.setModified(txNum, lsn)
 	write(b.contents)
 }
 
+// Latch keeps the contents from being modified, reassigned, written to disk, or latched by another caller, until Unlatch.
+// It guards the short reads of the blocks that no transaction lock protects.
+func (b *BufferImpl) Latch() {
+	b.latch.Lock()
+}
+
+func (b *BufferImpl) Unlatch() {
+	b.latch.Unlock()
+}
+
 func (b *BufferImpl) Block() file.BlockId {
 	return b.block
 }
@@ -72,7 +97,9 @@ func (b *BufferImpl) ModifyingTx() int {
 }
 
 func (b *BufferImpl) AssignToBlock(block file.BlockId) error {
-	if err := b.Flush(); err != nil {
+	b.latch.Lock()
+	defer b.latch.Unlock()
+	if err := b.FlushLatched(); err != nil {
 		return err
 	}
 	if err := b.fileMgr.Read(block, b.contents); err != nil {
@@ -84,12 +111,20 @@ func (b *BufferImpl) AssignToBlock(block file.BlockId) error {
 }
 
 func (b *BufferImpl) Flush() error {
+	b.latch.Lock()
+	defer b.latch.Unlock()
+	return b.FlushLatched()
+}
+
+// FlushLatched writes the contents as Flush does, for a caller that holds the latch.
+func (b *BufferImpl) FlushLatched() error {
 	if b.txNum == INIT_TX_NUM {
 		return nil
 	}
 	if err := b.logMgr.Flush(b.lsn); err != nil {
 		return fmt.Errorf("buffer: failed to flush log: %w", err)
 	}
+	b.contents.SetLSN(b.lsn)
 	if err := b.fileMgr.Write(b.block, b.contents); err != nil {
 		return fmt.Errorf("buffer: failed to write block: %w", err)
 	}
@@ -97,6 +132,17 @@ func (b *BufferImpl) Flush() error {
 	return nil
 }
 
+// Sync forces the file of the block to stable storage after Flush.
+func (b *BufferImpl) Sync() error {
+	if b.block == nil {
+		return nil
+	}
+	if err := b.fileMgr.Sync(b.block.Filename()); err != nil {
+		return fmt.Errorf("buffer: failed to sync block: %w", err)
+	}
+	return nil
+}
+
 func (b *BufferImpl) Pin() {
 	b.pins++
 }
@@ -105,6 +151,10 @@ func (b *BufferImpl) Unpin() {
 	b.pins--
 }
 
+func (b *BufferImpl) PinCount() int {
+	return b.pins
+}
+
 func (b *BufferImpl) setModified(txNum, lsn int) {
 	b.txNum = txNum
 	if lsn > INIT_LSN {
@@ -14,10 +14,34 @@ const defaultMaxWaitTime = 10 * time.Second
 
 type BufferMgrImpl struct {
 	pool         []Buffer
+	blocks       map[blockKey]int
 	availableNum int
-	mu           sync.Mutex
-	time         ttime.Time
-	maxWaitTime  time.Duration
+	mu           *sync.Mutex
+	cond         *sync.Cond
+	// waiters holds the tickets of blocked Pin calls in arrival order; only the first may take a freed buffer
+	waiters     []int
+	nextTicket  int
+	time        ttime.Time
+	maxWaitTime time.Duration
+	policy      ReplacementPolicy
+	stats       Stats
+	closed      bool
+
+	writerInterval time.Duration
+	writerMaxPages int
+	writerCursor   int
+	writerStop     chan struct{}
+	writerDone     chan struct{}
+	writerErr      error
+}
+
+type blockKey struct {
+	filename string
+	blockNum int
+}
+
+func newBlockKey(block file.BlockId) blockKey {
+	return blockKey{filename: block.Filename(), blockNum: block.Number()}
 }
 
 type Option func(*BufferMgrImpl)
@@ -34,34 +58,65 @@ func WithTime(t ttime.Time) Option {
 	}
 }
 
+// WithReplacementPolicy sets the policy that chooses which buffer to replace. The default is a ClockPolicy.
+func WithReplacementPolicy(p ReplacementPolicy) Option {
+	return func(b *BufferMgrImpl) {
+		b.policy = p
+	}
+}
+
 func NewBufferMgr(buffs []Buffer, opts ...Option) *BufferMgrImpl {
 	bm := &BufferMgrImpl{
 		pool:         buffs,
+		blocks:       make(map[blockKey]int, len(buffs)),
 		availableNum: len(buffs),
 		time:         ttime.NewTime(),
 		maxWaitTime:  defaultMaxWaitTime,
+		policy:       NewClockPolicy(),
+		mu:           &sync.Mutex{},
+	}
+	bm.cond = sync.NewCond(bm.mu)
+	for i, buff := range buffs {
+		if blk := buff.Block(); blk != nil {
+			bm.blocks[newBlockKey(blk)] = i
+		}
 	}
 	for _, opt := range opts {
 		opt(bm)
 	}
+	bm.startWriter()
 	return bm
 }
 
+// Pin pins a buffer to the block. If no buffer is available, it waits until one is unpinned or maxWaitTime passes.
+// Waiting calls are served in arrival order, and a new call does not take a buffer ahead of them.
 func (bm *BufferMgrImpl) Pin(block file.BlockId) (Buffer, error) {
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
+	if len(bm.waiters) == 0 || bm.isPinned(block) {
+		if buff, ok := bm.tryPin(block); ok {
+			return buff, nil
+		}
+	}
 	startTime := bm.time.Now()
+	bm.stats.Waits++
+	ticket := bm.enqueue()
+	defer bm.wakeAfterMaxWait()()
 	var buff Buffer
 	var ok bool
 	for {
-		buff, ok = bm.tryPin(block)
+		if bm.waiters[0] == ticket {
+			buff, ok = bm.tryPin(block)
+		}
 		if ok || bm.hasWaitedTooLong(startTime) {
 			break
 		}
-		bm.mu.Unlock()
-		bm.wait()
-		bm.mu.Lock()
+		bm.cond.Wait()
 	}
+	bm.dequeue(ticket)
+	bm.stats.WaitTime += bm.time.Since(startTime)
+	// the next waiter may be able to take a buffer now
+	bm.cond.Broadcast()
 	if !ok {
 		return nil, errors.New("buffer: no available buffer")
 	}
@@ -74,6 +129,7 @@ func (bm *BufferMgrImpl) Unpin(buff Buffer) {
 	buff.Unpin()
 	if !buff.IsPinned() {
 		bm.availableNum++
+		bm.cond.Broadcast()
 		return
 	}
 }
@@ -84,22 +140,59 @@ func (bm *BufferMgrImpl) AvailableNum() int {
 	return bm.availableNum
 }
 
+// FlushAll writes and syncs the buffers modified by the transaction without holding bm.mu.
+// It latches every buffer in turn, so it waits for a buffer that the background writer has written but not yet synced.
 func (bm *BufferMgrImpl) FlushAll(txNum int) error {
+	n, err := flushSynced(bm.pool, func(b Buffer) bool {
+		return b.ModifyingTx() == txNum
+	})
 	bm.mu.Lock()
 	defer bm.mu.Unlock()
-	for _, b := range bm.pool {
-		if b.ModifyingTx() != txNum {
+	bm.stats.DirtyFlushes += n
+	return err
+}
+
+// syncFiles syncs each file of the buffers once after all its blocks are written.
+func syncFiles(buffs []Buffer) error {
+	synced := make(map[string]bool)
+	for _, b := range buffs {
+		filename := b.Block().Filename()
+		if synced[filename] {
 			continue
 		}
-		if err := b.Flush(); err != nil {
+		if err := b.Sync(); err != nil {
 			return err
 		}
+		synced[filename] = true
 	}
 	return nil
 }
 
-func (bm *BufferMgrImpl) wait() {
-	bm.time.Sleep(bm.maxWaitTime)
+func (bm *BufferMgrImpl) wakeAfterMaxWait() func() {
+	timer := time.AfterFunc(bm.maxWaitTime, func() {
+		bm.mu.Lock()
+		defer bm.mu.Unlock()
+		bm.cond.Broadcast()
+	})
+	return func() {
+		timer.Stop()
+	}
+}
+
+func (bm *BufferMgrImpl) enqueue() int {
+	ticket := bm.nextTicket
+	bm.nextTicket++
+	bm.waiters = append(bm.waiters, ticket)
+	return ticket
+}
+
+func (bm *BufferMgrImpl) dequeue(ticket int) {
+	for i, t := range bm.waiters {
+		if t == ticket {
+			bm.waiters = append(bm.waiters[:i], bm.waiters[i+1:]...)
+			return
+		}
+	}
 }
 
 func (bm *BufferMgrImpl) hasWaitedTooLong(startTime time.Time) bool {
@@ -107,41 +200,83 @@ func (bm *BufferMgrImpl) hasWaitedTooLong(startTime time.Time) bool {
 }
 
 func (bm *BufferMgrImpl) tryPin(block file.BlockId) (Buffer, bool) {
-	buff, ok := bm.findBufferByBlock(block)
-	if !ok {
-		buff, ok = bm.findUnpinnedBuffer()
+	idx, ok := bm.findBufferByBlock(block)
+	if ok {
+		bm.stats.Hits++
+	} else {
+		idx, ok = bm.policy.Victim(bm.pool)
 		if !ok {
 			fmt.Println("buffer: no unpinned buffer")
 			return nil, false
 		}
-		err := buff.AssignToBlock(block)
-		if err != nil {
+		if err := bm.assign(idx, block); err != nil {
 			fmt.Println("buffer: failed to assign block to buff", err)
 			return nil, false
 		}
+		bm.stats.Misses++
 	}
+	buff := bm.pool[idx]
 	if !buff.IsPinned() {
 		bm.availableNum--
 	}
 	buff.Pin()
+	bm.stats.Pins++
+	bm.policy.Access(idx, block)
 	return buff, true
 }
 
-func (bm *BufferMgrImpl) findBufferByBlock(block file.BlockId) (Buffer, bool) {
-	for _, buff := range bm.pool {
-		b := buff.Block()
-		if b != nil && b.Equals(block) {
-			return buff, true
+// assign assigns the buffer at idx to the block and keeps the block map in sync with it.
+func (bm *BufferMgrImpl) assign(idx int, block file.BlockId) error {
+	buff := bm.pool[idx]
+	old := buff.Block()
+	dirty := buff.ModifyingTx() != INIT_TX_NUM
+	if err := buff.AssignToBlock(block); err != nil {
+		// a buffer that could not be flushed still holds the modified block, but a failed read leaves contents of no block
+		if old != nil && buff.ModifyingTx() == INIT_TX_NUM {
+			bm.unmap(idx, old)
 		}
+		return err
+	}
+	if old != nil {
+		bm.unmap(idx, old)
+		bm.stats.Evictions++
+	}
+	if dirty {
+		bm.stats.DirtyFlushes++
 	}
-	return nil, false
+	bm.blocks[newBlockKey(block)] = idx
+	return nil
 }
 
-func (bm *BufferMgrImpl) findUnpinnedBuffer() (Buffer, bool) {
-	for _, buff := range bm.pool {
-		if !buff.IsPinned() {
-			return buff, true
+// unmap removes the block from the block map if the buffer at idx is the one it maps to.
+// A buffer discarded with its file still reports its old block, which may be in another buffer by now.
+func (bm *BufferMgrImpl) unmap(idx int, block file.BlockId) {
+	key := newBlockKey(block)
+	if i, ok := bm.blocks[key]; ok && i == idx {
+		delete(bm.blocks, key)
+	}
+}
+
+// Discard forgets the unpinned buffers holding blocks of the file, so that its blocks are read from disk again the next time they are pinned.
+// It is called when the file is removed, and must not be called while the file has modified buffers.
+func (bm *BufferMgrImpl) Discard(filename string) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	for i, buff := range bm.pool {
+		blk := buff.Block()
+		if blk == nil || blk.Filename() != filename || buff.IsPinned() {
+			continue
 		}
+		bm.unmap(i, blk)
 	}
-	return nil, false
+}
+
+func (bm *BufferMgrImpl) findBufferByBlock(block file.BlockId) (int, bool) {
+	idx, ok := bm.blocks[newBlockKey(block)]
+	return idx, ok
+}
+
+func (bm *BufferMgrImpl) isPinned(block file.BlockId) bool {
+	idx, ok := bm.findBufferByBlock(block)
+	return ok && bm.pool[idx].IsPinned()
 }
@@ -2,6 +2,7 @@ package buffer
 
 import (
 	"testing"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/log"
@@ -150,18 +151,151 @@ func TestBufferMgrImpl_FlushAll(t *testing.T) {
 		// assert: buffer is not flushed
 		pageReader := file.NewPage(blockSize)
 		fileMgr.Read(blk, pageReader)
-		assert.Equal(t, uint32(0), pageReader.GetInt(100))
+		assert.Equal(t, int32(0), pageReader.GetInt(100))
 
 		// assert: buffer is not flushed if txNum is not matched
 		err = bm.FlushAll(txNum + 1)
 		assert.NoError(t, err)
 		fileMgr.Read(blk, pageReader)
-		assert.Equal(t, uint32(0), pageReader.GetInt(100))
+		assert.Equal(t, int32(0), pageReader.GetInt(100))
 
 		// assert: buffer was flushed
 		err = bm.FlushAll(txNum)
 		assert.NoError(t, err)
 		fileMgr.Read(blk, pageReader)
-		assert.Equal(t, uint32(200), pageReader.GetInt(100))
+		assert.Equal(t, int32(200), pageReader.GetInt(100))
 	})
 }
+
+func TestBufferMgr_Wait(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		logFileName = "logfile"
+		dataFile    = "data"
+	)
+	setup := func(t *testing.T, name string, opts ...Option) *BufferMgrImpl {
+		dir, cleanup := testutil.SetupDir(name)
+		t.Cleanup(cleanup)
+		fileMgr := file.NewFileMgr(dir, blockSize)
+		logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+		assert.NoError(t, err)
+		return NewBufferMgr([]Buffer{NewBuffer(fileMgr, logMgr, blockSize)}, opts...)
+	}
+	waiters := func(bm *BufferMgrImpl) int {
+		bm.mu.Lock()
+		defer bm.mu.Unlock()
+		return len(bm.waiters)
+	}
+	pinAsync := func(bm *BufferMgrImpl, blkNum int) <-chan Buffer {
+		ch := make(chan Buffer, 1)
+		go func() {
+			buff, err := bm.Pin(file.NewBlockId(dataFile, blkNum))
+			assert.NoError(t, err)
+			ch <- buff
+		}()
+		return ch
+	}
+	t.Run("unpin wakes a waiting pin", func(t *testing.T) {
+		t.Parallel()
+		bm := setup(t, "test_buffer_mgr_wait_wake", WithMaxWaitTime(time.Minute))
+		buff, err := bm.Pin(file.NewBlockId(dataFile, 0))
+		assert.NoError(t, err)
+		got := pinAsync(bm, 1)
+		assert.Eventually(t, func() bool { return waiters(bm) == 1 }, time.Second, time.Millisecond)
+
+		start := time.Now()
+		bm.Unpin(buff)
+
+		assert.Equal(t, file.NewBlockId(dataFile, 1), (<-got).Block())
+		assert.Less(t, time.Since(start), time.Second)
+	})
+	t.Run("waiters are served in arrival order", func(t *testing.T) {
+		t.Parallel()
+		bm := setup(t, "test_buffer_mgr_wait_order", WithMaxWaitTime(time.Minute))
+		buff, err := bm.Pin(file.NewBlockId(dataFile, 0))
+		assert.NoError(t, err)
+		first := pinAsync(bm, 1)
+		assert.Eventually(t, func() bool { return waiters(bm) == 1 }, time.Second, time.Millisecond)
+		second := pinAsync(bm, 2)
+		assert.Eventually(t, func() bool { return waiters(bm) == 2 }, time.Second, time.Millisecond)
+
+		bm.Unpin(buff)
+		buff = <-first
+		assert.Equal(t, file.NewBlockId(dataFile, 1), buff.Block())
+		assert.Equal(t, 1, waiters(bm))
+
+		bm.Unpin(buff)
+		assert.Equal(t, file.NewBlockId(dataFile, 2), (<-second).Block())
+		assert.Equal(t, 0, waiters(bm))
+	})
+	t.Run("pin gives up after max wait time", func(t *testing.T) {
+		t.Parallel()
+		const maxWaitTime = 50 * time.Millisecond
+		bm := setup(t, "test_buffer_mgr_wait_timeout", WithMaxWaitTime(maxWaitTime))
+		_, err := bm.Pin(file.NewBlockId(dataFile, 0))
+		assert.NoError(t, err)
+
+		start := time.Now()
+		_, err = bm.Pin(file.NewBlockId(dataFile, 1))
+
+		assert.Error(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), maxWaitTime)
+		assert.Equal(t, 0, waiters(bm))
+	})
+	t.Run("block map follows replacement", func(t *testing.T) {
+		t.Parallel()
+		bm := setup(t, "test_buffer_mgr_wait_block_map", WithMaxWaitTime(0))
+		buff, err := bm.Pin(file.NewBlockId(dataFile, 0))
+		assert.NoError(t, err)
+		bm.Unpin(buff)
+		_, err = bm.Pin(file.NewBlockId(dataFile, 1))
+		assert.NoError(t, err)
+
+		_, ok := bm.findBufferByBlock(file.NewBlockId(dataFile, 0))
+		assert.False(t, ok)
+		idx, ok := bm.findBufferByBlock(file.NewBlockId(dataFile, 1))
+		assert.True(t, ok)
+		assert.Equal(t, 0, idx)
+		assert.Len(t, bm.blocks, 1)
+	})
+}
+
+func TestBufferMgr_Discard(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		logFileName = "logfile"
+		dataFile    = "data"
+		otherFile   = "other"
+	)
+	dir, cleanup := testutil.SetupDir("test_buffer_mgr_discard")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	bm := NewBufferMgr(newPolicyTestBuffers(fileMgr, logMgr, blockSize, 3))
+	blk0, blk1, other := file.NewBlockId(dataFile, 0), file.NewBlockId(dataFile, 1), file.NewBlockId(otherFile, 0)
+	buff0, err := bm.Pin(blk0)
+	assert.NoError(t, err)
+	bm.Unpin(buff0)
+	_, err = bm.Pin(blk1)
+	assert.NoError(t, err)
+	buffOther, err := bm.Pin(other)
+	assert.NoError(t, err)
+	bm.Unpin(buffOther)
+
+	bm.Discard(dataFile)
+
+	// the pinned block stays cached, the unpinned one is read again
+	assert.Equal(t, map[blockKey]int{newBlockKey(blk1): 1, newBlockKey(other): 2}, bm.blocks)
+	hits := bm.Stats().Hits
+	_, err = bm.Pin(blk1)
+	assert.NoError(t, err)
+	_, err = bm.Pin(other)
+	assert.NoError(t, err)
+	assert.Equal(t, hits+2, bm.Stats().Hits)
+	_, err = bm.Pin(blk0)
+	assert.NoError(t, err)
+	assert.Equal(t, hits+2, bm.Stats().Hits)
+}
@@ -29,7 +29,7 @@ func TestBuffer_WriteContents(t *testing.T) {
 		p.SetInt(100, 200)
 	})
 
-	assert.Equal(t, uint32(200), buf.contents.GetInt(100))
+	assert.Equal(t, int32(200), buf.contents.GetInt(100))
 	assert.Equal(t, txNum, buf.ModifyingTx())
 	assert.Equal(t, lsn, buf.lsn)
 }
@@ -110,5 +110,5 @@ func TestBuffer_AssignToBlock(t *testing.T) {
 
 	assert.Equal(t, block, buf.Block())
 	assert.Equal(t, 0, buf.pins)
-	assert.Equal(t, uint32(200), buf.Contents().GetInt(100))
+	assert.Equal(t, int32(200), buf.Contents().GetInt(100))
 }
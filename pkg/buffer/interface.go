@@ -10,23 +10,50 @@ type Buffer interface {
 	Block() file.BlockId
 	IsPinned() bool
 	Contents() ReadPage
+	// WriteContents latches the buffer while write modifies the contents.
 	WriteContents(txNum, lsn int, write func(p ReadWritePage))
+	// Latch and Unlatch guard a short read of the contents that no transaction lock protects,
+	// and keep the buffer from being modified, reassigned or written by others in the meantime.
+	Latch()
+	Unlatch()
 	ModifyingTx() int
 	AssignToBlock(block file.BlockId) error
 	Flush() error
+	// FlushLatched is Flush for a caller that holds the latch.
+	FlushLatched() error
+	Sync() error
 	Pin()
 	Unpin()
+	// PinCount returns the number of pins held on the buffer.
+	PinCount() int
 }
 
 /*
 BufferMgr has methods to pin and unpin a page.
 The method pin returns a Buffer object pinned to a page containing the specified block, and the unpin method unpins the page.
 The available method returns the number of unpinned buffer pages.
-And the method flushAll ensures that all pages modified by the specified transaction have been written to disk.
+And the method flushAll ensures that all pages modified by the specified transaction have been written to disk and synced.
+The method close stops the background work of the manager and writes the remaining modified pages.
+The methods stats and snapshot describe the activity of the pool and the state of each buffer.
+And the method discard forgets the blocks of a removed file.
 */
 type BufferMgr interface {
 	Pin(block file.BlockId) (Buffer, error)
 	Unpin(buff Buffer)
 	AvailableNum() int
 	FlushAll(txNum int) error
+	Close() error
+	Stats() Stats
+	Snapshot() []BufferInfo
+	Discard(filename string)
+}
+
+/*
+ReplacementPolicy decides which buffer of the pool is replaced when a block that is not in the pool is pinned.
+Buffers are identified by their index in the pool.
+The method access is called every time a buffer is pinned to a block, and the method victim returns an unpinned buffer to replace.
+*/
+type ReplacementPolicy interface {
+	Access(idx int, block file.BlockId)
+	Victim(pool []Buffer) (int, bool)
 }
@@ -0,0 +1,198 @@
+package buffer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
+
+const (
+	POLICY_NAIVE = "naive"
+	POLICY_LRU   = "lru"
+	POLICY_CLOCK = "clock"
+	POLICY_LRU_K = "lru-k"
+
+	DEFAULT_LRU_K = 2
+	// LRU_K_RETAINED_ACCESSES bounds how long the pin history of a block is kept after its last pin, counted in pins.
+	LRU_K_RETAINED_ACCESSES = 1024
+)
+
+// NewReplacementPolicy returns the policy with the given name, one of POLICY_NAIVE, POLICY_LRU, POLICY_CLOCK and POLICY_LRU_K.
+func NewReplacementPolicy(name string) (ReplacementPolicy, error) {
+	switch name {
+	case POLICY_NAIVE:
+		return NewNaivePolicy(), nil
+	case POLICY_LRU:
+		return NewLRUPolicy(), nil
+	case POLICY_CLOCK:
+		return NewClockPolicy(), nil
+	case POLICY_LRU_K:
+		return NewLRUKPolicy(DEFAULT_LRU_K), nil
+	default:
+		return nil, fmt.Errorf("buffer: unknown replacement policy %q", name)
+	}
+}
+
+// NaivePolicy replaces the first unpinned buffer in pool order.
+type NaivePolicy struct{}
+
+func NewNaivePolicy() *NaivePolicy {
+	return &NaivePolicy{}
+}
+
+func (p *NaivePolicy) Access(idx int, block file.BlockId) {}
+
+func (p *NaivePolicy) Victim(pool []Buffer) (int, bool) {
+	for i, buff := range pool {
+		if !buff.IsPinned() {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// LRUPolicy replaces the unpinned buffer that was pinned least recently.
+type LRUPolicy struct {
+	clock    int
+	lastUsed []int
+}
+
+func NewLRUPolicy() *LRUPolicy {
+	return &LRUPolicy{}
+}
+
+func (p *LRUPolicy) Access(idx int, block file.BlockId) {
+	p.lastUsed = grow(p.lastUsed, idx)
+	p.clock++
+	p.lastUsed[idx] = p.clock
+}
+
+func (p *LRUPolicy) Victim(pool []Buffer) (int, bool) {
+	p.lastUsed = grow(p.lastUsed, len(pool)-1)
+	victim := -1
+	for i, buff := range pool {
+		if buff.IsPinned() {
+			continue
+		}
+		if victim == -1 || p.lastUsed[i] < p.lastUsed[victim] {
+			victim = i
+		}
+	}
+	return victim, victim != -1
+}
+
+// ClockPolicy sweeps the pool with a hand and gives each recently pinned buffer a second chance before replacing it.
+type ClockPolicy struct {
+	hand       int
+	referenced []bool
+}
+
+func NewClockPolicy() *ClockPolicy {
+	return &ClockPolicy{}
+}
+
+func (p *ClockPolicy) Access(idx int, block file.BlockId) {
+	p.referenced = grow(p.referenced, idx)
+	p.referenced[idx] = true
+}
+
+func (p *ClockPolicy) Victim(pool []Buffer) (int, bool) {
+	if len(pool) == 0 {
+		return -1, false
+	}
+	p.referenced = grow(p.referenced, len(pool)-1)
+	// the first sweep clears the reference bits, so a second one always finds an unpinned buffer if there is any
+	for n := 0; n < 2*len(pool); n++ {
+		i := p.hand
+		p.hand = (p.hand + 1) % len(pool)
+		if pool[i].IsPinned() {
+			continue
+		}
+		if p.referenced[i] {
+			p.referenced[i] = false
+			continue
+		}
+		return i, true
+	}
+	return -1, false
+}
+
+/*
+LRUKPolicy replaces the unpinned buffer whose block has the oldest k-th most recent pin.
+Blocks pinned fewer than k times are replaced first, least recently used among them,
+so blocks read once by a scan do not push out blocks that are used repeatedly.
+The history of a block is kept after it is replaced, so a block that comes back is recognized as hot.
+*/
+type LRUKPolicy struct {
+	k       int
+	clock   int
+	history map[blockKey][]int
+}
+
+func NewLRUKPolicy(k int) *LRUKPolicy {
+	if k < 1 {
+		k = 1
+	}
+	return &LRUKPolicy{k: k, history: make(map[blockKey][]int)}
+}
+
+func (p *LRUKPolicy) Access(idx int, block file.BlockId) {
+	p.clock++
+	key := newBlockKey(block)
+	h := append(p.history[key], p.clock)
+	if len(h) > p.k {
+		h = h[len(h)-p.k:]
+	}
+	p.history[key] = h
+	if len(p.history) > LRU_K_RETAINED_ACCESSES {
+		p.prune()
+	}
+}
+
+func (p *LRUKPolicy) Victim(pool []Buffer) (int, bool) {
+	victim := -1
+	victimKth, victimLast := 0, 0
+	for i, buff := range pool {
+		if buff.IsPinned() {
+			continue
+		}
+		kth, last := p.distance(buff.Block())
+		if victim == -1 || kth < victimKth || (kth == victimKth && last < victimLast) {
+			victim, victimKth, victimLast = i, kth, last
+		}
+	}
+	return victim, victim != -1
+}
+
+// distance returns the time of the k-th most recent pin of the block, math.MinInt if there are fewer than k, and the time of its last pin.
+func (p *LRUKPolicy) distance(block file.BlockId) (int, int) {
+	if block == nil {
+		return math.MinInt, 0
+	}
+	h := p.history[newBlockKey(block)]
+	if len(h) == 0 {
+		return math.MinInt, 0
+	}
+	last := h[len(h)-1]
+	if len(h) < p.k {
+		return math.MinInt, last
+	}
+	return h[len(h)-p.k], last
+}
+
+// prune drops the history of blocks not pinned during the last half of the retained accesses, which frees at least the other half.
+func (p *LRUKPolicy) prune() {
+	for key, h := range p.history {
+		if h[len(h)-1] <= p.clock-LRU_K_RETAINED_ACCESSES/2 {
+			delete(p.history, key)
+		}
+	}
+}
+
+func grow[T any](s []T, idx int) []T {
+	if idx < len(s) {
+		return s
+	}
+	return append(s, make([]T, idx+1-len(s))...)
+}
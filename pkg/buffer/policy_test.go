@@ -0,0 +1,265 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/log"
+	"github.com/kj455/simple-db/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPolicyTestPool(n int, pinned ...int) []Buffer {
+	pool := make([]Buffer, n)
+	for i := range pool {
+		buff := NewBuffer(nil, nil, 8)
+		buff.block = file.NewBlockId("file", i)
+		pool[i] = buff
+	}
+	for _, i := range pinned {
+		pool[i].Pin()
+	}
+	return pool
+}
+
+func TestNewReplacementPolicy(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{POLICY_NAIVE, POLICY_LRU, POLICY_CLOCK, POLICY_LRU_K} {
+		p, err := NewReplacementPolicy(name)
+		assert.NoError(t, err)
+		assert.NotNil(t, p)
+	}
+	_, err := NewReplacementPolicy("fifo")
+	assert.Error(t, err)
+}
+
+func TestReplacementPolicy_Victim(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		policy   ReplacementPolicy
+		accesses []int
+		pinned   []int
+		want     int
+	}{
+		{name: "naive - first unpinned", policy: NewNaivePolicy(), accesses: []int{2, 1, 0}, pinned: []int{0}, want: 1},
+		{name: "lru - least recently pinned", policy: NewLRUPolicy(), accesses: []int{0, 1, 2, 0}, want: 1},
+		{name: "lru - never pinned first", policy: NewLRUPolicy(), accesses: []int{0, 2}, want: 1},
+		{name: "lru - skips pinned", policy: NewLRUPolicy(), accesses: []int{0, 1, 2, 0}, pinned: []int{1}, want: 2},
+		{name: "clock - unreferenced after the hand", policy: NewClockPolicy(), accesses: []int{0, 2}, want: 1},
+		{name: "clock - second chance for all", policy: NewClockPolicy(), accesses: []int{0, 1, 2}, want: 0},
+		{name: "clock - skips pinned", policy: NewClockPolicy(), accesses: []int{0, 1, 2}, pinned: []int{0}, want: 1},
+		{name: "lru-k - fewer than k pins first", policy: NewLRUKPolicy(2), accesses: []int{0, 0, 1, 2, 2}, want: 1},
+		{name: "lru-k - oldest k-th pin", policy: NewLRUKPolicy(2), accesses: []int{0, 1, 0, 2, 1, 2}, want: 0},
+		{name: "lru-k - skips pinned", policy: NewLRUKPolicy(2), accesses: []int{0, 0, 1, 2, 2}, pinned: []int{1}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			pool := newPolicyTestPool(3, tt.pinned...)
+			for _, idx := range tt.accesses {
+				tt.policy.Access(idx, pool[idx].Block())
+			}
+
+			got, ok := tt.policy.Victim(pool)
+
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("no victim if all buffers are pinned", func(t *testing.T) {
+		t.Parallel()
+		for _, p := range []ReplacementPolicy{NewNaivePolicy(), NewLRUPolicy(), NewClockPolicy(), NewLRUKPolicy(2)} {
+			pool := newPolicyTestPool(2, 0, 1)
+			p.Access(0, pool[0].Block())
+			_, ok := p.Victim(pool)
+			assert.False(t, ok)
+		}
+	})
+	t.Run("clock hand moves on", func(t *testing.T) {
+		t.Parallel()
+		p := NewClockPolicy()
+		pool := newPolicyTestPool(3)
+		for _, want := range []int{0, 1, 2, 0} {
+			got, ok := p.Victim(pool)
+			assert.True(t, ok)
+			assert.Equal(t, want, got)
+		}
+	})
+	t.Run("lru-k history is kept for a replaced block", func(t *testing.T) {
+		t.Parallel()
+		p := NewLRUKPolicy(2)
+		pool := newPolicyTestPool(2)
+		hot := pool[0].Block()
+		p.Access(0, hot)
+		p.Access(1, pool[1].Block())
+		// the hot block is replaced by a block read once, then comes back to the other buffer
+		p.Access(0, file.NewBlockId("file", 2))
+		pool[0].(*BufferImpl).block = file.NewBlockId("file", 2)
+		pool[1].(*BufferImpl).block = hot
+		p.Access(1, hot)
+
+		got, ok := p.Victim(pool)
+
+		assert.True(t, ok)
+		assert.Equal(t, 0, got)
+	})
+	t.Run("lru-k history is pruned", func(t *testing.T) {
+		t.Parallel()
+		p := NewLRUKPolicy(2)
+		for i := 0; i < 2*LRU_K_RETAINED_ACCESSES; i++ {
+			p.Access(0, file.NewBlockId("file", i))
+		}
+		assert.LessOrEqual(t, len(p.history), LRU_K_RETAINED_ACCESSES)
+	})
+}
+
+func TestBufferMgr_ReplacementPolicy(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		logFileName = "logfile"
+		dataFile    = "data"
+	)
+	tests := []struct {
+		policy ReplacementPolicy
+		kept   bool
+	}{
+		{policy: NewNaivePolicy(), kept: false},
+		{policy: NewLRUPolicy(), kept: true},
+		{policy: NewClockPolicy(), kept: true},
+		{policy: NewLRUKPolicy(2), kept: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%T", tt.policy), func(t *testing.T) {
+			t.Parallel()
+			dir, cleanup := testutil.SetupDir(fmt.Sprintf("test_buffer_mgr_replacement_policy_%T", tt.policy))
+			t.Cleanup(cleanup)
+			fileMgr := file.NewFileMgr(dir, blockSize)
+			logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+			assert.NoError(t, err)
+			bm := NewBufferMgr(newPolicyTestBuffers(fileMgr, logMgr, blockSize, 3), WithMaxWaitTime(0), WithReplacementPolicy(tt.policy))
+			pinUnpin := func(blkNum int) {
+				buff, err := bm.Pin(file.NewBlockId(dataFile, blkNum))
+				assert.NoError(t, err)
+				bm.Unpin(buff)
+			}
+			const hot = 0
+			// the hot block is pinned again while the other blocks are read once
+			for _, blkNum := range []int{1, 2, hot, 3, hot, 4} {
+				pinUnpin(blkNum)
+			}
+			hits := bm.stats.Hits
+
+			pinUnpin(hot)
+
+			assert.Equal(t, tt.kept, bm.stats.Hits > hits)
+		})
+	}
+}
+
+func newPolicyTestBuffers(fm file.FileMgr, lm log.LogMgr, blockSize, n int) []Buffer {
+	buffs := make([]Buffer, n)
+	for i := range buffs {
+		buffs[i] = NewBuffer(fm, lm, blockSize)
+	}
+	return buffs
+}
+
+// BenchmarkReplacementPolicy_HitRatio reports the hit ratio of each policy on a pool of 8 buffers.
+// Both workloads read the catalog blocks before every table block, as the planner does when it looks up a layout.
+func BenchmarkReplacementPolicy_HitRatio(b *testing.B) {
+	const (
+		blockSize   = 400
+		buffNum     = 8
+		logFileName = "logfile"
+	)
+	catalog := []file.BlockId{file.NewBlockId("tblcat", 0), file.NewBlockId("fldcat", 0)}
+	blocks := func(filename string, n int) []file.BlockId {
+		blks := make([]file.BlockId, n)
+		for i := range blks {
+			blks[i] = file.NewBlockId(filename, i)
+		}
+		return blks
+	}
+	workloads := []struct {
+		name string
+		run  func(pin func(blk file.BlockId) Buffer, unpin func(buff Buffer))
+	}{
+		{
+			// a full scan of a table larger than the pool
+			name: "scan",
+			run: func(pin func(blk file.BlockId) Buffer, unpin func(buff Buffer)) {
+				for _, blk := range blocks("table", 20) {
+					for _, c := range catalog {
+						unpin(pin(c))
+					}
+					unpin(pin(blk))
+				}
+			},
+		},
+		{
+			// a scan interleaved with lookups of a few hot blocks, as an index join probes its index
+			name: "scan with lookups",
+			run: func(pin func(blk file.BlockId) Buffer, unpin func(buff Buffer)) {
+				hot := blocks("index", 4)
+				for i, blk := range blocks("table", 20) {
+					for _, c := range catalog {
+						unpin(pin(c))
+					}
+					unpin(pin(hot[i%len(hot)]))
+					unpin(pin(blk))
+				}
+			},
+		},
+		{
+			// a nested loop join that keeps the outer block pinned while it scans the inner table
+			name: "join",
+			run: func(pin func(blk file.BlockId) Buffer, unpin func(buff Buffer)) {
+				for _, outer := range blocks("outer", 4) {
+					outerBuff := pin(outer)
+					for _, inner := range blocks("inner", 6) {
+						for _, c := range catalog {
+							unpin(pin(c))
+						}
+						unpin(pin(inner))
+					}
+					unpin(outerBuff)
+				}
+			},
+		},
+	}
+	for _, name := range []string{POLICY_NAIVE, POLICY_LRU, POLICY_CLOCK, POLICY_LRU_K} {
+		for _, w := range workloads {
+			b.Run(name+"/"+strings.ReplaceAll(w.name, " ", "_"), func(b *testing.B) {
+				dir, cleanup := testutil.SetupDir("bench_replacement_policy_" + name + "_" + strings.ReplaceAll(w.name, " ", "_"))
+				b.Cleanup(cleanup)
+				fileMgr := file.NewFileMgr(dir, blockSize)
+				logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+				if err != nil {
+					b.Fatal(err)
+				}
+				policy, err := NewReplacementPolicy(name)
+				if err != nil {
+					b.Fatal(err)
+				}
+				bm := NewBufferMgr(newPolicyTestBuffers(fileMgr, logMgr, blockSize, buffNum), WithMaxWaitTime(0), WithReplacementPolicy(policy))
+				pin := func(blk file.BlockId) Buffer {
+					buff, err := bm.Pin(blk)
+					if err != nil {
+						b.Fatal(err)
+					}
+					return buff
+				}
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					w.run(pin, bm.Unpin)
+				}
+				b.StopTimer()
+				b.ReportMetric(bm.Stats().HitRatio(), "hit-ratio")
+			})
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package buffer
+
+import (
+	"time"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
+
+// Stats counts the activity of a buffer manager since it was created.
+type Stats struct {
+	// Pins is the number of successful Pin calls, Hits and Misses tell whether the block was already in the pool.
+	Pins   int
+	Hits   int
+	Misses int
+	// Evictions is the number of times a buffer holding a block was assigned to another block.
+	Evictions int
+	// DirtyFlushes is the number of modified buffers written to disk, on replacement, at commit or by the background writer.
+	DirtyFlushes int
+	// Waits is the number of Pin calls that had to wait for a buffer, and WaitTime the total time they waited.
+	Waits    int
+	WaitTime time.Duration
+}
+
+// HitRatio returns the fraction of pins that found their block in the pool, or 0 if there were none.
+func (s Stats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// BufferInfo describes the state of one buffer of the pool. Block is nil if the buffer was never assigned.
+type BufferInfo struct {
+	Index       int
+	Block       file.BlockId
+	Pins        int
+	ModifyingTx int
+	Dirty       bool
+}
+
+func (bm *BufferMgrImpl) Stats() Stats {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.stats
+}
+
+func (bm *BufferMgrImpl) Snapshot() []BufferInfo {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	infos := make([]BufferInfo, len(bm.pool))
+	for i, buff := range bm.pool {
+		infos[i] = BufferInfo{
+			Index:       i,
+			Block:       buff.Block(),
+			Pins:        buff.PinCount(),
+			ModifyingTx: buff.ModifyingTx(),
+			Dirty:       buff.ModifyingTx() != INIT_TX_NUM,
+		}
+	}
+	return infos
+}
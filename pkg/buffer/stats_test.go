@@ -0,0 +1,64 @@
+package buffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/log"
+	"github.com/kj455/simple-db/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferMgr_Stats(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		logFileName = "logfile"
+		dataFile    = "data"
+		txNum       = 1
+		maxWaitTime = 10 * time.Millisecond
+	)
+	dir, cleanup := testutil.SetupDir("test_buffer_mgr_stats")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	bm := NewBufferMgr(newPolicyTestBuffers(fileMgr, logMgr, blockSize, 2), WithMaxWaitTime(maxWaitTime))
+	blk0, blk1, blk2 := file.NewBlockId(dataFile, 0), file.NewBlockId(dataFile, 1), file.NewBlockId(dataFile, 2)
+
+	buff0, err := bm.Pin(blk0)
+	assert.NoError(t, err)
+	buff0.WriteContents(txNum, -1, func(p ReadWritePage) {
+		p.SetInt(0, 1)
+	})
+	_, err = bm.Pin(blk0)
+	assert.NoError(t, err)
+	_, err = bm.Pin(blk1)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []BufferInfo{
+		{Index: 0, Block: blk0, Pins: 2, ModifyingTx: txNum, Dirty: true},
+		{Index: 1, Block: blk1, Pins: 1, ModifyingTx: INIT_TX_NUM, Dirty: false},
+	}, bm.Snapshot())
+
+	// all buffers are pinned
+	_, err = bm.Pin(blk2)
+	assert.Error(t, err)
+	bm.Unpin(buff0)
+	bm.Unpin(buff0)
+	// the modified block is written out to replace it
+	_, err = bm.Pin(blk2)
+	assert.NoError(t, err)
+
+	stats := bm.Stats()
+	assert.Equal(t, 4, stats.Pins)
+	assert.Equal(t, 1, stats.Hits)
+	assert.Equal(t, 3, stats.Misses)
+	assert.Equal(t, 1, stats.Evictions)
+	assert.Equal(t, 1, stats.DirtyFlushes)
+	assert.Equal(t, 1, stats.Waits)
+	assert.GreaterOrEqual(t, stats.WaitTime, maxWaitTime)
+	assert.Equal(t, 0.25, stats.HitRatio())
+	assert.Equal(t, blk2, bm.Snapshot()[0].Block)
+}
@@ -0,0 +1,143 @@
+package buffer
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	DEFAULT_WRITER_INTERVAL  = 200 * time.Millisecond
+	DEFAULT_WRITER_MAX_PAGES = 16
+)
+
+/*
+WithBackgroundWriter starts a goroutine that writes dirty unpinned buffers to disk every interval,
+so replacing a buffer rarely has to wait for a write. Each round writes at most maxPages buffers, all of them if maxPages is not positive.
+The goroutine runs until Close is called.
+*/
+func WithBackgroundWriter(interval time.Duration, maxPages int) Option {
+	return func(b *BufferMgrImpl) {
+		b.writerInterval = interval
+		b.writerMaxPages = maxPages
+	}
+}
+
+// Close stops the background writer and writes all dirty unpinned buffers. It returns the errors the writer ran into.
+func (bm *BufferMgrImpl) Close() error {
+	bm.mu.Lock()
+	if bm.closed {
+		bm.mu.Unlock()
+		return nil
+	}
+	bm.closed = true
+	bm.mu.Unlock()
+	if bm.writerStop != nil {
+		close(bm.writerStop)
+		<-bm.writerDone
+	}
+	return errors.Join(bm.writerErr, bm.writeDirty(0))
+}
+
+func (bm *BufferMgrImpl) startWriter() {
+	if bm.writerInterval <= 0 {
+		return
+	}
+	bm.writerStop = make(chan struct{})
+	bm.writerDone = make(chan struct{})
+	go func() {
+		defer close(bm.writerDone)
+		ticker := time.NewTicker(bm.writerInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-bm.writerStop:
+				return
+			case <-ticker.C:
+				if err := bm.writeDirty(bm.writerMaxPages); err != nil {
+					bm.mu.Lock()
+					bm.writerErr = errors.Join(bm.writerErr, err)
+					bm.mu.Unlock()
+				}
+			}
+		}
+	}()
+}
+
+/*
+writeDirty writes at most maxPages dirty unpinned buffers, all of them if maxPages is not positive, and syncs their files.
+It holds bm.mu only to pick the buffers and pin them, so that they keep their blocks, and writes them after releasing it.
+Buffer.Flush forces the log up to the LSN of the page before writing it, so the write-ahead rule holds.
+It continues from where the previous round stopped, so every buffer is written eventually.
+*/
+func (bm *BufferMgrImpl) writeDirty(maxPages int) error {
+	buffs := bm.pinDirty(maxPages)
+	n, err := flushSynced(buffs, func(b Buffer) bool {
+		return b.ModifyingTx() != INIT_TX_NUM
+	})
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	for _, buff := range buffs {
+		buff.Unpin()
+		if !buff.IsPinned() {
+			bm.availableNum++
+		}
+	}
+	bm.cond.Broadcast()
+	bm.stats.DirtyFlushes += n
+	return err
+}
+
+// pinDirty pins at most maxPages dirty unpinned buffers, all of them if maxPages is not positive, and returns them in pool order.
+func (bm *BufferMgrImpl) pinDirty(maxPages int) []Buffer {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	var idxs []int
+	for n := 0; n < len(bm.pool); n++ {
+		if maxPages > 0 && len(idxs) >= maxPages {
+			break
+		}
+		idx := bm.writerCursor
+		bm.writerCursor = (bm.writerCursor + 1) % len(bm.pool)
+		buff := bm.pool[idx]
+		if buff.IsPinned() || buff.ModifyingTx() == INIT_TX_NUM {
+			continue
+		}
+		idxs = append(idxs, idx)
+	}
+	slices.Sort(idxs)
+	buffs := make([]Buffer, len(idxs))
+	for i, idx := range idxs {
+		buffs[i] = bm.pool[idx]
+		buffs[i].Pin()
+		bm.availableNum--
+	}
+	return buffs
+}
+
+/*
+flushSynced latches the buffers one by one, flushes those for which flush reports true, and syncs their files before unlatching them.
+A flushed buffer therefore does not look clean to another caller until its write is on stable storage,
+which is how FlushAll of a committing transaction waits for the background writer.
+The buffers must be in pool order, so that two callers latch them in the same order. It returns the number of buffers flushed.
+*/
+func flushSynced(buffs []Buffer, flush func(b Buffer) bool) (int, error) {
+	var flushed []Buffer
+	defer func() {
+		for _, b := range flushed {
+			b.Unlatch()
+		}
+	}()
+	for _, b := range buffs {
+		b.Latch()
+		if !flush(b) {
+			b.Unlatch()
+			continue
+		}
+		flushed = append(flushed, b)
+		if err := b.FlushLatched(); err != nil {
+			return len(flushed) - 1, err
+		}
+	}
+	return len(flushed), syncFiles(flushed)
+}
@@ -0,0 +1,185 @@
+package buffer
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/file/filetest"
+	"github.com/kj455/simple-db/pkg/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBufferMgr_BackgroundWriter(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		logFileName = "log"
+		dataFile    = "data"
+		txNum       = 1
+	)
+	setup := func(t *testing.T, buffNum int, opts ...Option) (*filetest.FaultyFileMgr, log.LogMgr, *BufferMgrImpl) {
+		fm := filetest.NewFaultyFileMgr(blockSize)
+		lm, err := log.NewLogMgr(fm, logFileName)
+		assert.NoError(t, err)
+		bm := NewBufferMgr(newPolicyTestBuffers(fm, lm, blockSize, buffNum), append([]Option{WithMaxWaitTime(0)}, opts...)...)
+		t.Cleanup(func() { _ = bm.Close() })
+		return fm, lm, bm
+	}
+	// modify pins the block, sets val with a new log record and unpins it unless told to keep it pinned
+	modify := func(t *testing.T, lm log.LogMgr, bm *BufferMgrImpl, blkNum int, val int32, keepPinned bool) Buffer {
+		buff, err := bm.Pin(file.NewBlockId(dataFile, blkNum))
+		assert.NoError(t, err)
+		lsn, err := lm.Append([]byte("record"))
+		assert.NoError(t, err)
+		buff.WriteContents(txNum, lsn, func(p ReadWritePage) {
+			p.SetInt(0, val)
+		})
+		if !keepPinned {
+			bm.Unpin(buff)
+		}
+		return buff
+	}
+	dirty := func(bm *BufferMgrImpl) int {
+		bm.mu.Lock()
+		defer bm.mu.Unlock()
+		n := 0
+		for _, buff := range bm.pool {
+			if buff.ModifyingTx() != INIT_TX_NUM {
+				n++
+			}
+		}
+		return n
+	}
+	t.Run("writes dirty buffers after their log records", func(t *testing.T) {
+		t.Parallel()
+		fm, lm, bm := setup(t, 2, WithBackgroundWriter(time.Millisecond, 0))
+		buff := modify(t, lm, bm, 0, 7, true)
+		fm.ResetOps()
+		bm.Unpin(buff)
+
+		assert.Eventually(t, func() bool { return dirty(bm) == 0 }, time.Second, time.Millisecond)
+		page := file.NewPage(blockSize)
+		assert.NoError(t, fm.Read(file.NewBlockId(dataFile, 0), page))
+		assert.Equal(t, int32(7), page.GetInt(0))
+		ops := fm.Ops()
+		logSync := slices.Index(ops, filetest.Op{Kind: filetest.OP_KIND_SYNC, Filename: logFileName, Block: -1})
+		dataWrite := slices.Index(ops, filetest.Op{Kind: filetest.OP_KIND_WRITE, Filename: dataFile, Block: 0})
+		dataSync := slices.Index(ops, filetest.Op{Kind: filetest.OP_KIND_SYNC, Filename: dataFile, Block: -1})
+		assert.GreaterOrEqual(t, logSync, 0)
+		assert.Less(t, logSync, dataWrite)
+		assert.Less(t, dataWrite, dataSync)
+	})
+	t.Run("pinned buffers are not written", func(t *testing.T) {
+		t.Parallel()
+		_, lm, bm := setup(t, 2)
+		modify(t, lm, bm, 0, 1, true)
+
+		assert.NoError(t, bm.writeDirty(0))
+
+		assert.Equal(t, 1, dirty(bm))
+	})
+	t.Run("each round writes at most max pages", func(t *testing.T) {
+		t.Parallel()
+		_, lm, bm := setup(t, 3)
+		for i := 0; i < 3; i++ {
+			modify(t, lm, bm, i, int32(i), false)
+		}
+
+		assert.NoError(t, bm.writeDirty(2))
+		assert.Equal(t, 1, dirty(bm))
+
+		assert.NoError(t, bm.writeDirty(2))
+		assert.Equal(t, 0, dirty(bm))
+	})
+	t.Run("pins do not wait for the write and commits wait for the sync", func(t *testing.T) {
+		t.Parallel()
+		fm := &blockingSyncFileMgr{
+			FaultyFileMgr: filetest.NewFaultyFileMgr(blockSize),
+			filename:      dataFile,
+			syncing:       make(chan struct{}),
+			release:       make(chan struct{}),
+		}
+		lm, err := log.NewLogMgr(fm, logFileName)
+		assert.NoError(t, err)
+		bm := NewBufferMgr(newPolicyTestBuffers(fm, lm, blockSize, 2), WithMaxWaitTime(0))
+		modify(t, lm, bm, 0, 1, false)
+		written := make(chan error, 1)
+		go func() { written <- bm.writeDirty(0) }()
+		<-fm.syncing
+
+		pinned := make(chan error, 1)
+		go func() {
+			buff, err := bm.Pin(file.NewBlockId(dataFile, 1))
+			if err == nil {
+				bm.Unpin(buff)
+			}
+			pinned <- err
+		}()
+		flushed := make(chan error, 1)
+		go func() { flushed <- bm.FlushAll(txNum) }()
+		select {
+		case err := <-pinned:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("pin waited for the write")
+		}
+		select {
+		case <-flushed:
+			t.Fatal("flush all did not wait for the sync")
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		close(fm.release)
+		assert.NoError(t, <-written)
+		assert.NoError(t, <-flushed)
+		assert.Equal(t, 0, dirty(bm))
+	})
+	t.Run("close stops the writer and writes the rest", func(t *testing.T) {
+		t.Parallel()
+		_, lm, bm := setup(t, 2, WithBackgroundWriter(time.Hour, 1))
+		modify(t, lm, bm, 0, 1, false)
+		modify(t, lm, bm, 1, 2, false)
+
+		assert.NoError(t, bm.Close())
+
+		assert.Equal(t, 0, dirty(bm))
+		_, open := <-bm.writerDone
+		assert.False(t, open)
+		assert.NoError(t, bm.Close())
+	})
+	t.Run("close returns the errors of the writer", func(t *testing.T) {
+		t.Parallel()
+		fm, lm, bm := setup(t, 1, WithBackgroundWriter(time.Millisecond, 0))
+		fm.InjectFault(filetest.FailOn(filetest.OP_KIND_WRITE, dataFile))
+		modify(t, lm, bm, 0, 1, false)
+		assert.Eventually(t, func() bool {
+			bm.mu.Lock()
+			defer bm.mu.Unlock()
+			return bm.writerErr != nil
+		}, time.Second, time.Millisecond)
+
+		assert.ErrorIs(t, bm.Close(), filetest.ErrInjected)
+	})
+}
+
+// blockingSyncFileMgr blocks the first sync of the file until release is closed, and closes syncing when it starts.
+type blockingSyncFileMgr struct {
+	*filetest.FaultyFileMgr
+	filename string
+	syncing  chan struct{}
+	release  chan struct{}
+	once     sync.Once
+}
+
+func (m *blockingSyncFileMgr) Sync(filename string) error {
+	if filename == m.filename {
+		m.once.Do(func() {
+			close(m.syncing)
+			<-m.release
+		})
+	}
+	return m.FaultyFileMgr.Sync(filename)
+}
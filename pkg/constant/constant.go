@@ -1,30 +1,90 @@
 package constant
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Kind string
 
 const (
-	KIND_INT Kind = "int"
-	KIND_STR Kind = "string"
+	KIND_INT       Kind = "int"
+	KIND_STR       Kind = "string"
+	KIND_NULL      Kind = "null"
+	KIND_INT64     Kind = "int64"
+	KIND_FLOAT64   Kind = "float64"
+	KIND_BOOL      Kind = "bool"
+	KIND_DATE      Kind = "date"
+	KIND_TIMESTAMP Kind = "timestamp"
+	KIND_BYTES     Kind = "bytes"
+)
+
+const (
+	DATE_FORMAT      = "2006-01-02"
+	TIMESTAMP_FORMAT = "2006-01-02 15:04:05.999999"
 )
 
 // Const denotes values stored in the database.
+// The value of each kind is of one Go type: int, string, int64, float64, bool, time.Time for dates and timestamps, and []byte.
 type Const struct {
 	val  any
 	kind Kind
 }
 
+// NewConstant returns a constant of the kind. An int must fit in 32 bits, as the INT fields that store it.
+// Dates are truncated to the day, and timestamps to the microsecond, both in UTC.
 func NewConstant(kind Kind, val any) (*Const, error) {
 	switch kind {
 	case KIND_INT:
-		if _, ok := val.(int); !ok {
+		i, ok := val.(int)
+		if !ok {
 			return nil, fmt.Errorf("constant: value is not an integer")
 		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return nil, fmt.Errorf("constant: %d is out of the range of int, use %s", i, KIND_INT64)
+		}
 	case KIND_STR:
 		if _, ok := val.(string); !ok {
 			return nil, fmt.Errorf("constant: value is not a string")
 		}
+	case KIND_NULL:
+		if val != nil {
+			return nil, fmt.Errorf("constant: null has no value")
+		}
+	case KIND_INT64:
+		if _, ok := val.(int64); !ok {
+			return nil, fmt.Errorf("constant: value is not a 64-bit integer")
+		}
+	case KIND_FLOAT64:
+		if _, ok := val.(float64); !ok {
+			return nil, fmt.Errorf("constant: value is not a double")
+		}
+	case KIND_BOOL:
+		if _, ok := val.(bool); !ok {
+			return nil, fmt.Errorf("constant: value is not a boolean")
+		}
+	case KIND_DATE:
+		t, ok := val.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("constant: value is not a date")
+		}
+		y, m, d := t.Date()
+		val = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case KIND_TIMESTAMP:
+		t, ok := val.(time.Time)
+		if !ok {
+			return nil, fmt.Errorf("constant: value is not a timestamp")
+		}
+		val = t.UTC().Truncate(time.Microsecond)
+	case KIND_BYTES:
+		if _, ok := val.([]byte); !ok {
+			return nil, fmt.Errorf("constant: value is not a byte string")
+		}
 	default:
 	}
 	return &Const{
@@ -33,6 +93,24 @@ func NewConstant(kind Kind, val any) (*Const, error) {
 	}, nil
 }
 
+// NewNull returns the SQL NULL, which has no value.
+func NewNull() *Const {
+	return &Const{kind: KIND_NULL}
+}
+
+// NewInt returns an integer constant of the narrowest kind that holds val.
+func NewInt(val int64) *Const {
+	if val < math.MinInt32 || val > math.MaxInt32 {
+		return &Const{kind: KIND_INT64, val: val}
+	}
+	return &Const{kind: KIND_INT, val: int(val)}
+}
+
+// IsNull reports whether the constant is NULL.
+func (c *Const) IsNull() bool {
+	return c.kind == KIND_NULL
+}
+
 func (c *Const) AsInt() (int, error) {
 	if c.kind == KIND_INT {
 		return c.val.(int), nil
@@ -48,30 +126,130 @@ func (c *Const) AsString() (string, error) {
 	return "", fmt.Errorf("constant: value is not a string")
 }
 
-// Equals checks if two constants are equal.
+// AsInt64 returns the value of an integer constant of either size.
+func (c *Const) AsInt64() (int64, error) {
+	switch c.kind {
+	case KIND_INT:
+		return int64(c.val.(int)), nil
+	case KIND_INT64:
+		return c.val.(int64), nil
+	}
+	return 0, fmt.Errorf("constant: value is not an integer")
+}
+
+// AsFloat64 returns the value of a numeric constant as a double.
+func (c *Const) AsFloat64() (float64, error) {
+	if c.kind == KIND_FLOAT64 {
+		return c.val.(float64), nil
+	}
+	i, err := c.AsInt64()
+	if err != nil {
+		return 0, fmt.Errorf("constant: value is not a number")
+	}
+	return float64(i), nil
+}
+
+func (c *Const) AsBool() (bool, error) {
+	if c.kind == KIND_BOOL {
+		return c.val.(bool), nil
+	}
+	return false, fmt.Errorf("constant: value is not a boolean")
+}
+
+// AsTime returns the value of a date or timestamp constant.
+func (c *Const) AsTime() (time.Time, error) {
+	if c.kind == KIND_DATE || c.kind == KIND_TIMESTAMP {
+		return c.val.(time.Time), nil
+	}
+	return time.Time{}, fmt.Errorf("constant: value is not a date or timestamp")
+}
+
+func (c *Const) AsBytes() ([]byte, error) {
+	if c.kind == KIND_BYTES {
+		return c.val.([]byte), nil
+	}
+	return nil, fmt.Errorf("constant: value is not a byte string")
+}
+
+// Equals checks if two constants are equal. Two NULLs are equal here; SQL comparisons with NULL are decided by query.Term.
+// Numbers of different kinds are equal if their values are, and so are dates and timestamps.
 func (c *Const) Equals(other *Const) bool {
-	if c.kind != other.kind {
+	if !c.comparable(other) {
 		return false
 	}
-	return c.val == other.val
+	return c.CompareTo(other) == 0
 }
 
 // CompareTo returns 0 if two constants are equal, -1 if the receiver is less than the other, and 1 if the receiver is greater than the other.
+// NULL is less than any other value. Constants of kinds that cannot be compared are reported as equal.
 func (c *Const) CompareTo(other *Const) int {
-	if c.kind != other.kind {
+	if c.IsNull() || other.IsNull() {
+		switch {
+		case c.IsNull() && other.IsNull():
+			return 0
+		case c.IsNull():
+			return -1
+		default:
+			return 1
+		}
+	}
+	if !c.comparable(other) {
 		return 0 // or panic/error if you want to handle it strictly
 	}
-	if c.val == other.val {
-		return 0
+	switch {
+	case c.isInteger() && other.isInteger():
+		a, _ := c.AsInt64()
+		b, _ := other.AsInt64()
+		return compare(a < b, a > b)
+	case c.isNumber():
+		a, _ := c.AsFloat64()
+		b, _ := other.AsFloat64()
+		return compare(a < b, a > b)
+	case c.isTime():
+		a, _ := c.AsTime()
+		b, _ := other.AsTime()
+		return a.Compare(b)
 	}
-	// TODO: Implement comparison for other types
-	if c.val.(int) < other.val.(int) {
+	switch c.kind {
+	case KIND_STR:
+		return strings.Compare(c.val.(string), other.val.(string))
+	case KIND_BOOL:
+		a, b := c.val.(bool), other.val.(bool)
+		return compare(!a && b, a && !b)
+	case KIND_BYTES:
+		return bytes.Compare(c.val.([]byte), other.val.([]byte))
+	}
+	return 0
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
 		return -1
+	case greater:
+		return 1
 	}
-	return 1
+	return 0
+}
+
+// comparable reports whether the constants are of the same kind, or both numbers, or both dates or timestamps.
+func (c *Const) comparable(other *Const) bool {
+	return c.kind == other.kind || (c.isNumber() && other.isNumber()) || (c.isTime() && other.isTime())
+}
+
+func (c *Const) isInteger() bool {
+	return c.kind == KIND_INT || c.kind == KIND_INT64
 }
 
-// HashCode returns the hash code of the constant.
+func (c *Const) isNumber() bool {
+	return c.isInteger() || c.kind == KIND_FLOAT64
+}
+
+func (c *Const) isTime() bool {
+	return c.kind == KIND_DATE || c.kind == KIND_TIMESTAMP
+}
+
+// HashCode returns the hash code of the constant. Constants that are equal have the same hash code.
 func (c *Const) HashCode() (int, error) {
 	// TODO: Implement more valid hash code
 	switch c.kind {
@@ -83,12 +261,31 @@ func (c *Const) HashCode() (int, error) {
 			return 0, err
 		}
 		return len(str), nil
+	case KIND_NULL:
+		return 0, nil
+	case KIND_INT64:
+		return int(c.val.(int64)), nil
+	case KIND_FLOAT64:
+		f := c.val.(float64)
+		if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return int(f), nil
+		}
+		return int(math.Float64bits(f)), nil
+	case KIND_BOOL:
+		if c.val.(bool) {
+			return 1, nil
+		}
+		return 0, nil
+	case KIND_DATE, KIND_TIMESTAMP:
+		return int(c.val.(time.Time).UnixMicro()), nil
+	case KIND_BYTES:
+		return len(c.val.([]byte)), nil
 	default:
 		return 0, fmt.Errorf("constant: unknown kind")
 	}
 }
 
-// ToString returns the string representation of the constant.
+// ToString returns the string representation of the constant. Byte strings are shown in hex, prefixed with \x.
 func (c *Const) ToString() string {
 	switch c.kind {
 	case KIND_INT:
@@ -97,6 +294,20 @@ func (c *Const) ToString() string {
 	case KIND_STR:
 		str, _ := c.AsString()
 		return str
+	case KIND_NULL:
+		return "null"
+	case KIND_INT64:
+		return strconv.FormatInt(c.val.(int64), 10)
+	case KIND_FLOAT64:
+		return strconv.FormatFloat(c.val.(float64), 'g', -1, 64)
+	case KIND_BOOL:
+		return strconv.FormatBool(c.val.(bool))
+	case KIND_DATE:
+		return c.val.(time.Time).Format(DATE_FORMAT)
+	case KIND_TIMESTAMP:
+		return c.val.(time.Time).Format(TIMESTAMP_FORMAT)
+	case KIND_BYTES:
+		return `\x` + hex.EncodeToString(c.val.([]byte))
 	default:
 		return "unknown"
 	}
@@ -2,6 +2,7 @@ package constant
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -39,3 +40,73 @@ func TestConstant(t *testing.T) {
 		assert.Equal(t, 0, c4.CompareTo(c4))
 	})
 }
+
+func TestConstant_Null(t *testing.T) {
+	null := NewNull()
+	i, _ := NewConstant(KIND_INT, 42)
+	assert.True(t, null.IsNull())
+	assert.False(t, i.IsNull())
+	assert.Nil(t, null.AnyValue())
+	assert.Equal(t, "null", null.ToString())
+	_, err := null.AsInt()
+	assert.Error(t, err)
+	_, err = NewConstant(KIND_NULL, 1)
+	assert.Error(t, err)
+
+	assert.True(t, null.Equals(NewNull()))
+	assert.False(t, null.Equals(i))
+	assert.Equal(t, 0, null.CompareTo(NewNull()))
+	assert.Equal(t, -1, null.CompareTo(i))
+	assert.Equal(t, 1, i.CompareTo(null))
+}
+
+func TestConstant_Types(t *testing.T) {
+	mustConst := func(kind Kind, val any) *Const {
+		c, err := NewConstant(kind, val)
+		assert.NoError(t, err)
+		return c
+	}
+	i := mustConst(KIND_INT, 3)
+	big := mustConst(KIND_INT64, int64(1)<<40)
+	three := mustConst(KIND_INT64, int64(3))
+	f := mustConst(KIND_FLOAT64, 2.5)
+	ts := mustConst(KIND_TIMESTAMP, time.Date(2024, 2, 29, 13, 4, 5, 123456789, time.FixedZone("JST", 9*60*60)))
+	date := mustConst(KIND_DATE, time.Date(2024, 2, 29, 13, 4, 5, 0, time.UTC))
+	midnight := mustConst(KIND_TIMESTAMP, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+	bs := mustConst(KIND_BYTES, []byte{0x0a, 0xff})
+
+	_, err := NewConstant(KIND_INT64, 3)
+	assert.Error(t, err)
+	_, err = NewConstant(KIND_DATE, "2024-02-29")
+	assert.Error(t, err)
+
+	// numbers compare by value whatever their kind
+	assert.True(t, i.Equals(three))
+	assert.Equal(t, -1, i.CompareTo(big))
+	assert.Equal(t, 1, i.CompareTo(f))
+	assert.Equal(t, -1, f.CompareTo(three))
+	assert.False(t, i.Equals(mustConst(KIND_STR, "3")))
+	h1, _ := i.HashCode()
+	h2, _ := three.HashCode()
+	assert.Equal(t, h1, h2)
+	v, err := f.AsFloat64()
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, v)
+	v, err = i.AsFloat64()
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, v)
+	_, err = f.AsInt64()
+	assert.Error(t, err)
+
+	// dates are days, and compare with timestamps
+	assert.True(t, date.Equals(midnight))
+	assert.Equal(t, -1, date.CompareTo(ts))
+	assert.Equal(t, "2024-02-29", date.ToString())
+	assert.Equal(t, "2024-02-29 04:04:05.123456", ts.ToString())
+
+	assert.Equal(t, -1, mustConst(KIND_BOOL, false).CompareTo(mustConst(KIND_BOOL, true)))
+	assert.True(t, bs.Equals(mustConst(KIND_BYTES, []byte{0x0a, 0xff})))
+	assert.Equal(t, -1, bs.CompareTo(mustConst(KIND_BYTES, []byte{0x0b})))
+	assert.Equal(t, `\x0aff`, bs.ToString())
+	assert.Equal(t, -1, mustConst(KIND_STR, "a").CompareTo(mustConst(KIND_STR, "b")))
+}
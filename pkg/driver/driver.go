@@ -1,12 +1,19 @@
 package driver
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/buffer"
+	"github.com/kj455/simple-db/pkg/constant"
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/log"
 	"github.com/kj455/simple-db/pkg/metadata"
@@ -31,24 +38,49 @@ func (d *SimpleDriver) Open(name string) (driver.Conn, error) {
 }
 
 type Conn struct {
-	fileMgr file.FileMgr
-	bufMgr  buffer.BufferMgr
-	logMgr  log.LogMgr
-	tx      tx.Transaction
-	mdMgr   metadata.MetadataMgr
-	planner *plan.Planner
+	fileMgr   file.FileMgr
+	bufMgr    buffer.BufferMgr
+	logMgr    log.LogMgr
+	txNumGen  tx.TxNumberGenerator
+	lockTable tx.Lock
+	tx        tx.Transaction
+	inTx      bool
+	mdMgr     metadata.MetadataMgr
+	planner   *plan.Planner
 }
 
 const dir = "./.tmp"
 
+// NewConn opens a connection to the database in the default directory; the name is not used.
 func NewConn(name string) (*Conn, error) {
+	return openConn(dir)
+}
+
+// Connector opens connections to the database in its directory, for sql.OpenDB.
+type Connector struct {
+	dir string
+}
+
+func NewConnector(dir string) *Connector {
+	return &Connector{dir: dir}
+}
+
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return openConn(c.dir)
+}
+
+func (c *Connector) Driver() driver.Driver {
+	return NewSimpleDriver()
+}
+
+func openConn(dir string) (*Conn, error) {
 	const (
 		buffNum     = 8
 		blockSize   = 4096
-		logFileName = "simple-db-conn-log"
+		logFileName = "simpledb-log"
 	)
 	fileMgr := file.NewFileMgr(dir, blockSize)
-	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName, log.WithSegmentBlocks(log.DEFAULT_SEGMENT_BLOCKS))
 	if err != nil {
 		return nil, fmt.Errorf("driver: failed to create log manager: %v", err)
 	}
@@ -56,9 +88,10 @@ func NewConn(name string) (*Conn, error) {
 	for i := 0; i < buffNum; i++ {
 		buffs[i] = buffer.NewBuffer(fileMgr, logMgr, blockSize)
 	}
-	bm := buffer.NewBufferMgr(buffs)
+	bm := buffer.NewBufferMgr(buffs, buffer.WithBackgroundWriter(buffer.DEFAULT_WRITER_INTERVAL, buffer.DEFAULT_WRITER_MAX_PAGES))
 	txNumGen := tx.NewTxNumberGenerator()
-	tx, err := tx.NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	lockTable := tx.NewLock()
+	tx, err := tx.NewTransaction(fileMgr, logMgr, bm, txNumGen, tx.WithTxLockTable(lockTable))
 	if err != nil {
 		return nil, fmt.Errorf("driver: failed to create transaction: %v", err)
 	}
@@ -72,30 +105,138 @@ func NewConn(name string) (*Conn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("driver: failed to create metadata manager: %v", err)
 	}
-	qp := plan.NewBasicQueryPlanner(mdMgr)
-	up := plan.NewBasicUpdatePlanner(mdMgr)
+	qp := plan.NewBasicQueryPlanner(mdMgr, plan.WithSystemTable(plan.BUFFERS_TABLE, plan.NewBuffersTable(bm)))
+	up := plan.NewBasicUpdatePlanner(mdMgr, plan.WithFileMgr(fileMgr))
 	planner := plan.NewPlanner(qp, up)
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("driver: failed to commit transaction: %v", err)
 	}
-	return &Conn{
-		fileMgr: fileMgr,
-		bufMgr:  bm,
-		logMgr:  logMgr,
-		tx:      tx,
-		mdMgr:   mdMgr,
-		planner: planner,
-	}, nil
+	c := &Conn{
+		fileMgr:   fileMgr,
+		bufMgr:    bm,
+		logMgr:    logMgr,
+		txNumGen:  txNumGen,
+		lockTable: lockTable,
+		mdMgr:     mdMgr,
+		planner:   planner,
+	}
+	if err := c.renewTx(); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (c *Conn) Begin() (driver.Tx, error) {
-	return nil, errors.New("driver: not implemented")
+	return c.BeginTx(context.Background(), driver.TxOptions{})
 }
 
-func (c *Conn) Close() error {
+/*
+BeginTx starts an explicit transaction. Statements executed outside of it are committed one by one.
+The settings of a SET TRANSACTION executed outside of a transaction apply to it, unless the options set them otherwise:
+a read-only one stays read-only.
+*/
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if c.inTx {
+		return nil, errors.New("driver: transaction already in progress")
+	}
+	level, err := isolationLevel(sql.IsolationLevel(opts.Isolation))
+	if err != nil {
+		return nil, err
+	}
+	readOnly := opts.ReadOnly
+	if !c.tx.Started() {
+		if sql.IsolationLevel(opts.Isolation) == sql.LevelDefault {
+			level = c.tx.IsolationLevel()
+		}
+		readOnly = readOnly || c.tx.IsReadOnly()
+	}
+	if err := c.tx.Commit(); err != nil {
+		return nil, fmt.Errorf("driver: failed to commit transaction: %v", err)
+	}
+	if err := c.renewTx(tx.WithIsolationLevel(level), tx.WithReadOnly(readOnly)); err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &Tx{conn: c}, nil
+}
+
+func (c *Conn) renewTx(opts ...tx.TransactionOption) error {
+	opts = append([]tx.TransactionOption{tx.WithTxLockTable(c.lockTable)}, opts...)
+	t, err := tx.NewTransaction(c.fileMgr, c.logMgr, c.bufMgr, c.txNumGen, opts...)
+	if err != nil {
+		return fmt.Errorf("driver: failed to create transaction: %v", err)
+	}
+	c.tx = t
 	return nil
 }
 
+/*
+endTx finishes the current transaction and starts a new autocommit one.
+An autocommit transaction that has read and written nothing, as one that only ran SET TRANSACTION, passes its settings
+on to the next transaction, as SQL sets those of the next transaction outside of one.
+*/
+func (c *Conn) endTx(commit bool) error {
+	var opts []tx.TransactionOption
+	if !c.inTx && !c.tx.Started() {
+		opts = append(opts, tx.WithIsolationLevel(c.tx.IsolationLevel()), tx.WithReadOnly(c.tx.IsReadOnly()))
+	}
+	c.inTx = false
+	var err error
+	if commit {
+		err = c.tx.Commit()
+	} else {
+		err = c.tx.Rollback()
+	}
+	if renewErr := c.renewTx(opts...); renewErr != nil {
+		return renewErr
+	}
+	return err
+}
+
+func isolationLevel(level sql.IsolationLevel) (tx.IsolationLevel, error) {
+	switch level {
+	case sql.LevelDefault:
+		return tx.DEFAULT_ISOLATION_LEVEL, nil
+	case sql.LevelReadUncommitted:
+		return tx.ISOLATION_LEVEL_READ_UNCOMMITTED, nil
+	case sql.LevelReadCommitted:
+		return tx.ISOLATION_LEVEL_READ_COMMITTED, nil
+	case sql.LevelRepeatableRead:
+		return tx.ISOLATION_LEVEL_REPEATABLE_READ, nil
+	case sql.LevelSerializable:
+		return tx.ISOLATION_LEVEL_SERIALIZABLE, nil
+	default:
+		return 0, fmt.Errorf("driver: unsupported isolation level %s", level)
+	}
+}
+
+type Tx struct {
+	conn *Conn
+}
+
+func (t *Tx) Commit() error {
+	return t.conn.endTx(true)
+}
+
+func (t *Tx) Rollback() error {
+	return t.conn.endTx(false)
+}
+
+// Close rolls back an explicit transaction in progress, commits the autocommit one and stops the background writer.
+func (c *Conn) Close() error {
+	var err error
+	if c.inTx {
+		err = c.tx.Rollback()
+	} else {
+		err = c.tx.Commit()
+	}
+	c.inTx = false
+	if err != nil {
+		err = fmt.Errorf("driver: failed to end transaction: %v", err)
+	}
+	return errors.Join(err, c.bufMgr.Close())
+}
+
 func (c *Conn) Prepare(query string) (driver.Stmt, error) {
 	return NewSimpleStmt(query, c), nil
 }
@@ -113,7 +254,10 @@ func NewSimpleStmt(query string, conn *Conn) *Stmt {
 }
 
 func (s *Stmt) Close() error {
-	return s.conn.tx.Commit()
+	if s.conn.inTx {
+		return nil
+	}
+	return s.conn.endTx(true)
 }
 
 func (s *Stmt) NumInput() int {
@@ -121,13 +265,71 @@ func (s *Stmt) NumInput() int {
 }
 
 func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
-	n, err := s.conn.planner.ExecuteUpdate(s.query, s.conn.tx)
+	query, err := bindArgs(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	n, err := s.conn.planner.ExecuteUpdate(query, s.conn.tx)
 	if err != nil {
 		return nil, err
 	}
 	return Result{n: n}, nil
 }
 
+// bindArgs replaces each ? placeholder outside of string literals in query with the literal of the next argument.
+// A nil argument is bound as NULL, and a time.Time as a timestamp in UTC.
+func bindArgs(query string, args []driver.Value) (string, error) {
+	var sb strings.Builder
+	inString := false
+	n := 0
+	for _, r := range query {
+		if r == '\'' {
+			inString = !inString
+		}
+		if r != '?' || inString {
+			sb.WriteRune(r)
+			continue
+		}
+		if n < len(args) {
+			lit, err := literal(args[n])
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(lit)
+		}
+		n++
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("driver: %d arguments for %d placeholders", len(args), n)
+	}
+	return sb.String(), nil
+}
+
+func literal(arg driver.Value) (string, error) {
+	switch v := arg.(type) {
+	case nil:
+		return "null", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		// the exponent makes the number a double even if it is whole
+		return strconv.FormatFloat(v, 'e', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return "timestamp '" + v.UTC().Format(constant.TIMESTAMP_FORMAT) + "'", nil
+	case []byte:
+		return "x'" + hex.EncodeToString(v) + "'", nil
+	case string:
+		if strings.ContainsRune(v, '\'') {
+			return "", fmt.Errorf("driver: string argument %q contains a quote", v)
+		}
+		return "'" + v + "'", nil
+	default:
+		return "", fmt.Errorf("driver: unsupported argument type %T", arg)
+	}
+}
+
 type Result struct {
 	n int
 }
@@ -141,7 +343,11 @@ func (r Result) RowsAffected() (int64, error) {
 }
 
 func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
-	p, err := s.conn.planner.CreateQueryPlan(s.query, s.conn.tx)
+	query, err := bindArgs(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.conn.planner.CreateQueryPlan(query, s.conn.tx)
 	if err != nil {
 		return nil, err
 	}
@@ -176,14 +382,24 @@ func (r *Rows) Close() error {
 
 func (r *Rows) Next(dest []driver.Value) error {
 	if !r.scan.Next() {
-		return driver.ErrSkip
+		return io.EOF
 	}
 	for i, field := range r.fields {
 		val, err := r.scan.GetVal(field)
 		if err != nil {
 			return fmt.Errorf("driver: failed to get value: %v", err)
 		}
-		dest[i] = val.AnyValue()
+		dest[i] = driverValue(val)
 	}
 	return nil
 }
+
+// driverValue returns the value of the constant as one of the types of driver.Value: nil for NULL, int64 for integers,
+// float64, bool, string, []byte, and time.Time for dates and timestamps.
+func driverValue(val *constant.Const) driver.Value {
+	if val.Kind() == constant.KIND_INT {
+		i, _ := val.AsInt64()
+		return i
+	}
+	return val.AnyValue()
+}
@@ -0,0 +1,161 @@
+package driver
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/kj455/simple-db/pkg/plan"
+	"github.com/kj455/simple-db/pkg/testutil"
+	"github.com/kj455/simple-db/pkg/tx"
+	"github.com/stretchr/testify/require"
+)
+
+// openTestConn opens a database of its own for the test, with a single connection, as a connection manages the whole database.
+func openTestConn(t *testing.T) *sql.Conn {
+	dir, cleanup := testutil.SetupDir(t.Name())
+	t.Cleanup(cleanup)
+	db := sql.OpenDB(NewConnector(dir))
+	db.SetMaxOpenConns(1)
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, conn.Close())
+		require.NoError(t, db.Close())
+	})
+	return conn
+}
+
+// settings returns the isolation level and access mode of the current transaction of the connection.
+func settings(t *testing.T, conn *sql.Conn) (level tx.IsolationLevel, readOnly bool) {
+	err := conn.Raw(func(dc any) error {
+		c := dc.(*Conn)
+		level, readOnly = c.tx.IsolationLevel(), c.tx.IsReadOnly()
+		return nil
+	})
+	require.NoError(t, err)
+	return level, readOnly
+}
+
+func TestDriver_SetTransaction(t *testing.T) {
+	ctx := context.Background()
+	conn := openTestConn(t)
+	exec := func(query string, args ...any) error {
+		_, err := conn.ExecContext(ctx, query, args...)
+		return err
+	}
+	require.NoError(t, exec("create table item(id int)"))
+
+	// outside of a transaction, the settings apply to the next one only
+	require.NoError(t, exec("set transaction isolation level read committed, read only"))
+	level, readOnly := settings(t, conn)
+	require.Equal(t, tx.ISOLATION_LEVEL_READ_COMMITTED, level)
+	require.True(t, readOnly)
+	require.ErrorContains(t, exec("insert into item(id) values(1)"), tx.ErrReadOnly.Error())
+	level, readOnly = settings(t, conn)
+	require.Equal(t, tx.DEFAULT_ISOLATION_LEVEL, level)
+	require.False(t, readOnly)
+	require.NoError(t, exec("insert into item(id) values(1)"))
+
+	// a transaction begun after them takes them, unless its options set them otherwise
+	require.NoError(t, exec("set transaction isolation level repeatable read"))
+	txn, err := conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	level, _ = settings(t, conn)
+	require.Equal(t, tx.ISOLATION_LEVEL_REPEATABLE_READ, level)
+	require.NoError(t, txn.Commit())
+	require.NoError(t, exec("set transaction isolation level repeatable read"))
+	txn, err = conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelReadUncommitted})
+	require.NoError(t, err)
+	level, _ = settings(t, conn)
+	require.Equal(t, tx.ISOLATION_LEVEL_READ_UNCOMMITTED, level)
+	require.NoError(t, txn.Commit())
+
+	// in a transaction, they must come before it reads or writes anything
+	txn, err = conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = txn.Exec("set transaction isolation level read uncommitted")
+	require.NoError(t, err)
+	level, _ = settings(t, conn)
+	require.Equal(t, tx.ISOLATION_LEVEL_READ_UNCOMMITTED, level)
+	rows, err := txn.Query("select id from item")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	_, err = txn.Exec("set transaction isolation level serializable")
+	require.ErrorIs(t, err, plan.ErrTransactionStarted)
+	level, _ = settings(t, conn)
+	require.Equal(t, tx.ISOLATION_LEVEL_READ_UNCOMMITTED, level)
+	require.NoError(t, txn.Commit())
+
+	// a transaction begun read-only cannot be made read-write
+	txn, err = conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	require.NoError(t, err)
+	_, err = txn.Exec("set transaction read write")
+	require.ErrorContains(t, err, "read-only")
+	_, err = txn.Exec("insert into item(id) values(2)")
+	require.ErrorContains(t, err, tx.ErrReadOnly.Error())
+	require.NoError(t, txn.Rollback())
+	txn, err = conn.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	_, err = txn.Exec("set transaction read only")
+	require.NoError(t, err)
+	_, err = txn.Exec("insert into item(id) values(2)")
+	require.ErrorContains(t, err, tx.ErrReadOnly.Error())
+	require.NoError(t, txn.Rollback())
+}
+
+func TestDriver_BindArgs(t *testing.T) {
+	ctx := context.Background()
+	conn := openTestConn(t)
+	_, err := conn.ExecContext(ctx, "create table kinds(id bigint, d double, b boolean, ts timestamp, bl blob(8), s varchar(10), n int)")
+	require.NoError(t, err)
+
+	// each kind of argument is written as a literal of its type and reads back unchanged
+	const id = int64(1) << 40
+	ts := time.Date(2024, 2, 29, 23, 30, 15, 123456000, time.FixedZone("JST", 9*60*60))
+	blob := []byte{0x00, 0x01, 0xff}
+	res, err := conn.ExecContext(ctx, "insert into kinds(id, d, b, ts, bl, s, n) values(?, ?, ?, ?, ?, ?, ?)", id, 3.0, true, ts, blob, "a?b", nil)
+	require.NoError(t, err)
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affected)
+
+	var (
+		gotID   int64
+		gotD    float64
+		gotB    bool
+		gotTS   time.Time
+		gotBlob []byte
+		gotS    string
+		gotN    sql.NullInt64
+	)
+	row := conn.QueryRowContext(ctx, "select id, d, b, ts, bl, s, n from kinds where id = ? and s = ?", id, "a?b")
+	require.NoError(t, row.Scan(&gotID, &gotD, &gotB, &gotTS, &gotBlob, &gotS, &gotN))
+	require.Equal(t, id, gotID)
+	require.Equal(t, 3.0, gotD)
+	require.True(t, gotB)
+	require.True(t, ts.Equal(gotTS), "got %v, want %v", gotTS, ts)
+	require.Equal(t, blob, gotBlob)
+	require.Equal(t, "a?b", gotS)
+	require.False(t, gotN.Valid)
+
+	// a question mark in a quoted string is not a placeholder
+	require.NoError(t, conn.QueryRowContext(ctx, "select id from kinds where s = 'a?b'").Scan(&gotID))
+	require.Equal(t, id, gotID)
+
+	// a string with a quote would end the literal early, so it is refused rather than escaped
+	_, err = conn.ExecContext(ctx, "insert into kinds(id, s) values(?, ?)", int64(2), "it's")
+	require.ErrorContains(t, err, "contains a quote")
+	_, err = conn.ExecContext(ctx, "insert into kinds(id) values(?)")
+	require.ErrorContains(t, err, "0 arguments for 1 placeholders")
+	rows, err := conn.QueryContext(ctx, "select id from kinds")
+	require.NoError(t, err)
+	ids := 0
+	for rows.Next() {
+		ids++
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.Equal(t, 1, ids)
+}
@@ -0,0 +1,83 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+/*
+Every block is stored on disk with a header in front of the page contents:
+
+	---------------------------------------------------
+	| 4 bytes: CRC32C | 4 bytes: page LSN | contents |
+	---------------------------------------------------
+
+The checksum covers the page LSN and the contents, so a corrupt or partially written block is detected on Read.
+A block of zeros is a block that has been appended but never written, and is valid.
+*/
+const (
+	PAGE_HEADER_SIZE  = 8
+	offsetChecksum    = 0
+	offsetPageLSN     = 4
+	offsetPageContent = PAGE_HEADER_SIZE
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+var ErrCorruptPage = errors.New("file: corrupt page")
+
+// CorruptPageError tells which block failed the verification. It matches ErrCorruptPage with errors.Is.
+type CorruptPageError struct {
+	Filename string
+	BlockNum int
+	Reason   string
+}
+
+func (e *CorruptPageError) Error() string {
+	return fmt.Sprintf("%v: file %s, block %d: %s", ErrCorruptPage, e.Filename, e.BlockNum, e.Reason)
+}
+
+func (e *CorruptPageError) Is(target error) bool {
+	return target == ErrCorruptPage
+}
+
+// encodeBlock writes the header and the contents of the page into the block buffer.
+func encodeBlock(block []byte, p Page) {
+	clear(block)
+	copy(block[offsetPageContent:], p.Contents().Bytes())
+	binary.BigEndian.PutUint32(block[offsetPageLSN:], uint32(int32(p.LSN())))
+	binary.BigEndian.PutUint32(block[offsetChecksum:], checksum(block))
+}
+
+// decodeBlock verifies the block and copies it into the page. n is the number of bytes read from the disk.
+func decodeBlock(id BlockId, block []byte, n int, p Page) error {
+	if n == 0 {
+		// beyond the end of the file
+		clear(block)
+	} else if n < len(block) {
+		return &CorruptPageError{Filename: id.Filename(), BlockNum: id.Number(), Reason: fmt.Sprintf("short block of %d bytes", n)}
+	} else if !isZero(block) {
+		stored := binary.BigEndian.Uint32(block[offsetChecksum:])
+		if actual := checksum(block); stored != actual {
+			return &CorruptPageError{Filename: id.Filename(), BlockNum: id.Number(), Reason: fmt.Sprintf("checksum mismatch: stored %08x, computed %08x", stored, actual)}
+		}
+	}
+	copy(p.Contents().Bytes(), block[offsetPageContent:])
+	p.SetLSN(int(int32(binary.BigEndian.Uint32(block[offsetPageLSN:]))))
+	return nil
+}
+
+func checksum(block []byte) uint32 {
+	return crc32.Checksum(block[offsetPageLSN:], crc32c)
+}
+
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
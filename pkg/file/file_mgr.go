@@ -10,29 +10,66 @@ import (
 )
 
 type FileMgrImpl struct {
-	dbDir     string
-	blockSize int
-	isNew     bool
-	openFiles map[string]*os.File
-	mu        sync.Mutex
+	dbDir      string
+	blockSize  int
+	isNew      bool
+	readOnly   bool
+	formatErr  error // set if the directory is of another format, and returned by every file operation
+	openFiles  map[string]*os.File
+	syncPolicy SyncPolicy
+	syncMethod SyncMethod
+	block      []byte // the physical block, header included, read or written under mu
+	mu         sync.Mutex
 }
 
-func NewFileMgr(dbDir string, blockSize int) *FileMgrImpl {
-	_, err := os.Stat(dbDir)
-	notExists := os.IsNotExist(err)
-	if notExists {
-		_ = os.MkdirAll(dbDir, 0755)
+type FileMgrOption func(*FileMgrImpl)
+
+func WithSyncPolicy(p SyncPolicy) FileMgrOption {
+	return func(m *FileMgrImpl) {
+		m.syncPolicy = p
+	}
+}
+
+func WithSyncMethod(method SyncMethod) FileMgrOption {
+	return func(m *FileMgrImpl) {
+		m.syncMethod = method
+	}
+}
+
+/*
+WithReadOnly opens an existing database without changing it, for the tools that only inspect it.
+The directory is not created and its format marker is not written: a directory without the marker is refused with ErrNotDatabase.
+The files are opened read-only, and the operations that would change them fail with ErrReadOnly.
+*/
+func WithReadOnly() FileMgrOption {
+	return func(m *FileMgrImpl) {
+		m.readOnly = true
 	}
+}
 
-	return &FileMgrImpl{
-		dbDir:     dbDir,
-		blockSize: blockSize,
-		isNew:     notExists,
-		openFiles: make(map[string]*os.File),
+func NewFileMgr(dbDir string, blockSize int, opts ...FileMgrOption) *FileMgrImpl {
+	m := &FileMgrImpl{
+		dbDir:      dbDir,
+		blockSize:  blockSize,
+		openFiles:  make(map[string]*os.File),
+		syncPolicy: DEFAULT_SYNC_POLICY,
+		syncMethod: DEFAULT_SYNC_METHOD,
+		block:      make([]byte, PAGE_HEADER_SIZE+blockSize),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	_, err := os.Stat(dbDir)
+	m.isNew = os.IsNotExist(err)
+	if m.isNew && !m.readOnly {
+		_ = os.MkdirAll(dbDir, 0755)
 	}
+	m.formatErr = m.checkFormat()
+	return m
 }
 
 // Read reads contents on a block from the file and stores it in the page.
+// It returns a *CorruptPageError if the checksum of the block does not match or the block is partially written.
 func (m *FileMgrImpl) Read(id BlockId, p Page) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -42,41 +79,67 @@ func (m *FileMgrImpl) Read(id BlockId, p Page) error {
 		return fmt.Errorf("file: cannot open file %s: %w", id.Filename(), err)
 	}
 
-	_, err = f.Seek(int64(id.Number())*int64(m.blockSize), 0)
+	_, err = f.Seek(m.offset(id.Number()), 0)
 	if err != nil {
 		return fmt.Errorf("file: cannot seek to block %d: %w", id.Number(), err)
 	}
 
-	_, err = f.Read(p.Contents().Bytes())
-	if errors.Is(err, io.EOF) {
-		return nil
+	n, err := io.ReadFull(f, m.block)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
 	}
-	return err
+	return decodeBlock(id, m.block, n, p)
 }
 
 // Write writes page contents to a block in the file.
 func (m *FileMgrImpl) Write(id BlockId, p Page) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.readOnly {
+		return ErrReadOnly
+	}
 
 	f, err := m.getFile(id.Filename())
 	if err != nil {
 		return fmt.Errorf("file: cannot open file %s: %w", id.Filename(), err)
 	}
 
-	_, err = f.Seek(int64(id.Number())*int64(m.blockSize), 0)
+	_, err = f.Seek(m.offset(id.Number()), 0)
 	if err != nil {
 		return fmt.Errorf("file: cannot seek to block %d: %w", id.Number(), err)
 	}
 
-	_, err = f.Write(p.Contents().Bytes())
-	return err
+	encodeBlock(m.block, p)
+	if _, err = f.Write(m.block); err != nil {
+		return err
+	}
+	if m.syncPolicy == SYNC_POLICY_ALWAYS {
+		return m.syncFile(f)
+	}
+	return nil
+}
+
+// Sync forces the written blocks of the file to stable storage, unless the sync policy is none.
+func (m *FileMgrImpl) Sync(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.syncPolicy == SYNC_POLICY_NONE {
+		return nil
+	}
+	f, err := m.getFile(filename)
+	if err != nil {
+		return fmt.Errorf("file: cannot open file %s: %w", filename, err)
+	}
+	return m.syncFile(f)
 }
 
 // Append appends a new block to the file and returns the block ID.
 func (m *FileMgrImpl) Append(filename string) (BlockId, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if m.readOnly {
+		return nil, ErrReadOnly
+	}
 
 	f, err := m.getFile(filename)
 	if err != nil {
@@ -85,16 +148,21 @@ func (m *FileMgrImpl) Append(filename string) (BlockId, error) {
 
 	blockNum := m.getBlockNum(filename)
 	block := NewBlockId(filename, blockNum)
-	_, err = f.Seek(int64(block.blockNum)*int64(m.blockSize), 0)
+	_, err = f.Seek(m.offset(block.blockNum), 0)
 	if err != nil {
 		return nil, fmt.Errorf("file: cannot seek to block %d: %w", block.blockNum, err)
 	}
 
-	buf := make([]byte, m.blockSize)
-	_, err = f.Write(buf)
+	clear(m.block)
+	_, err = f.Write(m.block)
 	if err != nil {
 		return nil, fmt.Errorf("file: cannot write to block %d: %w", block.blockNum, err)
 	}
+	if m.syncPolicy == SYNC_POLICY_ALWAYS {
+		if err := m.syncFile(f); err != nil {
+			return nil, err
+		}
+	}
 
 	return block, nil
 }
@@ -110,7 +178,8 @@ func (m *FileMgrImpl) BlockNum(filename string) (int, error) {
 	}
 
 	length, err := f.Seek(0, 2) // Seek to the end of the file
-	return int((length + int64(m.blockSize) - 1) / int64(m.blockSize)), err
+	size := int64(len(m.block))
+	return int((length + size - 1) / size), err
 }
 
 func (m *FileMgrImpl) BlockSize() int {
@@ -121,21 +190,117 @@ func (m *FileMgrImpl) IsNew() bool {
 	return m.isNew
 }
 
+// Files returns the names of the regular files in the database directory, the format marker excluded.
+func (m *FileMgrImpl) Files() ([]string, error) {
+	if m.formatErr != nil {
+		return nil, m.formatErr
+	}
+	entries, err := os.ReadDir(m.dbDir)
+	if err != nil {
+		return nil, fmt.Errorf("file: cannot read directory %s: %w", m.dbDir, err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Type().IsRegular() && e.Name() != FORMAT_FILE {
+			files = append(files, e.Name())
+		}
+	}
+	return files, nil
+}
+
+// Remove closes and deletes the file.
+func (m *FileMgrImpl) Remove(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if f, ok := m.openFiles[filename]; ok {
+		f.Close()
+		delete(m.openFiles, filename)
+	}
+	if err := os.Remove(filepath.Join(m.dbDir, filename)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("file: cannot remove file %s: %w", filename, err)
+	}
+	// the removal must survive a crash like the creation of the file
+	if m.syncPolicy != SYNC_POLICY_NONE {
+		if err := syncDir(m.dbDir); err != nil {
+			return fmt.Errorf("file: cannot sync directory %s: %w", m.dbDir, err)
+		}
+	}
+	return nil
+}
+
+// Truncate shortens the file to its first blocks, and forces the new size to stable storage unless the sync policy is none.
+func (m *FileMgrImpl) Truncate(filename string, blocks int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	f, err := m.getFile(filename)
+	if err != nil {
+		return fmt.Errorf("file: cannot open file %s: %w", filename, err)
+	}
+	if m.getBlockNum(filename) <= blocks {
+		return nil
+	}
+	if err := f.Truncate(m.offset(blocks)); err != nil {
+		return fmt.Errorf("file: cannot truncate file %s to %d blocks: %w", filename, blocks, err)
+	}
+	if m.syncPolicy != SYNC_POLICY_NONE {
+		return m.syncFile(f)
+	}
+	return nil
+}
+
+// offset returns the position of the block in its file. Each block is stored with its header.
+func (m *FileMgrImpl) offset(blockNum int) int64 {
+	return int64(blockNum) * int64(len(m.block))
+}
+
 func (mgr *FileMgrImpl) getFile(filename string) (*os.File, error) {
+	if mgr.formatErr != nil {
+		return nil, mgr.formatErr
+	}
 	if f, exists := mgr.openFiles[filename]; exists {
 		return f, nil
 	}
 
 	filePath := filepath.Join(mgr.dbDir, filename)
-	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE, 0666)
+	if mgr.readOnly {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return nil, err
+		}
+		mgr.openFiles[filename] = f
+		return f, nil
+	}
+	_, err := os.Stat(filePath)
+	created := os.IsNotExist(err)
+	f, err := os.OpenFile(filePath, os.O_RDWR|os.O_CREATE|mgr.syncMethod.openFlag(), 0666)
 	if err != nil {
 		return nil, err
 	}
+	// the new directory entry must survive a crash as well as the blocks written to the file
+	if created && mgr.syncPolicy != SYNC_POLICY_NONE {
+		if err := syncDir(mgr.dbDir); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("file: cannot sync directory %s: %w", mgr.dbDir, err)
+		}
+	}
 
 	mgr.openFiles[filename] = f
 	return f, nil
 }
 
+func (mgr *FileMgrImpl) syncFile(f *os.File) error {
+	if err := mgr.syncMethod.sync(f); err != nil {
+		return fmt.Errorf("file: cannot sync file %s: %w", f.Name(), err)
+	}
+	return nil
+}
+
 func (m *FileMgrImpl) getBlockNum(filename string) int {
 	f, err := m.getFile(filename)
 	if err != nil {
@@ -147,5 +312,5 @@ func (m *FileMgrImpl) getBlockNum(filename string) int {
 		return -1
 	}
 
-	return int(info.Size()) / m.blockSize
+	return int(info.Size()) / len(m.block)
 }
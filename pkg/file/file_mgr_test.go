@@ -1,6 +1,7 @@
 package file
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -32,6 +33,97 @@ func TestNewFileMgr(t *testing.T) {
 	})
 }
 
+func TestFileMgr_Format(t *testing.T) {
+	t.Parallel()
+	const blockSize = 400
+	t.Run("marked when empty", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_file_mgr_format_new")
+		t.Cleanup(cleanup)
+		mgr := NewFileMgr(dir, blockSize)
+		_, err := mgr.Append("file")
+		assert.NoError(t, err)
+		marker, err := os.ReadFile(filepath.Join(dir, FORMAT_FILE))
+		assert.NoError(t, err)
+		assert.Equal(t, formatMarker(), marker)
+		files, err := mgr.Files()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"file"}, files)
+
+		// the marked directory is opened again
+		mgr = NewFileMgr(dir, blockSize)
+		_, err = mgr.BlockNum("file")
+		assert.NoError(t, err)
+	})
+	t.Run("old format", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_file_mgr_format_old")
+		t.Cleanup(cleanup)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "file"), make([]byte, blockSize), 0666))
+		mgr := NewFileMgr(dir, blockSize)
+		_, err := mgr.BlockNum("file")
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+		_, err = mgr.Append("other")
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+	t.Run("other version", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_file_mgr_format_version")
+		t.Cleanup(cleanup)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, FORMAT_FILE), []byte("simpledb format 3\n"), 0666))
+		mgr := NewFileMgr(dir, blockSize)
+		_, err := mgr.BlockNum("file")
+		assert.ErrorIs(t, err, ErrUnsupportedFormat)
+	})
+}
+
+func TestFileMgr_ReadOnly(t *testing.T) {
+	t.Parallel()
+	const blockSize = 400
+	t.Run("missing directory", func(t *testing.T) {
+		t.Parallel()
+		dir := "test_file_mgr_read_only_missing"
+		t.Cleanup(func() {
+			os.RemoveAll(dir)
+		})
+		mgr := NewFileMgr(dir, blockSize, WithReadOnly())
+		_, err := Verify(mgr)
+		assert.ErrorIs(t, err, ErrNotDatabase)
+		_, err = os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("unmarked directory", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_file_mgr_read_only_unmarked")
+		t.Cleanup(cleanup)
+		mgr := NewFileMgr(dir, blockSize, WithReadOnly())
+		_, err := Verify(mgr)
+		assert.ErrorIs(t, err, ErrNotDatabase)
+		_, err = os.Stat(filepath.Join(dir, FORMAT_FILE))
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("database", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_file_mgr_read_only_database")
+		t.Cleanup(cleanup)
+		_, err := NewFileMgr(dir, blockSize).Append("file")
+		assert.NoError(t, err)
+
+		mgr := NewFileMgr(dir, blockSize, WithReadOnly())
+		corrupted, err := Verify(mgr)
+		assert.NoError(t, err)
+		assert.Empty(t, corrupted)
+		_, err = mgr.Append("file")
+		assert.ErrorIs(t, err, ErrReadOnly)
+		assert.ErrorIs(t, mgr.Write(NewBlockId("file", 0), NewPage(blockSize)), ErrReadOnly)
+		assert.ErrorIs(t, mgr.Remove("file"), ErrReadOnly)
+		_, err = mgr.BlockNum("other")
+		assert.Error(t, err)
+		_, err = os.Stat(filepath.Join(dir, "other"))
+		assert.True(t, os.IsNotExist(err))
+	})
+}
+
 func TestFileMgr_Read(t *testing.T) {
 	t.Parallel()
 	const blockSize = 4096
@@ -54,27 +146,43 @@ func TestFileMgr_Read(t *testing.T) {
 		const fileName = "read_test"
 		f, cleanup := setupFile(fileName)
 		t.Cleanup(cleanup)
-		bytes := []byte("hello world!!!!")
-		_, err := f.Write(bytes)
+		written := NewPage(blockSize)
+		written.SetString(0, "hello world!!!!")
+		written.SetLSN(42)
+		block := make([]byte, PAGE_HEADER_SIZE+blockSize)
+		encodeBlock(block, written)
+		_, err := f.Write(block)
 		assert.NoError(t, err)
-		page := NewPage(len(bytes))
+		page := NewPage(blockSize)
 		id := NewBlockId(fileName, 0)
 
 		err = mgr.Read(id, page)
 
 		assert.NoError(t, err)
-		assert.Equal(t, bytes, page.Contents().Bytes())
+		assert.Equal(t, written.Contents().Bytes(), page.Contents().Bytes())
+		assert.Equal(t, 42, page.LSN())
+	})
+	t.Run("Read beyond end of file", func(t *testing.T) {
+		t.Parallel()
+		const fileName = "read_eof_test"
+		_, cleanup := setupFile(fileName)
+		t.Cleanup(cleanup)
+		page := NewPage(blockSize)
+		page.SetString(0, "stale")
+
+		err := mgr.Read(NewBlockId(fileName, 3), page)
+
+		assert.NoError(t, err)
+		assert.Equal(t, make([]byte, blockSize), page.Contents().Bytes())
 	})
 	t.Run("Write", func(t *testing.T) {
 		t.Parallel()
-		const (
-			fileName  = "write_test"
-			blockSize = 4096
-		)
+		const fileName = "write_test"
 		_, cleanup := setupFile(fileName)
 		t.Cleanup(cleanup)
 		page := NewPage(blockSize)
 		page.SetString(0, "hello world!!!!")
+		page.SetLSN(7)
 		id := NewBlockId(fileName, 0)
 
 		err := mgr.Write(id, page)
@@ -82,7 +190,9 @@ func TestFileMgr_Read(t *testing.T) {
 		assert.NoError(t, err)
 		fileContent, err := os.ReadFile(filepath.Join(dbDir, fileName))
 		assert.NoError(t, err)
-		assert.Equal(t, page.Contents().Bytes(), fileContent)
+		assert.Len(t, fileContent, PAGE_HEADER_SIZE+blockSize)
+		assert.Equal(t, page.Contents().Bytes(), fileContent[PAGE_HEADER_SIZE:])
+		assert.Equal(t, []byte{0, 0, 0, 7}, fileContent[offsetPageLSN:PAGE_HEADER_SIZE])
 	})
 	t.Run("Append", func(t *testing.T) {
 		t.Parallel()
@@ -97,3 +207,176 @@ func TestFileMgr_Read(t *testing.T) {
 		assert.Equal(t, 0, id.Number())
 	})
 }
+
+func TestFileMgr_Checksum(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize = 64
+		fileName  = "checksum_test"
+	)
+	setup := func(t *testing.T, dirName string) (*FileMgrImpl, string) {
+		dir, cleanup := testutil.SetupDir(dirName)
+		t.Cleanup(cleanup)
+		mgr := NewFileMgr(dir, blockSize)
+		for i := 0; i < 2; i++ {
+			block, err := mgr.Append(fileName)
+			assert.NoError(t, err)
+			page := NewPage(blockSize)
+			page.SetString(0, "block content")
+			assert.NoError(t, mgr.Write(block, page))
+		}
+		return mgr, filepath.Join(dir, fileName)
+	}
+	t.Run("bit flip", func(t *testing.T) {
+		t.Parallel()
+		mgr, path := setup(t, "test_file_mgr_checksum_bit_flip")
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		data[PAGE_HEADER_SIZE+blockSize+PAGE_HEADER_SIZE+5] ^= 0x01
+		assert.NoError(t, os.WriteFile(path, data, 0666))
+
+		assert.NoError(t, mgr.Read(NewBlockId(fileName, 0), NewPage(blockSize)))
+		err = mgr.Read(NewBlockId(fileName, 1), NewPage(blockSize))
+
+		assert.True(t, errors.Is(err, ErrCorruptPage))
+		var cpe *CorruptPageError
+		assert.True(t, errors.As(err, &cpe))
+		assert.Equal(t, fileName, cpe.Filename)
+		assert.Equal(t, 1, cpe.BlockNum)
+	})
+	t.Run("torn write", func(t *testing.T) {
+		t.Parallel()
+		mgr, path := setup(t, "test_file_mgr_checksum_torn_write")
+		assert.NoError(t, os.Truncate(path, PAGE_HEADER_SIZE+blockSize+PAGE_HEADER_SIZE+blockSize/2))
+
+		err := mgr.Read(NewBlockId(fileName, 1), NewPage(blockSize))
+
+		var cpe *CorruptPageError
+		assert.True(t, errors.As(err, &cpe))
+		assert.Equal(t, 1, cpe.BlockNum)
+	})
+	t.Run("verify", func(t *testing.T) {
+		t.Parallel()
+		mgr, path := setup(t, "test_file_mgr_checksum_verify")
+		_, err := mgr.Append("other")
+		assert.NoError(t, err)
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		data[PAGE_HEADER_SIZE] ^= 0xff
+		assert.NoError(t, os.WriteFile(path, data, 0666))
+
+		corrupted, err := Verify(mgr)
+
+		assert.NoError(t, err)
+		assert.Len(t, corrupted, 1)
+		assert.Equal(t, fileName, corrupted[0].Filename)
+		assert.Equal(t, 0, corrupted[0].BlockNum)
+	})
+}
+
+func TestFileMgr_Remove(t *testing.T) {
+	t.Parallel()
+	const blockSize = 16
+	dir, cleanup := testutil.SetupDir("test_file_mgr_remove")
+	t.Cleanup(cleanup)
+	mgr := NewFileMgr(dir, blockSize)
+	_, err := mgr.Append("removed")
+	assert.NoError(t, err)
+	_, err = mgr.Append("kept")
+	assert.NoError(t, err)
+
+	assert.NoError(t, mgr.Remove("removed"))
+	assert.NoError(t, mgr.Remove("never_created"))
+
+	files, err := mgr.Files()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"kept"}, files)
+	_, err = os.Stat(filepath.Join(dir, "removed"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileMgr_Truncate(t *testing.T) {
+	t.Parallel()
+	const blockSize = 16
+	dir, cleanup := testutil.SetupDir("test_file_mgr_truncate")
+	t.Cleanup(cleanup)
+	mgr := NewFileMgr(dir, blockSize)
+	for i := 0; i < 3; i++ {
+		_, err := mgr.Append("truncated")
+		assert.NoError(t, err)
+	}
+	p := NewPage(blockSize)
+	p.SetInt(0, 7)
+	assert.NoError(t, mgr.Write(NewBlockId("truncated", 0), p))
+
+	assert.NoError(t, mgr.Truncate("truncated", 5))
+	size, err := mgr.BlockNum("truncated")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+
+	assert.NoError(t, mgr.Truncate("truncated", 1))
+	size, err = mgr.BlockNum("truncated")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, size)
+	read := NewPage(blockSize)
+	assert.NoError(t, mgr.Read(NewBlockId("truncated", 0), read))
+	assert.Equal(t, int32(7), read.GetInt(0))
+	blk, err := mgr.Append("truncated")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, blk.Number())
+}
+
+func TestFileMgr_Sync(t *testing.T) {
+	t.Parallel()
+	const blockSize = 16
+	tests := []struct {
+		name   string
+		policy SyncPolicy
+		method SyncMethod
+	}{
+		{name: "always fsync", policy: SYNC_POLICY_ALWAYS, method: SYNC_METHOD_FSYNC},
+		{name: "on commit fdatasync", policy: SYNC_POLICY_ON_COMMIT, method: SYNC_METHOD_FDATASYNC},
+		{name: "on commit dsync", policy: SYNC_POLICY_ON_COMMIT, method: SYNC_METHOD_DSYNC},
+		{name: "none", policy: SYNC_POLICY_NONE, method: SYNC_METHOD_FSYNC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, cleanup := testutil.SetupDir("test_file_mgr_sync_" + tt.policy.String() + "_" + tt.method.String())
+			t.Cleanup(cleanup)
+			mgr := NewFileMgr(dir, blockSize, WithSyncPolicy(tt.policy), WithSyncMethod(tt.method))
+			assert.Equal(t, tt.policy, mgr.syncPolicy)
+			assert.Equal(t, tt.method, mgr.syncMethod)
+
+			block, err := mgr.Append("sync_test")
+			assert.NoError(t, err)
+			page := NewPage(blockSize)
+			page.SetString(0, "durable")
+			assert.NoError(t, mgr.Write(block, page))
+			assert.NoError(t, mgr.Sync("sync_test"))
+
+			readPage := NewPage(blockSize)
+			assert.NoError(t, mgr.Read(block, readPage))
+			assert.Equal(t, "durable", readPage.GetString(0))
+		})
+	}
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_file_mgr_sync_defaults")
+		t.Cleanup(cleanup)
+		mgr := NewFileMgr(dir, blockSize)
+		assert.Equal(t, SYNC_POLICY_ON_COMMIT, mgr.syncPolicy)
+		assert.Equal(t, SYNC_METHOD_FSYNC, mgr.syncMethod)
+	})
+}
+
+func TestParseSyncPolicy(t *testing.T) {
+	t.Parallel()
+	for _, p := range []SyncPolicy{SYNC_POLICY_ALWAYS, SYNC_POLICY_ON_COMMIT, SYNC_POLICY_NONE} {
+		got, err := ParseSyncPolicy(p.String())
+		assert.NoError(t, err)
+		assert.Equal(t, p, got)
+	}
+	_, err := ParseSyncPolicy("sometimes")
+	assert.Error(t, err)
+}
@@ -0,0 +1,246 @@
+/*
+Package filetest provides a FileMgr test double that keeps the files in memory,
+records every operation and simulates crashes and I/O faults.
+
+Blocks are kept without the page header, so checksums are not verified and page LSNs are not kept.
+Written blocks only survive a Crash once their file has been synced, like on a real disk
+with a volatile write cache, so tests can check that data reaches stable storage in the right order.
+*/
+package filetest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
+
+type OpKind string
+
+const (
+	OP_KIND_READ     OpKind = "read"
+	OP_KIND_WRITE    OpKind = "write"
+	OP_KIND_SYNC     OpKind = "sync"
+	OP_KIND_APPEND   OpKind = "append"
+	OP_KIND_REMOVE   OpKind = "remove"
+	OP_KIND_TRUNCATE OpKind = "truncate"
+)
+
+// Op is an operation made on the file manager. Block is -1 for the operations on a whole file.
+type Op struct {
+	Kind     OpKind
+	Filename string
+	Block    int
+}
+
+func (o Op) String() string {
+	if o.Block < 0 {
+		return fmt.Sprintf("%s %s", o.Kind, o.Filename)
+	}
+	return fmt.Sprintf("%s %s:%d", o.Kind, o.Filename, o.Block)
+}
+
+var ErrInjected = errors.New("filetest: injected fault")
+
+// FaultFunc decides whether an operation fails. A non nil error is returned by the operation, which then has no effect.
+type FaultFunc func(op Op) error
+
+type FaultyFileMgr struct {
+	blockSize int
+	isNew     bool
+	files     map[string][][]byte // the blocks as seen by the readers
+	durable   map[string][][]byte // the blocks that survive a crash
+	ops       []Op
+	fault     FaultFunc
+	mu        sync.Mutex
+}
+
+func NewFaultyFileMgr(blockSize int) *FaultyFileMgr {
+	return &FaultyFileMgr{
+		blockSize: blockSize,
+		isNew:     true,
+		files:     make(map[string][][]byte),
+		durable:   make(map[string][][]byte),
+	}
+}
+
+func (m *FaultyFileMgr) Read(id file.BlockId, p file.Page) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.record(Op{Kind: OP_KIND_READ, Filename: id.Filename(), Block: id.Number()}); err != nil {
+		return err
+	}
+	buf := p.Contents().Bytes()
+	blocks := m.files[id.Filename()]
+	if id.Number() >= len(blocks) {
+		clear(buf)
+		return nil
+	}
+	copy(buf, blocks[id.Number()])
+	return nil
+}
+
+func (m *FaultyFileMgr) Write(id file.BlockId, p file.Page) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.record(Op{Kind: OP_KIND_WRITE, Filename: id.Filename(), Block: id.Number()}); err != nil {
+		return err
+	}
+	blocks := m.files[id.Filename()]
+	for len(blocks) <= id.Number() {
+		blocks = append(blocks, make([]byte, m.blockSize))
+	}
+	copy(blocks[id.Number()], p.Contents().Bytes())
+	m.files[id.Filename()] = blocks
+	return nil
+}
+
+func (m *FaultyFileMgr) Sync(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.record(Op{Kind: OP_KIND_SYNC, Filename: filename, Block: -1}); err != nil {
+		return err
+	}
+	m.durable[filename] = cloneBlocks(m.files[filename])
+	return nil
+}
+
+func (m *FaultyFileMgr) Append(filename string) (file.BlockId, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blockNum := len(m.files[filename])
+	if err := m.record(Op{Kind: OP_KIND_APPEND, Filename: filename, Block: blockNum}); err != nil {
+		return nil, err
+	}
+	m.files[filename] = append(m.files[filename], make([]byte, m.blockSize))
+	return file.NewBlockId(filename, blockNum), nil
+}
+
+func (m *FaultyFileMgr) BlockNum(filename string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.files[filename]), nil
+}
+
+func (m *FaultyFileMgr) BlockSize() int {
+	return m.blockSize
+}
+
+func (m *FaultyFileMgr) IsNew() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.isNew
+}
+
+// Files returns the names of the files in sorted order.
+func (m *FaultyFileMgr) Files() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	files := make([]string, 0, len(m.files))
+	for filename := range m.files {
+		files = append(files, filename)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Remove deletes the file. The removal is durable at once.
+func (m *FaultyFileMgr) Remove(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.record(Op{Kind: OP_KIND_REMOVE, Filename: filename, Block: -1}); err != nil {
+		return err
+	}
+	delete(m.files, filename)
+	delete(m.durable, filename)
+	return nil
+}
+
+// Truncate shortens the file to its first blocks. The truncation is durable at once.
+func (m *FaultyFileMgr) Truncate(filename string, blocks int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.record(Op{Kind: OP_KIND_TRUNCATE, Filename: filename, Block: blocks}); err != nil {
+		return err
+	}
+	if len(m.files[filename]) > blocks {
+		m.files[filename] = m.files[filename][:blocks]
+	}
+	if len(m.durable[filename]) > blocks {
+		m.durable[filename] = m.durable[filename][:blocks]
+	}
+	return nil
+}
+
+// InjectFault makes the following operations fail as decided by f. A nil f removes the fault.
+func (m *FaultyFileMgr) InjectFault(f FaultFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fault = f
+}
+
+// Ops returns the operations made so far, including the failed ones.
+func (m *FaultyFileMgr) Ops() []Op {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Op(nil), m.ops...)
+}
+
+// ResetOps forgets the recorded operations.
+func (m *FaultyFileMgr) ResetOps() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ops = nil
+}
+
+// Crash throws away everything written since the last sync of each file, as a power failure would.
+// The file manager can then be used to restart the database.
+func (m *FaultyFileMgr) Crash() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files = make(map[string][][]byte, len(m.durable))
+	for filename, blocks := range m.durable {
+		m.files[filename] = cloneBlocks(blocks)
+	}
+	m.isNew = false
+	m.fault = nil
+}
+
+// DurableBlock returns the contents of the block that would survive a crash, or nil if there is none.
+func (m *FaultyFileMgr) DurableBlock(id file.BlockId) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blocks := m.durable[id.Filename()]
+	if id.Number() >= len(blocks) {
+		return nil
+	}
+	return append([]byte(nil), blocks[id.Number()]...)
+}
+
+func (m *FaultyFileMgr) record(op Op) error {
+	m.ops = append(m.ops, op)
+	if m.fault == nil {
+		return nil
+	}
+	return m.fault(op)
+}
+
+func cloneBlocks(blocks [][]byte) [][]byte {
+	cloned := make([][]byte, len(blocks))
+	for i, b := range blocks {
+		cloned[i] = append([]byte(nil), b...)
+	}
+	return cloned
+}
+
+// FailOn returns a fault that fails every operation of the kind on the file.
+func FailOn(kind OpKind, filename string) FaultFunc {
+	return func(op Op) error {
+		if op.Kind == kind && op.Filename == filename {
+			return fmt.Errorf("%w: %s", ErrInjected, op)
+		}
+		return nil
+	}
+}
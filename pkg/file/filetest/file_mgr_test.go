@@ -0,0 +1,97 @@
+package filetest
+
+import (
+	"testing"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultyFileMgr(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize = 16
+		filename  = "test"
+	)
+	write := func(t *testing.T, m *FaultyFileMgr, blk file.BlockId, s string) error {
+		p := file.NewPage(blockSize)
+		p.SetString(0, s)
+		return m.Write(blk, p)
+	}
+	read := func(t *testing.T, m *FaultyFileMgr, blk file.BlockId) string {
+		p := file.NewPage(blockSize)
+		assert.NoError(t, m.Read(blk, p))
+		return p.GetString(0)
+	}
+	t.Run("crash keeps only synced writes", func(t *testing.T) {
+		t.Parallel()
+		m := NewFaultyFileMgr(blockSize)
+		blk, err := m.Append(filename)
+		assert.NoError(t, err)
+		assert.NoError(t, write(t, m, blk, "first"))
+		assert.NoError(t, m.Sync(filename))
+		assert.NoError(t, write(t, m, blk, "second"))
+		other, err := m.Append(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", read(t, m, blk))
+
+		m.Crash()
+
+		assert.False(t, m.IsNew())
+		assert.Equal(t, "first", read(t, m, blk))
+		n, err := m.BlockNum(filename)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, "", read(t, m, other))
+		assert.Nil(t, m.DurableBlock(other))
+	})
+	t.Run("records operations", func(t *testing.T) {
+		t.Parallel()
+		m := NewFaultyFileMgr(blockSize)
+		blk, err := m.Append(filename)
+		assert.NoError(t, err)
+		assert.NoError(t, write(t, m, blk, "a"))
+		assert.NoError(t, m.Sync(filename))
+		assert.Equal(t, []Op{
+			{Kind: OP_KIND_APPEND, Filename: filename, Block: 0},
+			{Kind: OP_KIND_WRITE, Filename: filename, Block: 0},
+			{Kind: OP_KIND_SYNC, Filename: filename, Block: -1},
+		}, m.Ops())
+		m.ResetOps()
+		assert.Empty(t, m.Ops())
+	})
+	t.Run("injected faults", func(t *testing.T) {
+		t.Parallel()
+		m := NewFaultyFileMgr(blockSize)
+		blk, err := m.Append(filename)
+		assert.NoError(t, err)
+		m.InjectFault(FailOn(OP_KIND_SYNC, filename))
+		assert.NoError(t, write(t, m, blk, "lost"))
+		assert.ErrorIs(t, m.Sync(filename), ErrInjected)
+		assert.Nil(t, m.DurableBlock(blk))
+
+		m.InjectFault(FailOn(OP_KIND_WRITE, filename))
+		assert.ErrorIs(t, write(t, m, blk, "failed"), ErrInjected)
+		assert.Equal(t, "lost", read(t, m, blk))
+
+		m.InjectFault(nil)
+		assert.NoError(t, m.Sync(filename))
+		assert.Equal(t, "lost", read(t, m, blk))
+	})
+	t.Run("remove", func(t *testing.T) {
+		t.Parallel()
+		m := NewFaultyFileMgr(blockSize)
+		blk, err := m.Append(filename)
+		assert.NoError(t, err)
+		assert.NoError(t, write(t, m, blk, "gone"))
+		assert.NoError(t, m.Sync(filename))
+
+		assert.NoError(t, m.Remove(filename))
+		m.Crash()
+
+		files, err := m.Files()
+		assert.NoError(t, err)
+		assert.Empty(t, files)
+		assert.Nil(t, m.DurableBlock(blk))
+	})
+}
@@ -0,0 +1,64 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+FORMAT_FILE marks the database directory with the version of the format of its files, which the file manager writes
+when it finds the directory empty. Version 2 stores every block with the checksum header of checksum.go;
+the files of version 1, with bare blocks, would be read at the wrong offsets, so a directory without the marker that
+already has files is refused rather than reported as corrupt block by block.
+*/
+const (
+	FORMAT_FILE    = "simpledb.format"
+	FORMAT_VERSION = 2
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("file: unsupported database format")
+	ErrNotDatabase       = errors.New("file: not a database directory")
+	ErrReadOnly          = errors.New("file: the file manager is read-only")
+)
+
+func formatMarker() []byte {
+	return []byte(fmt.Sprintf("simpledb format %d\n", FORMAT_VERSION))
+}
+
+// checkFormat checks the format marker of the database directory, writing it if the directory is empty and the file manager is not read-only.
+func (m *FileMgrImpl) checkFormat() error {
+	path := filepath.Join(m.dbDir, FORMAT_FILE)
+	marker, err := os.ReadFile(path)
+	if err == nil {
+		if !bytes.Equal(marker, formatMarker()) {
+			return fmt.Errorf("%w: %s is marked %q, expected %q", ErrUnsupportedFormat, m.dbDir, bytes.TrimSpace(marker), bytes.TrimSpace(formatMarker()))
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("file: cannot read %s: %w", path, err)
+	}
+	if m.readOnly {
+		return fmt.Errorf("%w: %s has no %s", ErrNotDatabase, m.dbDir, FORMAT_FILE)
+	}
+	files, err := m.Files()
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		return fmt.Errorf("%w: %s has no %s; it was written by a version that stored blocks without checksum headers, which cannot be read", ErrUnsupportedFormat, m.dbDir, FORMAT_FILE)
+	}
+	if err := os.WriteFile(path, formatMarker(), 0666); err != nil {
+		return fmt.Errorf("file: cannot write %s: %w", path, err)
+	}
+	if m.syncPolicy != SYNC_POLICY_NONE {
+		if err := syncDir(m.dbDir); err != nil {
+			return fmt.Errorf("file: cannot sync directory %s: %w", m.dbDir, err)
+		}
+	}
+	return nil
+}
@@ -2,7 +2,10 @@
 //go:generate mockgen -source=./interface.go -package=mock -destination=./mock/interface.go
 package file
 
-import "bytes"
+import (
+	"bytes"
+	"time"
+)
 
 // BlockId identifies a specific block by its filename and logical block number.
 type BlockId interface {
@@ -14,21 +17,40 @@ type BlockId interface {
 
 // Page holds the contents of a disk block.
 type Page interface {
-	GetInt(offset int) uint32
+	GetInt(offset int) int32
+	GetInt64(offset int) int64
+	GetFloat64(offset int) float64
+	GetBool(offset int) bool
+	GetTime(offset int) time.Time
 	GetBytes(offset int) []byte
 	GetString(offset int) string
-	SetInt(offset int, value uint32)
+	SetInt(offset int, value int32)
+	SetInt64(offset int, value int64)
+	SetFloat64(offset int, value float64)
+	SetBool(offset int, value bool)
+	SetTime(offset int, value time.Time)
 	SetBytes(offset int, value []byte)
 	SetString(offset int, value string)
 	Contents() *bytes.Buffer
+	// LSN is written to the header of the block with the page, outside of the contents.
+	LSN() int
+	SetLSN(lsn int)
 }
 
 // FileMgr handles the actual interaction with the OS file system.
 type FileMgr interface {
 	Read(id BlockId, p Page) error
 	Write(id BlockId, p Page) error
+	// Sync forces the blocks written to the file to stable storage, as far as the sync policy requires.
+	Sync(filename string) error
 	Append(filename string) (BlockId, error)
 	BlockNum(filename string) (int, error)
 	BlockSize() int
 	IsNew() bool
+	// Files returns the names of the files managed by the file manager.
+	Files() ([]string, error)
+	// Remove deletes the file. Removing a file that does not exist is not an error.
+	Remove(filename string) error
+	// Truncate shortens the file to its first blocks. A file that has no more blocks is left as it is.
+	Truncate(filename string, blocks int) error
 }
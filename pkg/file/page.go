@@ -3,6 +3,10 @@ package file
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
 	"unicode/utf8"
 )
 
@@ -11,6 +15,7 @@ const defaultCharset = "us-ascii"
 type PageImpl struct {
 	buf     *bytes.Buffer
 	charset string
+	lsn     int
 }
 
 func NewPage(size int) *PageImpl {
@@ -27,24 +32,81 @@ func NewPageFromBytes(data []byte) *PageImpl {
 	}
 }
 
-func (p *PageImpl) GetInt(offset int) uint32 {
+// GetInt returns the signed 32-bit integer at the offset, stored big-endian in two's complement.
+func (p *PageImpl) GetInt(offset int) int32 {
 	data := p.buf.Bytes()[offset : offset+4]
-	return binary.BigEndian.Uint32(data)
+	return int32(binary.BigEndian.Uint32(data))
 }
 
-func (p *PageImpl) SetInt(offset int, value uint32) {
-	data := make([]byte, 4)
-	binary.BigEndian.PutUint32(data, uint32(value))
-	copy(p.buf.Bytes()[offset:], data)
+func (p *PageImpl) SetInt(offset int, value int32) {
+	binary.BigEndian.PutUint32(p.buf.Bytes()[offset:offset+4], uint32(value))
 }
 
+// GetInt64 returns the signed 64-bit integer at the offset, stored big-endian in two's complement.
+func (p *PageImpl) GetInt64(offset int) int64 {
+	data := p.buf.Bytes()[offset : offset+8]
+	return int64(binary.BigEndian.Uint64(data))
+}
+
+func (p *PageImpl) SetInt64(offset int, value int64) {
+	binary.BigEndian.PutUint64(p.buf.Bytes()[offset:offset+8], uint64(value))
+}
+
+// GetFloat64 returns the IEEE 754 double at the offset, stored in 8 bytes.
+func (p *PageImpl) GetFloat64(offset int) float64 {
+	return math.Float64frombits(uint64(p.GetInt64(offset)))
+}
+
+func (p *PageImpl) SetFloat64(offset int, value float64) {
+	p.SetInt64(offset, int64(math.Float64bits(value)))
+}
+
+// GetBool returns the boolean at the offset, stored in 1 byte.
+func (p *PageImpl) GetBool(offset int) bool {
+	return p.buf.Bytes()[offset] != 0
+}
+
+func (p *PageImpl) SetBool(offset int, value bool) {
+	var b byte
+	if value {
+		b = 1
+	}
+	p.buf.Bytes()[offset] = b
+}
+
+// GetTime returns the time at the offset, stored in 8 bytes as microseconds since the Unix epoch. It is in UTC.
+func (p *PageImpl) GetTime(offset int) time.Time {
+	return time.UnixMicro(p.GetInt64(offset)).UTC()
+}
+
+// SetTime stores the time with microsecond precision; finer precision is lost.
+func (p *PageImpl) SetTime(offset int, value time.Time) {
+	p.SetInt64(offset, value.UnixMicro())
+}
+
+// ErrBadLength tells that the stored length of a byte string is negative or runs past the end of its page.
+var ErrBadLength = errors.New("file: bad length of byte string")
+
+// CheckBytes checks that the byte string at the offset of a page of size bytes lies within the page, so that GetBytes can read it.
+// Lengths are signed, so a corrupt one may be negative as well as too large.
+func CheckBytes(p interface{ GetInt(offset int) int32 }, offset, size int) error {
+	if offset < 0 || offset+4 > size {
+		return fmt.Errorf("%w: offset %d out of a page of %d bytes", ErrBadLength, offset, size)
+	}
+	if length := int(p.GetInt(offset)); length < 0 || offset+4+length > size {
+		return fmt.Errorf("%w: length %d at offset %d of a page of %d bytes", ErrBadLength, length, offset, size)
+	}
+	return nil
+}
+
+// GetBytes returns the byte string at the offset. Its length must be valid, as CheckBytes checks.
 func (p *PageImpl) GetBytes(offset int) []byte {
 	length := p.GetInt(offset)
 	return p.buf.Bytes()[offset+4 : offset+4+int(length)]
 }
 
 func (p *PageImpl) SetBytes(offset int, value []byte) {
-	p.SetInt(offset, uint32(len(value)))
+	p.SetInt(offset, int32(len(value)))
 	copy(p.buf.Bytes()[offset+4:], value)
 }
 
@@ -60,6 +122,20 @@ func (p *PageImpl) Contents() *bytes.Buffer {
 	return p.buf
 }
 
+// LSN returns the LSN of the latest log record for a change in the page, as stored in the block header.
+func (p *PageImpl) LSN() int {
+	return p.lsn
+}
+
+func (p *PageImpl) SetLSN(lsn int) {
+	p.lsn = lsn
+}
+
+// MaxBytesLength returns the number of bytes needed to store at most n bytes with SetBytes.
+func MaxBytesLength(n int) int {
+	return 4 + n
+}
+
 func MaxLength(strLen int) int {
 	bytesPerChar := utf8.UTFMax
 	return 4 + strLen*bytesPerChar
@@ -1,7 +1,9 @@
 package file
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -26,11 +28,22 @@ func TestNewPageFromBytes(t *testing.T) {
 func TestPageInt(t *testing.T) {
 	page := NewPage(4096)
 	offset := 0
-	value := uint32(42)
+	value := int32(42)
 	page.SetInt(offset, value)
 	assert.Equal(t, value, page.GetInt(offset))
 }
 
+func TestPageSignedInt(t *testing.T) {
+	page := NewPage(4096)
+	page.SetInt(0, -1)
+	page.SetInt(4, math.MinInt32)
+	page.SetInt64(8, math.MinInt64)
+	assert.Equal(t, int32(-1), page.GetInt(0))
+	assert.Equal(t, int32(math.MinInt32), page.GetInt(4))
+	assert.Equal(t, int64(math.MinInt64), page.GetInt64(8))
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0x80, 0, 0, 0}, page.Contents().Bytes()[:8])
+}
+
 func TestPageBytes(t *testing.T) {
 	page := NewPage(4096)
 	offset := 0
@@ -39,6 +52,18 @@ func TestPageBytes(t *testing.T) {
 	assert.Equal(t, value, page.GetBytes(offset))
 }
 
+func TestCheckBytes(t *testing.T) {
+	page := NewPage(64)
+	page.SetBytes(0, []byte("hello"))
+	page.SetInt(16, -8)
+	page.SetInt(32, 64)
+	assert.NoError(t, CheckBytes(page, 0, 64))
+	assert.ErrorIs(t, CheckBytes(page, 16, 64), ErrBadLength)
+	assert.ErrorIs(t, CheckBytes(page, 32, 64), ErrBadLength)
+	assert.ErrorIs(t, CheckBytes(page, 62, 64), ErrBadLength)
+	assert.ErrorIs(t, CheckBytes(page, -4, 64), ErrBadLength)
+}
+
 func TestPageString(t *testing.T) {
 	page := NewPage(4096)
 	offset := 0
@@ -47,6 +72,23 @@ func TestPageString(t *testing.T) {
 	assert.Equal(t, value, page.GetString(offset))
 }
 
+func TestPageFixedSizeValues(t *testing.T) {
+	page := NewPage(4096)
+	page.SetInt64(0, 1<<40+7)
+	page.SetFloat64(8, -2.5)
+	page.SetBool(16, true)
+	page.SetBool(17, false)
+	ts := time.Date(2024, 2, 29, 13, 4, 5, 123456789, time.UTC)
+	page.SetTime(20, ts)
+	assert.Equal(t, int64(1<<40+7), page.GetInt64(0))
+	assert.Equal(t, -2.5, page.GetFloat64(8))
+	assert.True(t, page.GetBool(16))
+	assert.False(t, page.GetBool(17))
+	// the time keeps microseconds only
+	assert.Equal(t, ts.Truncate(time.Microsecond), page.GetTime(20))
+	assert.Equal(t, 4, MaxBytesLength(0))
+}
+
 func TestMaxLength(t *testing.T) {
 	assert.Equal(t, 4*1024+4, MaxLength(1024))
 }
@@ -0,0 +1,100 @@
+package file
+
+import (
+	"fmt"
+	"os"
+)
+
+// SyncPolicy decides when the file manager forces written blocks to stable storage.
+type SyncPolicy int
+
+const (
+	// SYNC_POLICY_ALWAYS syncs the file after every Write and Append.
+	SYNC_POLICY_ALWAYS SyncPolicy = iota + 1
+	// SYNC_POLICY_ON_COMMIT syncs only on explicit Sync calls, which the log and buffer managers make when a transaction ends.
+	SYNC_POLICY_ON_COMMIT
+	// SYNC_POLICY_NONE never syncs. Committed transactions can be lost on power failure, so it is only meant for tests.
+	SYNC_POLICY_NONE
+)
+
+const DEFAULT_SYNC_POLICY = SYNC_POLICY_ON_COMMIT
+
+func (p SyncPolicy) String() string {
+	switch p {
+	case SYNC_POLICY_ALWAYS:
+		return "always"
+	case SYNC_POLICY_ON_COMMIT:
+		return "on-commit"
+	case SYNC_POLICY_NONE:
+		return "none"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(p))
+	}
+}
+
+// ParseSyncPolicy returns the policy named "always", "on-commit" or "none".
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	for _, p := range []SyncPolicy{SYNC_POLICY_ALWAYS, SYNC_POLICY_ON_COMMIT, SYNC_POLICY_NONE} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("file: unknown sync policy %q", s)
+}
+
+// SyncMethod is how a file is synced.
+type SyncMethod int
+
+const (
+	// SYNC_METHOD_FSYNC flushes the data and the metadata of the file with fsync.
+	SYNC_METHOD_FSYNC SyncMethod = iota + 1
+	// SYNC_METHOD_FDATASYNC flushes the data and only the metadata needed to read it back, such as the file size.
+	// It falls back to fsync where fdatasync is not available.
+	SYNC_METHOD_FDATASYNC
+	// SYNC_METHOD_DSYNC opens the files with O_DSYNC so that every write reaches stable storage before returning.
+	SYNC_METHOD_DSYNC
+)
+
+const DEFAULT_SYNC_METHOD = SYNC_METHOD_FSYNC
+
+func (m SyncMethod) String() string {
+	switch m {
+	case SYNC_METHOD_FSYNC:
+		return "fsync"
+	case SYNC_METHOD_FDATASYNC:
+		return "fdatasync"
+	case SYNC_METHOD_DSYNC:
+		return "dsync"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(m))
+	}
+}
+
+func (m SyncMethod) openFlag() int {
+	if m == SYNC_METHOD_DSYNC {
+		return dsyncFlag
+	}
+	return 0
+}
+
+func (m SyncMethod) sync(f *os.File) error {
+	switch m {
+	case SYNC_METHOD_DSYNC:
+		// the writes are already synchronous
+		return nil
+	case SYNC_METHOD_FDATASYNC:
+		return fdatasync(f)
+	default:
+		return f.Sync()
+	}
+}
+
+// syncDir makes the entries of the directory, e.g. a newly created file, durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,14 @@
+//go:build linux
+
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+const dsyncFlag = syscall.O_DSYNC
+
+func fdatasync(f *os.File) error {
+	return syscall.Fdatasync(int(f.Fd()))
+}
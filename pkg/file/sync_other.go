@@ -0,0 +1,12 @@
+//go:build !linux
+
+package file
+
+import "os"
+
+// O_SYNC is the portable flag closest to O_DSYNC.
+const dsyncFlag = os.O_SYNC
+
+func fdatasync(f *os.File) error {
+	return f.Sync()
+}
@@ -0,0 +1,35 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Verify reads every block of every file managed by fm and returns the blocks that fail the verification.
+// The error is for the failures other than corrupt pages, such as a file that cannot be opened.
+func Verify(fm FileMgr) ([]*CorruptPageError, error) {
+	files, err := fm.Files()
+	if err != nil {
+		return nil, fmt.Errorf("file: cannot list files: %w", err)
+	}
+	var corrupted []*CorruptPageError
+	p := NewPage(fm.BlockSize())
+	for _, filename := range files {
+		n, err := fm.BlockNum(filename)
+		if err != nil {
+			return nil, fmt.Errorf("file: cannot get size of %s: %w", filename, err)
+		}
+		for i := 0; i < n; i++ {
+			err := fm.Read(NewBlockId(filename, i), p)
+			var cpe *CorruptPageError
+			if errors.As(err, &cpe) {
+				corrupted = append(corrupted, cpe)
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("file: cannot read block %d of %s: %w", i, filename, err)
+			}
+		}
+	}
+	return corrupted, nil
+}
@@ -0,0 +1,63 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
+
+var errNoMoreRecords = errors.New("log: no more records")
+
+// ForwardLogIteratorImpl reads the log records from the oldest to the newest, for redo, replication or auditing.
+// The records of a block are written from right to left, so it reads a whole block at a time.
+type ForwardLogIteratorImpl struct {
+	fm        file.FileMgr
+	layout    segmentLayout
+	page      file.Page
+	blockNum  int // logical number of the block
+	lastBlock int
+	entries   []logEntry // records of the block in LSN order
+	pos       int
+	lsn       int
+	err       error
+}
+
+// HasNext returns true if there are more records to read.
+func (fi *ForwardLogIteratorImpl) HasNext() bool {
+	for fi.err == nil && fi.pos >= len(fi.entries) && fi.blockNum < fi.lastBlock {
+		fi.err = fi.moveToBlock(fi.blockNum + 1)
+	}
+	return fi.err != nil || fi.pos < len(fi.entries)
+}
+
+// Next returns the next record from the oldest to the newest.
+func (fi *ForwardLogIteratorImpl) Next() ([]byte, error) {
+	if !fi.HasNext() {
+		return nil, errNoMoreRecords
+	}
+	if fi.err != nil {
+		return nil, fi.err
+	}
+	entry := fi.entries[fi.pos]
+	fi.pos++
+	fi.lsn = entry.lsn
+	return entry.record, nil
+}
+
+func (fi *ForwardLogIteratorImpl) LSN() int {
+	return fi.lsn
+}
+
+func (fi *ForwardLogIteratorImpl) moveToBlock(n int) error {
+	block := fi.layout.block(n)
+	if err := fi.fm.Read(block, fi.page); err != nil {
+		return fmt.Errorf("log: cannot read block %s: %w", block, err)
+	}
+	entries, err := readEntries(fi.page, fi.fm.BlockSize())
+	if err != nil {
+		return fmt.Errorf("log: block %s: %w", block, err)
+	}
+	fi.blockNum, fi.entries, fi.pos = n, entries, 0
+	return nil
+}
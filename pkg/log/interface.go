@@ -6,9 +6,15 @@ type LogMgr interface {
 	Append(record []byte) (lsn int, err error)
 	Flush(lsn int) error
 	Iterator() (LogIterator, error)
+	ForwardIterator(lsn int) (LogIterator, error)
+	ReadAt(lsn int) ([]byte, error)
+	// Truncate removes the parts of the log that only hold records older than lsn.
+	Truncate(lsn int) error
 }
 
 type LogIterator interface {
 	HasNext() bool
 	Next() ([]byte, error)
+	// LSN returns the LSN of the record returned by the last call to Next.
+	LSN() int
 }
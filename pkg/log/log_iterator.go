@@ -7,53 +7,89 @@ import (
 )
 
 type LogIteratorImpl struct {
-	fm        file.FileMgr
-	block     file.BlockId
-	page      file.Page
-	curOffset int
+	fm         file.FileMgr
+	layout     segmentLayout
+	block      file.BlockId
+	blockNum   int // logical number of the block
+	firstBlock int
+	page       file.Page
+	curOffset  int
+	lsn        int
 }
 
+// NewLogIterator returns an iterator over a log kept in a single file, starting at the block.
 func NewLogIterator(fm file.FileMgr, block file.BlockId) (*LogIteratorImpl, error) {
+	return newLogIterator(fm, segmentLayout{filename: block.Filename()}, block.Number(), 0)
+}
+
+// OpenLogIterator returns an iterator from the newest record of a log that is not in use, e.g. for inspection.
+// segmentBlocks is the size of the segments of the log, or 0 if it is kept in a single file.
+func OpenLogIterator(fm file.FileMgr, filename string, segmentBlocks int) (*LogIteratorImpl, error) {
+	layout := segmentLayout{filename: filename, segmentBlocks: segmentBlocks}
+	first, last, err := layout.blocks(fm)
+	if err != nil {
+		return nil, fmt.Errorf("log: cannot get length of log %s: %w", filename, err)
+	}
+	if last < first {
+		return nil, fmt.Errorf("log: log %s is empty", filename)
+	}
+	return newLogIterator(fm, layout, last, first)
+}
+
+func newLogIterator(fm file.FileMgr, layout segmentLayout, blockNum, firstBlock int) (*LogIteratorImpl, error) {
 	page := file.NewPage(fm.BlockSize())
 	iter := &LogIteratorImpl{
-		fm:    fm,
-		block: block,
-		page:  page,
+		fm:         fm,
+		layout:     layout,
+		firstBlock: firstBlock,
+		page:       page,
 	}
-	err := iter.moveToBlock(block)
+	err := iter.moveToBlock(blockNum)
 	if err != nil {
-		return nil, fmt.Errorf("log: cannot move to block %s: %w", block, err)
+		return nil, fmt.Errorf("log: cannot move to block %s: %w", layout.block(blockNum), err)
 	}
 	return iter, nil
 }
 
 // HasNext returns true if there are more records to read.
 func (li *LogIteratorImpl) HasNext() bool {
-	return li.curOffset < li.fm.BlockSize() || li.block.Number() > 0
+	return li.curOffset < li.fm.BlockSize() || li.blockNum > li.firstBlock
 }
 
 // Next returns the next record from left to right(latest to oldest).
+// It returns an error matching ErrCorruptRecord if the checksum of the record does not match.
 func (li *LogIteratorImpl) Next() ([]byte, error) {
 	finished := li.curOffset == li.fm.BlockSize()
 	if finished {
-		blockId := file.NewBlockId(li.block.Filename(), li.block.Number()-1)
-		li.block = blockId
-		err := li.moveToBlock(blockId)
-		if err != nil {
-			return nil, fmt.Errorf("log: cannot move to block %s: %w", blockId, err)
+		if err := li.moveToBlock(li.blockNum - 1); err != nil {
+			return nil, fmt.Errorf("log: cannot move to block %s: %w", li.layout.block(li.blockNum-1), err)
 		}
 	}
-	record := li.page.GetBytes(li.curOffset)
-	const bytesLen = 4
-	li.curOffset += bytesLen + len(record)
+	frame, next, err := readFrame(li.page, li.curOffset, li.fm.BlockSize())
+	if err != nil {
+		return nil, fmt.Errorf("log: block %s: %w", li.block, err)
+	}
+	lsn, record, err := decodeRecord(frame)
+	if err != nil {
+		return nil, fmt.Errorf("log: block %s, offset %d: %w", li.block, li.curOffset, err)
+	}
+	li.curOffset = next
+	li.lsn = lsn
 	return record, nil
 }
 
-func (li *LogIteratorImpl) moveToBlock(block file.BlockId) error {
+// LSN returns the LSN of the record returned by the last call to Next.
+func (li *LogIteratorImpl) LSN() int {
+	return li.lsn
+}
+
+func (li *LogIteratorImpl) moveToBlock(n int) error {
+	block := li.layout.block(n)
 	err := li.fm.Read(block, li.page)
 	if err != nil {
 		return err
 	}
+	li.block, li.blockNum = block, n
 	li.curOffset = int(li.page.GetInt(0))
 	return nil
 }
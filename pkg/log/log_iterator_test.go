@@ -71,8 +71,8 @@ func TestLogIterator_Next(t *testing.T) {
 	t.Run("not finished", func(t *testing.T) {
 		t.Parallel()
 		const (
-			blockSize = 14
 			record    = "record"
+			blockSize = OFFSET_SIZE + OFFSET_SIZE + RECORD_HEADER_SIZE + len(record)
 			filename  = "file"
 		)
 		dir, cleanup := testutil.SetupDir("test_log_iterator_next_not_finished")
@@ -82,7 +82,7 @@ func TestLogIterator_Next(t *testing.T) {
 		page := file.NewPage(blockSize)
 		// setup record in the page
 		page.SetInt(0, 4)
-		page.SetBytes(4, []byte(record))
+		page.SetBytes(4, encodeRecord(1, []byte(record)))
 		fileMgr.Write(block, page)
 
 		li, err := NewLogIterator(fileMgr, block)
@@ -97,8 +97,7 @@ func TestLogIterator_Next(t *testing.T) {
 	t.Run("block finished", func(t *testing.T) {
 		t.Parallel()
 		const (
-			blockSize = 12
-			record    = "record"
+			blockSize = OFFSET_SIZE + OFFSET_SIZE + RECORD_HEADER_SIZE
 			filename  = "file"
 		)
 		dir, cleanup := testutil.SetupDir("test_log_iterator_next_block_finished")
@@ -107,6 +106,7 @@ func TestLogIterator_Next(t *testing.T) {
 		block0 := file.NewBlockId(filename, 0)
 		page0 := file.NewPage(blockSize)
 		page0.SetInt(0, 4) // second record
+		page0.SetBytes(4, encodeRecord(1, nil))
 		fileMgr.Write(block0, page0)
 
 		block1 := file.NewBlockId(filename, 1)
@@ -122,6 +122,65 @@ func TestLogIterator_Next(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.Equal(t, block0, li.block)
-		assert.Equal(t, 4+4, li.curOffset) // first record
+		assert.Equal(t, blockSize, li.curOffset) // first record
 	})
 }
+
+func TestLogIterator_Corrupt(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize = 64
+		filename  = "file"
+	)
+	tests := []struct {
+		name    string
+		corrupt func(p *file.PageImpl, offset int)
+	}{
+		{
+			name: "bit flip",
+			corrupt: func(p *file.PageImpl, offset int) {
+				p.Contents().Bytes()[offset+OFFSET_SIZE+RECORD_HEADER_SIZE] ^= 0x01
+			},
+		},
+		{
+			name: "unknown version",
+			corrupt: func(p *file.PageImpl, offset int) {
+				p.Contents().Bytes()[offset+OFFSET_SIZE+offsetRecordVersion] = RECORD_VERSION + 1
+			},
+		},
+		{
+			name: "length out of block",
+			corrupt: func(p *file.PageImpl, offset int) {
+				p.SetInt(offset, blockSize)
+			},
+		},
+		{
+			name: "negative length",
+			corrupt: func(p *file.PageImpl, offset int) {
+				p.SetInt(offset, -8)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir, cleanup := testutil.SetupDir("test_log_iterator_corrupt_" + tt.name)
+			t.Cleanup(cleanup)
+			fileMgr := file.NewFileMgr(dir, blockSize)
+			block := file.NewBlockId(filename, 0)
+			page := file.NewPage(blockSize)
+			frame := encodeRecord(1, []byte("record"))
+			offset := blockSize - OFFSET_SIZE - len(frame)
+			page.SetInt(0, int32(offset))
+			page.SetBytes(offset, frame)
+			tt.corrupt(page, offset)
+			assert.NoError(t, fileMgr.Write(block, page))
+
+			li, err := NewLogIterator(fileMgr, block)
+			assert.NoError(t, err)
+			_, err = li.Next()
+
+			assert.ErrorIs(t, err, ErrCorruptRecord)
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package log
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/file"
 )
@@ -24,104 +27,463 @@ import (
 	-------------------
 
 ````
+
+The log is kept in a single file, or split into segment files of a fixed number of blocks, see WithSegmentBlocks.
+
+Each record is framed with a checksum, see encodeRecord.
 */
 type LogMgrImpl struct {
 	filename     string
 	fileMgr      file.FileMgr
 	page         file.Page
 	currentBlock file.BlockId
-	latestLSN    int // LSN: log sequence number
+	layout       segmentLayout
+	blockNum     int // logical number of the current block
+	firstBlock   int // logical number of the oldest block that has not been removed
+	latestLSN    int // LSN: log sequence number, stored with each record so that it keeps growing across restarts
 	lastSavedLSN int
 	mu           sync.Mutex
+
+	// segments
+	archive         ArchiveFunc
+	archivedSegment int // the segments up to this one have been archived
+
+	// group commit
+	cond          *sync.Cond
+	flushing      bool
+	writing       bool      // the leader of a batch is writing flushPage without holding mu
+	flushPage     file.Page // the copy of the current page that the leader writes
+	flushWaiters  int       // committers waiting for a write, reset when a write starts
+	groupMaxDelay time.Duration
+	groupMaxBatch int
 }
 
 // First 4 bytes of a block is the offset where the last record starts.
 const OFFSET_SIZE = 4
 
-func NewLogMgr(fm file.FileMgr, filename string) (*LogMgrImpl, error) {
+var ErrLSNNotFound = errors.New("log: LSN not found")
+
+const (
+	// DEFAULT_GROUP_COMMIT_MAX_DELAY is 0 so that a flush never waits for other committers on purpose.
+	// Committers arriving while a flush is in progress are still covered by the next single write.
+	DEFAULT_GROUP_COMMIT_MAX_DELAY = 0
+	DEFAULT_GROUP_COMMIT_MAX_BATCH = 32
+)
+
+type LogMgrOption func(*LogMgrImpl)
+
+// WithGroupCommitMaxDelay sets how long a flush waits for other committers to join its batch.
+func WithGroupCommitMaxDelay(d time.Duration) LogMgrOption {
+	return func(lm *LogMgrImpl) {
+		lm.groupMaxDelay = d
+	}
+}
+
+// WithGroupCommitMaxBatch sets the number of waiting committers that makes a flush start without waiting the max delay.
+func WithGroupCommitMaxBatch(n int) LogMgrOption {
+	return func(lm *LogMgrImpl) {
+		lm.groupMaxBatch = n
+	}
+}
+
+// WithSegmentBlocks splits the log into segment files of n blocks, named by SegmentName.
+// When a segment is full, the log continues in the next one.
+func WithSegmentBlocks(n int) LogMgrOption {
+	return func(lm *LogMgrImpl) {
+		lm.layout.segmentBlocks = n
+	}
+}
+
+// WithArchive sets the function called with each completed segment, see ArchiveToDir.
+func WithArchive(f ArchiveFunc) LogMgrOption {
+	return func(lm *LogMgrImpl) {
+		lm.archive = f
+	}
+}
+
+func NewLogMgr(fm file.FileMgr, filename string, opts ...LogMgrOption) (*LogMgrImpl, error) {
 	page := file.NewPage(fm.BlockSize())
 	lm := &LogMgrImpl{
-		fileMgr:  fm,
-		filename: filename,
-		page:     page,
+		fileMgr:       fm,
+		filename:      filename,
+		page:          page,
+		flushPage:     file.NewPage(fm.BlockSize()),
+		layout:        segmentLayout{filename: filename},
+		groupMaxDelay: DEFAULT_GROUP_COMMIT_MAX_DELAY,
+		groupMaxBatch: DEFAULT_GROUP_COMMIT_MAX_BATCH,
+	}
+	lm.cond = sync.NewCond(&lm.mu)
+	for _, opt := range opts {
+		opt(lm)
 	}
-	blockNum, err := fm.BlockNum(filename)
+	first, last, err := lm.layout.blocks(fm)
 	if err != nil {
-		return nil, fmt.Errorf("log: cannot get length of file %s: %w", filename, err)
+		return nil, fmt.Errorf("log: cannot get length of log %s: %w", filename, err)
 	}
-	if blockNum == 0 {
-		lm.currentBlock, err = lm.appendNewBlock()
-		if err != nil {
+	lm.firstBlock, lm.blockNum = first, last
+	// the segments that exist at startup may not have been archived before a crash, archiving is idempotent
+	lm.archivedSegment = lm.layout.segment(first) - 1
+	if last < first {
+		if err := lm.appendNewBlock(); err != nil {
 			return nil, fmt.Errorf("log: cannot append new block: %w", err)
 		}
 		return lm, nil
 	}
-	lm.currentBlock = file.NewBlockId(filename, blockNum-1)
+	lm.currentBlock = lm.layout.block(last)
 	if err = fm.Read(lm.currentBlock, lm.page); err != nil {
 		return nil, fmt.Errorf("log: cannot read block %s: %w", lm.currentBlock, err)
 	}
+	if err := lm.truncateTornTail(); err != nil {
+		return nil, fmt.Errorf("log: cannot truncate torn records: %w", err)
+	}
+	if lm.latestLSN, err = lm.findLatestLSN(); err != nil {
+		return nil, fmt.Errorf("log: cannot find latest LSN: %w", err)
+	}
+	lm.lastSavedLSN = lm.latestLSN
+	if err := lm.archivePending(); err != nil {
+		return nil, err
+	}
 	return lm, nil
 }
 
+// findLatestLSN returns the LSN of the newest record in the log, or 0 if the log is empty.
+// Only the last block can be empty, after a crash right after it was appended.
+func (lm *LogMgrImpl) findLatestLSN() (int, error) {
+	for n := lm.blockNum; n >= lm.firstBlock; n-- {
+		entries, err := lm.readBlock(n)
+		if err != nil {
+			return 0, err
+		}
+		if len(entries) > 0 {
+			return entries[len(entries)-1].lsn, nil
+		}
+	}
+	return 0, nil
+}
+
+/*
+truncateTornTail drops the records of the last block that a crash left partially written, so that the log ends
+at the last intact record. The records of a block are written from right to left, so the torn ones are the newest,
+at the left of the block, and everything at the left of a corrupt record is dropped with it.
+If the records of the block cannot be delimited, the whole block is dropped.
+*/
+func (lm *LogMgrImpl) truncateTornTail() error {
+	blockSize := lm.fileMgr.BlockSize()
+	offset := lm.getLastOffset()
+	keep := offset
+	for pos := offset; pos < blockSize; {
+		frame, next, err := readFrame(lm.page, pos, blockSize)
+		if err != nil {
+			keep = blockSize
+			break
+		}
+		if _, _, err := decodeRecord(frame); err != nil {
+			keep = next
+		}
+		pos = next
+	}
+	if keep == offset {
+		return nil
+	}
+	lm.setLastOffset(keep)
+	if err := lm.fileMgr.Write(lm.currentBlock, lm.page); err != nil {
+		return err
+	}
+	return lm.fileMgr.Sync(lm.currentBlock.Filename())
+}
+
 // Append appends a record to the log backwardly and returns the LSN of the record.
 func (lm *LogMgrImpl) Append(record []byte) (lsn int, err error) {
 	lm.mu.Lock()
 	defer lm.mu.Unlock()
-	bytesNeeded := len(record) + OFFSET_SIZE
-	if lm.hasInsufficientSpace(bytesNeeded) {
+	frame := encodeRecord(lm.latestLSN+1, record)
+	bytesNeeded := len(frame) + OFFSET_SIZE
+	if bytesNeeded > lm.fileMgr.BlockSize()-OFFSET_SIZE {
+		return -1, fmt.Errorf("log: record of %d bytes does not fit in a block", len(record))
+	}
+	for lm.hasInsufficientSpace(bytesNeeded) {
+		// the block being written by the leader of a batch must not be written again until it is done
+		if lm.writing {
+			lm.cond.Wait()
+			continue
+		}
 		if err := lm.flush(); err != nil {
 			return -1, fmt.Errorf("log: cannot flush log: %w", err)
 		}
-		var err error
-		lm.currentBlock, err = lm.appendNewBlock()
-		if err != nil {
+		if err := lm.appendNewBlock(); err != nil {
 			return -1, fmt.Errorf("log: cannot append new block: %w", err)
 		}
 	}
+	// a failed archive is retried with the next record
+	if err := lm.archivePending(); err != nil {
+		return -1, err
+	}
 	offset := lm.getLastOffset() - bytesNeeded
-	lm.setBytes(offset, record)
+	lm.setBytes(offset, frame)
 	lm.latestLSN++
 	return lm.latestLSN, nil
 }
 
-// Flush flushes the log to disk.
+/*
+Flush makes sure that the log records up to lsn are written to disk.
+
+Concurrent flushes are grouped: the first caller becomes the leader of a batch and
+waits up to the max delay, or until the batch is full, for other callers to join.
+Then a single write and sync covers the records of the whole batch, and the callers whose LSN
+was covered return without writing. The others wait for the next leader.
+The leader writes a copy of the page without holding the mutex, so Append does not wait for the sync.
+*/
 func (lm *LogMgrImpl) Flush(lsn int) error {
-	if lsn < lm.lastSavedLSN {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lsn <= lm.lastSavedLSN {
 		return nil
 	}
+	lm.flushWaiters++
+	lm.cond.Broadcast()
+	for lm.lastSavedLSN < lsn {
+		if lm.flushing {
+			lm.cond.Wait()
+			continue
+		}
+		if err := lm.leadFlush(); err != nil {
+			return err
+		}
+		// the lsn has not been appended yet, the log is written as far as it exists
+		if lsn > lm.latestLSN {
+			return nil
+		}
+	}
+	return nil
+}
+
+/*
+leadFlush waits for other committers to join the batch and writes the log page for all of them.
+It copies the page under the mutex and releases it for the write and the sync, then takes it again to publish the saved LSN.
+The records appended in the meantime are left for the next flush.
+*/
+func (lm *LogMgrImpl) leadFlush() error {
+	lm.flushing = true
+	defer func() {
+		lm.flushing = false
+		lm.cond.Broadcast()
+	}()
+	if lm.groupMaxDelay > 0 {
+		deadline := time.Now().Add(lm.groupMaxDelay)
+		timer := time.AfterFunc(lm.groupMaxDelay, lm.cond.Broadcast)
+		defer timer.Stop()
+		for lm.flushWaiters < lm.groupMaxBatch && time.Now().Before(deadline) {
+			lm.cond.Wait()
+		}
+	}
+	copy(lm.flushPage.Contents().Bytes(), lm.page.Contents().Bytes())
+	block, lsn := lm.currentBlock, lm.latestLSN
+	// every waiting committer appended its record before this copy
+	lm.flushWaiters = 0
+	lm.writing = true
+	lm.mu.Unlock()
+	err := lm.writePage(block, lm.flushPage)
 	lm.mu.Lock()
-	defer lm.mu.Unlock()
-	return lm.flush()
+	lm.writing = false
+	if err != nil {
+		return err
+	}
+	lm.lastSavedLSN = lsn
+	return nil
 }
 
+// flush writes and syncs the current page while holding the mutex, after the leader of a batch is done writing.
 func (lm *LogMgrImpl) flush() error {
-	err := lm.fileMgr.Write(lm.currentBlock, lm.page)
-	if err != nil {
+	for lm.writing {
+		lm.cond.Wait()
+	}
+	if err := lm.writePage(lm.currentBlock, lm.page); err != nil {
 		return err
 	}
 	lm.lastSavedLSN = lm.latestLSN
+	// every waiting committer appended its record before this write
+	lm.flushWaiters = 0
+	lm.cond.Broadcast()
 	return nil
 }
 
+func (lm *LogMgrImpl) writePage(block file.BlockId, page file.Page) error {
+	if err := lm.fileMgr.Write(block, page); err != nil {
+		return err
+	}
+	return lm.fileMgr.Sync(block.Filename())
+}
+
 func (lm *LogMgrImpl) Iterator() (LogIterator, error) {
 	lm.mu.Lock()
 	defer lm.mu.Unlock()
 	if err := lm.flush(); err != nil {
 		return nil, fmt.Errorf("log: cannot flush log: %w", err)
 	}
-	return NewLogIterator(lm.fileMgr, lm.currentBlock)
+	return newLogIterator(lm.fileMgr, lm.layout, lm.blockNum, lm.firstBlock)
 }
 
-func (lm *LogMgrImpl) appendNewBlock() (file.BlockId, error) {
-	block, err := lm.fileMgr.Append(lm.filename)
+// ForwardIterator returns an iterator over the records from the oldest to the newest, starting at the first record
+// whose LSN is lsn or greater. The records appended after the call may not be returned.
+func (lm *LogMgrImpl) ForwardIterator(lsn int) (LogIterator, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if err := lm.flush(); err != nil {
+		return nil, fmt.Errorf("log: cannot flush log: %w", err)
+	}
+	iter := &ForwardLogIteratorImpl{
+		fm:        lm.fileMgr,
+		layout:    lm.layout,
+		page:      file.NewPage(lm.fileMgr.BlockSize()),
+		blockNum:  lm.blockNum,
+		lastBlock: lm.blockNum,
+	}
+	if lsn > lm.latestLSN {
+		return iter, nil
+	}
+	blockNum, entries, idx, err := lm.seek(lsn)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("log: cannot seek to LSN %d: %w", lsn, err)
 	}
-	if err = lm.fileMgr.Write(block, lm.page); err != nil {
+	iter.blockNum, iter.entries, iter.pos = blockNum, entries, idx
+	return iter, nil
+}
+
+// ReadAt returns the record whose LSN is lsn. It returns ErrLSNNotFound if the log has no such record.
+func (lm *LogMgrImpl) ReadAt(lsn int) ([]byte, error) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if lsn <= 0 || lsn > lm.latestLSN {
+		return nil, fmt.Errorf("%w: %d", ErrLSNNotFound, lsn)
+	}
+	_, entries, idx, err := lm.seek(lsn)
+	if err != nil {
+		return nil, fmt.Errorf("log: cannot seek to LSN %d: %w", lsn, err)
+	}
+	if idx >= len(entries) || entries[idx].lsn != lsn {
+		return nil, fmt.Errorf("%w: %d", ErrLSNNotFound, lsn)
+	}
+	return entries[idx].record, nil
+}
+
+/*
+seek finds the first record whose LSN is lsn or greater, which must not be greater than the latest LSN.
+It returns the block of the record, the records of the block in LSN order and the index of the record among them.
+
+The LSNs grow from block to block, so it binary searches the first block whose newest record is lsn or greater.
+An empty block can only be the last one and is treated as newer than everything.
+*/
+func (lm *LogMgrImpl) seek(lsn int) (int, []logEntry, int, error) {
+	var searchErr error
+	blockNum := lm.firstBlock + sort.Search(lm.blockNum-lm.firstBlock+1, func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		entries, err := lm.readBlock(lm.firstBlock + i)
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return len(entries) == 0 || entries[len(entries)-1].lsn >= lsn
+	})
+	if searchErr != nil {
+		return 0, nil, 0, searchErr
+	}
+	entries, err := lm.readBlock(blockNum)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	idx := sort.Search(len(entries), func(i int) bool {
+		return entries[i].lsn >= lsn
+	})
+	return blockNum, entries, idx, nil
+}
+
+// readBlock returns the records of the block in LSN order. The records of the current block are read from memory,
+// so that they do not need to be flushed.
+func (lm *LogMgrImpl) readBlock(n int) ([]logEntry, error) {
+	if n == lm.blockNum {
+		return readEntries(lm.page, lm.fileMgr.BlockSize())
+	}
+	page := file.NewPage(lm.fileMgr.BlockSize())
+	block := lm.layout.block(n)
+	if err := lm.fileMgr.Read(block, page); err != nil {
 		return nil, err
 	}
+	entries, err := readEntries(page, lm.fileMgr.BlockSize())
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %w", block, err)
+	}
+	return entries, nil
+}
+
+// appendNewBlock makes a new block the current one. It starts a new segment when the current one is full.
+func (lm *LogMgrImpl) appendNewBlock() error {
+	next := lm.blockNum + 1
+	block, err := lm.fileMgr.Append(lm.layout.file(lm.layout.segment(next)))
+	if err != nil {
+		return err
+	}
+	if !lm.layout.segmented() {
+		// a log kept in a single file continues at the end of the file
+		next = block.Number()
+	} else if !block.Equals(lm.layout.block(next)) {
+		return fmt.Errorf("log: appended block %s, want %s", block, lm.layout.block(next))
+	}
+	// the records of the previous block must not appear again in the new one
+	clear(lm.page.Contents().Bytes())
 	lm.setLastOffset(lm.fileMgr.BlockSize())
-	return block, nil
+	if err = lm.fileMgr.Write(block, lm.page); err != nil {
+		return err
+	}
+	lm.currentBlock, lm.blockNum = block, next
+	return nil
+}
+
+// archivePending archives the completed segments that have not been archived yet, in order.
+// The segments are synced before they are completed, by the flush before the new block is appended.
+func (lm *LogMgrImpl) archivePending() error {
+	if lm.archive == nil || !lm.layout.segmented() {
+		return nil
+	}
+	for seg := lm.archivedSegment + 1; seg < lm.layout.segment(lm.blockNum); seg++ {
+		if err := lm.archive(lm.layout.file(seg)); err != nil {
+			return fmt.Errorf("log: cannot archive segment %s: %w", lm.layout.file(seg), err)
+		}
+		lm.archivedSegment = seg
+	}
+	return nil
+}
+
+/*
+Truncate removes the segments that only hold records older than lsn, which are no longer needed for recovery
+once lsn is the LSN of a checkpoint record. The current segment and the segments not archived yet are kept.
+It does nothing if the log is not segmented.
+*/
+func (lm *LogMgrImpl) Truncate(lsn int) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	if !lm.layout.segmented() {
+		return nil
+	}
+	blockNum := lm.blockNum
+	if lsn <= lm.latestLSN {
+		var err error
+		if blockNum, _, _, err = lm.seek(lsn); err != nil {
+			return fmt.Errorf("log: cannot seek to LSN %d: %w", lsn, err)
+		}
+	}
+	keep := lm.layout.segment(blockNum)
+	if lm.archive != nil {
+		keep = min(keep, lm.archivedSegment+1)
+	}
+	for seg := lm.layout.segment(lm.firstBlock); seg < keep; seg++ {
+		if err := lm.fileMgr.Remove(lm.layout.file(seg)); err != nil {
+			return fmt.Errorf("log: cannot remove segment %s: %w", lm.layout.file(seg), err)
+		}
+		lm.firstBlock = lm.layout.firstBlock(seg + 1)
+	}
+	return nil
 }
 
 func (lm *LogMgrImpl) hasInsufficientSpace(size int) bool {
@@ -133,7 +495,7 @@ func (lm *LogMgrImpl) getLastOffset() int {
 }
 
 func (lm *LogMgrImpl) setLastOffset(val int) {
-	lm.page.SetInt(0, uint32(val))
+	lm.page.SetInt(0, int32(val))
 }
 
 func (lm *LogMgrImpl) setBytes(offset int, value []byte) {
@@ -1,10 +1,14 @@
 package log
 
 import (
-	"os"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/file/filetest"
 	"github.com/kj455/simple-db/pkg/testutil"
 	"github.com/stretchr/testify/assert"
 )
@@ -36,16 +40,19 @@ func TestNewLogMgr(t *testing.T) {
 		dir, cleanup := testutil.SetupDir("test_new_log_mgr_block_exists")
 		t.Cleanup(cleanup)
 		record := []byte("hello world!!")
-		f, err := os.Create(dir + "/" + testFileName)
-		assert.NoError(t, err)
-		_, err = f.Write(record)
-		assert.NoError(t, err)
 		fileMgr := file.NewFileMgr(dir, blockSize)
+		for i := 0; i < len(record); i += blockSize {
+			block, err := fileMgr.Append(testFileName)
+			assert.NoError(t, err)
+			page := file.NewPage(blockSize)
+			copy(page.Contents().Bytes(), record[i:])
+			assert.NoError(t, fileMgr.Write(block, page))
+		}
 
 		lm, err := NewLogMgr(fileMgr, testFileName)
 
 		assert.NoError(t, err)
-		assert.True(t, lm.currentBlock.Equals(file.NewBlockId(testFileName, len("hello world!!")/blockSize)))
+		assert.True(t, lm.currentBlock.Equals(file.NewBlockId(testFileName, len(record)/blockSize)))
 		assert.Equal(t, "d!!\x00\x00", string(lm.page.Contents().String()))
 	})
 }
@@ -56,16 +63,12 @@ func TestLogMgr_Append(t *testing.T) {
 		t.Parallel()
 		const (
 			testFileName = "file"
-			blockSize    = 10
+			blockSize    = 40
 			blockIdx     = 0
 		)
 		dir, cleanup := testutil.SetupDir("test_log_mgr_append_has_space")
 		t.Cleanup(cleanup)
-		page := file.NewPage(blockSize)
-		page.SetInt(0, blockSize)
 		fileMgr := file.NewFileMgr(dir, blockSize)
-		block := file.NewBlockId(testFileName, blockIdx)
-		fileMgr.Write(block, page)
 		lm, err := NewLogMgr(fileMgr, testFileName)
 		assert.NoError(t, err)
 		record := []byte("test")
@@ -74,33 +77,43 @@ func TestLogMgr_Append(t *testing.T) {
 
 		assert.Equal(t, 0, lm.lastSavedLSN)
 		assert.Equal(t, 1, lm.latestLSN)
-		assert.Equal(t, blockSize-OFFSET_SIZE-len("test"), lm.getLastOffset())
+		assert.Equal(t, blockSize-OFFSET_SIZE-RECORD_HEADER_SIZE-len("test"), lm.getLastOffset())
+		assert.True(t, lm.currentBlock.Equals(file.NewBlockId(testFileName, blockIdx)))
 	})
 	t.Run("no space", func(t *testing.T) {
 		t.Parallel()
 		const (
 			testFileName = "file"
-			blockSize    = 8
+			blockSize    = 40
 			blockIdx     = 0
 		)
 		dir, cleanup := testutil.SetupDir("test_log_mgr_append_no_space")
 		t.Cleanup(cleanup)
-		page := file.NewPage(blockSize)
-		page.SetInt(0, blockSize)
 		fileMgr := file.NewFileMgr(dir, blockSize)
-		block := file.NewBlockId(testFileName, blockIdx)
-		fileMgr.Write(block, page)
 		lm, err := NewLogMgr(fileMgr, testFileName)
 		assert.NoError(t, err)
 		record := []byte("test")
 
+		lm.Append(record)
 		lm.Append(record)
 
-		assert.Equal(t, 0, lm.lastSavedLSN)
-		assert.Equal(t, 1, lm.latestLSN)
+		assert.Equal(t, 1, lm.lastSavedLSN)
+		assert.Equal(t, 2, lm.latestLSN)
 		nextBlock := file.NewBlockId(testFileName, blockIdx+1)
 		assert.True(t, lm.currentBlock.Equals(nextBlock))
 	})
+	t.Run("too large", func(t *testing.T) {
+		t.Parallel()
+		const blockSize = 20
+		dir, cleanup := testutil.SetupDir("test_log_mgr_append_too_large")
+		t.Cleanup(cleanup)
+		lm, err := NewLogMgr(file.NewFileMgr(dir, blockSize), "file")
+		assert.NoError(t, err)
+
+		_, err = lm.Append(make([]byte, blockSize))
+
+		assert.Error(t, err)
+	})
 }
 
 func TestLogMgr_Flush(t *testing.T) {
@@ -145,4 +158,369 @@ func TestLogMgr_Flush(t *testing.T) {
 		assert.Equal(t, 100, lm.latestLSN)
 		assert.Equal(t, 99, lm.lastSavedLSN)
 	})
+	t.Run("flush lsn not appended yet", func(t *testing.T) {
+		t.Parallel()
+		const (
+			testFileName = "file"
+			blockSize    = 10
+		)
+		dir, cleanup := testutil.SetupDir("test_log_mgr_flush_not_appended")
+		t.Cleanup(cleanup)
+		fileMgr := file.NewFileMgr(dir, blockSize)
+		lm, err := NewLogMgr(fileMgr, testFileName)
+		assert.NoError(t, err)
+		lm.latestLSN = 100
+		lm.lastSavedLSN = 99
+
+		assert.NoError(t, lm.Flush(101))
+
+		assert.Equal(t, 100, lm.lastSavedLSN)
+	})
+}
+
+// countingFileMgr counts the writes to the underlying file manager.
+type countingFileMgr struct {
+	file.FileMgr
+	writes atomic.Int64
+}
+
+func (m *countingFileMgr) Write(id file.BlockId, p file.Page) error {
+	m.writes.Add(1)
+	return m.FileMgr.Write(id, p)
+}
+
+// blockingSyncFileMgr blocks the first sync until release is closed, and closes syncing when it starts.
+type blockingSyncFileMgr struct {
+	file.FileMgr
+	syncing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (m *blockingSyncFileMgr) Sync(filename string) error {
+	m.once.Do(func() {
+		close(m.syncing)
+		<-m.release
+	})
+	return m.FileMgr.Sync(filename)
+}
+
+func TestLogMgr_GroupCommit(t *testing.T) {
+	t.Parallel()
+	const (
+		testFileName = "file"
+		blockSize    = 4096
+		committers   = 8
+	)
+	t.Run("one write covers the batch", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_log_mgr_group_commit_batch")
+		t.Cleanup(cleanup)
+		fileMgr := &countingFileMgr{FileMgr: file.NewFileMgr(dir, blockSize)}
+		lm, err := NewLogMgr(fileMgr, testFileName, WithGroupCommitMaxDelay(time.Second), WithGroupCommitMaxBatch(committers))
+		assert.NoError(t, err)
+		before := fileMgr.writes.Load()
+
+		var wg sync.WaitGroup
+		for i := 0; i < committers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				lsn, err := lm.Append([]byte("commit"))
+				assert.NoError(t, err)
+				assert.NoError(t, lm.Flush(lsn))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int64(1), fileMgr.writes.Load()-before)
+		assert.Equal(t, committers, lm.lastSavedLSN)
+	})
+	t.Run("max delay", func(t *testing.T) {
+		t.Parallel()
+		const delay = 20 * time.Millisecond
+		dir, cleanup := testutil.SetupDir("test_log_mgr_group_commit_delay")
+		t.Cleanup(cleanup)
+		fileMgr := file.NewFileMgr(dir, blockSize)
+		lm, err := NewLogMgr(fileMgr, testFileName, WithGroupCommitMaxDelay(delay), WithGroupCommitMaxBatch(committers))
+		assert.NoError(t, err)
+		lsn, err := lm.Append([]byte("commit"))
+		assert.NoError(t, err)
+
+		start := time.Now()
+		assert.NoError(t, lm.Flush(lsn))
+		assert.GreaterOrEqual(t, time.Since(start), delay)
+		assert.Equal(t, lsn, lm.lastSavedLSN)
+
+		// already flushed
+		start = time.Now()
+		assert.NoError(t, lm.Flush(lsn))
+		assert.Less(t, time.Since(start), delay)
+	})
+	t.Run("appends do not wait for the sync", func(t *testing.T) {
+		t.Parallel()
+		fileMgr := &blockingSyncFileMgr{
+			FileMgr: filetest.NewFaultyFileMgr(blockSize),
+			syncing: make(chan struct{}),
+			release: make(chan struct{}),
+		}
+		lm, err := NewLogMgr(fileMgr, testFileName)
+		assert.NoError(t, err)
+		lsn, err := lm.Append([]byte("first"))
+		assert.NoError(t, err)
+		flushed := make(chan error, 1)
+		go func() { flushed <- lm.Flush(lsn) }()
+		<-fileMgr.syncing
+
+		appended := make(chan error, 1)
+		var next int
+		go func() {
+			var err error
+			next, err = lm.Append([]byte("second"))
+			appended <- err
+		}()
+		select {
+		case err := <-appended:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("append waited for the sync")
+		}
+
+		close(fileMgr.release)
+		assert.NoError(t, <-flushed)
+		assert.NoError(t, lm.Flush(next))
+		assert.Equal(t, next, lm.lastSavedLSN)
+	})
+}
+
+func BenchmarkLogMgr_GroupCommit(b *testing.B) {
+	const (
+		testFileName = "file"
+		blockSize    = 4096
+		writers      = 32
+	)
+	tests := []struct {
+		name string
+		opts []LogMgrOption
+	}{
+		{name: "no delay"},
+		{name: "delay 100us", opts: []LogMgrOption{WithGroupCommitMaxDelay(100 * time.Microsecond), WithGroupCommitMaxBatch(writers)}},
+		{name: "delay 1ms", opts: []LogMgrOption{WithGroupCommitMaxDelay(time.Millisecond), WithGroupCommitMaxBatch(writers)}},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			dir, cleanup := testutil.SetupDir("bench_log_mgr_group_commit")
+			b.Cleanup(cleanup)
+			fileMgr := &countingFileMgr{FileMgr: file.NewFileMgr(dir, blockSize)}
+			lm, err := NewLogMgr(fileMgr, testFileName, tt.opts...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			record := []byte("commit record")
+			var remaining atomic.Int64
+			remaining.Store(int64(b.N))
+			b.ResetTimer()
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for remaining.Add(-1) >= 0 {
+						lsn, err := lm.Append(record)
+						if err != nil {
+							b.Error(err)
+							return
+						}
+						if err := lm.Flush(lsn); err != nil {
+							b.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			b.StopTimer()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "commits/s")
+			b.ReportMetric(float64(b.N)/float64(fileMgr.writes.Load()), "commits/write")
+		})
+	}
+}
+
+func TestLogMgr_FlushSync(t *testing.T) {
+	t.Parallel()
+	const (
+		testFileName = "file"
+		blockSize    = 64
+	)
+	t.Run("flush writes then syncs the log", func(t *testing.T) {
+		t.Parallel()
+		fileMgr := filetest.NewFaultyFileMgr(blockSize)
+		lm, err := NewLogMgr(fileMgr, testFileName)
+		assert.NoError(t, err)
+		lsn, err := lm.Append([]byte("record"))
+		assert.NoError(t, err)
+		fileMgr.ResetOps()
+
+		assert.NoError(t, lm.Flush(lsn))
+
+		assert.Equal(t, []filetest.Op{
+			{Kind: filetest.OP_KIND_WRITE, Filename: testFileName, Block: 0},
+			{Kind: filetest.OP_KIND_SYNC, Filename: testFileName, Block: -1},
+		}, fileMgr.Ops())
+		fileMgr.Crash()
+		lm, err = NewLogMgr(fileMgr, testFileName)
+		assert.NoError(t, err)
+		iter, err := lm.Iterator()
+		assert.NoError(t, err)
+		assert.True(t, iter.HasNext())
+		rec, err := iter.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("record"), rec)
+	})
+	t.Run("failed sync is not a flush", func(t *testing.T) {
+		t.Parallel()
+		fileMgr := filetest.NewFaultyFileMgr(blockSize)
+		lm, err := NewLogMgr(fileMgr, testFileName)
+		assert.NoError(t, err)
+		lsn, err := lm.Append([]byte("record"))
+		assert.NoError(t, err)
+		fileMgr.InjectFault(filetest.FailOn(filetest.OP_KIND_SYNC, testFileName))
+
+		assert.ErrorIs(t, lm.Flush(lsn), filetest.ErrInjected)
+		assert.Equal(t, 0, lm.lastSavedLSN)
+
+		fileMgr.InjectFault(nil)
+		assert.NoError(t, lm.Flush(lsn))
+		assert.Equal(t, lsn, lm.lastSavedLSN)
+	})
+}
+
+func TestLogMgr_TornTail(t *testing.T) {
+	t.Parallel()
+	const (
+		testFileName = "file"
+		blockSize    = 64
+	)
+	setup := func(t *testing.T, dirName string) (*file.FileMgrImpl, *LogMgrImpl) {
+		dir, cleanup := testutil.SetupDir(dirName)
+		t.Cleanup(cleanup)
+		fileMgr := file.NewFileMgr(dir, blockSize)
+		lm, err := NewLogMgr(fileMgr, testFileName)
+		assert.NoError(t, err)
+		for _, rec := range []string{"one", "two", "three"} {
+			lsn, err := lm.Append([]byte(rec))
+			assert.NoError(t, err)
+			assert.NoError(t, lm.Flush(lsn))
+		}
+		return fileMgr, lm
+	}
+	records := func(t *testing.T, lm *LogMgrImpl) []string {
+		iter, err := lm.Iterator()
+		assert.NoError(t, err)
+		var got []string
+		for iter.HasNext() {
+			rec, err := iter.Next()
+			assert.NoError(t, err)
+			got = append(got, string(rec))
+		}
+		return got
+	}
+	t.Run("torn record", func(t *testing.T) {
+		t.Parallel()
+		fileMgr, lm := setup(t, "test_log_mgr_torn_tail_record")
+		// the newest record is at the left of the block
+		lm.page.Contents().Bytes()[lm.getLastOffset()+OFFSET_SIZE+RECORD_HEADER_SIZE] ^= 0xff
+		assert.NoError(t, fileMgr.Write(lm.currentBlock, lm.page))
+
+		reopened, err := NewLogMgr(fileMgr, testFileName)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"two", "one"}, records(t, reopened))
+		lsn, err := reopened.Append([]byte("four"))
+		assert.NoError(t, err)
+		assert.NoError(t, reopened.Flush(lsn))
+		assert.Equal(t, []string{"four", "two", "one"}, records(t, reopened))
+	})
+	t.Run("torn block", func(t *testing.T) {
+		t.Parallel()
+		fileMgr, _ := setup(t, "test_log_mgr_torn_tail_block")
+		// a crash between appending a block and writing it leaves a block of zeros
+		_, err := fileMgr.Append(testFileName)
+		assert.NoError(t, err)
+
+		reopened, err := NewLogMgr(fileMgr, testFileName)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"three", "two", "one"}, records(t, reopened))
+	})
+}
+
+func TestLogMgr_LSN(t *testing.T) {
+	t.Parallel()
+	const (
+		testFileName = "file"
+		blockSize    = 64
+		recordNum    = 10
+	)
+	dir, cleanup := testutil.SetupDir("test_log_mgr_lsn")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	lm, err := NewLogMgr(fileMgr, testFileName)
+	assert.NoError(t, err)
+	record := func(lsn int) []byte {
+		return []byte(fmt.Sprintf("rec%d", lsn))
+	}
+	for i := 1; i <= recordNum; i++ {
+		lsn, err := lm.Append(record(i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, lsn)
+	}
+	blockNum, err := fileMgr.BlockNum(testFileName)
+	assert.NoError(t, err)
+	assert.Greater(t, blockNum, 2)
+
+	t.Run("survive restart", func(t *testing.T) {
+		iter, err := lm.Iterator()
+		assert.NoError(t, err)
+		reopened, err := NewLogMgr(fileMgr, testFileName)
+		assert.NoError(t, err)
+		assert.Equal(t, recordNum, reopened.latestLSN)
+		assert.Equal(t, recordNum, reopened.lastSavedLSN)
+
+		for lsn := recordNum; iter.HasNext(); lsn-- {
+			rec, err := iter.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, lsn, iter.LSN())
+			assert.Equal(t, record(lsn), rec)
+		}
+	})
+	t.Run("read at", func(t *testing.T) {
+		for lsn := 1; lsn <= recordNum; lsn++ {
+			rec, err := lm.ReadAt(lsn)
+			assert.NoError(t, err)
+			assert.Equal(t, record(lsn), rec)
+		}
+		for _, lsn := range []int{0, recordNum + 1} {
+			_, err := lm.ReadAt(lsn)
+			assert.ErrorIs(t, err, ErrLSNNotFound)
+		}
+	})
+	t.Run("forward iterator", func(t *testing.T) {
+		for _, start := range []int{0, 1, 4, recordNum} {
+			iter, err := lm.ForwardIterator(start)
+			assert.NoError(t, err)
+			want := max(start, 1)
+			for iter.HasNext() {
+				rec, err := iter.Next()
+				assert.NoError(t, err)
+				assert.Equal(t, want, iter.LSN())
+				assert.Equal(t, record(want), rec)
+				want++
+			}
+			assert.Equal(t, recordNum+1, want)
+		}
+		iter, err := lm.ForwardIterator(recordNum + 1)
+		assert.NoError(t, err)
+		assert.False(t, iter.HasNext())
+	})
 }
@@ -0,0 +1,99 @@
+package log
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"slices"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
+
+/*
+Each log record is stored in a frame, after the 4 bytes length written by the page:
+
+	-----------------------------------------------------------------
+	| 4 bytes: CRC32C | 1 byte: version | 8 bytes: LSN | record |
+	-----------------------------------------------------------------
+
+The checksum covers the version byte, the LSN and the record.
+The LSN is stored with the record so that it survives restarts and addresses the record, see LogMgrImpl.ReadAt.
+*/
+const (
+	RECORD_HEADER_SIZE = 13
+	// RECORD_VERSION is the version of the frame format written by the log manager.
+	RECORD_VERSION byte = 1
+
+	offsetRecordChecksum = 0
+	offsetRecordVersion  = 4
+	offsetRecordLSN      = 5
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+var ErrCorruptRecord = errors.New("log: corrupt record")
+
+func encodeRecord(lsn int, record []byte) []byte {
+	frame := make([]byte, RECORD_HEADER_SIZE+len(record))
+	frame[offsetRecordVersion] = RECORD_VERSION
+	binary.BigEndian.PutUint64(frame[offsetRecordLSN:], uint64(lsn))
+	copy(frame[RECORD_HEADER_SIZE:], record)
+	binary.BigEndian.PutUint32(frame[offsetRecordChecksum:], crc32.Checksum(frame[offsetRecordVersion:], crc32c))
+	return frame
+}
+
+// decodeRecord verifies the frame and returns the LSN and the record in it.
+func decodeRecord(frame []byte) (lsn int, record []byte, err error) {
+	if len(frame) < RECORD_HEADER_SIZE {
+		return 0, nil, fmt.Errorf("%w: frame of %d bytes", ErrCorruptRecord, len(frame))
+	}
+	if v := frame[offsetRecordVersion]; v != RECORD_VERSION {
+		return 0, nil, fmt.Errorf("%w: unknown version %d", ErrCorruptRecord, v)
+	}
+	stored := binary.BigEndian.Uint32(frame[offsetRecordChecksum:])
+	if actual := crc32.Checksum(frame[offsetRecordVersion:], crc32c); stored != actual {
+		return 0, nil, fmt.Errorf("%w: checksum mismatch: stored %08x, computed %08x", ErrCorruptRecord, stored, actual)
+	}
+	return int(binary.BigEndian.Uint64(frame[offsetRecordLSN:])), frame[RECORD_HEADER_SIZE:], nil
+}
+
+// readFrame returns the frame stored at offset in the log page and the offset of the next (older) record.
+// It checks the length so that garbage never makes it read out of the page.
+func readFrame(p file.Page, offset, blockSize int) (frame []byte, next int, err error) {
+	if offset < OFFSET_SIZE || offset+OFFSET_SIZE > blockSize {
+		return nil, 0, fmt.Errorf("%w: offset %d out of block", ErrCorruptRecord, offset)
+	}
+	length := int(p.GetInt(offset))
+	// the length is signed, so garbage may make it negative as well as too large
+	if length < 0 || length > blockSize-offset-OFFSET_SIZE {
+		return nil, 0, fmt.Errorf("%w: length %d at offset %d out of block", ErrCorruptRecord, length, offset)
+	}
+	return p.GetBytes(offset), offset + OFFSET_SIZE + length, nil
+}
+
+// logEntry is a record read from a log block.
+type logEntry struct {
+	lsn    int
+	offset int
+	record []byte
+}
+
+// readEntries returns the records of the log page in LSN order, i.e. from the right of the block to the left.
+func readEntries(p file.Page, blockSize int) ([]logEntry, error) {
+	var entries []logEntry
+	for pos := int(p.GetInt(0)); pos < blockSize; {
+		frame, next, err := readFrame(p, pos, blockSize)
+		if err != nil {
+			return nil, err
+		}
+		lsn, record, err := decodeRecord(frame)
+		if err != nil {
+			return nil, fmt.Errorf("offset %d: %w", pos, err)
+		}
+		entries = append(entries, logEntry{lsn: lsn, offset: pos, record: slices.Clone(record)})
+		pos = next
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
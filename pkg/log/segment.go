@@ -0,0 +1,132 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
+
+// DEFAULT_SEGMENT_BLOCKS is the size of a log segment used by the driver, 1 MiB with 4 KiB blocks.
+const DEFAULT_SEGMENT_BLOCKS = 256
+
+// ArchiveFunc is called with the file name of each completed log segment, after the segment is synced.
+// A segment is not removed by Truncate until it has been archived.
+type ArchiveFunc func(segment string) error
+
+// SegmentName returns the name of the file of a log segment, e.g. simpledb-log.000001. Segments are numbered from 1.
+func SegmentName(filename string, segment int) string {
+	return fmt.Sprintf("%s.%06d", filename, segment)
+}
+
+// ArchiveToDir returns an ArchiveFunc that copies the segments managed by fm into dir.
+func ArchiveToDir(fm file.FileMgr, dir string) ArchiveFunc {
+	dst := file.NewFileMgr(dir, fm.BlockSize())
+	return func(segment string) error {
+		n, err := fm.BlockNum(segment)
+		if err != nil {
+			return fmt.Errorf("log: cannot get size of segment %s: %w", segment, err)
+		}
+		page := file.NewPage(fm.BlockSize())
+		for i := 0; i < n; i++ {
+			block := file.NewBlockId(segment, i)
+			if err := fm.Read(block, page); err != nil {
+				return fmt.Errorf("log: cannot read block %s: %w", block, err)
+			}
+			if err := dst.Write(block, page); err != nil {
+				return fmt.Errorf("log: cannot archive block %s: %w", block, err)
+			}
+		}
+		if err := dst.Sync(segment); err != nil {
+			return fmt.Errorf("log: cannot sync archived segment %s: %w", segment, err)
+		}
+		return nil
+	}
+}
+
+/*
+segmentLayout maps the logical block numbers of the log to the blocks of its files.
+The logical block numbers grow from the first block ever written, and are not reused when the segments are removed.
+Segment i holds the logical blocks from (i-1)*segmentBlocks, so a block is found without reading any file.
+*/
+type segmentLayout struct {
+	filename      string
+	segmentBlocks int // 0 for a log kept in a single file named filename
+}
+
+func (l segmentLayout) segmented() bool {
+	return l.segmentBlocks > 0
+}
+
+// segment returns the segment of the logical block, or 0 if the log is not segmented.
+func (l segmentLayout) segment(n int) int {
+	if !l.segmented() {
+		return 0
+	}
+	return n/l.segmentBlocks + 1
+}
+
+func (l segmentLayout) file(segment int) string {
+	if !l.segmented() {
+		return l.filename
+	}
+	return SegmentName(l.filename, segment)
+}
+
+func (l segmentLayout) block(n int) file.BlockId {
+	if !l.segmented() {
+		return file.NewBlockId(l.filename, n)
+	}
+	return file.NewBlockId(l.file(l.segment(n)), n%l.segmentBlocks)
+}
+
+// firstBlock returns the logical number of the first block of the segment.
+func (l segmentLayout) firstBlock(segment int) int {
+	if !l.segmented() {
+		return 0
+	}
+	return (segment - 1) * l.segmentBlocks
+}
+
+// blocks returns the logical numbers of the first and the last block of the log. last is first-1 for an empty log.
+func (l segmentLayout) blocks(fm file.FileMgr) (first, last int, err error) {
+	if !l.segmented() {
+		n, err := fm.BlockNum(l.filename)
+		return 0, n - 1, err
+	}
+	segments, err := l.segments(fm)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(segments) == 0 {
+		return 0, -1, nil
+	}
+	lastSegment := segments[len(segments)-1]
+	n, err := fm.BlockNum(l.file(lastSegment))
+	if err != nil {
+		return 0, 0, err
+	}
+	return l.firstBlock(segments[0]), l.firstBlock(lastSegment) + n - 1, nil
+}
+
+// segments returns the numbers of the existing segments in order.
+func (l segmentLayout) segments(fm file.FileMgr) ([]int, error) {
+	files, err := fm.Files()
+	if err != nil {
+		return nil, err
+	}
+	var segments []int
+	for _, f := range files {
+		suffix, ok := strings.CutPrefix(f, l.filename+".")
+		if !ok {
+			continue
+		}
+		if segment, err := strconv.Atoi(suffix); err == nil && segment > 0 {
+			segments = append(segments, segment)
+		}
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
@@ -0,0 +1,183 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSegmentName(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, "simpledb-log.000001", SegmentName("simpledb-log", 1))
+	assert.Equal(t, "simpledb-log.123456", SegmentName("simpledb-log", 123456))
+}
+
+func TestLogMgr_Segments(t *testing.T) {
+	t.Parallel()
+	const (
+		logName       = "simpledb-log"
+		blockSize     = 64
+		segmentBlocks = 2
+		recordNum     = 10 // 2 records per block, 3 segments
+	)
+	record := func(lsn int) []byte {
+		return []byte(fmt.Sprintf("rec%02d", lsn))
+	}
+	appendRecords := func(t *testing.T, lm *LogMgrImpl, from, to int) {
+		for i := from; i <= to; i++ {
+			lsn, err := lm.Append(record(i))
+			assert.NoError(t, err)
+			assert.Equal(t, i, lsn)
+		}
+	}
+	backward := func(t *testing.T, lm *LogMgrImpl) []int {
+		iter, err := lm.Iterator()
+		assert.NoError(t, err)
+		var lsns []int
+		for iter.HasNext() {
+			rec, err := iter.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, record(iter.LSN()), rec)
+			lsns = append(lsns, iter.LSN())
+		}
+		return lsns
+	}
+	lsnRange := func(from, to int) []int {
+		var lsns []int
+		for i := to; i >= from; i-- {
+			lsns = append(lsns, i)
+		}
+		return lsns
+	}
+
+	t.Run("rotation", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_log_mgr_segments_rotation")
+		t.Cleanup(cleanup)
+		fm := file.NewFileMgr(dir, blockSize)
+		lm, err := NewLogMgr(fm, logName, WithSegmentBlocks(segmentBlocks))
+		assert.NoError(t, err)
+		appendRecords(t, lm, 1, recordNum)
+
+		files, err := fm.Files()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{SegmentName(logName, 1), SegmentName(logName, 2), SegmentName(logName, 3)}, files)
+		for _, f := range files {
+			n, err := fm.BlockNum(f)
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, n, segmentBlocks)
+		}
+		assert.Equal(t, lsnRange(1, recordNum), backward(t, lm))
+		for lsn := 1; lsn <= recordNum; lsn++ {
+			rec, err := lm.ReadAt(lsn)
+			assert.NoError(t, err)
+			assert.Equal(t, record(lsn), rec)
+		}
+		iter, err := lm.ForwardIterator(3)
+		assert.NoError(t, err)
+		for want := 3; want <= recordNum; want++ {
+			assert.True(t, iter.HasNext())
+			_, err := iter.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, want, iter.LSN())
+		}
+		assert.False(t, iter.HasNext())
+
+		reopened, err := NewLogMgr(fm, logName, WithSegmentBlocks(segmentBlocks))
+		assert.NoError(t, err)
+		assert.Equal(t, recordNum, reopened.latestLSN)
+		appendRecords(t, reopened, recordNum+1, recordNum+2)
+		assert.Equal(t, lsnRange(1, recordNum+2), backward(t, reopened))
+
+		logIter, err := OpenLogIterator(fm, logName, segmentBlocks)
+		assert.NoError(t, err)
+		assert.True(t, logIter.HasNext())
+		_, err = logIter.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, recordNum+2, logIter.LSN())
+	})
+	t.Run("archive", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_log_mgr_segments_archive")
+		t.Cleanup(cleanup)
+		archiveDir, cleanupArchive := testutil.SetupDir("test_log_mgr_segments_archive_dst")
+		t.Cleanup(cleanupArchive)
+		fm := file.NewFileMgr(dir, blockSize)
+		lm, err := NewLogMgr(fm, logName, WithSegmentBlocks(segmentBlocks), WithArchive(ArchiveToDir(fm, archiveDir)))
+		assert.NoError(t, err)
+		appendRecords(t, lm, 1, recordNum)
+
+		archive := file.NewFileMgr(archiveDir, blockSize)
+		files, err := archive.Files()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{SegmentName(logName, 1), SegmentName(logName, 2)}, files)
+		for _, f := range files {
+			for i := 0; i < segmentBlocks; i++ {
+				want, got := file.NewPage(blockSize), file.NewPage(blockSize)
+				assert.NoError(t, fm.Read(file.NewBlockId(f, i), want))
+				assert.NoError(t, archive.Read(file.NewBlockId(f, i), got))
+				assert.Equal(t, want.Contents().Bytes(), got.Contents().Bytes())
+			}
+		}
+	})
+	t.Run("archive failure is retried", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_log_mgr_segments_archive_failure")
+		t.Cleanup(cleanup)
+		fm := file.NewFileMgr(dir, blockSize)
+		errArchive := errors.New("archive unavailable")
+		var archived []string
+		fail := true
+		lm, err := NewLogMgr(fm, logName, WithSegmentBlocks(segmentBlocks), WithArchive(func(segment string) error {
+			if fail {
+				return errArchive
+			}
+			archived = append(archived, segment)
+			return nil
+		}))
+		assert.NoError(t, err)
+		appendRecords(t, lm, 1, 4)
+
+		_, err = lm.Append(record(5))
+		assert.ErrorIs(t, err, errArchive)
+		// the segment cannot be removed before it is archived
+		assert.NoError(t, lm.Truncate(lm.latestLSN+1))
+		assert.Equal(t, lsnRange(1, 4), backward(t, lm))
+
+		fail = false
+		appendRecords(t, lm, 5, 6)
+		assert.Equal(t, []string{SegmentName(logName, 1)}, archived)
+	})
+	t.Run("truncate", func(t *testing.T) {
+		t.Parallel()
+		dir, cleanup := testutil.SetupDir("test_log_mgr_segments_truncate")
+		t.Cleanup(cleanup)
+		fm := file.NewFileMgr(dir, blockSize)
+		lm, err := NewLogMgr(fm, logName, WithSegmentBlocks(segmentBlocks))
+		assert.NoError(t, err)
+		appendRecords(t, lm, 1, recordNum)
+
+		// the records 9 and 10 are in the third segment
+		assert.NoError(t, lm.Truncate(9))
+
+		files, err := fm.Files()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{SegmentName(logName, 3)}, files)
+		assert.Equal(t, lsnRange(9, recordNum), backward(t, lm))
+		_, err = lm.ReadAt(1)
+		assert.ErrorIs(t, err, ErrLSNNotFound)
+		rec, err := lm.ReadAt(9)
+		assert.NoError(t, err)
+		assert.Equal(t, record(9), rec)
+
+		reopened, err := NewLogMgr(fm, logName, WithSegmentBlocks(segmentBlocks))
+		assert.NoError(t, err)
+		assert.Equal(t, recordNum, reopened.latestLSN)
+		appendRecords(t, reopened, recordNum+1, recordNum+4)
+		assert.Equal(t, lsnRange(9, recordNum+4), backward(t, reopened))
+	})
+}
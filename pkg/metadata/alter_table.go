@@ -0,0 +1,280 @@
+package metadata
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/kj455/simple-db/pkg/constant"
+	"github.com/kj455/simple-db/pkg/record"
+	"github.com/kj455/simple-db/pkg/tx"
+)
+
+/*
+AddField adds the field to the end of the schema of the table, and rewrites the records of the table for the new layout.
+The field of the existing records is set to def, or to NULL if def is nil.
+*/
+func (m *MetadataMgrImpl) AddField(tblname, fldname string, typ record.SchemaType, length int, def *constant.Const, tx tx.Transaction) error {
+	layout, err := m.alterableLayout(tblname, tx)
+	if err != nil {
+		return err
+	}
+	sch := layout.Schema()
+	if sch.HasField(fldname) {
+		return fmt.Errorf("metadata: field %s already exists in %s", fldname, tblname)
+	}
+	var defaults map[string]*constant.Const
+	if def != nil && !def.IsNull() {
+		if err := checkDefault(fldname, typ, length, def); err != nil {
+			return err
+		}
+		defaults = map[string]*constant.Const{fldname: def}
+	}
+	newSch := record.NewSchema()
+	if err := newSch.AddAll(sch); err != nil {
+		return err
+	}
+	newSch.AddField(fldname, typ, length)
+	return m.moveRecords(tblname, tblname, layout, newSch, defaults, tx)
+}
+
+// checkDefault checks that the default can be stored in the field, as record.TableScanImpl.SetVal would convert it.
+// A VARCHAR default takes at most as many characters as the length of the field, whatever their bytes.
+func checkDefault(fldname string, typ record.SchemaType, length int, def *constant.Const) error {
+	var err error
+	switch typ {
+	case record.SCHEMA_TYPE_INTEGER:
+		if def.Kind() != constant.KIND_INT {
+			return fmt.Errorf("metadata: default %s of %s is not an integer", def.ToString(), fldname)
+		}
+	case record.SCHEMA_TYPE_VARCHAR:
+		str, err := def.AsString()
+		if err != nil {
+			return fmt.Errorf("metadata: default %s of %s is not a string", def.ToString(), fldname)
+		}
+		if utf8.RuneCountInString(str) > length {
+			return fmt.Errorf("metadata: default %s is longer than %s", def.ToString(), fldname)
+		}
+	case record.SCHEMA_TYPE_TEXT:
+		if _, err := def.AsString(); err != nil {
+			return fmt.Errorf("metadata: default %s of %s is not a string", def.ToString(), fldname)
+		}
+	case record.SCHEMA_TYPE_BIGINT:
+		_, err = def.AsInt64()
+	case record.SCHEMA_TYPE_DOUBLE:
+		_, err = def.AsFloat64()
+	case record.SCHEMA_TYPE_BOOLEAN:
+		_, err = def.AsBool()
+	case record.SCHEMA_TYPE_DATE, record.SCHEMA_TYPE_TIMESTAMP:
+		_, err = def.AsTime()
+	case record.SCHEMA_TYPE_BLOB:
+		b, err := def.AsBytes()
+		if err != nil {
+			return fmt.Errorf("metadata: default %s of %s: %w", def.ToString(), fldname, err)
+		}
+		if len(b) > length {
+			return fmt.Errorf("metadata: default %s is longer than %s", def.ToString(), fldname)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("metadata: default %s of %s: %w", def.ToString(), fldname, err)
+	}
+	return nil
+}
+
+// DropField removes the field and its indexes from the table, and rewrites the records of the table for the new layout.
+func (m *MetadataMgrImpl) DropField(tblname, fldname string, tx tx.Transaction) error {
+	layout, err := m.alterableLayout(tblname, tx)
+	if err != nil {
+		return err
+	}
+	sch := layout.Schema()
+	if !sch.HasField(fldname) {
+		return fmt.Errorf("%w: %s.%s", ErrFieldNotFound, tblname, fldname)
+	}
+	if len(sch.Fields()) == 1 {
+		return fmt.Errorf("metadata: cannot drop %s, the only field of %s", fldname, tblname)
+	}
+	newSch := record.NewSchema()
+	for _, fld := range sch.Fields() {
+		if fld == fldname {
+			continue
+		}
+		if err := newSch.Add(fld, sch); err != nil {
+			return err
+		}
+	}
+	if err := m.dropIndexes(tblname, func(fld string) bool { return fld == fldname }, tx); err != nil {
+		return err
+	}
+	return m.moveRecords(tblname, tblname, layout, newSch, nil, tx)
+}
+
+// RenameField renames the field of the table and of its indexes, and drops the stored statistics of the table.
+// The layout of the table stays the same, so the records are not rewritten.
+func (m *MetadataMgrImpl) RenameField(tblname, fldname, newName string, tx tx.Transaction) error {
+	layout, err := m.alterableLayout(tblname, tx)
+	if err != nil {
+		return err
+	}
+	sch := layout.Schema()
+	if !sch.HasField(fldname) {
+		return fmt.Errorf("%w: %s.%s", ErrFieldNotFound, tblname, fldname)
+	}
+	if sch.HasField(newName) {
+		return fmt.Errorf("metadata: field %s already exists in %s", newName, tblname)
+	}
+	newSch := record.NewSchema()
+	for _, fld := range sch.Fields() {
+		typ, err := sch.Type(fld)
+		if err != nil {
+			return err
+		}
+		length, err := sch.Length(fld)
+		if err != nil {
+			return err
+		}
+		if fld == fldname {
+			fld = newName
+		}
+		newSch.AddField(fld, typ, length)
+	}
+	if err := m.tableMgr.AlterTable(tblname, tblname, newSch, tx); err != nil {
+		return err
+	}
+	if err := m.statMgr.DropStatInfo(tblname, tx); err != nil {
+		return err
+	}
+	return m.idxMgr.RenameField(tblname, fldname, newName, tx)
+}
+
+/*
+RenameTable renames the table and moves its indexes to the new name.
+The records are copied to the file of the new name, and the old file is cleared, then removed when tx commits.
+*/
+func (m *MetadataMgrImpl) RenameTable(tblname, newName string, tx tx.Transaction) error {
+	if m.isCatalog(newName) {
+		return fmt.Errorf("metadata: table %s already exists", newName)
+	}
+	layout, err := m.alterableLayout(tblname, tx)
+	if err != nil {
+		return err
+	}
+	if err := lockExclusive(tx, newName+record.TABLE_SUFFIX); err != nil {
+		return fmt.Errorf("metadata: failed to lock table %s: %w", newName, err)
+	}
+	if err := m.moveRecords(tblname, newName, layout, layout.Schema(), nil, tx); err != nil {
+		return err
+	}
+	if err := m.idxMgr.RenameTable(tblname, newName, tx); err != nil {
+		return err
+	}
+	return removeTable(tblname, layout, tx)
+}
+
+// removeTable clears the files of the table, which are then removed when tx commits.
+func removeTable(tblname string, layout record.Layout, tx tx.Transaction) error {
+	if err := clearTable(tblname, layout, tx); err != nil {
+		return err
+	}
+	tx.RemoveFileOnCommit(tblname + record.TABLE_SUFFIX)
+	tx.RemoveFileOnCommit(tblname + record.OVERFLOW_SUFFIX)
+	tx.RemoveFileOnCommit(tblname + record.FREE_SPACE_SUFFIX)
+	return nil
+}
+
+// alterableLayout takes an exclusive lock on the table and returns its layout.
+// The catalog tables cannot be altered, and it returns ErrTableNotFound if there is no such table.
+func (m *MetadataMgrImpl) alterableLayout(tblname string, tx tx.Transaction) (record.Layout, error) {
+	if m.isCatalog(tblname) {
+		return nil, fmt.Errorf("metadata: cannot alter catalog table %s", tblname)
+	}
+	if err := lockExclusive(tx, tblname+record.TABLE_SUFFIX); err != nil {
+		return nil, fmt.Errorf("metadata: failed to lock table %s: %w", tblname, err)
+	}
+	hasTable, err := m.tableMgr.HasTable(tblname, tx)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: failed to check if table exists: %w", err)
+	}
+	if !hasTable {
+		return nil, fmt.Errorf("%w: %s", ErrTableNotFound, tblname)
+	}
+	return m.tableMgr.GetLayout(tblname, tx)
+}
+
+// ALTER_TEMP_SUFFIX is appended to the name of a table to name the table its records are copied to while its layout changes.
+// No table can be created with such a name, as it is not an identifier.
+const ALTER_TEMP_SUFFIX = "#alter"
+
+/*
+moveRecords replaces the catalog entries of the table with ones for newName and sch, and rewrites the records of the table,
+read with layout, into the file of newName for the layout of sch. Fields the records do not have are set to their value in defaults,
+or to NULL. The file of newName is cleared first, which rolling back undoes. The stored statistics of the table are dropped,
+so that the table is analyzed again.
+The records are copied one at a time. If the file stays the same, they are copied to a temporary table, then back into the cleared file,
+and the temporary table is removed when tx commits: the file of a table is named after it, so swapping the files on commit
+would leave the catalog and the file apart if the system crashed in between.
+*/
+func (m *MetadataMgrImpl) moveRecords(tblname, newName string, layout record.Layout, sch record.Schema, defaults map[string]*constant.Const, tx tx.Transaction) error {
+	if err := m.statMgr.DropStatInfo(tblname, tx); err != nil {
+		return err
+	}
+	if err := m.tableMgr.AlterTable(tblname, newName, sch, tx); err != nil {
+		return err
+	}
+	newLayout, err := record.NewLayoutFromSchema(sch)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create layout from schema: %w", err)
+	}
+	if newName != tblname {
+		return copyRecords(tblname, layout, newName, newLayout, defaults, tx)
+	}
+	temp := tblname + ALTER_TEMP_SUFFIX
+	if err := lockExclusive(tx, temp+record.TABLE_SUFFIX); err != nil {
+		return fmt.Errorf("metadata: failed to lock table %s: %w", temp, err)
+	}
+	if err := copyRecords(tblname, layout, temp, newLayout, defaults, tx); err != nil {
+		return err
+	}
+	if err := copyRecords(temp, newLayout, tblname, newLayout, nil, tx); err != nil {
+		return err
+	}
+	return removeTable(temp, newLayout, tx)
+}
+
+// copyRecords clears the table to, then inserts into it the records of the table from with the fields of its layout.
+// Fields a record does not have are set to their value in defaults, or to NULL.
+func copyRecords(from string, fromLayout record.Layout, to string, toLayout record.Layout, defaults map[string]*constant.Const, tx tx.Transaction) error {
+	src, err := record.NewTableScan(tx, from, fromLayout)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer src.Close()
+	dst, err := record.NewTableScan(tx, to, toLayout)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer dst.Close()
+	if err := dst.Clear(); err != nil {
+		return fmt.Errorf("metadata: failed to clear %s: %w", to, err)
+	}
+	fromSch := fromLayout.Schema()
+	for src.Next() {
+		if err := dst.Insert(); err != nil {
+			return fmt.Errorf("metadata: failed to insert record: %w", err)
+		}
+		for _, fld := range toLayout.Schema().Fields() {
+			val, ok := defaults[fld]
+			if fromSch.HasField(fld) {
+				if val, err = src.GetVal(fld); err != nil {
+					return fmt.Errorf("metadata: failed to get %s: %w", fld, err)
+				}
+			} else if !ok {
+				val = constant.NewNull()
+			}
+			if err := dst.SetVal(fld, val); err != nil {
+				return fmt.Errorf("metadata: failed to set %s: %w", fld, err)
+			}
+		}
+	}
+	return nil
+}
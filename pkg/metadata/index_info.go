@@ -90,15 +90,11 @@ func (ii *IndexInfoImpl) createIdxLayout() (record.Layout, error) {
 		return nil, fmt.Errorf("metadata: failed to get field type: %v", err)
 	}
 
-	if schType == record.SCHEMA_TYPE_INTEGER {
-		sch.AddIntField("dataval")
-	} else {
-		fldlen, err := ii.tblSchema.Length(ii.fldName)
-		if err != nil {
-			return nil, fmt.Errorf("metadata: failed to get field length: %v", err)
-		}
-		sch.AddStringField("dataval", fldlen)
+	fldlen, err := ii.tblSchema.Length(ii.fldName)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: failed to get field length: %v", err)
 	}
+	sch.AddField("dataval", schType, fldlen)
 
 	layout, err := record.NewLayoutFromSchema(sch)
 	if err != nil {
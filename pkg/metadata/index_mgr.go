@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -16,6 +17,8 @@ const (
 	indexFieldField = "fieldname"
 )
 
+var ErrIndexNotFound = errors.New("metadata: index not found")
+
 // IndexMgr is the index manager.
 type IndexMgrImpl struct {
 	layout   record.Layout
@@ -111,3 +114,77 @@ func (im *IndexMgrImpl) GetIndexInfo(tblname string, tx tx.Transaction) (map[str
 	}
 	return result, nil
 }
+
+// DropIndex removes the index from the index catalog. It returns ErrIndexNotFound if there is no such index.
+func (im *IndexMgrImpl) DropIndex(idxName string, tx tx.Transaction) error {
+	ts, err := record.NewTableScan(tx, indexTable, im.layout)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	for ts.Next() {
+		name, err := ts.GetString(indexFieldIndex)
+		if err != nil {
+			return fmt.Errorf("metadata: failed to get index name: %w", err)
+		}
+		if name != idxName {
+			continue
+		}
+		if err := ts.Delete(); err != nil {
+			return fmt.Errorf("metadata: failed to delete index: %w", err)
+		}
+		return nil
+	}
+	return ErrIndexNotFound
+}
+
+// RenameTable moves the indexes of the table to newName in the index catalog.
+func (im *IndexMgrImpl) RenameTable(tblname, newName string, tx tx.Transaction) error {
+	return im.rename(tx, func(table, field string) (string, string) {
+		if table != tblname {
+			return table, field
+		}
+		return newName, field
+	})
+}
+
+// RenameField moves the indexes on the field of the table to newName in the index catalog.
+func (im *IndexMgrImpl) RenameField(tblname, fldname, newName string, tx tx.Transaction) error {
+	return im.rename(tx, func(table, field string) (string, string) {
+		if table != tblname || field != fldname {
+			return table, field
+		}
+		return table, newName
+	})
+}
+
+// rename rewrites the table and field names of the rows in the index catalog with the ones fn returns for them.
+func (im *IndexMgrImpl) rename(tx tx.Transaction, fn func(table, field string) (string, string)) error {
+	ts, err := record.NewTableScan(tx, indexTable, im.layout)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	for ts.Next() {
+		table, err := ts.GetString(indexFieldTable)
+		if err != nil {
+			return fmt.Errorf("metadata: failed to get table name: %w", err)
+		}
+		field, err := ts.GetString(indexFieldField)
+		if err != nil {
+			return fmt.Errorf("metadata: failed to get field name: %w", err)
+		}
+		newTable, newField := fn(table, field)
+		if newTable != table {
+			if err := ts.SetString(indexFieldTable, newTable); err != nil {
+				return fmt.Errorf("metadata: failed to set table name: %w", err)
+			}
+		}
+		if newField != field {
+			if err := ts.SetString(indexFieldField, newField); err != nil {
+				return fmt.Errorf("metadata: failed to set field name: %w", err)
+			}
+		}
+	}
+	return nil
+}
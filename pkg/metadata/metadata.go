@@ -1,14 +1,20 @@
 package metadata
 
 import (
+	"github.com/kj455/simple-db/pkg/constant"
 	"github.com/kj455/simple-db/pkg/record"
 	"github.com/kj455/simple-db/pkg/tx"
 )
 
 type TableMgr interface {
 	CreateTable(table string, schema record.Schema, tx tx.Transaction) error
+	DropTable(table string, tx tx.Transaction) error
+	// AlterTable replaces the catalog entries of the table with ones for newName and the schema.
+	AlterTable(table, newName string, schema record.Schema, tx tx.Transaction) error
 	GetLayout(table string, tx tx.Transaction) (record.Layout, error)
 	HasTable(table string, tx tx.Transaction) (bool, error)
+	// TableNames returns the names of all the tables, in the order of the table catalog.
+	TableNames(tx tx.Transaction) ([]string, error)
 	TableCatalog() string
 	FieldCatalog() string
 }
@@ -16,16 +22,28 @@ type TableMgr interface {
 type ViewMgr interface {
 	CreateView(name, def string, tx tx.Transaction) error
 	GetViewDef(name string, tx tx.Transaction) (string, error)
+	// GetViewDefs returns the definitions of all views by name.
+	GetViewDefs(tx tx.Transaction) (map[string]string, error)
+	DeleteView(name string, tx tx.Transaction) error
 }
 
 type StatInfo interface {
 	BlocksAccessed() int
 	RecordsOutput() int
 	DistinctValues(field string) int
+	// NullFraction returns the fraction of the records in which the field is NULL.
+	NullFraction(field string) float64
+	// MinValue and MaxValue return the least and greatest values of the field, nil if they are not known.
+	MinValue(field string) *constant.Const
+	MaxValue(field string) *constant.Const
 }
 
 type StatMgr interface {
 	GetStatInfo(table string, layout record.Layout, tx tx.Transaction) (StatInfo, error)
+	// Analyze computes the statistics of the table and stores them in the stat catalog.
+	Analyze(table string, layout record.Layout, tx tx.Transaction) (StatInfo, error)
+	// DropStatInfo removes the stored statistics of the table.
+	DropStatInfo(table string, tx tx.Transaction) error
 }
 
 type IndexInfo interface {
@@ -38,14 +56,38 @@ type IndexInfo interface {
 type IndexMgr interface {
 	CreateIndex(name, table, field string, tx tx.Transaction) error
 	GetIndexInfo(table string, tx tx.Transaction) (map[string]IndexInfo, error)
+	DropIndex(name string, tx tx.Transaction) error
+	// RenameTable moves the indexes of the table to newName.
+	RenameTable(table, newName string, tx tx.Transaction) error
+	// RenameField moves the indexes on the field of the table to newName.
+	RenameField(table, field, newName string, tx tx.Transaction) error
 }
 
 type MetadataMgr interface {
 	CreateTable(table string, sch record.Schema, tx tx.Transaction) error
+	HasTable(table string, tx tx.Transaction) (bool, error)
+	// TableNames returns the names of the tables, the catalog tables excluded.
+	TableNames(tx tx.Transaction) ([]string, error)
 	GetLayout(table string, tx tx.Transaction) (record.Layout, error)
+	// DropTable removes the table, its indexes and its statistics from the catalogs, and its file once tx commits.
+	DropTable(table string, tx tx.Transaction) error
+	// AddField adds the field to the table, setting it to def, or to NULL if def is nil, in the existing records.
+	AddField(table, field string, typ record.SchemaType, length int, def *constant.Const, tx tx.Transaction) error
+	// DropField removes the field and its indexes from the table.
+	DropField(table, field string, tx tx.Transaction) error
+	RenameField(table, field, newName string, tx tx.Transaction) error
+	RenameTable(table, newName string, tx tx.Transaction) error
+	// Vacuum compacts the records of the table and returns the number of blocks it frees, which are removed from its file once tx commits.
+	// It returns ErrIndexedTable if the table has indexes.
+	Vacuum(table string, tx tx.Transaction) (int, error)
+	// Analyze computes the statistics of the table and stores them in the stat catalog, for the planner to use instead of estimating them from the size of the table.
+	Analyze(table string, tx tx.Transaction) error
 	CreateView(name string, def string, tx tx.Transaction) error
 	GetViewDef(name string, tx tx.Transaction) (string, error)
+	GetViewDefs(tx tx.Transaction) (map[string]string, error)
+	DropView(name string, tx tx.Transaction) error
 	CreateIndex(name string, table string, field string, tx tx.Transaction) error
 	GetIndexInfo(table string, tx tx.Transaction) (map[string]IndexInfo, error)
+	DropIndex(name string, tx tx.Transaction) error
 	GetStatInfo(table string, layout record.Layout, tx tx.Transaction) (StatInfo, error)
 }
@@ -1,6 +1,8 @@
 package metadata
 
 import (
+	"fmt"
+
 	"github.com/kj455/simple-db/pkg/record"
 	"github.com/kj455/simple-db/pkg/tx"
 )
@@ -43,6 +45,121 @@ func (m *MetadataMgrImpl) CreateTable(tblname string, sch record.Schema, tx tx.T
 	return m.tableMgr.CreateTable(tblname, sch, tx)
 }
 
+func (m *MetadataMgrImpl) HasTable(tblname string, tx tx.Transaction) (bool, error) {
+	return m.tableMgr.HasTable(tblname, tx)
+}
+
+/*
+DropTable removes the table, its indexes and its statistics from the catalogs, and schedules the removal of its file for when tx commits.
+It takes an exclusive lock on the table first. The catalog tables themselves cannot be dropped,
+and it returns ErrTableNotFound if there is no such table.
+The records are cleared first, so that a table created with the same name before tx commits starts empty.
+*/
+func (m *MetadataMgrImpl) DropTable(tblname string, tx tx.Transaction) error {
+	if m.isCatalog(tblname) {
+		return fmt.Errorf("metadata: cannot drop catalog table %s", tblname)
+	}
+	filename := tblname + record.TABLE_SUFFIX
+	if err := lockExclusive(tx, filename); err != nil {
+		return fmt.Errorf("metadata: failed to lock table %s: %w", tblname, err)
+	}
+	hasTable, err := m.tableMgr.HasTable(tblname, tx)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to check if table exists: %w", err)
+	}
+	if !hasTable {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, tblname)
+	}
+	if err := m.dropIndexes(tblname, func(string) bool { return true }, tx); err != nil {
+		return err
+	}
+	layout, err := m.tableMgr.GetLayout(tblname, tx)
+	if err != nil {
+		return err
+	}
+	if err := clearTable(tblname, layout, tx); err != nil {
+		return err
+	}
+	if err := m.statMgr.DropStatInfo(tblname, tx); err != nil {
+		return err
+	}
+	if err := m.tableMgr.DropTable(tblname, tx); err != nil {
+		return err
+	}
+	tx.RemoveFileOnCommit(filename)
+	tx.RemoveFileOnCommit(tblname + record.OVERFLOW_SUFFIX)
+	tx.RemoveFileOnCommit(tblname + record.FREE_SPACE_SUFFIX)
+	return nil
+}
+
+// dropIndexes drops the indexes of the table on the fields match reports true for.
+// GetIndexInfo returns one index per field, so it is called until no index is left to drop.
+func (m *MetadataMgrImpl) dropIndexes(tblname string, match func(field string) bool, tx tx.Transaction) error {
+	for {
+		indexes, err := m.idxMgr.GetIndexInfo(tblname, tx)
+		if err != nil {
+			return err
+		}
+		dropped := false
+		for fld, ii := range indexes {
+			if !match(fld) {
+				continue
+			}
+			if err := m.idxMgr.DropIndex(ii.IndexName(), tx); err != nil {
+				return err
+			}
+			dropped = true
+		}
+		if !dropped {
+			return nil
+		}
+	}
+}
+
+// clearTable empties the files of the table with logged changes, as a table that is dropped or renamed leaves its files to be removed on commit.
+func clearTable(tblname string, layout record.Layout, tx tx.Transaction) error {
+	ts, err := record.NewTableScan(tx, tblname, layout)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	if err := ts.Clear(); err != nil {
+		return fmt.Errorf("metadata: failed to clear %s: %w", tblname, err)
+	}
+	return nil
+}
+
+func lockExclusive(t tx.Transaction, filename string) error {
+	return t.LockFile(filename, tx.LOCK_MODE_X)
+}
+
+func lockShared(t tx.Transaction, filename string) error {
+	return t.LockFile(filename, tx.LOCK_MODE_S)
+}
+
+func (m *MetadataMgrImpl) isCatalog(tblname string) bool {
+	switch tblname {
+	case m.tableMgr.TableCatalog(), m.tableMgr.FieldCatalog(), tableViewCatalog, indexTable, statTable:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *MetadataMgrImpl) TableNames(tx tx.Transaction) ([]string, error) {
+	names, err := m.tableMgr.TableNames(tx)
+	if err != nil {
+		return nil, err
+	}
+	tables := names[:0]
+	for _, name := range names {
+		if !m.isCatalog(name) {
+			tables = append(tables, name)
+		}
+	}
+	return tables, nil
+}
+
 func (m *MetadataMgrImpl) GetLayout(tblname string, tx tx.Transaction) (record.Layout, error) {
 	return m.tableMgr.GetLayout(tblname, tx)
 }
@@ -55,6 +172,15 @@ func (m *MetadataMgrImpl) GetViewDef(viewname string, tx tx.Transaction) (string
 	return m.viewMgr.GetViewDef(viewname, tx)
 }
 
+func (m *MetadataMgrImpl) GetViewDefs(tx tx.Transaction) (map[string]string, error) {
+	return m.viewMgr.GetViewDefs(tx)
+}
+
+// DropView removes the view from the view catalog. It returns ErrViewNotFound if there is no such view.
+func (m *MetadataMgrImpl) DropView(viewname string, tx tx.Transaction) error {
+	return m.viewMgr.DeleteView(viewname, tx)
+}
+
 func (m *MetadataMgrImpl) CreateIndex(idxname string, tblname string, fldname string, tx tx.Transaction) error {
 	return m.idxMgr.CreateIndex(idxname, tblname, fldname, tx)
 }
@@ -63,6 +189,38 @@ func (m *MetadataMgrImpl) GetIndexInfo(tblname string, tx tx.Transaction) (map[s
 	return m.idxMgr.GetIndexInfo(tblname, tx)
 }
 
+// DropIndex removes the index from the index catalog. It returns ErrIndexNotFound if there is no such index.
+func (m *MetadataMgrImpl) DropIndex(idxname string, tx tx.Transaction) error {
+	return m.idxMgr.DropIndex(idxname, tx)
+}
+
+/*
+Analyze computes the statistics of the table and stores them in the stat catalog, replacing the ones it had.
+It takes a shared lock on the table first, so that no other transaction changes it while it is scanned. The catalog tables cannot be analyzed,
+and it returns ErrTableNotFound if there is no such table.
+*/
+func (m *MetadataMgrImpl) Analyze(tblname string, tx tx.Transaction) error {
+	if m.isCatalog(tblname) {
+		return fmt.Errorf("metadata: cannot analyze catalog table %s", tblname)
+	}
+	if err := lockShared(tx, tblname+record.TABLE_SUFFIX); err != nil {
+		return fmt.Errorf("metadata: failed to lock table %s: %w", tblname, err)
+	}
+	hasTable, err := m.tableMgr.HasTable(tblname, tx)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to check if table exists: %w", err)
+	}
+	if !hasTable {
+		return fmt.Errorf("%w: %s", ErrTableNotFound, tblname)
+	}
+	layout, err := m.tableMgr.GetLayout(tblname, tx)
+	if err != nil {
+		return err
+	}
+	_, err = m.statMgr.Analyze(tblname, layout, tx)
+	return err
+}
+
 func (m *MetadataMgrImpl) GetStatInfo(tblname string, layout record.Layout, tx tx.Transaction) (StatInfo, error) {
 	return m.statMgr.GetStatInfo(tblname, layout, tx)
 }
@@ -1,21 +1,34 @@
 package metadata
 
+import "github.com/kj455/simple-db/pkg/constant"
+
 // StatInfoImpl holds statistical information about a table:
 // the number of blocks, the number of records,
-// and the number of distinct values for each field.
+// and, for each field, the number of distinct values, of NULLs, and the least and greatest values.
 type StatInfoImpl struct {
 	numBlocks  int
 	numRecords int
+	fields     map[string]*fieldStat
+}
+
+// fieldStat holds the statistics of a field. min and max are nil if every value of the field is NULL.
+type fieldStat struct {
+	distinct int
+	nulls    int
+	min      *constant.Const
+	max      *constant.Const
 }
 
 // NewStatInfo creates a StatInfoImpl object.
-// Note that the number of distinct values is not
-// passed into the constructor.
-// The function fakes this value.
-func NewStatInfo(numBlocks, numRecords int) StatInfo {
+// Note that the statistics of the fields are not
+// passed into the constructor: they are added by
+// the statistics manager as it computes or loads them.
+// Until then the number of distinct values is faked.
+func NewStatInfo(numBlocks, numRecords int) *StatInfoImpl {
 	return &StatInfoImpl{
 		numBlocks:  numBlocks,
 		numRecords: numRecords,
+		fields:     make(map[string]*fieldStat),
 	}
 }
 
@@ -30,8 +43,37 @@ func (si *StatInfoImpl) RecordsOutput() int {
 }
 
 // DistinctValues returns the estimated number of distinct values
-// for the specified field.
-// FIXME: This is a fake value.
+// for the specified field, which is at least 1.
+// Fields without statistics are assumed to have a value every 3 records.
 func (si *StatInfoImpl) DistinctValues(fldname string) int {
-	return 1 + (si.numRecords / 3)
+	fs, ok := si.fields[fldname]
+	if !ok {
+		return 1 + (si.numRecords / 3)
+	}
+	return max(fs.distinct, 1)
+}
+
+// NullFraction returns the fraction of the records in which the field is NULL, 0 if it is not known.
+func (si *StatInfoImpl) NullFraction(fldname string) float64 {
+	fs, ok := si.fields[fldname]
+	if !ok || si.numRecords == 0 {
+		return 0
+	}
+	return float64(fs.nulls) / float64(si.numRecords)
+}
+
+// MinValue returns the least value of the field, nil if it is not known or the field has no value but NULL.
+func (si *StatInfoImpl) MinValue(fldname string) *constant.Const {
+	if fs, ok := si.fields[fldname]; ok {
+		return fs.min
+	}
+	return nil
+}
+
+// MaxValue returns the greatest value of the field, nil if it is not known or the field has no value but NULL.
+func (si *StatInfoImpl) MaxValue(fldname string) *constant.Const {
+	if fs, ok := si.fields[fldname]; ok {
+		return fs.max
+	}
+	return nil
 }
@@ -1,9 +1,15 @@
 package metadata
 
 import (
+	"encoding/hex"
 	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/kj455/simple-db/pkg/constant"
 	"github.com/kj455/simple-db/pkg/record"
 	"github.com/kj455/simple-db/pkg/tx"
 )
@@ -13,93 +19,399 @@ const (
 	INIT_STAT_CAP          = 50
 )
 
-// StatMgrImpl is responsible for keeping statistical information about each table.
+const (
+	statTable = "statcat"
+
+	statFieldBlocks   = "numblocks"
+	statFieldRecords  = "numrecs"
+	statFieldDistinct = "ndistinct"
+	statFieldNulls    = "nnulls"
+	statFieldMin      = "minval"
+	statFieldMax      = "maxval"
+)
+
+/*
+StatMgrImpl is responsible for keeping statistical information about each table.
+The statistics of a table are computed by scanning it when it is analyzed, and stored in the statcat catalog,
+with one row per field of the table. They are loaded from the catalog when the table is first used;
+those of a table that was never analyzed are estimated from the number of blocks of its file, without scanning it.
+The statistics are kept in memory, and dropped every STAT_REFRESH_THRESHOLD calls to be loaded or estimated again.
+The statistics in memory are shared by the transactions, so Analyze and DropStatInfo change them only once their transaction has committed,
+and the statistics a transaction reads while it has such changes pending are not kept.
+mu guards only the statistics in memory: the catalog and the tables are scanned without it, as the scans may wait for locks
+held by transactions whose commit takes it.
+*/
 type StatMgrImpl struct {
-	tableMgr   TableMgr
+	layout     record.Layout
 	tableStats map[string]StatInfo
 	numCalls   int
-	mu         sync.Mutex
+	// pending are the transactions that have changed the catalog and not ended yet
+	pending map[tx.Transaction]bool
+	// version counts the changes of tableStats on commit, so that statistics loaded before one are not kept
+	version int
+	mu      sync.Mutex
 }
 
-// NewStatMgr creates the statistics manager.
+// NewStatMgr creates the statistics manager. If the database is new, the statcat table is created.
 func NewStatMgr(tblMgr TableMgr, tx tx.Transaction) (*StatMgrImpl, error) {
-	sm := &StatMgrImpl{
-		tableMgr:   tblMgr,
-		tableStats: make(map[string]StatInfo, INIT_STAT_CAP),
+	hasTable, err := tblMgr.HasTable(statTable, tx)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: failed to check for stat catalog: %w", err)
+	}
+	if !hasTable {
+		sch := record.NewSchema()
+		sch.AddStringField(fieldTableName, MAX_NAME_LENGTH)
+		sch.AddStringField(fieldFieldName, MAX_NAME_LENGTH)
+		sch.AddIntField(statFieldBlocks)
+		sch.AddIntField(statFieldRecords)
+		sch.AddIntField(statFieldDistinct)
+		sch.AddIntField(statFieldNulls)
+		// the values are stored as strings, which may be long, and read back for the type of the field
+		sch.AddField(statFieldMin, record.SCHEMA_TYPE_TEXT, 0)
+		sch.AddField(statFieldMax, record.SCHEMA_TYPE_TEXT, 0)
+		if err := tblMgr.CreateTable(statTable, sch, tx); err != nil {
+			return nil, fmt.Errorf("metadata: failed to create stat catalog: %w", err)
+		}
 	}
-	if err := sm.refreshStatistics(tx); err != nil {
-		return nil, fmt.Errorf("metadata: failed to refresh statistics: %w", err)
+	layout, err := tblMgr.GetLayout(statTable, tx)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: failed to get stat catalog layout: %w", err)
 	}
-	return sm, nil
+	return &StatMgrImpl{
+		layout:     layout,
+		tableStats: make(map[string]StatInfo, INIT_STAT_CAP),
+	}, nil
 }
 
 // GetStatInfo returns the statistical information about the specified table.
 func (sm *StatMgrImpl) GetStatInfo(tableName string, layout record.Layout, tx tx.Transaction) (StatInfo, error) {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
 	sm.numCalls++
-
 	if sm.numCalls > STAT_REFRESH_THRESHOLD {
-		err := sm.refreshStatistics(tx)
-		if err != nil {
-			return nil, fmt.Errorf("metadata: failed to refresh statistics: %w", err)
-		}
+		sm.tableStats = make(map[string]StatInfo, INIT_STAT_CAP)
+		sm.numCalls = 0
 	}
-	if stat, ok := sm.tableStats[tableName]; ok {
+	// a transaction with pending changes reads its own statistics from the catalog
+	pending, version := sm.pending[tx], sm.version
+	if stat, ok := sm.tableStats[tableName]; ok && !pending {
+		sm.mu.Unlock()
 		return stat, nil
 	}
-	stat, err := sm.calcTableStats(tableName, layout, tx)
+	sm.mu.Unlock()
+
+	stat, err := sm.loadTableStats(tableName, layout, tx)
 	if err != nil {
 		return nil, fmt.Errorf("metadata: get stat info: %w", err)
 	}
-	sm.tableStats[tableName] = stat
+	if stat == nil {
+		if stat, err = estimateTableStats(tableName, layout, tx); err != nil {
+			return nil, fmt.Errorf("metadata: get stat info: %w", err)
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if !sm.pending[tx] && sm.version == version {
+		sm.tableStats[tableName] = stat
+	}
 	return stat, nil
 }
 
-func (sm *StatMgrImpl) refreshStatistics(tx tx.Transaction) error {
-	sm.tableStats = make(map[string]StatInfo, INIT_STAT_CAP)
-	sm.numCalls = 0
-
-	tableCatLayout, err := sm.tableMgr.GetLayout(sm.tableMgr.TableCatalog(), tx)
+// Analyze computes the statistics of the table and stores them in the stat catalog, replacing the ones it had.
+func (sm *StatMgrImpl) Analyze(tableName string, layout record.Layout, tx tx.Transaction) (StatInfo, error) {
+	sm.markPending(tx)
+	stat, err := calcTableStats(tableName, layout, tx)
 	if err != nil {
+		return nil, fmt.Errorf("metadata: analyze %s: %w", tableName, err)
+	}
+	if err := sm.deleteTableStats(tableName, tx); err != nil {
+		return nil, err
+	}
+	if err := sm.storeTableStats(tableName, layout, stat, tx); err != nil {
+		return nil, err
+	}
+	tx.OnCommit(func() error {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		sm.tableStats[tableName] = stat
+		sm.version++
+		return nil
+	})
+	return stat, nil
+}
+
+// DropStatInfo removes the statistics of the table, as when it is dropped or its fields change.
+func (sm *StatMgrImpl) DropStatInfo(tableName string, tx tx.Transaction) error {
+	sm.markPending(tx)
+	if err := sm.deleteTableStats(tableName, tx); err != nil {
 		return err
 	}
-	tcat, err := record.NewTableScan(tx, sm.tableMgr.TableCatalog(), tableCatLayout)
+	tx.OnCommit(func() error {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		delete(sm.tableStats, tableName)
+		sm.version++
+		return nil
+	})
+	return nil
+}
+
+// markPending records that the transaction changes the catalog until it ends. It is called before the changes,
+// so that no statistics the transaction reads meanwhile are kept.
+func (sm *StatMgrImpl) markPending(txn tx.Transaction) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.pending[txn] {
+		return
+	}
+	if sm.pending == nil {
+		sm.pending = make(map[tx.Transaction]bool)
+	}
+	sm.pending[txn] = true
+	txn.OnEnd(func() {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		delete(sm.pending, txn)
+	})
+}
+
+// loadTableStats reads the statistics of the table from the stat catalog. It returns nil if the table was never analyzed.
+func (sm *StatMgrImpl) loadTableStats(tableName string, layout record.Layout, tx tx.Transaction) (*StatInfoImpl, error) {
+	ts, err := record.NewTableScan(tx, statTable, sm.layout)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer tcat.Close()
+	defer ts.Close()
 
-	for tcat.Next() {
-		tableName, err := tcat.GetString(fieldTableName)
+	var stat *StatInfoImpl
+	sch := layout.Schema()
+	for ts.Next() {
+		name, err := ts.GetString(fieldTableName)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if name != tableName {
+			continue
+		}
+		if stat == nil {
+			numBlocks, err := ts.GetInt(statFieldBlocks)
+			if err != nil {
+				return nil, err
+			}
+			numRecs, err := ts.GetInt(statFieldRecords)
+			if err != nil {
+				return nil, err
+			}
+			stat = NewStatInfo(numBlocks, numRecs)
 		}
-		layout, err := sm.tableMgr.GetLayout(tableName, tx)
+		fld, err := ts.GetString(fieldFieldName)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		typ, err := sch.Type(fld)
+		if err != nil {
+			// the field is not in the layout the statistics are asked for
+			continue
+		}
+		fs := &fieldStat{}
+		if fs.distinct, err = ts.GetInt(statFieldDistinct); err != nil {
+			return nil, err
+		}
+		if fs.nulls, err = ts.GetInt(statFieldNulls); err != nil {
+			return nil, err
+		}
+		if fs.min, err = getStatValue(ts, statFieldMin, typ); err != nil {
+			return nil, err
+		}
+		if fs.max, err = getStatValue(ts, statFieldMax, typ); err != nil {
+			return nil, err
+		}
+		stat.fields[fld] = fs
+	}
+	return stat, nil
+}
+
+func (sm *StatMgrImpl) storeTableStats(tableName string, layout record.Layout, stat *StatInfoImpl, tx tx.Transaction) error {
+	ts, err := record.NewTableScan(tx, statTable, sm.layout)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+
+	for _, fld := range layout.Schema().Fields() {
+		fs := stat.fields[fld]
+		if err := ts.Insert(); err != nil {
+			return fmt.Errorf("metadata: failed to insert into stat catalog: %w", err)
+		}
+		vals := map[string]*constant.Const{
+			fieldTableName:    stringConst(tableName),
+			fieldFieldName:    stringConst(fld),
+			statFieldBlocks:   constant.NewInt(int64(stat.numBlocks)),
+			statFieldRecords:  constant.NewInt(int64(stat.numRecords)),
+			statFieldDistinct: constant.NewInt(int64(fs.distinct)),
+			statFieldNulls:    constant.NewInt(int64(fs.nulls)),
+			statFieldMin:      statValueString(fs.min),
+			statFieldMax:      statValueString(fs.max),
 		}
-		si, err := sm.calcTableStats(tableName, layout, tx)
+		for _, statFld := range sm.layout.Schema().Fields() {
+			if err := ts.SetVal(statFld, vals[statFld]); err != nil {
+				return fmt.Errorf("metadata: failed to set %s: %w", statFld, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (sm *StatMgrImpl) deleteTableStats(tableName string, tx tx.Transaction) error {
+	ts, err := record.NewTableScan(tx, statTable, sm.layout)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+
+	for ts.Next() {
+		name, err := ts.GetString(fieldTableName)
 		if err != nil {
-			return err
+			return fmt.Errorf("metadata: failed to get table name: %w", err)
+		}
+		if name != tableName {
+			continue
+		}
+		if err := ts.Delete(); err != nil {
+			return fmt.Errorf("metadata: failed to delete from stat catalog: %w", err)
 		}
-		sm.tableStats[tableName] = si
 	}
 	return nil
 }
 
-func (sm *StatMgrImpl) calcTableStats(tableName string, layout record.Layout, tx tx.Transaction) (StatInfo, error) {
+// estimateTableStats estimates the statistics of a table that was never analyzed from the number of blocks of its file,
+// as if they were full of records without variable-length values.
+func estimateTableStats(tableName string, layout record.Layout, tx tx.Transaction) (*StatInfoImpl, error) {
+	numBlocks, err := tx.Size(tableName + record.TABLE_SUFFIX)
+	if err != nil {
+		return nil, err
+	}
+	return NewStatInfo(numBlocks, numBlocks*max(tx.BlockSize()/layout.SlotSize(), 1)), nil
+}
+
+/*
+calcTableStats scans the table and counts its records, the blocks up to the last one holding a record,
+and, for each field, its NULLs and distinct values, and finds its least and greatest values.
+The distinct values are told apart by a 64-bit hash of their string form, so that only the hashes are held in memory.
+*/
+func calcTableStats(tableName string, layout record.Layout, tx tx.Transaction) (*StatInfoImpl, error) {
 	ts, err := record.NewTableScan(tx, tableName, layout)
 	if err != nil {
 		return nil, err
 	}
 	defer ts.Close()
 
-	var numRecs, numBlocks int
+	fields := layout.Schema().Fields()
+	hashes := make(map[string]map[uint64]struct{}, len(fields))
+	stat := NewStatInfo(0, 0)
+	for _, fld := range fields {
+		hashes[fld] = make(map[uint64]struct{})
+		stat.fields[fld] = &fieldStat{}
+	}
 	for ts.Next() {
-		numRecs++
-		numBlocks = ts.GetRID().BlockNumber() + 1
+		stat.numRecords++
+		stat.numBlocks = ts.GetRID().BlockNumber() + 1
+		for _, fld := range fields {
+			val, err := ts.GetVal(fld)
+			if err != nil {
+				return nil, err
+			}
+			fs := stat.fields[fld]
+			if val.IsNull() {
+				fs.nulls++
+				continue
+			}
+			h := fnv.New64a()
+			h.Write([]byte(val.ToString()))
+			hashes[fld][h.Sum64()] = struct{}{}
+			if fs.min == nil || val.CompareTo(fs.min) < 0 {
+				fs.min = val
+			}
+			if fs.max == nil || val.CompareTo(fs.max) > 0 {
+				fs.max = val
+			}
+		}
+	}
+	for _, fld := range fields {
+		stat.fields[fld].distinct = len(hashes[fld])
+	}
+	return stat, nil
+}
+
+func stringConst(s string) *constant.Const {
+	c, _ := constant.NewConstant(constant.KIND_STR, s)
+	return c
+}
+
+// statValueString returns the string form of the value to store in the stat catalog, NULL if there is no value.
+func statValueString(val *constant.Const) *constant.Const {
+	if val == nil {
+		return constant.NewNull()
+	}
+	return stringConst(val.ToString())
+}
+
+// getStatValue reads back a value stored by statValueString for a field of the type. It returns nil if there is no value.
+func getStatValue(ts *record.TableScanImpl, statFld string, typ record.SchemaType) (*constant.Const, error) {
+	null, err := ts.IsNull(statFld)
+	if err != nil || null {
+		return nil, err
+	}
+	s, err := ts.GetString(statFld)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case record.SCHEMA_TYPE_INTEGER:
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewConstant(constant.KIND_INT, i)
+	case record.SCHEMA_TYPE_VARCHAR, record.SCHEMA_TYPE_TEXT:
+		return stringConst(s), nil
+	case record.SCHEMA_TYPE_BIGINT:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewConstant(constant.KIND_INT64, i)
+	case record.SCHEMA_TYPE_DOUBLE:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewConstant(constant.KIND_FLOAT64, f)
+	case record.SCHEMA_TYPE_BOOLEAN:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewConstant(constant.KIND_BOOL, b)
+	case record.SCHEMA_TYPE_DATE:
+		t, err := time.Parse(constant.DATE_FORMAT, s)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewConstant(constant.KIND_DATE, t)
+	case record.SCHEMA_TYPE_TIMESTAMP:
+		t, err := time.Parse(constant.TIMESTAMP_FORMAT, s)
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewConstant(constant.KIND_TIMESTAMP, t)
+	case record.SCHEMA_TYPE_BLOB:
+		b, err := hex.DecodeString(strings.TrimPrefix(s, `\x`))
+		if err != nil {
+			return nil, err
+		}
+		return constant.NewConstant(constant.KIND_BYTES, b)
+	default:
+		return nil, fmt.Errorf("metadata: unknown schema type %v", typ)
 	}
-	return NewStatInfo(numBlocks, numRecs), nil
 }
@@ -1,7 +1,9 @@
 package metadata
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/buffer"
 	"github.com/kj455/simple-db/pkg/file"
@@ -29,12 +31,16 @@ func TestStatMgr(t *testing.T) {
 	}
 	bufferMgr := buffer.NewBufferMgr(buffs, buffer.WithMaxWaitTime(0))
 	txNumGen := tx.NewTxNumberGenerator()
-	tx, err := tx.NewTransaction(fileMgr, logMgr, bufferMgr, txNumGen)
-	assert.NoError(t, err)
-	tblMgr, err := NewTableMgr(tx)
+	newTx := func() tx.Transaction {
+		txn, err := tx.NewTransaction(fileMgr, logMgr, bufferMgr, txNumGen)
+		assert.NoError(t, err)
+		return txn
+	}
+	txn := newTx()
+	tblMgr, err := NewTableMgr(txn)
 	assert.NoError(t, err)
 
-	statMgr, err := NewStatMgr(tblMgr, tx)
+	statMgr, err := NewStatMgr(tblMgr, txn)
 
 	assert.NoError(t, err)
 
@@ -44,13 +50,164 @@ func TestStatMgr(t *testing.T) {
 	layout, err := record.NewLayoutFromSchema(schema)
 	assert.NoError(t, err)
 
-	const tableName = "test_stat_mgr_table"
-	stat, err := statMgr.GetStatInfo(tableName, layout, tx)
-	defer func() {
-		err := tblMgr.DropTable(tableName, tx)
+	const tableName = "stat_table"
+	stat, err := statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stat.BlocksAccessed())
+	assert.Equal(t, 0, stat.RecordsOutput())
+
+	err = tblMgr.CreateTable(tableName, schema, txn)
+	assert.NoError(t, err)
+	ts, err := record.NewTableScan(txn, tableName, layout)
+	assert.NoError(t, err)
+	for i := 0; i < 30; i++ {
+		assert.NoError(t, ts.Insert())
+		assert.NoError(t, ts.SetInt("A", i%10))
+		if i%3 == 0 {
+			assert.NoError(t, ts.SetNull("B"))
+		} else {
+			assert.NoError(t, ts.SetString("B", fmt.Sprintf("b%02d", i)))
+		}
+	}
+	ts.Close()
+	assert.NoError(t, txn.Commit())
+
+	// the statistics of a table that was never analyzed are estimated from the size of its file
+	txn = newTx()
+	statMgr, err = NewStatMgr(tblMgr, txn)
+	assert.NoError(t, err)
+	numBlocks, err := txn.Size(tableName + record.TABLE_SUFFIX)
+	assert.NoError(t, err)
+	assertEstimated := func(stat StatInfo) {
+		t.Helper()
+		assert.Equal(t, numBlocks, stat.BlocksAccessed())
+		assert.Equal(t, numBlocks*(blockSize/layout.SlotSize()), stat.RecordsOutput())
+		assert.Nil(t, stat.MaxValue("A"))
+	}
+	stat, err = statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertEstimated(stat)
+
+	// analyzing counts the distinct values and NULLs of each field and finds its bounds
+	assertStats := func(stat StatInfo) {
+		t.Helper()
+		assert.Equal(t, 30, stat.RecordsOutput())
+		assert.Equal(t, 10, stat.DistinctValues("A"))
+		assert.Equal(t, 20, stat.DistinctValues("B"))
+		assert.Equal(t, 0.0, stat.NullFraction("A"))
+		assert.InDelta(t, 1.0/3, stat.NullFraction("B"), 1e-9)
+		assert.Equal(t, "0", stat.MinValue("A").ToString())
+		assert.Equal(t, "9", stat.MaxValue("A").ToString())
+		assert.Equal(t, "b01", stat.MinValue("B").ToString())
+		assert.Equal(t, "b29", stat.MaxValue("B").ToString())
+	}
+	stat, err = statMgr.Analyze(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertStats(stat)
+
+	// the transaction reads its own statistics, which the other transactions share only once the analysis is committed
+	stat, err = statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertStats(stat)
+	assert.NoError(t, txn.Rollback())
+	txn = newTx()
+	stat, err = statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertEstimated(stat)
+	_, err = statMgr.Analyze(tableName, layout, txn)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit())
+	txn = newTx()
+	stat, err = statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertStats(stat)
+
+	// the stored statistics are loaded instead of scanning the table, so the records inserted since are not counted
+	ts, err = record.NewTableScan(txn, tableName, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, ts.Insert())
+	assert.NoError(t, ts.SetInt("A", 100))
+	assert.NoError(t, ts.SetString("B", "c"))
+	ts.Close()
+	statMgr, err = NewStatMgr(tblMgr, txn)
+	assert.NoError(t, err)
+	stat, err = statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertStats(stat)
+
+	// once they are dropped, they are estimated again, by the other transactions once the transaction has committed
+	err = statMgr.DropStatInfo(tableName, txn)
+	assert.NoError(t, err)
+	other := newTx()
+	stat, err = statMgr.GetStatInfo(tableName, layout, other)
+	assert.NoError(t, err)
+	assertStats(stat)
+	assert.NoError(t, other.Commit())
+	stat, err = statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertEstimated(stat)
+	assert.NoError(t, txn.Commit())
+	txn = newTx()
+	stat, err = statMgr.GetStatInfo(tableName, layout, txn)
+	assert.NoError(t, err)
+	assertEstimated(stat)
+
+	err = tblMgr.DropTable(tableName, txn)
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit())
+}
+
+func TestStatMgr_Concurrent(t *testing.T) {
+	const (
+		logFileName = "file"
+		blockSize   = 1024
+		tableName   = "stat_table"
+	)
+	dir, cleanup := testutil.SetupDir("test_stat_mgr_concurrent")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 10)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fileMgr, logMgr, blockSize)
+	}
+	bufferMgr := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	lockTable := tx.NewLock(tx.WithWaitTime(2 * time.Second))
+	newTx := func() tx.Transaction {
+		txn, err := tx.NewTransaction(fileMgr, logMgr, bufferMgr, txNumGen, tx.WithTxLockTable(lockTable))
 		assert.NoError(t, err)
+		return txn
+	}
+	schema := record.NewSchema()
+	schema.AddIntField("A")
+	layout, err := record.NewLayoutFromSchema(schema)
+	assert.NoError(t, err)
+
+	txn := newTx()
+	tblMgr, err := NewTableMgr(txn)
+	assert.NoError(t, err)
+	statMgr, err := NewStatMgr(tblMgr, txn)
+	assert.NoError(t, err)
+	assert.NoError(t, tblMgr.CreateTable(tableName, schema, txn))
+	assert.NoError(t, txn.Commit())
+
+	// a transaction waiting for the catalog that another one analyzed does not keep it from committing
+	analyzer := newTx()
+	_, err = statMgr.Analyze(tableName, layout, analyzer)
+	assert.NoError(t, err)
+	reader := newTx()
+	read := make(chan error)
+	go func() {
+		_, err := statMgr.GetStatInfo("other_table", layout, reader)
+		read <- err
 	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, analyzer.Commit())
+	assert.NoError(t, <-read)
+	stat, err := statMgr.GetStatInfo(tableName, layout, reader)
 	assert.NoError(t, err)
-	assert.Equal(t, 0, stat.BlocksAccessed())
 	assert.Equal(t, 0, stat.RecordsOutput())
+	assert.NoError(t, reader.Commit())
 }
@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -42,6 +43,11 @@ func WithTableFieldCatalog(name string) TableMgrOption {
 	}
 }
 
+var (
+	ErrTableNotFound = errors.New("metadata: table not found")
+	ErrFieldNotFound = errors.New("metadata: field not found")
+)
+
 // MaxName is the maximum character length a tablename or fieldname can have.
 const MAX_NAME_LENGTH = 16
 
@@ -139,6 +145,23 @@ func (tm *TableMgrImpl) HasTable(tblname string, tx tx.Transaction) (bool, error
 	return false, nil
 }
 
+func (tm *TableMgrImpl) TableNames(tx tx.Transaction) ([]string, error) {
+	tcat, err := record.NewTableScan(tx, tm.tableCatalog, tm.tblCatLayout)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer tcat.Close()
+	var names []string
+	for tcat.Next() {
+		name, err := tcat.GetString(fieldTableName)
+		if err != nil {
+			return nil, fmt.Errorf("metadata: failed to get tableName: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
 func (tm *TableMgrImpl) addToTableCatalog(tblname string, slotSize int, tx tx.Transaction) error {
 	tcat, err := record.NewTableScan(tx, tm.tableCatalog, tm.tblCatLayout)
 	if err != nil {
@@ -201,6 +224,38 @@ func (tm *TableMgrImpl) addToFieldCatalog(tblname string, schema record.Schema,
 func (tm *TableMgrImpl) DropTable(tblname string, tx tx.Transaction) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	return tm.removeFromCatalogs(tblname, tx)
+}
+
+// AlterTable replaces the catalog entries of the table with ones for newName and the schema,
+// recomputing the slot size and the offsets of the fields. newName may be the current name of the table.
+// Moving the records to the new layout is up to the caller.
+func (tm *TableMgrImpl) AlterTable(tblname, newName string, sch record.Schema, tx tx.Transaction) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if newName != tblname {
+		hasTable, err := tm.HasTable(newName, tx)
+		if err != nil {
+			return fmt.Errorf("metadata: failed to check if table exists: %w", err)
+		}
+		if hasTable {
+			return fmt.Errorf("metadata: table %s already exists", newName)
+		}
+	}
+	layout, err := record.NewLayoutFromSchema(sch)
+	if err != nil {
+		return fmt.Errorf("metadata: failed to create layout from schema: %w", err)
+	}
+	if err := tm.removeFromCatalogs(tblname, tx); err != nil {
+		return err
+	}
+	if err := tm.addToTableCatalog(newName, layout.SlotSize(), tx); err != nil {
+		return err
+	}
+	return tm.addToFieldCatalog(newName, sch, tx)
+}
+
+func (tm *TableMgrImpl) removeFromCatalogs(tblname string, tx tx.Transaction) error {
 	tcat, err := record.NewTableScan(tx, tm.tableCatalog, tm.tblCatLayout)
 	if err != nil {
 		return fmt.Errorf("metadata: failed to create table scan: %w", err)
@@ -64,5 +64,28 @@ func TestTableMgr(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 10, l)
 
+	// Alter the table: drop A, add C and rename it
+	const newName = "test_table_mgr_new"
+	altered := record.NewSchema()
+	altered.AddStringField("B", 10)
+	altered.AddIntField("C")
+	assert.Error(t, tblMgr.AlterTable(tableName, tblMgr.fieldCatalog, altered, tx))
+	assert.NoError(t, tblMgr.AlterTable(tableName, newName, altered, tx))
+	defer func() {
+		err = tblMgr.DropTable(newName, tx)
+		assert.NoError(t, err)
+	}()
+	hasTable, err := tblMgr.HasTable(tableName, tx)
+	assert.NoError(t, err)
+	assert.False(t, hasTable)
+	layout, err = tblMgr.GetLayout(newName, tx)
+	assert.NoError(t, err)
+	expected, err := record.NewLayoutFromSchema(altered)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"B", "C"}, layout.Schema().Fields())
+	assert.Equal(t, expected.SlotSize(), layout.SlotSize())
+	assert.Equal(t, expected.Offset("B"), layout.Offset("B"))
+	assert.Equal(t, expected.Offset("C"), layout.Offset("C"))
+
 	tx.Commit()
 }
@@ -0,0 +1,48 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kj455/simple-db/pkg/record"
+	"github.com/kj455/simple-db/pkg/tx"
+)
+
+// ErrIndexedTable is returned by Vacuum for a table that has indexes.
+var ErrIndexedTable = errors.New("metadata: cannot vacuum a table with indexes")
+
+/*
+Vacuum moves the records of the last blocks of the table into the free space of its first blocks, in place, and returns the number of
+blocks left empty at its end, which are removed from the file once tx commits. The table is analyzed afterwards, which stores its new statistics.
+The records moved get new RIDs, which the entries of an index would have to follow; as indexes cannot be opened yet to be rebuilt,
+a table with indexes is not vacuumed, and ErrIndexedTable is returned.
+*/
+func (m *MetadataMgrImpl) Vacuum(tblname string, tx tx.Transaction) (int, error) {
+	layout, err := m.alterableLayout(tblname, tx)
+	if err != nil {
+		return 0, err
+	}
+	indexes, err := m.idxMgr.GetIndexInfo(tblname, tx)
+	if err != nil {
+		return 0, fmt.Errorf("metadata: failed to get indexes of %s: %w", tblname, err)
+	}
+	if len(indexes) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrIndexedTable, tblname)
+	}
+	ts, err := record.NewTableScan(tx, tblname, layout)
+	if err != nil {
+		return 0, fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	if err := ts.Compact(); err != nil {
+		return 0, fmt.Errorf("metadata: failed to compact %s: %w", tblname, err)
+	}
+	freed, err := ts.Shrink()
+	if err != nil {
+		return 0, fmt.Errorf("metadata: failed to shrink %s: %w", tblname, err)
+	}
+	if _, err := m.statMgr.Analyze(tblname, layout, tx); err != nil {
+		return 0, err
+	}
+	return freed, nil
+}
@@ -12,8 +12,6 @@ const (
 
 	fieldViewName = "viewname"
 	fieldDef      = "viewdef"
-
-	MAX_VIEW_DEF = 100
 )
 
 var (
@@ -35,7 +33,8 @@ func NewViewMgr(tableMgr TableMgr, tx tx.Transaction) (*ViewMgrImpl, error) {
 	}
 	sch := record.NewSchema()
 	sch.AddStringField(fieldViewName, MAX_NAME_LENGTH)
-	sch.AddStringField(fieldDef, MAX_VIEW_DEF)
+	// definitions of any length are stored, the long ones in the overflow file of the catalog
+	sch.AddField(fieldDef, record.SCHEMA_TYPE_TEXT, 0)
 	if err := tableMgr.CreateTable(tableViewCatalog, sch, tx); err != nil {
 		return nil, fmt.Errorf("metadata: failed to create view catalog: %w", err)
 	}
@@ -98,6 +97,33 @@ func (vm *ViewMgrImpl) GetViewDef(vname string, tx tx.Transaction) (string, erro
 	return result, nil
 }
 
+// GetViewDefs returns the definitions of all views by name.
+func (vm *ViewMgrImpl) GetViewDefs(tx tx.Transaction) (map[string]string, error) {
+	layout, err := vm.tableMgr.GetLayout(tableViewCatalog, tx)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: failed to get view catalog layout: %w", err)
+	}
+	ts, err := record.NewTableScan(tx, tableViewCatalog, layout)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: failed to create table scan: %w", err)
+	}
+	defer ts.Close()
+	defs := make(map[string]string)
+	for ts.Next() {
+		name, err := ts.GetString(fieldViewName)
+		if err != nil {
+			return nil, fmt.Errorf("metadata: failed to get view name: %w", err)
+		}
+		def, err := ts.GetString(fieldDef)
+		if err != nil {
+			return nil, fmt.Errorf("metadata: failed to get view def: %w", err)
+		}
+		defs[name] = def
+	}
+	return defs, nil
+}
+
+// DeleteView removes the view from the view catalog. It returns ErrViewNotFound if there is no such view.
 func (vm *ViewMgrImpl) DeleteView(vname string, tx tx.Transaction) error {
 	layout, err := vm.tableMgr.GetLayout(tableViewCatalog, tx)
 	if err != nil {
@@ -119,7 +145,7 @@ func (vm *ViewMgrImpl) DeleteView(vname string, tx tx.Transaction) error {
 		if err := ts.Delete(); err != nil {
 			return fmt.Errorf("metadata: failed to delete view: %w", err)
 		}
-		break
+		return nil
 	}
-	return nil
+	return ErrViewNotFound
 }
@@ -1,6 +1,7 @@
 package metadata
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/kj455/simple-db/pkg/buffer"
@@ -53,4 +54,17 @@ func TestViewMgr(t *testing.T) {
 	def, err := viewMgr.GetViewDef(viewName, tx)
 	assert.NoError(t, err)
 	assert.Equal(t, viewDef, def)
+
+	// a definition longer than a block is stored in the overflow file
+	const longViewName = "long_view"
+	longViewDef := "SELECT A FROM test_table WHERE " + strings.Repeat("A = 1 OR ", 300) + "A = 2"
+	err = viewMgr.CreateView(longViewName, longViewDef, tx)
+	assert.NoError(t, err)
+	defer func() {
+		err := viewMgr.DeleteView(longViewName, tx)
+		assert.NoError(t, err)
+	}()
+	def, err = viewMgr.GetViewDef(longViewName, tx)
+	assert.NoError(t, err)
+	assert.Equal(t, longViewDef, def)
 }
@@ -1,12 +1,15 @@
 package parse
 
 import (
+	"encoding/hex"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/kj455/simple-db/pkg/constant"
 	"github.com/kj455/simple-db/pkg/query"
 	"github.com/kj455/simple-db/pkg/record"
+	"github.com/kj455/simple-db/pkg/tx"
 )
 
 type Data interface {
@@ -132,20 +135,57 @@ func (c *CreateTableData) String() string {
 		}
 		sb.WriteString(field)
 		typ, _ := c.Schema.Type(field)
-		switch typ {
-		case record.SCHEMA_TYPE_INTEGER:
-			sb.WriteString(" int")
-		case record.SCHEMA_TYPE_VARCHAR:
-			sb.WriteString(" varchar(")
-			length, _ := c.Schema.Length(field)
-			sb.WriteString(fmt.Sprintf("%d", length))
-			sb.WriteString(")")
-		}
+		length, _ := c.Schema.Length(field)
+		sb.WriteString(" " + typeString(typ, length))
 	}
 	sb.WriteString(")")
 	return sb.String()
 }
 
+func typeString(typ record.SchemaType, length int) string {
+	switch typ {
+	case record.SCHEMA_TYPE_INTEGER:
+		return "int"
+	case record.SCHEMA_TYPE_VARCHAR:
+		return fmt.Sprintf("varchar(%d)", length)
+	case record.SCHEMA_TYPE_BIGINT:
+		return "bigint"
+	case record.SCHEMA_TYPE_BOOLEAN:
+		return "boolean"
+	case record.SCHEMA_TYPE_DOUBLE:
+		return "double"
+	case record.SCHEMA_TYPE_DATE:
+		return "date"
+	case record.SCHEMA_TYPE_TIMESTAMP:
+		return "timestamp"
+	case record.SCHEMA_TYPE_BLOB:
+		return fmt.Sprintf("blob(%d)", length)
+	case record.SCHEMA_TYPE_TEXT:
+		return "text"
+	}
+	return ""
+}
+
+// literal returns the constant as it is written in SQL.
+func literal(c *constant.Const) string {
+	switch c.Kind() {
+	case constant.KIND_STR:
+		return "'" + c.ToString() + "'"
+	case constant.KIND_DATE:
+		return "date '" + c.ToString() + "'"
+	case constant.KIND_TIMESTAMP:
+		return "timestamp '" + c.ToString() + "'"
+	case constant.KIND_BYTES:
+		b, _ := c.AsBytes()
+		return "x'" + hex.EncodeToString(b) + "'"
+	case constant.KIND_FLOAT64:
+		// keep a fraction so that the number is read back as a double
+		f, _ := c.AsFloat64()
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	}
+	return c.ToString()
+}
+
 // CreateViewData is the data for the SQL "create view" statement.
 type CreateViewData struct {
 	ViewName string
@@ -183,3 +223,281 @@ func NewCreateIndexData(idx, table, field string) *CreateIndexData {
 func (c *CreateIndexData) String() string {
 	return fmt.Sprintf("create index %s on %s(%s)", c.Idx, c.Table, c.Field)
 }
+
+// SetTransactionData is the data for the SQL "set transaction" statement.
+// Isolation is zero and HasAccessMode is false when the statement does not mention them.
+type SetTransactionData struct {
+	Isolation     tx.IsolationLevel
+	HasAccessMode bool
+	ReadOnly      bool
+}
+
+func NewSetTransactionData(isolation tx.IsolationLevel, hasAccessMode, readOnly bool) *SetTransactionData {
+	return &SetTransactionData{
+		Isolation:     isolation,
+		HasAccessMode: hasAccessMode,
+		ReadOnly:      readOnly,
+	}
+}
+
+func (s *SetTransactionData) String() string {
+	modes := make([]string, 0, 2)
+	if s.Isolation != 0 {
+		modes = append(modes, "isolation level "+s.Isolation.String())
+	}
+	if s.HasAccessMode {
+		if s.ReadOnly {
+			modes = append(modes, "read only")
+		} else {
+			modes = append(modes, "read write")
+		}
+	}
+	return "set transaction " + strings.Join(modes, ", ")
+}
+
+// SavepointData is the data for the SQL "savepoint" statement.
+type SavepointData struct {
+	Name string
+}
+
+func NewSavepointData(name string) *SavepointData {
+	return &SavepointData{Name: name}
+}
+
+func (s *SavepointData) String() string {
+	return fmt.Sprintf("savepoint %s", s.Name)
+}
+
+// RollbackToSavepointData is the data for the SQL "rollback to savepoint" statement.
+type RollbackToSavepointData struct {
+	Name string
+}
+
+func NewRollbackToSavepointData(name string) *RollbackToSavepointData {
+	return &RollbackToSavepointData{Name: name}
+}
+
+func (r *RollbackToSavepointData) String() string {
+	return fmt.Sprintf("rollback to savepoint %s", r.Name)
+}
+
+// ReleaseSavepointData is the data for the SQL "release savepoint" statement.
+type ReleaseSavepointData struct {
+	Name string
+}
+
+func NewReleaseSavepointData(name string) *ReleaseSavepointData {
+	return &ReleaseSavepointData{Name: name}
+}
+
+func (r *ReleaseSavepointData) String() string {
+	return fmt.Sprintf("release savepoint %s", r.Name)
+}
+
+// LockTableData is the data for the SQL "lock table" statement.
+// Mode is either tx.LOCK_MODE_S (share mode) or tx.LOCK_MODE_X (exclusive mode).
+type LockTableData struct {
+	Table string
+	Mode  tx.LockMode
+}
+
+func NewLockTableData(table string, mode tx.LockMode) *LockTableData {
+	return &LockTableData{
+		Table: table,
+		Mode:  mode,
+	}
+}
+
+func (l *LockTableData) String() string {
+	mode := "share"
+	if l.Mode == tx.LOCK_MODE_X {
+		mode = "exclusive"
+	}
+	return fmt.Sprintf("lock table %s in %s mode", l.Table, mode)
+}
+
+// VerifyDatabaseData is the data for the SQL "verify database" statement, which checks the checksums of all the blocks.
+type VerifyDatabaseData struct{}
+
+func NewVerifyDatabaseData() *VerifyDatabaseData {
+	return &VerifyDatabaseData{}
+}
+
+func (v *VerifyDatabaseData) String() string {
+	return "verify database"
+}
+
+// VacuumData is the data for the SQL "vacuum" statement, which compacts the records of the table, or of all the tables if Table is empty.
+type VacuumData struct {
+	Table string
+}
+
+func NewVacuumData(table string) *VacuumData {
+	return &VacuumData{Table: table}
+}
+
+func (v *VacuumData) String() string {
+	if v.Table == "" {
+		return "vacuum"
+	}
+	return "vacuum " + v.Table
+}
+
+// AnalyzeData is the data for the SQL "analyze" statement, which stores the statistics of the table, or of all the tables if Table is empty.
+type AnalyzeData struct {
+	Table string
+}
+
+func NewAnalyzeData(table string) *AnalyzeData {
+	return &AnalyzeData{Table: table}
+}
+
+func (a *AnalyzeData) String() string {
+	if a.Table == "" {
+		return "analyze"
+	}
+	return "analyze " + a.Table
+}
+
+// DropTableData is the data for the SQL "drop table" statement.
+// With IfExists, dropping a table that does not exist is not an error. With Cascade, the views depending on the table are dropped too.
+type DropTableData struct {
+	Table    string
+	IfExists bool
+	Cascade  bool
+}
+
+func NewDropTableData(table string, ifExists, cascade bool) *DropTableData {
+	return &DropTableData{
+		Table:    table,
+		IfExists: ifExists,
+		Cascade:  cascade,
+	}
+}
+
+func (d *DropTableData) String() string {
+	return "drop table " + dropString(d.Table, d.IfExists, d.Cascade)
+}
+
+// DropViewData is the data for the SQL "drop view" statement. IfExists and Cascade are as in DropTableData.
+type DropViewData struct {
+	View     string
+	IfExists bool
+	Cascade  bool
+}
+
+func NewDropViewData(view string, ifExists, cascade bool) *DropViewData {
+	return &DropViewData{
+		View:     view,
+		IfExists: ifExists,
+		Cascade:  cascade,
+	}
+}
+
+func (d *DropViewData) String() string {
+	return "drop view " + dropString(d.View, d.IfExists, d.Cascade)
+}
+
+// DropIndexData is the data for the SQL "drop index" statement. IfExists is as in DropTableData.
+type DropIndexData struct {
+	Idx      string
+	IfExists bool
+}
+
+func NewDropIndexData(idx string, ifExists bool) *DropIndexData {
+	return &DropIndexData{
+		Idx:      idx,
+		IfExists: ifExists,
+	}
+}
+
+func (d *DropIndexData) String() string {
+	return "drop index " + dropString(d.Idx, d.IfExists, false)
+}
+
+func dropString(name string, ifExists, cascade bool) string {
+	if ifExists {
+		name = "if exists " + name
+	}
+	if cascade {
+		name += " cascade"
+	}
+	return name
+}
+
+type AlterAction int
+
+const (
+	ALTER_ACTION_ADD_COLUMN AlterAction = iota + 1
+	ALTER_ACTION_DROP_COLUMN
+	ALTER_ACTION_RENAME_COLUMN
+	ALTER_ACTION_RENAME_TABLE
+)
+
+// AlterTableData is the data for the SQL "alter table" statement. Which fields are set depends on Action:
+// ADD COLUMN sets Field, Type, Length and optionally Default, DROP COLUMN sets Field,
+// RENAME COLUMN sets Field and NewName, and RENAME TO sets NewName.
+type AlterTableData struct {
+	Table   string
+	Action  AlterAction
+	Field   string
+	NewName string
+	Type    record.SchemaType
+	Length  int
+	Default *constant.Const
+}
+
+func NewAddColumnData(table, field string, typ record.SchemaType, length int, def *constant.Const) *AlterTableData {
+	return &AlterTableData{
+		Table:   table,
+		Action:  ALTER_ACTION_ADD_COLUMN,
+		Field:   field,
+		Type:    typ,
+		Length:  length,
+		Default: def,
+	}
+}
+
+func NewDropColumnData(table, field string) *AlterTableData {
+	return &AlterTableData{
+		Table:  table,
+		Action: ALTER_ACTION_DROP_COLUMN,
+		Field:  field,
+	}
+}
+
+func NewRenameColumnData(table, field, newName string) *AlterTableData {
+	return &AlterTableData{
+		Table:   table,
+		Action:  ALTER_ACTION_RENAME_COLUMN,
+		Field:   field,
+		NewName: newName,
+	}
+}
+
+func NewRenameTableData(table, newName string) *AlterTableData {
+	return &AlterTableData{
+		Table:   table,
+		Action:  ALTER_ACTION_RENAME_TABLE,
+		NewName: newName,
+	}
+}
+
+func (a *AlterTableData) String() string {
+	prefix := "alter table " + a.Table
+	switch a.Action {
+	case ALTER_ACTION_ADD_COLUMN:
+		str := fmt.Sprintf("%s add column %s %s", prefix, a.Field, typeString(a.Type, a.Length))
+		if a.Default != nil {
+			str += " default " + literal(a.Default)
+		}
+		return str
+	case ALTER_ACTION_DROP_COLUMN:
+		return fmt.Sprintf("%s drop column %s", prefix, a.Field)
+	case ALTER_ACTION_RENAME_COLUMN:
+		return fmt.Sprintf("%s rename column %s to %s", prefix, a.Field, a.NewName)
+	case ALTER_ACTION_RENAME_TABLE:
+		return fmt.Sprintf("%s rename to %s", prefix, a.NewName)
+	}
+	return prefix
+}
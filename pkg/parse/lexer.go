@@ -2,7 +2,10 @@ package parse
 
 import (
 	"bufio"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -16,6 +19,8 @@ const (
 	TokenNumber
 	TokenString
 	TokenOther
+	TokenFloat
+	TokenBytes
 )
 
 const (
@@ -47,6 +52,50 @@ var keywords = []string{
 	"as",
 	"index",
 	"on",
+	"transaction",
+	"isolation",
+	"level",
+	"read",
+	"write",
+	"only",
+	"uncommitted",
+	"committed",
+	"repeatable",
+	"serializable",
+	"savepoint",
+	"rollback",
+	"release",
+	"to",
+	"lock",
+	"in",
+	"share",
+	"exclusive",
+	"mode",
+	"verify",
+	"database",
+	"vacuum",
+	"analyze",
+	"drop",
+	"if",
+	"exists",
+	"cascade",
+	"alter",
+	"add",
+	"column",
+	"rename",
+	"default",
+	"null",
+	"is",
+	"not",
+	"bigint",
+	"boolean",
+	"double",
+	"date",
+	"timestamp",
+	"blob",
+	"text",
+	"true",
+	"false",
 }
 
 // Lexer is the lexical analyzer.
@@ -55,7 +104,11 @@ type Lexer struct {
 	tok      *bufio.Scanner
 	typ      TokenType
 	strVal   string
-	numVal   int
+	numVal   int64
+	// numErr is why the current integer token has no value, if it is out of the 64-bit range
+	numErr   error
+	floatVal float64
+	bytesVal []byte
 }
 
 func scanSQLChars(data []byte, atEOF bool) (advance int, token []byte, err error) {
@@ -75,6 +128,27 @@ func scanSQLChars(data []byte, atEOF bool) (advance int, token []byte, err error
 		return start + 1, data[start : start+1], nil
 	}
 
+	// A minus sign is a token of its own unless a number follows it, as in -1 or -.5
+	if data[start] == '-' {
+		if start+1 >= len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if start+1 >= len(data) || !(isDigit(data[start+1]) || data[start+1] == '.') {
+			return start + 1, data[start : start+1], nil
+		}
+	}
+
+	// String constants, which may contain spaces and delimiters
+	if data[start] == DelimiterSingle {
+		if end := strings.IndexByte(string(data[start+1:]), DelimiterSingle); end >= 0 {
+			return start + end + 2, data[start : start+end+2], nil
+		}
+		if atEOF {
+			return len(data), data[start:], nil
+		}
+		return 0, nil, nil
+	}
+
 	// Collect token until delimiter or space
 	for i := start; i < len(data); i++ {
 		if data[i] == DelimiterSpace || strings.ContainsRune("(),=", rune(data[i])) {
@@ -109,12 +183,11 @@ func initKeywords() map[string]bool {
 }
 
 // matchDelim returns true if the current token is the specified delimiter character.
+// The value of a number, string or byte string token is not a delimiter even if it starts with one.
 func (l *Lexer) MatchDelim(d rune) bool {
-	// ttype == 'W and sval == d
-	// '=' のケースに対応
-	// if l.MatchKeyword(string(d)) && len(l.sval) == 1 {
-	// 	return rune(l.sval[0]) == d
-	// }
+	if (l.typ != TokenWord && l.typ != TokenOther) || l.strVal == "" {
+		return false
+	}
 	return d == rune(l.strVal[0])
 }
 
@@ -128,6 +201,16 @@ func (l *Lexer) MatchStringConstant() bool {
 	return l.typ == TokenString
 }
 
+// MatchFloatConstant returns true if the current token is a number with a fraction or an exponent, like 1.5 or 2e3.
+func (l *Lexer) MatchFloatConstant() bool {
+	return l.typ == TokenFloat
+}
+
+// MatchBytesConstant returns true if the current token is a hex byte string, like x'0aff'.
+func (l *Lexer) MatchBytesConstant() bool {
+	return l.typ == TokenBytes
+}
+
 // matchKeyword returns true if the current token is the specified keyword.
 func (l *Lexer) MatchKeyword(w string) bool {
 	return l.typ == TokenWord && l.strVal == w
@@ -148,10 +231,26 @@ func (l *Lexer) EatDelim(d rune) error {
 }
 
 // eatIntConstant throws an exception if the current token is not an integer. Otherwise, returns that integer and moves to the next token.
+// It returns an error if the integer does not fit in the 32 bits of an int.
 func (l *Lexer) EatIntConstant() (int, error) {
+	if l.matchIntConstant() && l.numErr == nil && (l.numVal < math.MinInt32 || l.numVal > math.MaxInt32) {
+		return 0, fmt.Errorf("%w: integer %d is out of the range of int", errBadSyntax, l.numVal)
+	}
+	i, err := l.EatInt64Constant()
+	if err != nil {
+		return 0, err
+	}
+	return int(i), nil
+}
+
+// EatInt64Constant returns the integer if the current token is one that fits in 64 bits, and moves to the next token.
+func (l *Lexer) EatInt64Constant() (int64, error) {
 	if !l.matchIntConstant() {
 		return 0, errBadSyntax
 	}
+	if l.numErr != nil {
+		return 0, l.numErr
+	}
 	i := l.numVal
 	l.nextToken()
 	return i, nil
@@ -167,6 +266,26 @@ func (l *Lexer) EatStringConstant() (string, error) {
 	return s, nil
 }
 
+// EatFloatConstant returns the number if the current token is a float and moves to the next token.
+func (l *Lexer) EatFloatConstant() (float64, error) {
+	if !l.MatchFloatConstant() {
+		return 0, errBadSyntax
+	}
+	f := l.floatVal
+	l.nextToken()
+	return f, nil
+}
+
+// EatBytesConstant returns the bytes if the current token is a hex byte string and moves to the next token.
+func (l *Lexer) EatBytesConstant() ([]byte, error) {
+	if !l.MatchBytesConstant() {
+		return nil, errBadSyntax
+	}
+	b := l.bytesVal
+	l.nextToken()
+	return b, nil
+}
+
 // eatKeyword throws an exception if the current token is not the specified keyword. Otherwise, moves to the next token.
 func (l *Lexer) EatKeyword(w string) error {
 	if !l.MatchKeyword(w) {
@@ -192,12 +311,40 @@ func (l *Lexer) nextToken() {
 		return
 	}
 	token := l.tok.Text()
-	if numVal, err := strconv.Atoi(token); err == nil {
+	numVal, err := strconv.ParseInt(token, 10, 64)
+	if err == nil || errors.Is(err, strconv.ErrRange) {
 		l.typ = TokenNumber
 		l.numVal = numVal
+		l.numErr = nil
+		if err != nil {
+			l.numErr = fmt.Errorf("%w: integer %s is out of the range of bigint", errBadSyntax, token)
+		}
 		return
 	}
-	if strings.HasPrefix(token, "'") && strings.HasSuffix(token, "'") {
+	if token == "-" {
+		l.typ = TokenOther
+		l.strVal = token
+		return
+	}
+	if digits := strings.TrimLeft(token, "+-"); digits != "" && (isDigit(digits[0]) || (digits[0] == '.' && len(digits) > 1 && isDigit(digits[1]))) {
+		if floatVal, err := strconv.ParseFloat(token, 64); err == nil {
+			l.typ = TokenFloat
+			l.floatVal = floatVal
+			return
+		}
+	}
+	if len(token) >= 3 && (token[0] == 'x' || token[0] == 'X') && token[1] == '\'' && strings.HasSuffix(token, "'") {
+		bytesVal, err := hex.DecodeString(token[2 : len(token)-1])
+		if err != nil {
+			l.typ = TokenUnknown
+			l.strVal = token
+			return
+		}
+		l.typ = TokenBytes
+		l.bytesVal = bytesVal
+		return
+	}
+	if len(token) >= 2 && strings.HasPrefix(token, "'") && strings.HasSuffix(token, "'") {
 		l.typ = TokenString
 		l.strVal = token[1 : len(token)-1]
 		return
@@ -205,3 +352,7 @@ func (l *Lexer) nextToken() {
 	l.typ = TokenWord
 	l.strVal = strings.ToLower(token)
 }
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
@@ -53,4 +53,48 @@ func TestLexer(t *testing.T) {
 
 		assert.Equal(t, "foo", tbl)
 	})
+	t.Run("string with spaces", func(t *testing.T) {
+		lex := NewLexer("name = 'a b, c=d'")
+
+		lex.EatId()
+		lex.EatDelim('=')
+		str, err := lex.EatStringConstant()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "a b, c=d", str)
+	})
+	t.Run("floats and bytes", func(t *testing.T) {
+		lex := NewLexer("1.5 .25 2e3 x'0aff' X'' 'x'")
+
+		for _, want := range []float64{1.5, 0.25, 2000} {
+			f, err := lex.EatFloatConstant()
+			assert.NoError(t, err)
+			assert.Equal(t, want, f)
+		}
+		b, err := lex.EatBytesConstant()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x0a, 0xff}, b)
+		b, err = lex.EatBytesConstant()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{}, b)
+		assert.True(t, lex.MatchStringConstant())
+	})
+	t.Run("integers", func(t *testing.T) {
+		lex := NewLexer("a -1 - 3000000000 99999999999999999999")
+
+		_, err := lex.EatId()
+		assert.NoError(t, err)
+		n, err := lex.EatIntConstant()
+		assert.NoError(t, err)
+		assert.Equal(t, -1, n)
+		assert.True(t, lex.MatchDelim('-'))
+		assert.NoError(t, lex.EatDelim('-'))
+		_, err = lex.EatIntConstant()
+		assert.Error(t, err)
+		big, err := lex.EatInt64Constant()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3000000000), big)
+		_, err = lex.EatInt64Constant()
+		assert.Error(t, err)
+	})
 }
@@ -2,10 +2,13 @@ package parse
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/constant"
 	"github.com/kj455/simple-db/pkg/query"
 	"github.com/kj455/simple-db/pkg/record"
+	"github.com/kj455/simple-db/pkg/tx"
 )
 
 // Parser is the SimpleDB parser.
@@ -21,7 +24,54 @@ func (p *Parser) Field() (string, error) {
 	return p.lexer.EatId()
 }
 
+/*
+Constant parses and returns a constant, or NULL. Besides string and integer constants, these are:
+  - a number with a fraction or an exponent, which is a double
+  - true or false
+  - a hex byte string, like x'0aff'
+  - date 'YYYY-MM-DD' and timestamp 'YYYY-MM-DD HH:MM:SS[.ffffff]', in UTC
+
+An integer is an int if it fits in 32 bits and a bigint otherwise. A number may be preceded by a unary minus.
+*/
 func (p *Parser) Constant() (*constant.Const, error) {
+	if p.lexer.MatchKeyword("null") {
+		return constant.NewNull(), p.lexer.EatKeyword("null")
+	}
+	if p.lexer.MatchDelim('-') {
+		if err := p.lexer.EatDelim('-'); err != nil {
+			return nil, err
+		}
+		cons, err := p.Constant()
+		if err != nil {
+			return nil, err
+		}
+		return negate(cons)
+	}
+	for _, b := range []string{"true", "false"} {
+		if p.lexer.MatchKeyword(b) {
+			if err := p.lexer.EatKeyword(b); err != nil {
+				return nil, err
+			}
+			return constant.NewConstant(constant.KIND_BOOL, b == "true")
+		}
+	}
+	if p.lexer.MatchFloatConstant() {
+		f, err := p.lexer.EatFloatConstant()
+		if err != nil {
+			return nil, fmt.Errorf("parse: invalid double constant: %w", err)
+		}
+		return constant.NewConstant(constant.KIND_FLOAT64, f)
+	}
+	if p.lexer.MatchBytesConstant() {
+		b, err := p.lexer.EatBytesConstant()
+		if err != nil {
+			return nil, fmt.Errorf("parse: invalid byte string constant: %w", err)
+		}
+		return constant.NewConstant(constant.KIND_BYTES, b)
+	}
+	if p.lexer.MatchKeyword("date") || p.lexer.MatchKeyword("timestamp") {
+		return p.timeConstant()
+	}
 	if p.lexer.MatchStringConstant() {
 		str, err := p.lexer.EatStringConstant()
 		if err != nil {
@@ -34,19 +84,51 @@ func (p *Parser) Constant() (*constant.Const, error) {
 		return cons, nil
 	}
 	if p.lexer.matchIntConstant() {
-		num, err := p.lexer.EatIntConstant()
+		num, err := p.lexer.EatInt64Constant()
 		if err != nil {
 			return nil, fmt.Errorf("parse: invalid integer constant: %w", err)
 		}
-		cons, err := constant.NewConstant(constant.KIND_INT, num)
-		if err != nil {
-			return nil, fmt.Errorf("parse: invalid integer constant: %w", err)
-		}
-		return cons, nil
+		return constant.NewInt(num), nil
 	}
 	return nil, fmt.Errorf("parse: invalid constant")
 }
 
+// negate returns the number with the opposite sign.
+func negate(cons *constant.Const) (*constant.Const, error) {
+	switch cons.Kind() {
+	case constant.KIND_INT, constant.KIND_INT64:
+		num, _ := cons.AsInt64()
+		if num == math.MinInt64 {
+			return nil, fmt.Errorf("parse: -(%d) is out of the range of bigint", num)
+		}
+		return constant.NewInt(-num), nil
+	case constant.KIND_FLOAT64:
+		f, _ := cons.AsFloat64()
+		return constant.NewConstant(constant.KIND_FLOAT64, -f)
+	}
+	return nil, fmt.Errorf("parse: unary minus cannot be applied to %s", cons.ToString())
+}
+
+func (p *Parser) timeConstant() (*constant.Const, error) {
+	keyword, kind, layout := "date", constant.KIND_DATE, constant.DATE_FORMAT
+	if p.lexer.MatchKeyword("timestamp") {
+		// the fraction of the seconds is optional when parsing
+		keyword, kind, layout = "timestamp", constant.KIND_TIMESTAMP, "2006-01-02 15:04:05"
+	}
+	if err := p.lexer.EatKeyword(keyword); err != nil {
+		return nil, err
+	}
+	str, err := p.lexer.EatStringConstant()
+	if err != nil {
+		return nil, fmt.Errorf("parse: expected a string after %s: %w", keyword, err)
+	}
+	t, err := time.Parse(layout, str)
+	if err != nil {
+		return nil, fmt.Errorf("parse: invalid %s constant '%s': %w", keyword, str, err)
+	}
+	return constant.NewConstant(kind, t)
+}
+
 // Expression parses and returns an expression.
 func (p *Parser) Expression() (query.Expression, error) {
 	if p.lexer.MatchId() {
@@ -63,12 +145,27 @@ func (p *Parser) Expression() (query.Expression, error) {
 	return query.NewConstantExpression(constant), nil
 }
 
-// Term parses and returns a term.
+// Term parses and returns a term, which is either "<expression> = <expression>" or "<expression> is [not] null".
 func (p *Parser) Term() (*query.Term, error) {
 	lhs, err := p.Expression()
 	if err != nil {
 		return nil, err
 	}
+	if p.lexer.MatchKeyword("is") {
+		if err := p.lexer.EatKeyword("is"); err != nil {
+			return nil, err
+		}
+		not := p.lexer.MatchKeyword("not")
+		if not {
+			if err := p.lexer.EatKeyword("not"); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.lexer.EatKeyword("null"); err != nil {
+			return nil, fmt.Errorf("expected 'null' in term: %w", err)
+		}
+		return query.NewIsNullTerm(lhs, not), nil
+	}
 	if err := p.lexer.EatDelim('='); err != nil {
 		return nil, fmt.Errorf("expected '=' in term: %w", err)
 	}
@@ -179,6 +276,36 @@ func (p *Parser) UpdateCmd() (Data, error) {
 	if p.lexer.MatchKeyword("create") {
 		return p.create()
 	}
+	if p.lexer.MatchKeyword("set") {
+		return p.SetTransaction()
+	}
+	if p.lexer.MatchKeyword("savepoint") {
+		return p.Savepoint()
+	}
+	if p.lexer.MatchKeyword("rollback") {
+		return p.RollbackToSavepoint()
+	}
+	if p.lexer.MatchKeyword("release") {
+		return p.ReleaseSavepoint()
+	}
+	if p.lexer.MatchKeyword("lock") {
+		return p.LockTable()
+	}
+	if p.lexer.MatchKeyword("verify") {
+		return p.VerifyDatabase()
+	}
+	if p.lexer.MatchKeyword("vacuum") {
+		return p.Vacuum()
+	}
+	if p.lexer.MatchKeyword("analyze") {
+		return p.Analyze()
+	}
+	if p.lexer.MatchKeyword("drop") {
+		return p.drop()
+	}
+	if p.lexer.MatchKeyword("alter") {
+		return p.AlterTable()
+	}
 	return nil, fmt.Errorf("parse: invalid command")
 }
 
@@ -385,30 +512,55 @@ func (p *Parser) fieldDef() (record.Schema, error) {
 	return p.fieldType(field)
 }
 
+// fixedSizeTypes are the types whose name is all there is to them.
+var fixedSizeTypes = map[string]record.SchemaType{
+	"int":       record.SCHEMA_TYPE_INTEGER,
+	"bigint":    record.SCHEMA_TYPE_BIGINT,
+	"boolean":   record.SCHEMA_TYPE_BOOLEAN,
+	"double":    record.SCHEMA_TYPE_DOUBLE,
+	"date":      record.SCHEMA_TYPE_DATE,
+	"timestamp": record.SCHEMA_TYPE_TIMESTAMP,
+	"text":      record.SCHEMA_TYPE_TEXT,
+}
+
+// sizedTypes are the types that take a length in parentheses.
+var sizedTypes = map[string]record.SchemaType{
+	"varchar": record.SCHEMA_TYPE_VARCHAR,
+	"blob":    record.SCHEMA_TYPE_BLOB,
+}
+
 func (p *Parser) fieldType(field string) (record.Schema, error) {
 	schema := record.NewSchema()
-	if p.lexer.MatchKeyword("int") {
-		if err := p.lexer.EatKeyword("int"); err != nil {
-			return nil, err
+	for name, typ := range fixedSizeTypes {
+		if p.lexer.MatchKeyword(name) {
+			if err := p.lexer.EatKeyword(name); err != nil {
+				return nil, err
+			}
+			schema.AddField(field, typ, 0)
+			return schema, nil
 		}
-		schema.AddIntField(field)
-		return schema, nil
 	}
-	if p.lexer.MatchKeyword("varchar") {
-		if err := p.lexer.EatKeyword("varchar"); err != nil {
+	for name, typ := range sizedTypes {
+		if !p.lexer.MatchKeyword(name) {
+			continue
+		}
+		if err := p.lexer.EatKeyword(name); err != nil {
 			return nil, err
 		}
 		if err := p.lexer.EatDelim('('); err != nil {
 			return nil, err
 		}
-		strLen, err := p.lexer.EatIntConstant()
+		length, err := p.lexer.EatIntConstant()
 		if err != nil {
 			return nil, err
 		}
+		if length < 0 {
+			return nil, fmt.Errorf("parse: length of %s must not be negative: %w", field, errBadSyntax)
+		}
 		if err := p.lexer.EatDelim(')'); err != nil {
 			return nil, err
 		}
-		schema.AddStringField(field, strLen)
+		schema.AddField(field, typ, length)
 	}
 	return schema, nil
 }
@@ -460,3 +612,370 @@ func (p *Parser) CreateIndex() (*CreateIndexData, error) {
 	}
 	return NewCreateIndexData(idx, table, field), nil
 }
+
+// SetTransaction parses and returns a set transaction data.
+// The statement takes one or more comma separated modes:
+// "isolation level <level>", "read only" or "read write".
+func (p *Parser) SetTransaction() (*SetTransactionData, error) {
+	if err := p.lexer.EatKeyword("set"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("transaction"); err != nil {
+		return nil, err
+	}
+	data := NewSetTransactionData(0, false, false)
+	for {
+		if err := p.transactionMode(data); err != nil {
+			return nil, err
+		}
+		if !p.lexer.MatchDelim(',') {
+			return data, nil
+		}
+		if err := p.lexer.EatDelim(','); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *Parser) transactionMode(data *SetTransactionData) error {
+	if p.lexer.MatchKeyword("isolation") {
+		if err := p.lexer.EatKeyword("isolation"); err != nil {
+			return err
+		}
+		if err := p.lexer.EatKeyword("level"); err != nil {
+			return err
+		}
+		level, err := p.isolationLevel()
+		if err != nil {
+			return err
+		}
+		data.Isolation = level
+		return nil
+	}
+	if err := p.lexer.EatKeyword("read"); err != nil {
+		return err
+	}
+	data.HasAccessMode = true
+	if p.lexer.MatchKeyword("only") {
+		data.ReadOnly = true
+		return p.lexer.EatKeyword("only")
+	}
+	data.ReadOnly = false
+	return p.lexer.EatKeyword("write")
+}
+
+func (p *Parser) isolationLevel() (tx.IsolationLevel, error) {
+	if p.lexer.MatchKeyword("serializable") {
+		return tx.ISOLATION_LEVEL_SERIALIZABLE, p.lexer.EatKeyword("serializable")
+	}
+	if p.lexer.MatchKeyword("repeatable") {
+		if err := p.lexer.EatKeyword("repeatable"); err != nil {
+			return 0, err
+		}
+		return tx.ISOLATION_LEVEL_REPEATABLE_READ, p.lexer.EatKeyword("read")
+	}
+	if err := p.lexer.EatKeyword("read"); err != nil {
+		return 0, fmt.Errorf("parse: invalid isolation level: %w", err)
+	}
+	if p.lexer.MatchKeyword("committed") {
+		return tx.ISOLATION_LEVEL_READ_COMMITTED, p.lexer.EatKeyword("committed")
+	}
+	if p.lexer.MatchKeyword("uncommitted") {
+		return tx.ISOLATION_LEVEL_READ_UNCOMMITTED, p.lexer.EatKeyword("uncommitted")
+	}
+	return 0, fmt.Errorf("parse: invalid isolation level: %w", errBadSyntax)
+}
+
+// Savepoint parses and returns a savepoint data.
+func (p *Parser) Savepoint() (*SavepointData, error) {
+	if err := p.lexer.EatKeyword("savepoint"); err != nil {
+		return nil, err
+	}
+	name, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewSavepointData(name), nil
+}
+
+// RollbackToSavepoint parses and returns a rollback to savepoint data.
+// The "savepoint" keyword is optional.
+func (p *Parser) RollbackToSavepoint() (*RollbackToSavepointData, error) {
+	if err := p.lexer.EatKeyword("rollback"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("to"); err != nil {
+		return nil, err
+	}
+	name, err := p.savepointName()
+	if err != nil {
+		return nil, err
+	}
+	return NewRollbackToSavepointData(name), nil
+}
+
+// ReleaseSavepoint parses and returns a release savepoint data.
+// The "savepoint" keyword is optional.
+func (p *Parser) ReleaseSavepoint() (*ReleaseSavepointData, error) {
+	if err := p.lexer.EatKeyword("release"); err != nil {
+		return nil, err
+	}
+	name, err := p.savepointName()
+	if err != nil {
+		return nil, err
+	}
+	return NewReleaseSavepointData(name), nil
+}
+
+func (p *Parser) savepointName() (string, error) {
+	if p.lexer.MatchKeyword("savepoint") {
+		if err := p.lexer.EatKeyword("savepoint"); err != nil {
+			return "", err
+		}
+	}
+	return p.lexer.EatId()
+}
+
+// LockTable parses and returns a lock table data.
+func (p *Parser) LockTable() (*LockTableData, error) {
+	if err := p.lexer.EatKeyword("lock"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("table"); err != nil {
+		return nil, err
+	}
+	table, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("in"); err != nil {
+		return nil, err
+	}
+	mode := tx.LOCK_MODE_S
+	if p.lexer.MatchKeyword("exclusive") {
+		if err := p.lexer.EatKeyword("exclusive"); err != nil {
+			return nil, err
+		}
+		mode = tx.LOCK_MODE_X
+	} else if err := p.lexer.EatKeyword("share"); err != nil {
+		return nil, fmt.Errorf("parse: invalid lock mode: %w", err)
+	}
+	if err := p.lexer.EatKeyword("mode"); err != nil {
+		return nil, err
+	}
+	return NewLockTableData(table, mode), nil
+}
+
+// VerifyDatabase parses and returns a verify database data.
+func (p *Parser) VerifyDatabase() (*VerifyDatabaseData, error) {
+	if err := p.lexer.EatKeyword("verify"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("database"); err != nil {
+		return nil, err
+	}
+	return NewVerifyDatabaseData(), nil
+}
+
+// Vacuum parses and returns a vacuum data. The table is optional.
+func (p *Parser) Vacuum() (*VacuumData, error) {
+	if err := p.lexer.EatKeyword("vacuum"); err != nil {
+		return nil, err
+	}
+	if !p.lexer.MatchId() {
+		return NewVacuumData(""), nil
+	}
+	table, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewVacuumData(table), nil
+}
+
+// Analyze parses and returns an analyze data. The table is optional.
+func (p *Parser) Analyze() (*AnalyzeData, error) {
+	if err := p.lexer.EatKeyword("analyze"); err != nil {
+		return nil, err
+	}
+	if !p.lexer.MatchId() {
+		return NewAnalyzeData(""), nil
+	}
+	table, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	return NewAnalyzeData(table), nil
+}
+
+func (p *Parser) drop() (Data, error) {
+	if err := p.lexer.EatKeyword("drop"); err != nil {
+		return nil, err
+	}
+	if p.lexer.MatchKeyword("table") {
+		return p.DropTable()
+	}
+	if p.lexer.MatchKeyword("view") {
+		return p.DropView()
+	}
+	if p.lexer.MatchKeyword("index") {
+		return p.DropIndex()
+	}
+	return nil, fmt.Errorf("parse: invalid command")
+}
+
+func (p *Parser) DropTable() (*DropTableData, error) {
+	if err := p.lexer.EatKeyword("table"); err != nil {
+		return nil, err
+	}
+	table, ifExists, cascade, err := p.dropTarget(true)
+	if err != nil {
+		return nil, err
+	}
+	return NewDropTableData(table, ifExists, cascade), nil
+}
+
+func (p *Parser) DropView() (*DropViewData, error) {
+	if err := p.lexer.EatKeyword("view"); err != nil {
+		return nil, err
+	}
+	view, ifExists, cascade, err := p.dropTarget(true)
+	if err != nil {
+		return nil, err
+	}
+	return NewDropViewData(view, ifExists, cascade), nil
+}
+
+func (p *Parser) DropIndex() (*DropIndexData, error) {
+	if err := p.lexer.EatKeyword("index"); err != nil {
+		return nil, err
+	}
+	idx, ifExists, _, err := p.dropTarget(false)
+	if err != nil {
+		return nil, err
+	}
+	return NewDropIndexData(idx, ifExists), nil
+}
+
+// dropTarget parses "[if exists] name", followed by an optional "cascade" if allowed.
+func (p *Parser) dropTarget(allowCascade bool) (name string, ifExists, cascade bool, err error) {
+	if p.lexer.MatchKeyword("if") {
+		if err := p.lexer.EatKeyword("if"); err != nil {
+			return "", false, false, err
+		}
+		if err := p.lexer.EatKeyword("exists"); err != nil {
+			return "", false, false, err
+		}
+		ifExists = true
+	}
+	name, err = p.lexer.EatId()
+	if err != nil {
+		return "", false, false, err
+	}
+	if allowCascade && p.lexer.MatchKeyword("cascade") {
+		if err := p.lexer.EatKeyword("cascade"); err != nil {
+			return "", false, false, err
+		}
+		cascade = true
+	}
+	return name, ifExists, cascade, nil
+}
+
+// AlterTable parses and returns an alter table data. The statement takes one of
+// "add [column] <field> <type> [default <constant>]", "drop [column] <field>",
+// "rename [column] <field> to <name>" or "rename to <name>".
+func (p *Parser) AlterTable() (*AlterTableData, error) {
+	if err := p.lexer.EatKeyword("alter"); err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("table"); err != nil {
+		return nil, err
+	}
+	table, err := p.lexer.EatId()
+	if err != nil {
+		return nil, err
+	}
+	if p.lexer.MatchKeyword("add") {
+		return p.addColumn(table)
+	}
+	if p.lexer.MatchKeyword("drop") {
+		if err := p.lexer.EatKeyword("drop"); err != nil {
+			return nil, err
+		}
+		field, err := p.columnName()
+		if err != nil {
+			return nil, err
+		}
+		return NewDropColumnData(table, field), nil
+	}
+	if err := p.lexer.EatKeyword("rename"); err != nil {
+		return nil, fmt.Errorf("parse: invalid alter table action: %w", err)
+	}
+	if p.lexer.MatchKeyword("to") {
+		if err := p.lexer.EatKeyword("to"); err != nil {
+			return nil, err
+		}
+		newName, err := p.lexer.EatId()
+		if err != nil {
+			return nil, err
+		}
+		return NewRenameTableData(table, newName), nil
+	}
+	field, err := p.columnName()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.lexer.EatKeyword("to"); err != nil {
+		return nil, err
+	}
+	newName, err := p.Field()
+	if err != nil {
+		return nil, err
+	}
+	return NewRenameColumnData(table, field, newName), nil
+}
+
+func (p *Parser) addColumn(table string) (*AlterTableData, error) {
+	if err := p.lexer.EatKeyword("add"); err != nil {
+		return nil, err
+	}
+	field, err := p.columnName()
+	if err != nil {
+		return nil, err
+	}
+	sch, err := p.fieldType(field)
+	if err != nil {
+		return nil, err
+	}
+	if !sch.HasField(field) {
+		return nil, fmt.Errorf("parse: invalid type of %s: %w", field, errBadSyntax)
+	}
+	typ, err := sch.Type(field)
+	if err != nil {
+		return nil, err
+	}
+	length, err := sch.Length(field)
+	if err != nil {
+		return nil, err
+	}
+	var def *constant.Const
+	if p.lexer.MatchKeyword("default") {
+		if err := p.lexer.EatKeyword("default"); err != nil {
+			return nil, err
+		}
+		def, err = p.Constant()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewAddColumnData(table, field, typ, length, def), nil
+}
+
+// columnName parses a field name, preceded by an optional "column" keyword.
+func (p *Parser) columnName() (string, error) {
+	if p.lexer.MatchKeyword("column") {
+		if err := p.lexer.EatKeyword("column"); err != nil {
+			return "", err
+		}
+	}
+	return p.Field()
+}
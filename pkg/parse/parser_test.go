@@ -1,8 +1,12 @@
 package parse
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/kj455/simple-db/pkg/constant"
+	"github.com/kj455/simple-db/pkg/record"
+	"github.com/kj455/simple-db/pkg/tx"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -70,3 +74,358 @@ func TestParser_String(t *testing.T) {
 		})
 	})
 }
+
+func TestParser_SetTransaction(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input  string
+		expect *SetTransactionData
+	}{
+		{
+			input:  "set transaction isolation level read uncommitted",
+			expect: NewSetTransactionData(tx.ISOLATION_LEVEL_READ_UNCOMMITTED, false, false),
+		},
+		{
+			input:  "set transaction isolation level read committed",
+			expect: NewSetTransactionData(tx.ISOLATION_LEVEL_READ_COMMITTED, false, false),
+		},
+		{
+			input:  "set transaction isolation level repeatable read, read only",
+			expect: NewSetTransactionData(tx.ISOLATION_LEVEL_REPEATABLE_READ, true, true),
+		},
+		{
+			input:  "set transaction read write, isolation level serializable",
+			expect: NewSetTransactionData(tx.ISOLATION_LEVEL_SERIALIZABLE, true, false),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			data, err := NewParser(tt.input).UpdateCmd()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, data)
+		})
+	}
+	t.Run("invalid level", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewParser("set transaction isolation level snapshot").UpdateCmd()
+		assert.Error(t, err)
+	})
+	t.Run("string", func(t *testing.T) {
+		t.Parallel()
+		s := "set transaction isolation level repeatable read, read only"
+		data, err := NewParser(s).SetTransaction()
+		assert.NoError(t, err)
+		assert.Equal(t, s, data.String())
+	})
+}
+
+func TestParser_Savepoint(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input  string
+		expect any
+		str    string
+	}{
+		{
+			input:  "savepoint sp1",
+			expect: NewSavepointData("sp1"),
+			str:    "savepoint sp1",
+		},
+		{
+			input:  "rollback to savepoint sp1",
+			expect: NewRollbackToSavepointData("sp1"),
+			str:    "rollback to savepoint sp1",
+		},
+		{
+			input:  "rollback to sp1",
+			expect: NewRollbackToSavepointData("sp1"),
+			str:    "rollback to savepoint sp1",
+		},
+		{
+			input:  "release savepoint sp1",
+			expect: NewReleaseSavepointData("sp1"),
+			str:    "release savepoint sp1",
+		},
+		{
+			input:  "release sp1",
+			expect: NewReleaseSavepointData("sp1"),
+			str:    "release savepoint sp1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			data, err := NewParser(tt.input).UpdateCmd()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, data)
+			assert.Equal(t, tt.str, data.(fmt.Stringer).String())
+		})
+	}
+	t.Run("missing name", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewParser("rollback to savepoint").UpdateCmd()
+		assert.Error(t, err)
+	})
+}
+
+func TestParser_LockTable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input  string
+		expect *LockTableData
+	}{
+		{
+			input:  "lock table student in share mode",
+			expect: NewLockTableData("student", tx.LOCK_MODE_S),
+		},
+		{
+			input:  "lock table student in exclusive mode",
+			expect: NewLockTableData("student", tx.LOCK_MODE_X),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			data, err := NewParser(tt.input).UpdateCmd()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, data)
+			assert.Equal(t, tt.input, data.(fmt.Stringer).String())
+		})
+	}
+	t.Run("invalid mode", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewParser("lock table student in row mode").UpdateCmd()
+		assert.Error(t, err)
+	})
+}
+
+func TestParser_VerifyDatabase(t *testing.T) {
+	t.Parallel()
+	data, err := NewParser("verify database").UpdateCmd()
+	assert.NoError(t, err)
+	assert.Equal(t, NewVerifyDatabaseData(), data)
+	assert.Equal(t, "verify database", data.(fmt.Stringer).String())
+
+	_, err = NewParser("verify table").UpdateCmd()
+	assert.Error(t, err)
+}
+
+func TestParser_Vacuum(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		s     string
+		table string
+	}{
+		{s: "vacuum", table: ""},
+		{s: "vacuum student", table: "student"},
+	} {
+		data, err := NewParser(tt.s).UpdateCmd()
+		assert.NoError(t, err)
+		assert.Equal(t, NewVacuumData(tt.table), data)
+		assert.Equal(t, tt.s, data.(fmt.Stringer).String())
+	}
+}
+
+func TestParser_Analyze(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		s     string
+		table string
+	}{
+		{s: "analyze", table: ""},
+		{s: "analyze student", table: "student"},
+	} {
+		data, err := NewParser(tt.s).UpdateCmd()
+		assert.NoError(t, err)
+		assert.Equal(t, NewAnalyzeData(tt.table), data)
+		assert.Equal(t, tt.s, data.(fmt.Stringer).String())
+	}
+}
+
+func TestParser_Drop(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		input  string
+		expect Data
+	}{
+		{input: "drop table student", expect: NewDropTableData("student", false, false)},
+		{input: "drop table if exists student cascade", expect: NewDropTableData("student", true, true)},
+		{input: "drop view if exists seniors", expect: NewDropViewData("seniors", true, false)},
+		{input: "drop view seniors cascade", expect: NewDropViewData("seniors", false, true)},
+		{input: "drop index sid_idx", expect: NewDropIndexData("sid_idx", false)},
+		{input: "drop index if exists sid_idx", expect: NewDropIndexData("sid_idx", true)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			data, err := NewParser(tt.input).UpdateCmd()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, data)
+			assert.Equal(t, tt.input, data.String())
+		})
+	}
+	for _, input := range []string{"drop database", "drop table if student"} {
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewParser(input).UpdateCmd()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParser_AlterTable(t *testing.T) {
+	t.Parallel()
+	mustConst := func(kind constant.Kind, val any) *constant.Const {
+		c, err := constant.NewConstant(kind, val)
+		assert.NoError(t, err)
+		return c
+	}
+	tests := []struct {
+		input  string
+		expect Data
+	}{
+		{input: "alter table student add column gradyear int", expect: NewAddColumnData("student", "gradyear", record.SCHEMA_TYPE_INTEGER, 0, nil)},
+		{input: "alter table student add column gradyear int default 2020", expect: NewAddColumnData("student", "gradyear", record.SCHEMA_TYPE_INTEGER, 0, mustConst(constant.KIND_INT, 2020))},
+		{input: "alter table student add column major varchar(10) default 'math'", expect: NewAddColumnData("student", "major", record.SCHEMA_TYPE_VARCHAR, 10, mustConst(constant.KIND_STR, "math"))},
+		{input: "alter table student drop column major", expect: NewDropColumnData("student", "major")},
+		{input: "alter table student rename column sname to name", expect: NewRenameColumnData("student", "sname", "name")},
+		{input: "alter table student rename to pupil", expect: NewRenameTableData("student", "pupil")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			data, err := NewParser(tt.input).UpdateCmd()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, data)
+			assert.Equal(t, tt.input, data.String())
+		})
+	}
+	t.Run("column keyword is optional", func(t *testing.T) {
+		t.Parallel()
+		data, err := NewParser("alter table student drop major").UpdateCmd()
+		assert.NoError(t, err)
+		assert.Equal(t, NewDropColumnData("student", "major"), data)
+	})
+	for _, input := range []string{"alter table student", "alter table student add column gradyear", "alter table student rename column sname", "alter view seniors rename to juniors"} {
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewParser(input).UpdateCmd()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParser_Null(t *testing.T) {
+	t.Parallel()
+	t.Run("select", func(t *testing.T) {
+		t.Parallel()
+		s := "select foo from tests where foo is null and bar is not null and baz=null"
+		q, err := NewParser(s).Query()
+		assert.NoError(t, err)
+		assert.Equal(t, s, q.String())
+	})
+	t.Run("insert", func(t *testing.T) {
+		t.Parallel()
+		data, err := NewParser("insert into tests(foo, bar) values(null, 1)").Insert()
+		assert.NoError(t, err)
+		assert.True(t, data.Vals[0].IsNull())
+		assert.False(t, data.Vals[1].IsNull())
+	})
+	t.Run("update", func(t *testing.T) {
+		t.Parallel()
+		s := "update tests set foo = null"
+		data, err := NewParser(s).Modify()
+		assert.NoError(t, err)
+		assert.Equal(t, s, data.String())
+	})
+	for _, s := range []string{"select foo from tests where foo is 1", "select foo from tests where null is"} {
+		t.Run(s, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewParser(s).Query()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParser_Types(t *testing.T) {
+	t.Parallel()
+	t.Run("create table", func(t *testing.T) {
+		t.Parallel()
+		s := "create table tests(a bigint, b boolean, c double, d date, e timestamp, f blob(16), g text)"
+		p := NewParser(s)
+		p.lexer.EatKeyword("create")
+		data, err := p.CreateTable()
+		assert.NoError(t, err)
+		assert.Equal(t, s, data.String())
+	})
+	t.Run("constants", func(t *testing.T) {
+		t.Parallel()
+		data, err := NewParser("insert into tests(a, b, c, d, e, f) values(true, false, 1.5, date '2024-02-29', timestamp '2024-02-29 13:04:05.25', x'0aff')").Insert()
+		assert.NoError(t, err)
+		want := []string{"true", "false", "1.5", "2024-02-29", "2024-02-29 13:04:05.25", `\x0aff`}
+		kinds := []constant.Kind{constant.KIND_BOOL, constant.KIND_BOOL, constant.KIND_FLOAT64, constant.KIND_DATE, constant.KIND_TIMESTAMP, constant.KIND_BYTES}
+		for i, val := range data.Vals {
+			assert.Equal(t, kinds[i], val.Kind())
+			assert.Equal(t, want[i], val.ToString())
+		}
+	})
+	t.Run("defaults", func(t *testing.T) {
+		t.Parallel()
+		for _, s := range []string{
+			"alter table tests add column a double default 1.5e+00",
+			"alter table tests add column a date default date '2024-02-29'",
+			"alter table tests add column a timestamp default timestamp '2024-02-29 13:04:05'",
+			"alter table tests add column a blob(2) default x'0aff'",
+			"alter table tests add column a boolean default true",
+		} {
+			data, err := NewParser(s).AlterTable()
+			assert.NoError(t, err)
+			assert.Equal(t, s, data.String())
+		}
+	})
+	for _, s := range []string{
+		"select a from tests where a = date '2024-02-30'",
+		"select a from tests where a = timestamp 1",
+		"select a from tests where a = x'0g'",
+	} {
+		t.Run(s, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewParser(s).Query()
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParser_SignedInt(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		s    string
+		kind constant.Kind
+		want string
+	}{
+		{"-5", constant.KIND_INT, "-5"},
+		{"- 5", constant.KIND_INT, "-5"},
+		{"- -5", constant.KIND_INT, "5"},
+		{"-1.5", constant.KIND_FLOAT64, "-1.5"},
+		{"3000000000", constant.KIND_INT64, "3000000000"},
+		{"-2147483648", constant.KIND_INT, "-2147483648"},
+		{"-9223372036854775808", constant.KIND_INT64, "-9223372036854775808"},
+	}
+	for _, tt := range tests {
+		cons, err := NewParser(tt.s).Constant()
+		assert.NoError(t, err, tt.s)
+		assert.Equal(t, tt.kind, cons.Kind(), tt.s)
+		assert.Equal(t, tt.want, cons.ToString(), tt.s)
+	}
+	for _, s := range []string{"99999999999999999999", "-x", "-'a'", "-true"} {
+		_, err := NewParser(s).Constant()
+		assert.Error(t, err, s)
+	}
+	s := "select a from tests where a=-5"
+	data, err := NewParser(s).Query()
+	assert.NoError(t, err)
+	assert.Equal(t, s, data.String())
+	_, err = NewParser("create table tests(a varchar(-1))").UpdateCmd()
+	assert.Error(t, err)
+}
@@ -21,15 +21,48 @@ func (p *PredParser) Field() (string, error) {
 	return field, nil
 }
 
-// Constant parses either an integer or a string constant.
+// Constant parses a constant, or NULL, as Parser.Constant does.
 func (p *PredParser) Constant() error {
+	if p.lexer.MatchDelim('-') {
+		if err := p.lexer.EatDelim('-'); err != nil {
+			return err
+		}
+		if !p.lexer.matchIntConstant() && !p.lexer.MatchFloatConstant() && !p.lexer.MatchDelim('-') {
+			return fmt.Errorf("expected number after '-': %w", errBadSyntax)
+		}
+		return p.Constant()
+	}
+	for _, kw := range []string{"null", "true", "false"} {
+		if p.lexer.MatchKeyword(kw) {
+			return p.lexer.EatKeyword(kw)
+		}
+	}
+	if p.lexer.MatchFloatConstant() {
+		_, err := p.lexer.EatFloatConstant()
+		return err
+	}
+	if p.lexer.MatchBytesConstant() {
+		_, err := p.lexer.EatBytesConstant()
+		return err
+	}
+	for _, kw := range []string{"date", "timestamp"} {
+		if p.lexer.MatchKeyword(kw) {
+			if err := p.lexer.EatKeyword(kw); err != nil {
+				return err
+			}
+			if _, err := p.lexer.EatStringConstant(); err != nil {
+				return fmt.Errorf("expected string constant after %s: %w", kw, err)
+			}
+			return nil
+		}
+	}
 	if p.lexer.MatchStringConstant() {
 		if _, err := p.lexer.EatStringConstant(); err != nil {
 			return fmt.Errorf("expected string constant: %w", err)
 		}
 		return nil
 	}
-	if _, err := p.lexer.EatIntConstant(); err != nil {
+	if _, err := p.lexer.EatInt64Constant(); err != nil {
 		return fmt.Errorf("expected integer constant: %w", err)
 	}
 	return nil
@@ -47,11 +80,25 @@ func (p *PredParser) Expression() error {
 	return nil
 }
 
-// Term parses an equality condition of the form `expression = expression`.
+// Term parses an equality condition of the form `expression = expression`, or a test of the form `expression is [not] null`.
 func (p *PredParser) Term() error {
 	if err := p.Expression(); err != nil {
 		return fmt.Errorf("invalid term: %w", err)
 	}
+	if p.lexer.MatchKeyword("is") {
+		if err := p.lexer.EatKeyword("is"); err != nil {
+			return err
+		}
+		if p.lexer.MatchKeyword("not") {
+			if err := p.lexer.EatKeyword("not"); err != nil {
+				return err
+			}
+		}
+		if err := p.lexer.EatKeyword("null"); err != nil {
+			return fmt.Errorf("expected 'null' keyword: %w", err)
+		}
+		return nil
+	}
 	if err := p.lexer.EatDelim('='); err != nil {
 		return fmt.Errorf("expected '=' delimiter: %w", err)
 	}
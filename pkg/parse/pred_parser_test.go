@@ -11,6 +11,8 @@ func TestPredParser(t *testing.T) {
 	tests := []string{
 		"foo = 1",
 		"foo = 1 and bar = 2",
+		"foo is null and bar is not null",
+		"foo = null",
 	}
 	for _, tt := range tests {
 		t.Run(tt, func(t *testing.T) {
@@ -10,19 +10,42 @@ import (
 )
 
 type BasicQueryPlanner struct {
-	mdMgr metadata.MetadataMgr
+	mdMgr        metadata.MetadataMgr
+	systemTables map[string]*SystemTable
 }
 
-func NewBasicQueryPlanner(mdMgr metadata.MetadataMgr) *BasicQueryPlanner {
-	return &BasicQueryPlanner{
-		mdMgr: mdMgr,
+type BasicQueryPlannerOption func(*BasicQueryPlanner)
+
+// WithSystemTable makes the system table queryable under the name. It takes precedence over tables and views of the same name.
+func WithSystemTable(name string, table *SystemTable) BasicQueryPlannerOption {
+	return func(bp *BasicQueryPlanner) {
+		bp.systemTables[name] = table
+	}
+}
+
+func NewBasicQueryPlanner(mdMgr metadata.MetadataMgr, opts ...BasicQueryPlannerOption) *BasicQueryPlanner {
+	bp := &BasicQueryPlanner{
+		mdMgr:        mdMgr,
+		systemTables: make(map[string]*SystemTable),
+	}
+	for _, opt := range opts {
+		opt(bp)
 	}
+	return bp
 }
 
 // CreatePlan creates a query plan for the given query data.
 func (bp *BasicQueryPlanner) CreatePlan(data *parse.QueryData, tx tx.Transaction) (Plan, error) {
 	plans := make([]Plan, 0, len(data.Tables))
 	for _, table := range data.Tables {
+		if sys, ok := bp.systemTables[table]; ok {
+			plan, err := NewSystemTablePlan(sys)
+			if err != nil {
+				return nil, fmt.Errorf("plan: failed to create system table plan for %s: %v", table, err)
+			}
+			plans = append(plans, plan)
+			continue
+		}
 		viewDef, err := bp.mdMgr.GetViewDef(table, tx)
 		if err != nil && !errors.Is(err, metadata.ErrViewNotFound) {
 			return nil, fmt.Errorf("plan: failed to get view definition for %s: %v", table, err)
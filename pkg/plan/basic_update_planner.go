@@ -1,25 +1,58 @@
 package plan
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
 
+	"github.com/kj455/simple-db/pkg/constant"
+	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/metadata"
 	"github.com/kj455/simple-db/pkg/parse"
 	"github.com/kj455/simple-db/pkg/query"
+	"github.com/kj455/simple-db/pkg/record"
 	"github.com/kj455/simple-db/pkg/tx"
 )
 
+// STATEMENT_SAVEPOINT is the name of the implicit savepoint taken before each data modification statement.
+// It contains a space so it never collides with a savepoint named by the user.
+const STATEMENT_SAVEPOINT = "statement savepoint"
+
+// ErrTransactionStarted is returned by SET TRANSACTION once the transaction has read or written anything.
+var ErrTransactionStarted = errors.New("planner: set transaction must come before the transaction reads or writes")
+
 type BasicUpdatePlanner struct {
-	mdMgr metadata.MetadataMgr
+	mdMgr   metadata.MetadataMgr
+	fileMgr file.FileMgr
+}
+
+type BasicUpdatePlannerOption func(*BasicUpdatePlanner)
+
+// WithFileMgr gives the planner access to the files of the database, which VERIFY DATABASE requires.
+func WithFileMgr(fm file.FileMgr) BasicUpdatePlannerOption {
+	return func(bp *BasicUpdatePlanner) {
+		bp.fileMgr = fm
+	}
 }
 
-func NewBasicUpdatePlanner(mdMgr metadata.MetadataMgr) *BasicUpdatePlanner {
-	return &BasicUpdatePlanner{
+func NewBasicUpdatePlanner(mdMgr metadata.MetadataMgr, opts ...BasicUpdatePlannerOption) *BasicUpdatePlanner {
+	bp := &BasicUpdatePlanner{
 		mdMgr: mdMgr,
 	}
+	for _, opt := range opts {
+		opt(bp)
+	}
+	return bp
 }
 
 func (bp *BasicUpdatePlanner) ExecuteDelete(data parse.DeleteData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		return bp.executeDelete(data, tx)
+	})
+}
+
+func (bp *BasicUpdatePlanner) executeDelete(data parse.DeleteData, tx tx.Transaction) (int, error) {
 	tablePlan, err := NewTablePlan(tx, data.Table, bp.mdMgr)
 	if err != nil {
 		return 0, fmt.Errorf("planner: failed to create table plan for %s: %v", data.Table, err)
@@ -45,6 +78,12 @@ func (bp *BasicUpdatePlanner) ExecuteDelete(data parse.DeleteData, tx tx.Transac
 }
 
 func (bp *BasicUpdatePlanner) ExecuteModify(data parse.ModifyData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		return bp.executeModify(data, tx)
+	})
+}
+
+func (bp *BasicUpdatePlanner) executeModify(data parse.ModifyData, tx tx.Transaction) (int, error) {
 	tablePlan, err := NewTablePlan(tx, data.Table, bp.mdMgr)
 	if err != nil {
 		return 0, fmt.Errorf("planner: failed to create table plan for %s: %v", data.Table, err)
@@ -74,6 +113,16 @@ func (bp *BasicUpdatePlanner) ExecuteModify(data parse.ModifyData, tx tx.Transac
 }
 
 func (bp *BasicUpdatePlanner) ExecuteInsert(data parse.InsertData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		return bp.executeInsert(data, tx)
+	})
+}
+
+// executeInsert inserts a record with the values of the statement. The fields the statement omits are set to NULL.
+func (bp *BasicUpdatePlanner) executeInsert(data parse.InsertData, tx tx.Transaction) (int, error) {
+	if len(data.Fields) != len(data.Vals) {
+		return 0, fmt.Errorf("planner: %d fields but %d values to insert", len(data.Fields), len(data.Vals))
+	}
 	tablePlan, err := NewTablePlan(tx, data.Table, bp.mdMgr)
 	if err != nil {
 		return 0, fmt.Errorf("planner: failed to create table plan for %s: %v", data.Table, err)
@@ -98,6 +147,14 @@ func (bp *BasicUpdatePlanner) ExecuteInsert(data parse.InsertData, tx tx.Transac
 		}
 		idx++
 	}
+	for _, field := range tablePlan.Schema().Fields() {
+		if slices.Contains(data.Fields, field) {
+			continue
+		}
+		if err := insertScan.SetVal(field, constant.NewNull()); err != nil {
+			return 0, fmt.Errorf("planner: failed to set value: %v", err)
+		}
+	}
 	return 1, nil
 }
 
@@ -112,3 +169,270 @@ func (bp *BasicUpdatePlanner) ExecuteCreateView(data parse.CreateViewData, tx tx
 func (bp *BasicUpdatePlanner) ExecuteCreateIndex(data parse.CreateIndexData, tx tx.Transaction) (int, error) {
 	return 0, bp.mdMgr.CreateIndex(data.Idx, data.Table, data.Field, tx)
 }
+
+// ExecuteDropTable drops the table with its indexes. Its file is removed when tx commits.
+// It fails if views depend on the table, unless the statement cascades to them.
+func (bp *BasicUpdatePlanner) ExecuteDropTable(data parse.DropTableData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		hasTable, err := bp.mdMgr.HasTable(data.Table, tx)
+		if err != nil {
+			return 0, fmt.Errorf("planner: failed to check if table %s exists: %v", data.Table, err)
+		}
+		if !hasTable {
+			if data.IfExists {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("planner: %w: %s", metadata.ErrTableNotFound, data.Table)
+		}
+		if err := bp.dropDependentViews(data.Table, data.Cascade, tx); err != nil {
+			return 0, err
+		}
+		return 0, bp.mdMgr.DropTable(data.Table, tx)
+	})
+}
+
+// ExecuteDropView drops the view. It fails if other views depend on it, unless the statement cascades to them.
+func (bp *BasicUpdatePlanner) ExecuteDropView(data parse.DropViewData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		_, err := bp.mdMgr.GetViewDef(data.View, tx)
+		if errors.Is(err, metadata.ErrViewNotFound) && data.IfExists {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("planner: failed to get view %s: %w", data.View, err)
+		}
+		if err := bp.dropDependentViews(data.View, data.Cascade, tx); err != nil {
+			return 0, err
+		}
+		return 0, bp.mdMgr.DropView(data.View, tx)
+	})
+}
+
+func (bp *BasicUpdatePlanner) ExecuteDropIndex(data parse.DropIndexData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		err := bp.mdMgr.DropIndex(data.Idx, tx)
+		if errors.Is(err, metadata.ErrIndexNotFound) && data.IfExists {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("planner: failed to drop index %s: %w", data.Idx, err)
+		}
+		return 0, nil
+	})
+}
+
+/*
+ExecuteAlterTable adds, drops or renames a field of the table, or renames the table.
+Views are stored as their text, so dropping or renaming a field or renaming the table fails if views depend on the table.
+*/
+func (bp *BasicUpdatePlanner) ExecuteAlterTable(data parse.AlterTableData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		if data.Action != parse.ALTER_ACTION_ADD_COLUMN {
+			defs, err := bp.mdMgr.GetViewDefs(tx)
+			if err != nil {
+				return 0, fmt.Errorf("planner: failed to get view definitions: %v", err)
+			}
+			views, err := dependentViews(data.Table, defs)
+			if err != nil {
+				return 0, err
+			}
+			if len(views) > 0 {
+				return 0, fmt.Errorf("planner: cannot alter %s because view %s depends on it", data.Table, views[0])
+			}
+		}
+		var err error
+		switch data.Action {
+		case parse.ALTER_ACTION_ADD_COLUMN:
+			err = bp.mdMgr.AddField(data.Table, data.Field, data.Type, data.Length, data.Default, tx)
+		case parse.ALTER_ACTION_DROP_COLUMN:
+			err = bp.mdMgr.DropField(data.Table, data.Field, tx)
+		case parse.ALTER_ACTION_RENAME_COLUMN:
+			err = bp.mdMgr.RenameField(data.Table, data.Field, data.NewName, tx)
+		case parse.ALTER_ACTION_RENAME_TABLE:
+			err = bp.mdMgr.RenameTable(data.Table, data.NewName, tx)
+		default:
+			err = fmt.Errorf("planner: unknown alter table action %d", data.Action)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("planner: failed to alter %s: %w", data.Table, err)
+		}
+		return 0, nil
+	})
+}
+
+// ExecuteVacuum compacts the records of the table, or of every table if none is given, and returns the number of blocks freed.
+// A table with indexes cannot be vacuumed, and is skipped when every table is.
+func (bp *BasicUpdatePlanner) ExecuteVacuum(data parse.VacuumData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		tables := []string{data.Table}
+		if data.Table == "" {
+			var err error
+			if tables, err = bp.mdMgr.TableNames(tx); err != nil {
+				return 0, fmt.Errorf("planner: failed to get tables: %w", err)
+			}
+		}
+		freed := 0
+		for _, table := range tables {
+			n, err := bp.mdMgr.Vacuum(table, tx)
+			if data.Table == "" && errors.Is(err, metadata.ErrIndexedTable) {
+				continue
+			}
+			if err != nil {
+				return 0, fmt.Errorf("planner: failed to vacuum %s: %w", table, err)
+			}
+			freed += n
+		}
+		return freed, nil
+	})
+}
+
+// ExecuteAnalyze stores the statistics of the table, or of every table if none is given, and returns the number of tables analyzed.
+func (bp *BasicUpdatePlanner) ExecuteAnalyze(data parse.AnalyzeData, tx tx.Transaction) (int, error) {
+	return executeAtomically(tx, func() (int, error) {
+		tables := []string{data.Table}
+		if data.Table == "" {
+			var err error
+			if tables, err = bp.mdMgr.TableNames(tx); err != nil {
+				return 0, fmt.Errorf("planner: failed to get tables: %w", err)
+			}
+		}
+		for _, table := range tables {
+			if err := bp.mdMgr.Analyze(table, tx); err != nil {
+				return 0, fmt.Errorf("planner: failed to analyze %s: %w", table, err)
+			}
+		}
+		return len(tables), nil
+	})
+}
+
+// dropDependentViews drops the views that read from the table or view, directly or through other views, if cascade is set.
+// Otherwise it fails if there are any.
+func (bp *BasicUpdatePlanner) dropDependentViews(name string, cascade bool, tx tx.Transaction) error {
+	defs, err := bp.mdMgr.GetViewDefs(tx)
+	if err != nil {
+		return fmt.Errorf("planner: failed to get view definitions: %v", err)
+	}
+	views, err := dependentViews(name, defs)
+	if err != nil {
+		return err
+	}
+	if len(views) == 0 {
+		return nil
+	}
+	if !cascade {
+		return fmt.Errorf("planner: cannot drop %s because view %s depends on it", name, views[0])
+	}
+	for _, view := range views {
+		if err := bp.mdMgr.DropView(view, tx); err != nil {
+			return fmt.Errorf("planner: failed to drop view %s: %w", view, err)
+		}
+	}
+	return nil
+}
+
+// dependentViews returns, sorted by name, the views of defs that read from name directly or through other views.
+func dependentViews(name string, defs map[string]string) ([]string, error) {
+	reads := make(map[string][]string, len(defs))
+	for view, def := range defs {
+		data, err := parse.NewParser(def).Query()
+		if err != nil {
+			return nil, fmt.Errorf("planner: failed to parse definition of view %s: %v", view, err)
+		}
+		reads[view] = data.Tables
+	}
+	found := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		for view, tables := range reads {
+			if found[view] || !slices.Contains(tables, target) {
+				continue
+			}
+			found[view] = true
+			queue = append(queue, view)
+		}
+	}
+	views := make([]string, 0, len(found))
+	for view := range found {
+		views = append(views, view)
+	}
+	sort.Strings(views)
+	return views, nil
+}
+
+/*
+ExecuteSetTransaction changes the isolation level or the access mode of the transaction. As SQL requires, it must come before
+the transaction reads or writes anything, since the locks taken before would not follow the new level, and a read-only transaction
+cannot be made read-write.
+*/
+func (bp *BasicUpdatePlanner) ExecuteSetTransaction(data parse.SetTransactionData, tx tx.Transaction) (int, error) {
+	if tx.Started() {
+		return 0, ErrTransactionStarted
+	}
+	if data.HasAccessMode && !data.ReadOnly && tx.IsReadOnly() {
+		return 0, errors.New("planner: a read-only transaction cannot be made read-write")
+	}
+	if data.Isolation != 0 {
+		if err := tx.SetIsolationLevel(data.Isolation); err != nil {
+			return 0, fmt.Errorf("planner: failed to set isolation level: %v", err)
+		}
+	}
+	if data.HasAccessMode {
+		tx.SetReadOnly(data.ReadOnly)
+	}
+	return 0, nil
+}
+
+// executeAtomically runs the statement inside an implicit savepoint so that a failing statement leaves no partial changes behind,
+// while the changes made by earlier statements of the transaction are kept.
+func executeAtomically(tx tx.Transaction, exec func() (int, error)) (int, error) {
+	if err := tx.Savepoint(STATEMENT_SAVEPOINT); err != nil {
+		return 0, fmt.Errorf("planner: failed to create statement savepoint: %v", err)
+	}
+	count, err := exec()
+	if err != nil {
+		if rbErr := tx.RollbackToSavepoint(STATEMENT_SAVEPOINT); rbErr != nil {
+			return 0, fmt.Errorf("planner: failed to rollback statement: %v: %v", rbErr, err)
+		}
+		if relErr := tx.ReleaseSavepoint(STATEMENT_SAVEPOINT); relErr != nil {
+			return 0, fmt.Errorf("planner: failed to release statement savepoint: %v: %v", relErr, err)
+		}
+		return 0, err
+	}
+	if err := tx.ReleaseSavepoint(STATEMENT_SAVEPOINT); err != nil {
+		return 0, fmt.Errorf("planner: failed to release statement savepoint: %v", err)
+	}
+	return count, nil
+}
+
+func (bp *BasicUpdatePlanner) ExecuteLockTable(data parse.LockTableData, tx tx.Transaction) (int, error) {
+	layout, err := bp.mdMgr.GetLayout(data.Table, tx)
+	if err != nil {
+		return 0, fmt.Errorf("planner: failed to get layout for %s: %v", data.Table, err)
+	}
+	if len(layout.Schema().Fields()) == 0 {
+		return 0, fmt.Errorf("planner: table %s not found", data.Table)
+	}
+	if err := tx.LockFile(data.Table+record.TABLE_SUFFIX, data.Mode); err != nil {
+		return 0, fmt.Errorf("planner: failed to lock table %s: %v", data.Table, err)
+	}
+	return 0, nil
+}
+
+// ExecuteVerifyDatabase reads every block on disk and returns the number of corrupt blocks.
+// The error joins a *file.CorruptPageError for each of them. Blocks modified in the buffer pool are checked as last written.
+func (bp *BasicUpdatePlanner) ExecuteVerifyDatabase(tx tx.Transaction) (int, error) {
+	if bp.fileMgr == nil {
+		return 0, errors.New("planner: verify database is not available")
+	}
+	corrupted, err := file.Verify(bp.fileMgr)
+	if err != nil {
+		return 0, fmt.Errorf("planner: failed to verify database: %w", err)
+	}
+	errs := make([]error, len(corrupted))
+	for i, c := range corrupted {
+		errs[i] = c
+	}
+	return len(corrupted), errors.Join(errs...)
+}
@@ -47,6 +47,30 @@ func (p *Planner) ExecuteUpdate(cmd string, tx tx.Transaction) (int, error) {
 		return p.updatePlanner.ExecuteCreateView(*data, tx)
 	case *parse.CreateIndexData:
 		return p.updatePlanner.ExecuteCreateIndex(*data, tx)
+	case *parse.DropTableData:
+		return p.updatePlanner.ExecuteDropTable(*data, tx)
+	case *parse.DropViewData:
+		return p.updatePlanner.ExecuteDropView(*data, tx)
+	case *parse.DropIndexData:
+		return p.updatePlanner.ExecuteDropIndex(*data, tx)
+	case *parse.AlterTableData:
+		return p.updatePlanner.ExecuteAlterTable(*data, tx)
+	case *parse.SetTransactionData:
+		return p.updatePlanner.ExecuteSetTransaction(*data, tx)
+	case *parse.LockTableData:
+		return p.updatePlanner.ExecuteLockTable(*data, tx)
+	case *parse.VerifyDatabaseData:
+		return p.updatePlanner.ExecuteVerifyDatabase(tx)
+	case *parse.VacuumData:
+		return p.updatePlanner.ExecuteVacuum(*data, tx)
+	case *parse.AnalyzeData:
+		return p.updatePlanner.ExecuteAnalyze(*data, tx)
+	case *parse.SavepointData:
+		return 0, tx.Savepoint(data.Name)
+	case *parse.RollbackToSavepointData:
+		return 0, tx.RollbackToSavepoint(data.Name)
+	case *parse.ReleaseSavepointData:
+		return 0, tx.ReleaseSavepoint(data.Name)
 	default:
 		return 0, fmt.Errorf("planner: unknown update type %T", data)
 	}
@@ -4,12 +4,17 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/buffer"
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/log"
 	"github.com/kj455/simple-db/pkg/metadata"
+	"github.com/kj455/simple-db/pkg/parse"
+	"github.com/kj455/simple-db/pkg/record"
 	"github.com/kj455/simple-db/pkg/testutil"
 	"github.com/kj455/simple-db/pkg/tx"
 	"github.com/stretchr/testify/require"
@@ -84,3 +89,843 @@ func TestPlanner(t *testing.T) {
 
 	tx.Commit()
 }
+
+func TestPlanner_StatementAtomicity(t *testing.T) {
+	const (
+		dirname     = "test_planner_statement_atomicity"
+		logFileName = "logfile"
+		blockSize   = 400
+		recordNum   = 200
+	)
+	dir, cleanup := testutil.SetupDir(dirname)
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	require.NoError(t, err)
+	const buffNum = 8
+	buffs := make([]buffer.Buffer, buffNum)
+	for i := 0; i < buffNum; i++ {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txn, err := tx.NewTransaction(fm, lm, bm, tx.NewTxNumberGenerator())
+	require.NoError(t, err)
+	mdm, err := metadata.NewMetadataMgr(txn)
+	require.NoError(t, err)
+	up := NewBasicUpdatePlanner(mdm)
+	planner := NewPlanner(NewBasicQueryPlanner(mdm), up)
+
+	_, err = planner.ExecuteUpdate("create table item(id int, name varchar(10))", txn)
+	require.NoError(t, err)
+	for i := 0; i < recordNum; i++ {
+		cmd := fmt.Sprintf("insert into item(id, name) values(%d, 'item%d')", i, i)
+		_, err = planner.ExecuteUpdate(cmd, txn)
+		require.NoError(t, err)
+	}
+	sumIds := func() (int, int) {
+		p, err := planner.CreateQueryPlan("select id from item", txn)
+		require.NoError(t, err)
+		s, err := p.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		count, sum := 0, 0
+		for s.Next() {
+			id, err := s.GetInt("id")
+			require.NoError(t, err)
+			count++
+			sum += id
+		}
+		return count, sum
+	}
+	wantCount, wantSum := sumIds()
+	require.Equal(t, recordNum, wantCount)
+
+	t.Run("failure in the middle of an update", func(t *testing.T) {
+		data, err := parseModify("update item set id = 0")
+		require.NoError(t, err)
+		_, err = executeAtomically(txn, func() (int, error) {
+			count, err := up.executeModify(data, txn)
+			require.NoError(t, err)
+			require.Equal(t, recordNum, count)
+			return 0, fmt.Errorf("failed at row %d", count)
+		})
+		require.Error(t, err)
+		count, sum := sumIds()
+		require.Equal(t, wantCount, count)
+		require.Equal(t, wantSum, sum)
+	})
+	t.Run("type error in an update", func(t *testing.T) {
+		_, err := planner.ExecuteUpdate("update item set id = name", txn)
+		require.Error(t, err)
+		count, sum := sumIds()
+		require.Equal(t, wantCount, count)
+		require.Equal(t, wantSum, sum)
+	})
+	t.Run("type error in an insert", func(t *testing.T) {
+		_, err := planner.ExecuteUpdate("insert into item(id, name) values('x', 'y')", txn)
+		require.Error(t, err)
+		count, sum := sumIds()
+		require.Equal(t, wantCount, count)
+		require.Equal(t, wantSum, sum)
+	})
+	t.Run("earlier statements are kept", func(t *testing.T) {
+		num, err := planner.ExecuteUpdate("delete from item where id = 10", txn)
+		require.NoError(t, err)
+		require.Equal(t, 1, num)
+		_, err = planner.ExecuteUpdate("update item set id = name", txn)
+		require.Error(t, err)
+		count, sum := sumIds()
+		require.Equal(t, wantCount-1, count)
+		require.Equal(t, wantSum-10, sum)
+	})
+	require.NoError(t, txn.Commit())
+}
+
+func parseModify(cmd string) (parse.ModifyData, error) {
+	data, err := parse.NewParser(cmd).UpdateCmd()
+	if err != nil {
+		return parse.ModifyData{}, err
+	}
+	return *data.(*parse.ModifyData), nil
+}
+
+func TestPlanner_LockTable(t *testing.T) {
+	const (
+		dirname     = "test_planner_lock_table"
+		logFileName = "logfile"
+		blockSize   = 400
+	)
+	dir, cleanup := testutil.SetupDir(dirname)
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	require.NoError(t, err)
+	const buffNum = 8
+	buffs := make([]buffer.Buffer, buffNum)
+	for i := 0; i < buffNum; i++ {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	lockTable := tx.NewLock(tx.WithWaitTime(10 * time.Millisecond))
+	newTx := func() tx.Transaction {
+		txn, err := tx.NewTransaction(fm, lm, bm, txNumGen, tx.WithTxLockTable(lockTable))
+		require.NoError(t, err)
+		return txn
+	}
+	txn := newTx()
+	mdm, err := metadata.NewMetadataMgr(txn)
+	require.NoError(t, err)
+	planner := NewPlanner(NewBasicQueryPlanner(mdm), NewBasicUpdatePlanner(mdm))
+	_, err = planner.ExecuteUpdate("create table item(id int)", txn)
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("insert into item(id) values(1)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	locker := newTx()
+	_, err = planner.ExecuteUpdate("lock table item in share mode", locker)
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("lock table unknown in share mode", locker)
+	require.Error(t, err)
+
+	other := newTx()
+	_, err = planner.ExecuteUpdate("update item set id = 2", other)
+	require.Error(t, err)
+	_, err = planner.ExecuteUpdate("lock table item in exclusive mode", other)
+	require.Error(t, err)
+	require.NoError(t, other.Rollback())
+
+	require.NoError(t, locker.Commit())
+	other = newTx()
+	_, err = planner.ExecuteUpdate("lock table item in exclusive mode", other)
+	require.NoError(t, err)
+	num, err := planner.ExecuteUpdate("update item set id = 2", other)
+	require.NoError(t, err)
+	require.Equal(t, 1, num)
+	require.NoError(t, other.Commit())
+}
+
+func TestPlanner_VerifyDatabase(t *testing.T) {
+	const (
+		dirname     = "test_planner_verify_database"
+		logFileName = "logfile"
+		blockSize   = 400
+	)
+	dir, cleanup := testutil.SetupDir(dirname)
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	require.NoError(t, err)
+	const buffNum = 8
+	buffs := make([]buffer.Buffer, buffNum)
+	for i := 0; i < buffNum; i++ {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txn, err := tx.NewTransaction(fm, lm, bm, tx.NewTxNumberGenerator())
+	require.NoError(t, err)
+	mdm, err := metadata.NewMetadataMgr(txn)
+	require.NoError(t, err)
+	planner := NewPlanner(NewBasicQueryPlanner(mdm), NewBasicUpdatePlanner(mdm, WithFileMgr(fm)))
+	_, err = planner.ExecuteUpdate("create table item(id int)", txn)
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("insert into item(id) values(1)", txn)
+	require.NoError(t, err)
+	require.NoError(t, txn.Commit())
+
+	num, err := planner.ExecuteUpdate("verify database", txn)
+	require.NoError(t, err)
+	require.Equal(t, 0, num)
+
+	f, err := os.OpenFile(filepath.Join(dir, "item"+record.TABLE_SUFFIX), os.O_RDWR, 0666)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, file.PAGE_HEADER_SIZE+10)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	num, err = planner.ExecuteUpdate("verify database", txn)
+	require.ErrorIs(t, err, file.ErrCorruptPage)
+	require.Equal(t, 1, num)
+	var cpe *file.CorruptPageError
+	require.ErrorAs(t, err, &cpe)
+	require.Equal(t, "item"+record.TABLE_SUFFIX, cpe.Filename)
+	require.Equal(t, 0, cpe.BlockNum)
+
+	_, err = NewPlanner(NewBasicQueryPlanner(mdm), NewBasicUpdatePlanner(mdm)).ExecuteUpdate("verify database", txn)
+	require.Error(t, err)
+}
+
+func TestPlanner_BuffersTable(t *testing.T) {
+	const (
+		dirname     = "test_planner_buffers_table"
+		logFileName = "logfile"
+		blockSize   = 400
+	)
+	dir, cleanup := testutil.SetupDir(dirname)
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	require.NoError(t, err)
+	const buffNum = 8
+	buffs := make([]buffer.Buffer, buffNum)
+	for i := 0; i < buffNum; i++ {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txn, err := tx.NewTransaction(fm, lm, bm, tx.NewTxNumberGenerator())
+	require.NoError(t, err)
+	mdm, err := metadata.NewMetadataMgr(txn)
+	require.NoError(t, err)
+	planner := NewPlanner(NewBasicQueryPlanner(mdm, WithSystemTable(BUFFERS_TABLE, NewBuffersTable(bm))), NewBasicUpdatePlanner(mdm))
+	_, err = planner.ExecuteUpdate("create table item(id int)", txn)
+	require.NoError(t, err)
+	_, err = planner.ExecuteUpdate("insert into item(id) values(1)", txn)
+	require.NoError(t, err)
+
+	// the block of item was modified by the transaction, which has not committed yet
+	p, err := planner.CreateQueryPlan("select id, blknum, pins, txnum, dirty from simpledb_buffers where filename = 'item"+record.TABLE_SUFFIX+"'", txn)
+	require.NoError(t, err)
+	s, err := p.Open()
+	require.NoError(t, err)
+	require.True(t, s.Next())
+	blkNum, err := s.GetInt("blknum")
+	require.NoError(t, err)
+	require.Equal(t, 0, blkNum)
+	txNum, err := s.GetInt("txnum")
+	require.NoError(t, err)
+	require.NotEqual(t, buffer.INIT_TX_NUM, txNum)
+	dirty, err := s.GetInt("dirty")
+	require.NoError(t, err)
+	require.Equal(t, 1, dirty)
+	require.False(t, s.Next())
+	s.Close()
+
+	require.NoError(t, txn.Commit())
+	p, err = planner.CreateQueryPlan("select id, pins, dirty from simpledb_buffers", txn)
+	require.NoError(t, err)
+	s, err = p.Open()
+	require.NoError(t, err)
+	rows := 0
+	for s.Next() {
+		pins, err := s.GetInt("pins")
+		require.NoError(t, err)
+		require.Equal(t, 0, pins)
+		dirty, err := s.GetInt("dirty")
+		require.NoError(t, err)
+		require.Equal(t, 0, dirty)
+		rows++
+	}
+	s.Close()
+	require.Equal(t, buffNum, rows)
+}
+
+func TestPlanner_Drop(t *testing.T) {
+	tp, txn := newTestPlanner(t)
+	planner, mdm, dir := tp.Planner, tp.mdm, tp.dir
+	begin := func() tx.Transaction { return tp.begin(t) }
+	exec := func(cmd string) error {
+		_, err := planner.ExecuteUpdate(cmd, txn)
+		return err
+	}
+	count := func(table string) int {
+		p, err := planner.CreateQueryPlan("select id from "+table, txn)
+		require.NoError(t, err)
+		s, err := p.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		n := 0
+		for s.Next() {
+			n++
+		}
+		return n
+	}
+	tableFile := filepath.Join(dir, "item"+record.TABLE_SUFFIX)
+	for _, cmd := range []string{
+		"create table item(id int)",
+		"insert into item(id) values(1)",
+		"create index item_id on item(id)",
+		"create view items as select id from item",
+		"create view item_ids as select id from items",
+	} {
+		require.NoError(t, exec(cmd))
+	}
+	require.NoError(t, txn.Commit())
+	txn = begin()
+
+	// views depend on the table
+	require.ErrorContains(t, exec("drop table item"), "view item_ids depends on it")
+	require.ErrorContains(t, exec("drop view items"), "view item_ids depends on it")
+
+	// rolling back the drop keeps everything
+	require.NoError(t, exec("drop table item cascade"))
+	require.NoError(t, txn.Rollback())
+	txn = begin()
+	require.Equal(t, 1, count("items"))
+	require.FileExists(t, tableFile)
+
+	// the file is removed only once the drop commits
+	require.NoError(t, exec("drop table item cascade"))
+	require.FileExists(t, tableFile)
+	require.NoError(t, txn.Commit())
+	require.NoFileExists(t, tableFile)
+	txn = begin()
+	for _, view := range []string{"items", "item_ids"} {
+		_, err := mdm.GetViewDef(view, txn)
+		require.ErrorIs(t, err, metadata.ErrViewNotFound)
+	}
+	require.ErrorIs(t, exec("drop index item_id"), metadata.ErrIndexNotFound)
+
+	require.ErrorIs(t, exec("drop table item"), metadata.ErrTableNotFound)
+	require.NoError(t, exec("drop table if exists item"))
+	require.NoError(t, exec("drop view if exists items"))
+	require.NoError(t, exec("drop index if exists item_id"))
+	require.Error(t, exec("drop table tblcat"))
+
+	// a table created again with the same name starts empty
+	require.NoError(t, exec("create table item(id int)"))
+	require.Equal(t, 0, count("item"))
+	require.NoError(t, exec("create index item_id on item(id)"))
+	require.NoError(t, exec("drop index item_id"))
+	indexes, err := mdm.GetIndexInfo("item", txn)
+	require.NoError(t, err)
+	require.Empty(t, indexes)
+	require.NoError(t, exec("create view items as select id from item"))
+	require.NoError(t, exec("drop view items"))
+	require.NoError(t, exec("insert into item(id) values(2)"))
+	require.NoError(t, txn.Commit())
+
+	// a table dropped and created again in one transaction does not see the old records, and keeps the new ones once it commits
+	txn = begin()
+	require.NoError(t, exec("drop table item"))
+	require.NoError(t, exec("create table item(id int)"))
+	require.NoError(t, exec("insert into item(id) values(3)"))
+	require.Equal(t, 1, count("item"))
+	require.NoError(t, txn.Commit())
+	txn = begin()
+	require.Equal(t, 1, count("item"))
+	require.NoError(t, txn.Commit())
+}
+
+func TestPlanner_AlterTable(t *testing.T) {
+	const recordNum = 40
+	tp, txn := newTestPlanner(t)
+	planner, mdm, dir := tp.Planner, tp.mdm, tp.dir
+	begin := func() tx.Transaction { return tp.begin(t) }
+	exec := func(cmd string) error {
+		_, err := planner.ExecuteUpdate(cmd, txn)
+		return err
+	}
+	// column returns the values of the field in all the records of the table
+	column := func(table, field string) ([]string, error) {
+		p, err := planner.CreateQueryPlan(fmt.Sprintf("select %s from %s", field, table), txn)
+		if err != nil {
+			return nil, err
+		}
+		s, err := p.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		var vals []string
+		for s.Next() {
+			val, err := s.GetVal(field)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, val.ToString())
+		}
+		return vals, nil
+	}
+	repeat := func(val string) []string {
+		vals := make([]string, recordNum)
+		for i := range vals {
+			vals[i] = val
+		}
+		return vals
+	}
+	names := make([]string, recordNum)
+	require.NoError(t, exec("create table item(id int, name varchar(5))"))
+	for i := range names {
+		names[i] = fmt.Sprintf("n%d", i)
+		require.NoError(t, exec(fmt.Sprintf("insert into item(id, name) values(%d, '%s')", i, names[i])))
+	}
+	require.NoError(t, exec("create index item_name on item(name)"))
+	require.NoError(t, txn.Commit())
+
+	// added fields widen the records, which are rewritten until the statement is rolled back
+	txn = begin()
+	require.NoError(t, exec("alter table item add column price int default 7"))
+	require.NoError(t, exec("alter table item add note varchar(10)"))
+	prices, err := column("item", "price")
+	require.NoError(t, err)
+	require.Equal(t, repeat("7"), prices)
+	notes, err := column("item", "note")
+	require.NoError(t, err)
+	require.Equal(t, repeat("null"), notes)
+	got, err := column("item", "name")
+	require.NoError(t, err)
+	require.Equal(t, names, got)
+	require.NoError(t, txn.Rollback())
+	txn = begin()
+	_, err = column("item", "price")
+	require.Error(t, err)
+	got, err = column("item", "name")
+	require.NoError(t, err)
+	require.Equal(t, names, got)
+
+	require.ErrorContains(t, exec("alter table item add column name int"), "already exists")
+	require.ErrorContains(t, exec("alter table item add column code varchar(2) default 'long'"), "longer than")
+	require.ErrorContains(t, exec("alter table item add column code varchar(2) default 'ééé'"), "longer than")
+	// the length of a varchar counts characters, not bytes
+	require.NoError(t, exec("alter table item add column code varchar(2) default 'éé'"))
+	codes, err := column("item", "code")
+	require.NoError(t, err)
+	require.Equal(t, repeat("éé"), codes)
+	require.NoError(t, exec("alter table item drop column code"))
+	require.ErrorContains(t, exec("alter table item add column code int default 'a'"), "not an integer")
+	require.ErrorIs(t, exec("alter table missing add column code int"), metadata.ErrTableNotFound)
+	require.ErrorIs(t, exec("alter table item drop column missing"), metadata.ErrFieldNotFound)
+	require.ErrorContains(t, exec("alter table tblcat add column code int"), "catalog")
+
+	// a dropped field takes its indexes with it, a renamed one keeps them
+	require.NoError(t, exec("alter table item add column price int default 3"))
+	// the records are copied through a temporary table, removed once the statement commits
+	temp := filepath.Join(dir, "item"+metadata.ALTER_TEMP_SUFFIX+record.TABLE_SUFFIX)
+	require.FileExists(t, temp)
+	require.NoError(t, exec("create index item_price on item(price)"))
+	require.NoError(t, exec("alter table item drop column name"))
+	require.NoError(t, exec("alter table item rename column price to cost"))
+	_, err = column("item", "name")
+	require.Error(t, err)
+	costs, err := column("item", "cost")
+	require.NoError(t, err)
+	require.Equal(t, repeat("3"), costs)
+	indexes, err := mdm.GetIndexInfo("item", txn)
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	require.Equal(t, "item_price", indexes["cost"].IndexName())
+	require.NoError(t, exec("alter table item drop column id"))
+	require.ErrorContains(t, exec("alter table item drop column cost"), "only field")
+
+	// views are stored as text, so they keep the table from changing under them
+	require.NoError(t, exec("create view costs as select cost from item"))
+	require.ErrorContains(t, exec("alter table item rename to goods"), "view costs depends on it")
+	require.ErrorContains(t, exec("alter table item rename column cost to price"), "view costs depends on it")
+	require.NoError(t, exec("alter table item add column id int"))
+	require.NoError(t, exec("drop view costs"))
+
+	// a renamed table moves to a new file, and the old one is removed once the statement commits
+	require.NoError(t, exec("alter table item rename to goods"))
+	require.FileExists(t, filepath.Join(dir, "item"+record.TABLE_SUFFIX))
+	require.NoError(t, txn.Commit())
+	require.NoFileExists(t, filepath.Join(dir, "item"+record.TABLE_SUFFIX))
+	require.NoFileExists(t, temp)
+	txn = begin()
+	costs, err = column("goods", "cost")
+	require.NoError(t, err)
+	require.Equal(t, repeat("3"), costs)
+	_, err = column("item", "cost")
+	require.Error(t, err)
+	indexes, err = mdm.GetIndexInfo("goods", txn)
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	require.NoError(t, txn.Commit())
+
+	// renaming back and forth in one transaction keeps the records in the file of the final name
+	txn = begin()
+	require.NoError(t, exec("alter table goods rename to item"))
+	require.NoError(t, exec("alter table item rename to goods"))
+	require.NoError(t, txn.Commit())
+	txn = begin()
+	costs, err = column("goods", "cost")
+	require.NoError(t, err)
+	require.Equal(t, repeat("3"), costs)
+
+	// as does renaming a table to the name of one dropped in the transaction, which a new table of the old name does not see
+	require.NoError(t, exec("create table item(id int)"))
+	require.NoError(t, exec("insert into item(id) values(1)"))
+	require.NoError(t, txn.Commit())
+	txn = begin()
+	require.NoError(t, exec("drop table item"))
+	require.NoError(t, exec("alter table goods rename to item"))
+	require.NoError(t, exec("create table goods(cost int)"))
+	costs, err = column("goods", "cost")
+	require.NoError(t, err)
+	require.Empty(t, costs)
+	require.NoError(t, txn.Commit())
+	txn = begin()
+	costs, err = column("item", "cost")
+	require.NoError(t, err)
+	require.Equal(t, repeat("3"), costs)
+	costs, err = column("goods", "cost")
+	require.NoError(t, err)
+	require.Empty(t, costs)
+	require.NoError(t, txn.Commit())
+}
+
+func TestPlanner_Null(t *testing.T) {
+	tp, txn := newTestPlanner(t)
+	planner := tp.Planner
+	exec := func(cmd string) (int, error) {
+		return planner.ExecuteUpdate(cmd, txn)
+	}
+	// ids returns the ids of the records the query selects
+	ids := func(cmd string) []string {
+		p, err := planner.CreateQueryPlan(cmd, txn)
+		require.NoError(t, err)
+		s, err := p.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		var vals []string
+		for s.Next() {
+			val, err := s.GetVal("id")
+			require.NoError(t, err)
+			vals = append(vals, val.ToString())
+		}
+		return vals
+	}
+	_, err := exec("create table item(id int, name varchar(5), price int)")
+	require.NoError(t, err)
+	_, err = exec("insert into item(id, name, price) values(1, 'a', 10)")
+	require.NoError(t, err)
+	_, err = exec("insert into item(id, name) values(2, 'b')")
+	require.NoError(t, err)
+	_, err = exec("insert into item(id, price) values(3, null)")
+	require.NoError(t, err)
+	_, err = exec("insert into item(id, price) values(4)")
+	require.ErrorContains(t, err, "2 fields but 1 values")
+
+	require.Equal(t, []string{"2", "3"}, ids("select id from item where price is null"))
+	require.Equal(t, []string{"1"}, ids("select id from item where price is not null"))
+	require.Equal(t, []string{"3"}, ids("select id from item where name is null"))
+	// comparisons with NULL are unknown, so they select nothing
+	require.Empty(t, ids("select id from item where price = null"))
+	require.Empty(t, ids("select id from item where name = price"))
+
+	n, err := exec("update item set price = null where id = 1")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, []string{"1", "2", "3"}, ids("select id from item where price is null"))
+	n, err = exec("update item set name = 'c' where name is null")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Empty(t, ids("select id from item where name is null"))
+	require.NoError(t, txn.Commit())
+}
+
+func TestPlanner_Types(t *testing.T) {
+	tp, txn := newTestPlanner(t)
+	planner := tp.Planner
+	exec := func(cmd string) error {
+		_, err := planner.ExecuteUpdate(cmd, txn)
+		return err
+	}
+	// ids returns the ids of the records the query selects
+	ids := func(cmd string) []string {
+		p, err := planner.CreateQueryPlan(cmd, txn)
+		require.NoError(t, err)
+		s, err := p.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		var vals []string
+		for s.Next() {
+			val, err := s.GetVal("id")
+			require.NoError(t, err)
+			vals = append(vals, val.ToString())
+		}
+		return vals
+	}
+	require.NoError(t, exec("create table item(id int, big bigint, ok boolean, ratio double, day date, at timestamp, data blob(4))"))
+	require.NoError(t, exec("insert into item(id, big, ok, ratio, day, at, data) values(1, 5000000000, true, 0.5, date '2024-02-29', timestamp '2024-02-29 13:04:05.5', x'0aff')"))
+	require.NoError(t, exec("insert into item(id, big, ok, ratio, day, at, data) values(2, 3, false, 2, timestamp '2024-03-01 10:00:00', date '2024-03-01', x'')"))
+	require.NoError(t, exec("insert into item(id) values(3)"))
+	require.ErrorContains(t, exec("insert into item(id, data) values(4, x'0102030405')"), "do not fit")
+	require.ErrorContains(t, exec("insert into item(id, ok) values(4, 1)"), "boolean")
+
+	require.Equal(t, []string{"1"}, ids("select id from item where big = 5000000000"))
+	require.Equal(t, []string{"2"}, ids("select id from item where big = 3"))
+	require.Equal(t, []string{"1"}, ids("select id from item where ok = true"))
+	require.Equal(t, []string{"2"}, ids("select id from item where ratio = 2"))
+	require.Equal(t, []string{"1"}, ids("select id from item where ratio = 5e-1"))
+	// a date field keeps the day of a timestamp, and a timestamp field takes a date as its midnight
+	require.Equal(t, []string{"2"}, ids("select id from item where day = date '2024-03-01'"))
+	require.Equal(t, []string{"2"}, ids("select id from item where at = timestamp '2024-03-01 00:00:00'"))
+	require.Equal(t, []string{"1"}, ids("select id from item where at = timestamp '2024-02-29 13:04:05.500'"))
+	require.Equal(t, []string{"1"}, ids("select id from item where data = x'0AFF'"))
+	require.Equal(t, []string{"2"}, ids("select id from item where data = x''"))
+	require.Equal(t, []string{"3"}, ids("select id from item where day is null"))
+
+	require.NoError(t, exec("update item set ok = false where id = 1"))
+	require.Empty(t, ids("select id from item where ok = true"))
+	require.NoError(t, exec("alter table item add column flag boolean default true"))
+	require.Equal(t, []string{"1", "2", "3"}, ids("select id from item where flag = true"))
+	require.ErrorContains(t, exec("alter table item add column d2 date default 1"), "not a date")
+
+	// integers are signed, and those that do not fit in an int field are rejected
+	require.NoError(t, exec("insert into item(id, big) values(-4, -5000000000)"))
+	require.Equal(t, []string{"-4"}, ids("select id from item where id = - 4"))
+	require.Equal(t, []string{"-4"}, ids("select id from item where big = -5000000000"))
+	require.ErrorContains(t, exec("insert into item(id) values(3000000000)"), "out of range for int field id")
+	require.ErrorContains(t, exec("insert into item(id) values(99999999999999999999)"), "out of the range of bigint")
+	require.NoError(t, txn.Commit())
+}
+
+func TestPlanner_Vacuum(t *testing.T) {
+	tp, txn := newTestPlanner(t)
+	planner, mdm, fm := tp.Planner, tp.mdm, tp.fm
+	begin := func() tx.Transaction { return tp.begin(t) }
+	exec := func(cmd string) (int, error) {
+		return planner.ExecuteUpdate(cmd, txn)
+	}
+	names := func() map[int]string {
+		p, err := planner.CreateQueryPlan("select id, name from item", txn)
+		require.NoError(t, err)
+		s, err := p.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		names := make(map[int]string)
+		for s.Next() {
+			id, err := s.GetInt("id")
+			require.NoError(t, err)
+			names[id], err = s.GetString("name")
+			require.NoError(t, err)
+		}
+		return names
+	}
+	size := func() int {
+		n, err := fm.BlockNum("item" + record.TABLE_SUFFIX)
+		require.NoError(t, err)
+		return n
+	}
+
+	// load the table, then delete nine records in ten
+	_, err := exec("create table item(id int, name varchar(20), kept int)")
+	require.NoError(t, err)
+	_, err = exec("create index item_id on item(id)")
+	require.NoError(t, err)
+	const recordNum = 200
+	want := make(map[int]string)
+	for i := 0; i < recordNum; i++ {
+		kept := 0
+		if i%10 == 0 {
+			kept = 1
+			want[i] = fmt.Sprintf("item%d", i)
+		}
+		_, err = exec(fmt.Sprintf("insert into item(id, name, kept) values(%d, 'item%d', %d)", i, i, kept))
+		require.NoError(t, err)
+	}
+	num, err := exec("delete from item where kept = 0")
+	require.NoError(t, err)
+	require.Equal(t, recordNum-len(want), num)
+	require.NoError(t, txn.Commit())
+	loaded := size()
+
+	// the records would move away from the RIDs in the index, so the table is not vacuumed until the index is dropped
+	txn = begin()
+	_, err = exec("vacuum item")
+	require.ErrorIs(t, err, metadata.ErrIndexedTable)
+	freed, err := exec("vacuum")
+	require.NoError(t, err)
+	require.Equal(t, 0, freed)
+	require.NoError(t, mdm.DropIndex("item_id", txn))
+	require.NoError(t, txn.Commit())
+	require.Equal(t, loaded, size())
+
+	// rolling back the vacuum keeps the file as it was
+	txn = begin()
+	freed, err = exec("vacuum item")
+	require.NoError(t, err)
+	require.Greater(t, freed, 0)
+	require.NoError(t, txn.Rollback())
+	require.Equal(t, loaded, size())
+	txn = begin()
+	require.Equal(t, want, names())
+	require.NoError(t, txn.Commit())
+
+	// the records are compacted, and the empty blocks removed once the vacuum commits
+	txn = begin()
+	freed, err = exec("vacuum item")
+	require.NoError(t, err)
+	require.Equal(t, loaded, size())
+	require.NoError(t, txn.Commit())
+	require.Equal(t, loaded-freed, size())
+	require.Less(t, size(), loaded/5)
+
+	txn = begin()
+	require.Equal(t, want, names())
+	layout, err := mdm.GetLayout("item", txn)
+	require.NoError(t, err)
+	si, err := mdm.GetStatInfo("item", layout, txn)
+	require.NoError(t, err)
+	require.Equal(t, size(), si.BlocksAccessed())
+	require.Equal(t, len(want), si.RecordsOutput())
+
+	// the table can grow again, and vacuuming all the tables finds nothing more to free
+	_, err = exec("insert into item(id, name, kept) values(1000, 'new', 1)")
+	require.NoError(t, err)
+	want[1000] = "new"
+	require.Equal(t, want, names())
+	freed, err = exec("vacuum")
+	require.NoError(t, err)
+	require.Equal(t, 0, freed)
+	_, err = exec("vacuum missing")
+	require.ErrorIs(t, err, metadata.ErrTableNotFound)
+	_, err = exec("vacuum tblcat")
+	require.Error(t, err)
+	require.NoError(t, txn.Commit())
+}
+
+func TestPlanner_Analyze(t *testing.T) {
+	tp, txn := newTestPlanner(t)
+	planner := tp.Planner
+	exec := func(cmd string) (int, error) {
+		return planner.ExecuteUpdate(cmd, txn)
+	}
+	statRows := func() int {
+		p, err := planner.CreateQueryPlan("select tblname from statcat", txn)
+		require.NoError(t, err)
+		s, err := p.Open()
+		require.NoError(t, err)
+		defer s.Close()
+		n := 0
+		for s.Next() {
+			n++
+		}
+		return n
+	}
+
+	_, err := exec("create table item(id int, category varchar(10))")
+	require.NoError(t, err)
+	_, err = exec("create table other(id int)")
+	require.NoError(t, err)
+	const recordNum = 40
+	for i := 0; i < recordNum; i++ {
+		category := fmt.Sprintf("'cat%d'", i%4)
+		if i < recordNum/4 {
+			category = "null"
+		}
+		_, err = exec(fmt.Sprintf("insert into item(id, category) values(%d, %s)", i, category))
+		require.NoError(t, err)
+	}
+
+	n, err := exec("analyze item")
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, 2, statRows())
+	require.NoError(t, txn.Commit())
+
+	// a new metadata manager loads the stored statistics
+	txn = tp.begin(t)
+	mdm, err := metadata.NewMetadataMgr(txn)
+	require.NoError(t, err)
+	planner = NewPlanner(NewBasicQueryPlanner(mdm), NewBasicUpdatePlanner(mdm))
+	layout, err := mdm.GetLayout("item", txn)
+	require.NoError(t, err)
+	si, err := mdm.GetStatInfo("item", layout, txn)
+	require.NoError(t, err)
+	require.Equal(t, recordNum, si.RecordsOutput())
+	require.Equal(t, recordNum, si.DistinctValues("id"))
+	require.Equal(t, 4, si.DistinctValues("category"))
+	require.InDelta(t, 0.25, si.NullFraction("category"), 1e-9)
+	require.Equal(t, "39", si.MaxValue("id").ToString())
+
+	// analyzing every table replaces the stored statistics, and dropping a table removes them
+	n, err = exec("analyze")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, 3, statRows())
+	_, err = exec("drop table item")
+	require.NoError(t, err)
+	require.Equal(t, 1, statRows())
+	_, err = exec("analyze missing")
+	require.ErrorIs(t, err, metadata.ErrTableNotFound)
+	_, err = exec("analyze statcat")
+	require.Error(t, err)
+	require.NoError(t, txn.Commit())
+}
+
+// testPlanner is a planner over a new database in a directory of its own, set up as the planner tests need it.
+type testPlanner struct {
+	*Planner
+	dir      string
+	fm       *file.FileMgrImpl
+	lm       log.LogMgr
+	bm       buffer.BufferMgr
+	txNumGen tx.TxNumberGenerator
+	mdm      metadata.MetadataMgr
+}
+
+// newTestPlanner returns the planner and the transaction its metadata manager was created in, which the caller ends.
+func newTestPlanner(t *testing.T) (*testPlanner, tx.Transaction) {
+	const (
+		logFileName = "logfile"
+		blockSize   = 400
+		buffNum     = 8
+	)
+	dir, cleanup := testutil.SetupDir(t.Name())
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	require.NoError(t, err)
+	buffs := make([]buffer.Buffer, buffNum)
+	for i := 0; i < buffNum; i++ {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	tp := &testPlanner{
+		dir:      dir,
+		fm:       fm,
+		lm:       lm,
+		bm:       buffer.NewBufferMgr(buffs),
+		txNumGen: tx.NewTxNumberGenerator(),
+	}
+	txn := tp.begin(t)
+	tp.mdm, err = metadata.NewMetadataMgr(txn)
+	require.NoError(t, err)
+	tp.Planner = NewPlanner(NewBasicQueryPlanner(tp.mdm), NewBasicUpdatePlanner(tp.mdm))
+	return tp, txn
+}
+
+func (tp *testPlanner) begin(t *testing.T) tx.Transaction {
+	txn, err := tx.NewTransaction(tp.fm, tp.lm, tp.bm, tp.txNumGen)
+	require.NoError(t, err)
+	return txn
+}
@@ -0,0 +1,128 @@
+package plan
+
+import (
+	"fmt"
+
+	"github.com/kj455/simple-db/pkg/buffer"
+	"github.com/kj455/simple-db/pkg/constant"
+	"github.com/kj455/simple-db/pkg/query"
+	"github.com/kj455/simple-db/pkg/record"
+)
+
+// BUFFERS_TABLE is the name of the system table with one row per buffer of the pool.
+const BUFFERS_TABLE = "simpledb_buffers"
+
+// SystemTable is a read-only table whose rows are produced by the database when it is queried instead of read from a file.
+type SystemTable struct {
+	schema record.Schema
+	rows   func() ([]map[string]*constant.Const, error)
+}
+
+func NewSystemTable(schema record.Schema, rows func() ([]map[string]*constant.Const, error)) *SystemTable {
+	return &SystemTable{
+		schema: schema,
+		rows:   rows,
+	}
+}
+
+/*
+NewBuffersTable returns the system table describing the buffers of bm.
+Its fields are id, the index of the buffer, filename and blknum of the assigned block, empty and -1 if there is none,
+pins, txnum of the transaction that modified the buffer, -1 if none did, and dirty, 1 if the buffer holds changes not yet written.
+*/
+func NewBuffersTable(bm buffer.BufferMgr) *SystemTable {
+	sch := record.NewSchema()
+	sch.AddIntField("id")
+	sch.AddStringField("filename", 64)
+	sch.AddIntField("blknum")
+	sch.AddIntField("pins")
+	sch.AddIntField("txnum")
+	sch.AddIntField("dirty")
+	return NewSystemTable(sch, func() ([]map[string]*constant.Const, error) {
+		infos := bm.Snapshot()
+		rows := make([]map[string]*constant.Const, 0, len(infos))
+		for _, info := range infos {
+			filename, blkNum := "", -1
+			if info.Block != nil {
+				filename, blkNum = info.Block.Filename(), info.Block.Number()
+			}
+			dirty := 0
+			if info.Dirty {
+				dirty = 1
+			}
+			row, err := newSystemRow(map[string]any{
+				"id":       info.Index,
+				"filename": filename,
+				"blknum":   blkNum,
+				"pins":     info.Pins,
+				"txnum":    info.ModifyingTx,
+				"dirty":    dirty,
+			})
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	})
+}
+
+func newSystemRow(vals map[string]any) (map[string]*constant.Const, error) {
+	row := make(map[string]*constant.Const, len(vals))
+	for field, val := range vals {
+		kind := constant.KIND_INT
+		if _, ok := val.(string); ok {
+			kind = constant.KIND_STR
+		}
+		c, err := constant.NewConstant(kind, val)
+		if err != nil {
+			return nil, fmt.Errorf("plan: failed to create value of %s: %v", field, err)
+		}
+		row[field] = c
+	}
+	return row, nil
+}
+
+// SystemTablePlan scans the rows a system table had when the plan was created.
+type SystemTablePlan struct {
+	table *SystemTable
+	rows  []map[string]*constant.Const
+}
+
+func NewSystemTablePlan(table *SystemTable) (*SystemTablePlan, error) {
+	rows, err := table.rows()
+	if err != nil {
+		return nil, fmt.Errorf("plan: failed to read system table: %v", err)
+	}
+	return &SystemTablePlan{
+		table: table,
+		rows:  rows,
+	}, nil
+}
+
+func (sp *SystemTablePlan) Open() (query.Scan, error) {
+	return query.NewValuesScan(sp.table.schema.Fields(), sp.rows), nil
+}
+
+// BlocksAccessed returns 0 since the rows are not read from disk.
+func (sp *SystemTablePlan) BlocksAccessed() int {
+	return 0
+}
+
+func (sp *SystemTablePlan) RecordsOutput() int {
+	return len(sp.rows)
+}
+
+func (sp *SystemTablePlan) DistinctValues(field string) int {
+	distinct := make(map[any]bool)
+	for _, row := range sp.rows {
+		if val, ok := row[field]; ok {
+			distinct[val.AnyValue()] = true
+		}
+	}
+	return max(len(distinct), 1)
+}
+
+func (sp *SystemTablePlan) Schema() record.Schema {
+	return sp.table.schema
+}
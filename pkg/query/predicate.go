@@ -27,14 +27,29 @@ func (p *PredicateImpl) ConjoinWith(pred *PredicateImpl) {
 	p.terms = append(p.terms, pred.terms...)
 }
 
-// IsSatisfied returns true if the predicate evaluates to true with respect to the specified scan.
-func (p *PredicateImpl) IsSatisfied(s Scan) (bool, error) {
+// Evaluate returns the truth of the conjunction of the terms: FALSE if any term is FALSE, otherwise UNKNOWN if any term is UNKNOWN, and TRUE otherwise.
+func (p *PredicateImpl) Evaluate(s Scan) (Truth, error) {
+	result := TRUTH_TRUE
 	for _, t := range p.terms {
-		if ok, err := t.IsSatisfied(s); !ok || err != nil {
-			return false, err
+		truth, err := t.Evaluate(s)
+		if err != nil {
+			return TRUTH_FALSE, err
+		}
+		if truth == TRUTH_FALSE {
+			return TRUTH_FALSE, nil
+		}
+		if truth == TRUTH_UNKNOWN {
+			result = TRUTH_UNKNOWN
 		}
 	}
-	return true, nil
+	return result, nil
+}
+
+// IsSatisfied returns true if the predicate evaluates to true with respect to the specified scan.
+// As in SQL, a predicate that is UNKNOWN is not satisfied.
+func (p *PredicateImpl) IsSatisfied(s Scan) (bool, error) {
+	truth, err := p.Evaluate(s)
+	return truth == TRUTH_TRUE, err
 }
 
 // ReductionFactor calculates the extent to which selecting on the predicate reduces the number of records output by a query.
@@ -0,0 +1,54 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/kj455/simple-db/pkg/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredicate_Null(t *testing.T) {
+	t.Parallel()
+	one, err := constant.NewConstant(constant.KIND_INT, 1)
+	assert.NoError(t, err)
+	two, err := constant.NewConstant(constant.KIND_INT, 2)
+	assert.NoError(t, err)
+	a, b := NewFieldExpression("A"), NewFieldExpression("B")
+	tests := []struct {
+		name   string
+		pred   *PredicateImpl
+		expect Truth
+	}{
+		{name: "equal values", pred: NewPredicate(NewTerm(a, NewConstantExpression(one))), expect: TRUTH_TRUE},
+		{name: "different values", pred: NewPredicate(NewTerm(a, NewConstantExpression(two))), expect: TRUTH_FALSE},
+		{name: "comparison with null field", pred: NewPredicate(NewTerm(b, NewConstantExpression(one))), expect: TRUTH_UNKNOWN},
+		{name: "null equals null", pred: NewPredicate(NewTerm(b, NewConstantExpression(constant.NewNull()))), expect: TRUTH_UNKNOWN},
+		{name: "is null", pred: NewPredicate(NewIsNullTerm(b, false)), expect: TRUTH_TRUE},
+		{name: "is not null", pred: NewPredicate(NewIsNullTerm(b, true)), expect: TRUTH_FALSE},
+		{name: "true and unknown", pred: NewPredicate(NewIsNullTerm(a, true), NewTerm(b, a)), expect: TRUTH_UNKNOWN},
+		{name: "false and unknown", pred: NewPredicate(NewIsNullTerm(a, false), NewTerm(b, a)), expect: TRUTH_FALSE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			scan := NewValuesScan([]string{"A", "B"}, []map[string]*constant.Const{{"A": one, "B": constant.NewNull()}})
+			assert.True(t, scan.Next())
+
+			truth, err := tt.pred.Evaluate(scan)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect, truth)
+			ok, err := tt.pred.IsSatisfied(scan)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expect == TRUTH_TRUE, ok)
+		})
+	}
+	t.Run("null is never a constant equivalence", func(t *testing.T) {
+		t.Parallel()
+		pred := NewPredicate(NewTerm(b, NewConstantExpression(constant.NewNull())), NewIsNullTerm(a, false))
+		_, ok := pred.FindConstantEquivalence("B")
+		assert.False(t, ok)
+		_, ok = pred.FindConstantEquivalence("A")
+		assert.False(t, ok)
+		assert.Equal(t, "B=null and A is null", pred.String())
+	})
+}
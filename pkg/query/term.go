@@ -5,8 +5,36 @@ import (
 	"github.com/kj455/simple-db/pkg/record"
 )
 
+// Truth is the value of a condition in SQL's three-valued logic. Comparing with NULL is neither true nor false but UNKNOWN.
+type Truth int
+
+const (
+	TRUTH_FALSE Truth = iota
+	TRUTH_TRUE
+	TRUTH_UNKNOWN
+)
+
+func truthOf(b bool) Truth {
+	if b {
+		return TRUTH_TRUE
+	}
+	return TRUTH_FALSE
+}
+
+type TermOp int
+
+const (
+	// TERM_OP_EQ compares lhs and rhs: "lhs = rhs".
+	TERM_OP_EQ TermOp = iota
+	// TERM_OP_IS_NULL tests lhs, and has no rhs: "lhs is null".
+	TERM_OP_IS_NULL
+	// TERM_OP_IS_NOT_NULL tests lhs, and has no rhs: "lhs is not null".
+	TERM_OP_IS_NOT_NULL
+)
+
 type Term struct {
 	lhs, rhs Expression
+	op       TermOp
 }
 
 // NewTerm creates a new Term instance with two expressions
@@ -14,24 +42,62 @@ func NewTerm(lhs, rhs Expression) *Term {
 	return &Term{
 		lhs: lhs,
 		rhs: rhs,
+		op:  TERM_OP_EQ,
 	}
 }
 
-func (t *Term) IsSatisfied(s Scan) (bool, error) {
+// NewIsNullTerm creates a term testing whether the expression is NULL, or is not NULL if not is set.
+func NewIsNullTerm(expr Expression, not bool) *Term {
+	op := TERM_OP_IS_NULL
+	if not {
+		op = TERM_OP_IS_NOT_NULL
+	}
+	return &Term{
+		lhs: expr,
+		op:  op,
+	}
+}
+
+// Evaluate returns the truth of the term for the current record of the scan. A comparison with NULL is UNKNOWN.
+func (t *Term) Evaluate(s Scan) (Truth, error) {
 	lhsVal, err := t.lhs.Evaluate(s)
 	if err != nil {
-		return false, err
+		return TRUTH_FALSE, err
+	}
+	switch t.op {
+	case TERM_OP_IS_NULL:
+		return truthOf(lhsVal.IsNull()), nil
+	case TERM_OP_IS_NOT_NULL:
+		return truthOf(!lhsVal.IsNull()), nil
 	}
 	rhsVal, err := t.rhs.Evaluate(s)
 	if err != nil {
-		return false, err
+		return TRUTH_FALSE, err
 	}
-	return lhsVal.Equals(rhsVal), nil
+	if lhsVal.IsNull() || rhsVal.IsNull() {
+		return TRUTH_UNKNOWN, nil
+	}
+	return truthOf(lhsVal.Equals(rhsVal)), nil
+}
+
+// IsSatisfied returns true only if the term is TRUE; FALSE and UNKNOWN terms are not satisfied.
+func (t *Term) IsSatisfied(s Scan) (bool, error) {
+	truth, err := t.Evaluate(s)
+	return truth == TRUTH_TRUE, err
 }
 
 // ReductionFactor calculates the extent to which selecting on the predicate reduces the number of records output by a query.
 func (t *Term) ReductionFactor(p PlanInfo) int {
 	var lhsName, rhsName string
+	switch t.op {
+	case TERM_OP_IS_NULL:
+		if t.lhs.IsFieldName() {
+			return p.DistinctValues(t.lhs.AsFieldName())
+		}
+		return 1
+	case TERM_OP_IS_NOT_NULL:
+		return 1
+	}
 	if t.lhs.IsFieldName() && t.rhs.IsFieldName() {
 		lhsName = t.lhs.AsFieldName()
 		rhsName = t.rhs.AsFieldName()
@@ -45,13 +111,25 @@ func (t *Term) ReductionFactor(p PlanInfo) int {
 		rhsName = t.rhs.AsFieldName()
 		return p.DistinctValues(rhsName)
 	}
-	if t.lhs.AsConstant().Equals(t.rhs.AsConstant()) {
+	lhsVal, rhsVal := t.lhs.AsConstant(), t.rhs.AsConstant()
+	if !lhsVal.IsNull() && lhsVal.Equals(rhsVal) {
 		return 1
 	}
 	return int(^uint(0) >> 1) // Max int value
 }
 
+// FindConstantEquivalence returns c if the term is "field=c" or "c=field". A comparison with NULL equates the field to nothing.
 func (t *Term) FindConstantEquivalence(field string) (*constant.Const, bool) {
+	if t.op != TERM_OP_EQ {
+		return nil, false
+	}
+	if c, ok := t.constantEquivalence(field); ok && !c.IsNull() {
+		return c, true
+	}
+	return nil, false
+}
+
+func (t *Term) constantEquivalence(field string) (*constant.Const, bool) {
 	if t.lhs.IsFieldName() && t.lhs.AsFieldName() == field && !t.rhs.IsFieldName() {
 		return t.rhs.AsConstant(), true
 	}
@@ -62,6 +140,9 @@ func (t *Term) FindConstantEquivalence(field string) (*constant.Const, bool) {
 }
 
 func (t *Term) FindFieldEquivalence(field string) (string, bool) {
+	if t.op != TERM_OP_EQ {
+		return "", false
+	}
 	if t.lhs.IsFieldName() && t.lhs.AsFieldName() == field && t.rhs.IsFieldName() {
 		return t.rhs.AsFieldName(), true
 	}
@@ -72,9 +153,18 @@ func (t *Term) FindFieldEquivalence(field string) (string, bool) {
 }
 
 func (t *Term) CanApply(sch record.Schema) bool {
+	if t.op != TERM_OP_EQ {
+		return t.lhs.CanApply(sch)
+	}
 	return t.lhs.CanApply(sch) && t.rhs.CanApply(sch)
 }
 
 func (t *Term) String() string {
+	switch t.op {
+	case TERM_OP_IS_NULL:
+		return t.lhs.ToString() + " is null"
+	case TERM_OP_IS_NOT_NULL:
+		return t.lhs.ToString() + " is not null"
+	}
 	return t.lhs.ToString() + "=" + t.rhs.ToString()
 }
@@ -0,0 +1,74 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/kj455/simple-db/pkg/constant"
+)
+
+// ValuesScan scans rows held in memory. Each row maps every field of the scan to its value.
+type ValuesScan struct {
+	fields []string
+	rows   []map[string]*constant.Const
+	pos    int
+}
+
+func NewValuesScan(fields []string, rows []map[string]*constant.Const) *ValuesScan {
+	return &ValuesScan{
+		fields: fields,
+		rows:   rows,
+		pos:    -1,
+	}
+}
+
+func (vs *ValuesScan) BeforeFirst() error {
+	vs.pos = -1
+	return nil
+}
+
+func (vs *ValuesScan) Next() bool {
+	if vs.pos+1 >= len(vs.rows) {
+		vs.pos = len(vs.rows)
+		return false
+	}
+	vs.pos++
+	return true
+}
+
+func (vs *ValuesScan) GetInt(field string) (int, error) {
+	val, err := vs.GetVal(field)
+	if err != nil {
+		return 0, err
+	}
+	return val.AsInt()
+}
+
+func (vs *ValuesScan) GetString(field string) (string, error) {
+	val, err := vs.GetVal(field)
+	if err != nil {
+		return "", err
+	}
+	return val.AsString()
+}
+
+func (vs *ValuesScan) GetVal(field string) (*constant.Const, error) {
+	if vs.pos < 0 || vs.pos >= len(vs.rows) {
+		return nil, fmt.Errorf("query: no current record")
+	}
+	val, ok := vs.rows[vs.pos][field]
+	if !ok {
+		return nil, fmt.Errorf("query: field %s not found", field)
+	}
+	return val, nil
+}
+
+func (vs *ValuesScan) HasField(field string) bool {
+	for _, f := range vs.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (vs *ValuesScan) Close() {}
@@ -0,0 +1,39 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/kj455/simple-db/pkg/constant"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValuesScan(t *testing.T) {
+	t.Parallel()
+	row := func(a int, b string) map[string]*constant.Const {
+		aVal, _ := constant.NewConstant(constant.KIND_INT, a)
+		bVal, _ := constant.NewConstant(constant.KIND_STR, b)
+		return map[string]*constant.Const{"A": aVal, "B": bVal}
+	}
+	scan := NewValuesScan([]string{"A", "B"}, []map[string]*constant.Const{row(1, "one"), row(2, "two")})
+
+	_, err := scan.GetInt("A")
+	assert.Error(t, err)
+	var got []string
+	for scan.Next() {
+		a, err := scan.GetInt("A")
+		assert.NoError(t, err)
+		b, err := scan.GetString("B")
+		assert.NoError(t, err)
+		got = append(got, b)
+		assert.Equal(t, len(got), a)
+	}
+	assert.Equal(t, []string{"one", "two"}, got)
+	assert.False(t, scan.Next())
+
+	assert.NoError(t, scan.BeforeFirst())
+	assert.True(t, scan.Next())
+	_, err = scan.GetVal("C")
+	assert.Error(t, err)
+	assert.True(t, scan.HasField("B"))
+	assert.False(t, scan.HasField("C"))
+}
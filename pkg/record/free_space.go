@@ -0,0 +1,91 @@
+package record
+
+import (
+	"github.com/kj455/simple-db/pkg/buffer"
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/tx"
+)
+
+const FREE_SPACE_SUFFIX = ".fsm"
+
+/*
+freeSpaceMap tells how many bytes are free in each block of a table, so that an insert goes to a block with room without reading the others.
+It is a file of int32 words, one for each block of the table in order, each word holding the free bytes of its block plus one:
+0, as in a block of the map that was never written, means that the free space of the block is not known yet.
+The map is updated when space is freed, by deletes and moves, and when a block is found full. Space that is taken,
+by inserts and values that grow, is not: an entry may thus claim more room than there is, and the block is then checked and its entry corrected.
+The transactions share the map, reading and writing it with tx.Transaction.ReadShared and WriteShared, so that those that change
+different blocks of a table do not wait for one another on the block of the map. The changes are not logged: an entry of a rolled back
+delete claims too much room, which is corrected as above, and one of a rolled back insert too little, which is corrected by the next delete from the block.
+*/
+type freeSpaceMap struct {
+	tx       tx.Transaction
+	filename string
+}
+
+func newFreeSpaceMap(tx tx.Transaction, table string) *freeSpaceMap {
+	return &freeSpaceMap{tx: tx, filename: table + FREE_SPACE_SUFFIX}
+}
+
+// insertBytes returns the most bytes InsertAfter takes from the free space of a page of the layout: a new slot and an empty record.
+func insertBytes(layout Layout) int {
+	n := slotBytes + layout.SlotSize()
+	for _, fld := range layout.Schema().Fields() {
+		if typ, err := layout.Schema().Type(fld); err == nil && isVariable(typ) {
+			n += file.MaxBytesLength(0)
+		}
+	}
+	return n
+}
+
+func (fsm *freeSpaceMap) entriesPerBlock() int {
+	return fsm.tx.BlockSize() / int32Bytes
+}
+
+// find returns the first block of the table, from the block numbered from on and before size, that may have need free bytes:
+// one with as many, or whose free space is not known. It returns -1 if there is none.
+func (fsm *freeSpaceMap) find(need, from, size int) (int, error) {
+	perBlock := fsm.entriesPerBlock()
+	for blknum := from; blknum < size; {
+		mapBlk := blknum / perBlock
+		found := SLOT_INIT
+		err := fsm.tx.ReadShared(file.NewBlockId(fsm.filename, mapBlk), func(p buffer.ReadPage) {
+			for ; blknum < size && blknum/perBlock == mapBlk; blknum++ {
+				if entry := int(p.GetInt((blknum % perBlock) * int32Bytes)); entry == 0 || entry-1 >= need {
+					found = blknum
+					return
+				}
+			}
+		})
+		if err != nil || found >= 0 {
+			return found, err
+		}
+	}
+	return SLOT_INIT, nil
+}
+
+// set records that the block of the table has free bytes free.
+func (fsm *freeSpaceMap) set(blknum, free int) error {
+	perBlock := fsm.entriesPerBlock()
+	blk := file.NewBlockId(fsm.filename, blknum/perBlock)
+	offset := (blknum % perBlock) * int32Bytes
+	var entry int
+	err := fsm.tx.ReadShared(blk, func(p buffer.ReadPage) {
+		entry = int(p.GetInt(offset))
+	})
+	if err != nil || entry == free+1 {
+		return err
+	}
+	return fsm.tx.WriteShared(blk, func(p buffer.ReadWritePage) {
+		p.SetInt(offset, int32(free+1))
+	})
+}
+
+// update records the free space of the page.
+func (fsm *freeSpaceMap) update(rp RecordPage) error {
+	free, err := rp.Free()
+	if err != nil {
+		return err
+	}
+	return fsm.set(rp.Block().Number(), free)
+}
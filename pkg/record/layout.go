@@ -1,25 +1,38 @@
 package record
 
-import (
-	"fmt"
+import "fmt"
 
-	"github.com/kj455/simple-db/pkg/file"
+const (
+	int32Bytes = 4
+	int64Bytes = 8
+	// nullBitsPerWord is the number of null flags in each int32 word of the null bitmap of a slot.
+	nullBitsPerWord = 32
 )
 
-const int32Bytes = 4
-
+/*
+LayoutImpl describes how the records of a table are laid out:
+------------------------------------------------------------------------------
+| null bitmap | field 0 | field 1 | ... | field n | variable-length values |
+------------------------------------------------------------------------------
+The null bitmap has one bit per field, in the order of the schema, packed into int32 words.
+A set bit means the field is NULL, whatever the bytes of the field hold.
+A VARCHAR, TEXT or BLOB field holds the position in the record of its value, which follows the fixed-length part,
+so that a record takes only as many bytes as its values need. The values are in the order of the schema, each padded to whole int32 words.
+*/
 type LayoutImpl struct {
 	schema   Schema
 	offsets  map[string]int
+	nullBits map[string]int
 	slotSize int
 }
 
 func NewLayoutFromSchema(schema Schema) (Layout, error) {
 	l := &LayoutImpl{
-		schema:  schema,
-		offsets: make(map[string]int),
+		schema:   schema,
+		offsets:  make(map[string]int),
+		nullBits: newNullBits(schema),
 	}
-	pos := int32Bytes // for int32 slot
+	pos := int32Bytes * nullWords(len(schema.Fields()))
 	for _, field := range schema.Fields() {
 		l.offsets[field] = pos
 		length, err := l.lengthInBytes(field)
@@ -36,10 +49,23 @@ func NewLayout(schema Schema, offsets map[string]int, slotSize int) *LayoutImpl
 	return &LayoutImpl{
 		schema:   schema,
 		offsets:  offsets,
+		nullBits: newNullBits(schema),
 		slotSize: slotSize,
 	}
 }
 
+func newNullBits(schema Schema) map[string]int {
+	bits := make(map[string]int, len(schema.Fields()))
+	for i, field := range schema.Fields() {
+		bits[field] = i
+	}
+	return bits
+}
+
+func nullWords(fieldNum int) int {
+	return (fieldNum + nullBitsPerWord - 1) / nullBitsPerWord
+}
+
 func (l *LayoutImpl) Schema() Schema {
 	return l.schema
 }
@@ -48,6 +74,12 @@ func (l *LayoutImpl) Offset(field string) int {
 	return l.offsets[field]
 }
 
+// NullFlag returns the offset in a record of the null bitmap word holding the flag of the field, and the mask of the flag in the word.
+func (l *LayoutImpl) NullFlag(field string) (offset int, mask int) {
+	bit := l.nullBits[field]
+	return int32Bytes * (bit / nullBitsPerWord), 1 << (bit % nullBitsPerWord)
+}
+
 func (l *LayoutImpl) SlotSize() int {
 	return l.slotSize
 }
@@ -60,12 +92,19 @@ func (l *LayoutImpl) lengthInBytes(field string) (int, error) {
 	switch typ {
 	case SCHEMA_TYPE_INTEGER:
 		return int32Bytes, nil
-	case SCHEMA_TYPE_VARCHAR:
-		len, err := l.schema.Length(field)
-		if err != nil {
-			return 0, err
-		}
-		return file.MaxLength(len), nil
+	case SCHEMA_TYPE_VARCHAR, SCHEMA_TYPE_BLOB, SCHEMA_TYPE_TEXT:
+		// the position of the value
+		return int32Bytes, nil
+	case SCHEMA_TYPE_BIGINT, SCHEMA_TYPE_DOUBLE, SCHEMA_TYPE_DATE, SCHEMA_TYPE_TIMESTAMP:
+		return int64Bytes, nil
+	case SCHEMA_TYPE_BOOLEAN:
+		// a boolean takes one byte, padded so that the fields stay aligned to int32 words
+		return int32Bytes, nil
 	}
 	return 0, fmt.Errorf("record: unknown schema type %v", typ)
 }
+
+// alignInt32 rounds n up to a multiple of int32Bytes. Records are made of whole int32 words, which RecordPageImpl copies one by one.
+func alignInt32(n int) int {
+	return (n + int32Bytes - 1) / int32Bytes * int32Bytes
+}
@@ -1,6 +1,7 @@
 package record
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,5 +18,34 @@ func TestRecordLayout(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, int32Bytes, layout.Offset("id"))
 	assert.Equal(t, int32Bytes+4, layout.Offset("name"))
-	assert.Equal(t, int32Bytes+4+(4+4*20), layout.SlotSize())
+	// the varchar holds the position of its value, which follows the fixed-length part
+	assert.Equal(t, int32Bytes+4+4, layout.SlotSize())
+	offset, mask := layout.NullFlag("name")
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, 1<<1, mask)
+}
+
+func TestRecordLayout_NullBitmap(t *testing.T) {
+	t.Parallel()
+	schema := NewSchema()
+	for i := 0; i < nullBitsPerWord+1; i++ {
+		schema.AddIntField(fmt.Sprintf("f%d", i))
+	}
+
+	layout, err := NewLayoutFromSchema(schema)
+
+	assert.NoError(t, err)
+	// two bitmap words for 33 fields
+	assert.Equal(t, int32Bytes*2, layout.Offset("f0"))
+	offset, mask := layout.NullFlag("f31")
+	assert.Equal(t, 0, offset)
+	assert.Equal(t, 1<<31, mask)
+	offset, mask = layout.NullFlag("f32")
+	assert.Equal(t, int32Bytes, offset)
+	assert.Equal(t, 1, mask)
+	// a layout read back from the catalog finds the same flags
+	fromCatalog := NewLayout(schema, map[string]int{}, layout.SlotSize())
+	offset, mask = fromCatalog.NullFlag("f32")
+	assert.Equal(t, int32Bytes, offset)
+	assert.Equal(t, 1, mask)
 }
@@ -0,0 +1,282 @@
+package record
+
+import (
+	"fmt"
+
+	"github.com/kj455/simple-db/pkg/buffer"
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/tx"
+)
+
+const OVERFLOW_SUFFIX = ".ovf"
+
+const (
+	offsetFreeHead   = 0
+	offsetGeneration = int32Bytes
+	offsetTaken      = 2 * int32Bytes
+	offsetNextChunk  = 0
+	offsetChunk      = int32Bytes
+)
+
+/*
+overflowFile holds the values of a table that are too large to be kept in their records, as overflows tells.
+A value is split into chunks, each in a block of its own, chained by the number of the next block, which is 0 for the last one:
+------------------------
+| next | chunk         |
+------------------------
+The chunk is stored as file.Page.SetBytes does, and is at most half a block, so that its log record fits in a log block.
+Block 0 is the header: it holds the first block of the free list, which chains the blocks of the values that were freed in the same way,
+the number of times the file was cleared and the number of blocks taken from the list.
+The chunks are written with logged changes, so rolling back restores the values. The transactions share the free list, taking
+blocks from it and adding them with tx.Transaction.WriteShared, so that those that store values do not wait for one another on the header:
+  - A value freed joins the list once its transaction has committed, so that no other transaction reuses its blocks while it could be restored.
+    It does not if the file was cleared since, as its blocks are then free already.
+  - A block taken from the list is written to disk off the list before the transaction that takes it commits.
+    If the transaction rolls back, the block is lost until the file is cleared, as is a block it appended.
+
+Clearing the file changes the header with logged changes, as the table is then locked exclusively.
+*/
+type overflowFile struct {
+	tx       tx.Transaction
+	filename string
+}
+
+func newOverflowFile(tx tx.Transaction, table string) *overflowFile {
+	return &overflowFile{tx: tx, filename: table + OVERFLOW_SUFFIX}
+}
+
+// overflows reports whether a value of n bytes is stored in the overflow file rather than in its record:
+// those that would take more than a quarter of a block.
+func overflows(blockSize, n int) bool {
+	return file.MaxBytesLength(n) > blockSize/4
+}
+
+func (of *overflowFile) chunkSize() int {
+	return of.tx.BlockSize() / 2
+}
+
+// write stores the value and returns the number of its first block.
+func (of *overflowFile) write(val []byte) (int, error) {
+	chunk := of.chunkSize()
+	blocks := make([]int, (len(val)+chunk-1)/chunk)
+	for i := range blocks {
+		blknum, err := of.allocate()
+		if err != nil {
+			return 0, err
+		}
+		blocks[i] = blknum
+	}
+	for i, blknum := range blocks {
+		next := 0
+		if i+1 < len(blocks) {
+			next = blocks[i+1]
+		}
+		part := val[i*chunk : min((i+1)*chunk, len(val))]
+		err := of.withBlock(blknum, func(blk file.BlockId) error {
+			if err := of.tx.SetInt(blk, offsetNextChunk, next, true); err != nil {
+				return err
+			}
+			return of.tx.SetBytes(blk, offsetChunk, part, true)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("record: failed to write overflow value: %w", err)
+		}
+	}
+	return blocks[0], nil
+}
+
+// read returns the value of length bytes stored from the first block.
+func (of *overflowFile) read(first, length int) ([]byte, error) {
+	val := make([]byte, 0, length)
+	for blknum := first; blknum != 0 && len(val) < length; {
+		err := of.withBlock(blknum, func(blk file.BlockId) error {
+			part, err := of.tx.GetBytes(blk, offsetChunk)
+			if err != nil {
+				return err
+			}
+			val = append(val, part...)
+			blknum, err = of.tx.GetInt(blk, offsetNextChunk)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("record: failed to read overflow value: %w", err)
+		}
+	}
+	if len(val) != length {
+		return nil, fmt.Errorf("record: overflow value from block %d has %d bytes instead of %d", first, len(val), length)
+	}
+	return val, nil
+}
+
+// free adds the blocks of the value stored from the first block to the free list once the transaction has committed.
+func (of *overflowFile) free(first int) error {
+	last := first
+	for {
+		var next int
+		err := of.withBlock(last, func(blk file.BlockId) (err error) {
+			next, err = of.tx.GetInt(blk, offsetNextChunk)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("record: failed to free overflow value: %w", err)
+		}
+		if next == 0 {
+			break
+		}
+		last = next
+	}
+	_, _, generation, err := of.readHeader()
+	if err != nil {
+		return fmt.Errorf("record: failed to free overflow value: %w", err)
+	}
+	tx, filename := of.tx, of.filename
+	tx.OnCommit(func() error {
+		return (&overflowFile{tx: tx, filename: filename}).push(first, last, generation)
+	})
+	return nil
+}
+
+// push adds the chain of blocks from first to last to the free list, unless the file was cleared since the generation.
+// The chain is the transaction's own until then, so its last block is linked to the head before the header is latched,
+// and linked again if the head changed meanwhile.
+func (of *overflowFile) push(first, last int, generation int32) error {
+	for {
+		head, _, current, err := of.readHeader()
+		if err != nil {
+			return fmt.Errorf("record: failed to free overflow value: %w", err)
+		}
+		if current != generation {
+			return nil
+		}
+		err = of.tx.WriteShared(file.NewBlockId(of.filename, last), func(p buffer.ReadWritePage) {
+			p.SetInt(offsetNextChunk, head)
+		})
+		if err != nil {
+			return fmt.Errorf("record: failed to free overflow value: %w", err)
+		}
+		var pushed bool
+		err = of.tx.WriteShared(of.header(), func(header buffer.ReadWritePage) {
+			if header.GetInt(offsetGeneration) != generation {
+				pushed = true
+				return
+			}
+			if header.GetInt(offsetFreeHead) == head {
+				header.SetInt(offsetFreeHead, int32(first))
+				pushed = true
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("record: failed to free overflow value: %w", err)
+		}
+		if pushed {
+			return nil
+		}
+	}
+}
+
+// clear frees all the blocks of the file.
+func (of *overflowFile) clear() error {
+	size, err := of.tx.Size(of.filename)
+	if err != nil {
+		return err
+	}
+	for blknum := 1; blknum < size; blknum++ {
+		next := (blknum + 1) % size
+		err := of.withBlock(blknum, func(blk file.BlockId) error {
+			return of.tx.SetInt(blk, offsetNextChunk, next, true)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if size == 0 {
+		return nil
+	}
+	return of.withBlock(0, func(header file.BlockId) error {
+		generation, err := of.tx.GetInt(header, offsetGeneration)
+		if err != nil {
+			return err
+		}
+		// the values freed before, which would join the list on commit, are in it already
+		if err := of.tx.SetInt(header, offsetGeneration, generation+1, true); err != nil {
+			return err
+		}
+		return of.tx.SetInt(header, offsetFreeHead, min(1, size-1), true)
+	})
+}
+
+// allocate returns the number of a block for a chunk: the first one of the free list, or else a new block.
+func (of *overflowFile) allocate() (int, error) {
+	blknum, err := of.pop()
+	if err != nil || blknum != 0 {
+		return blknum, err
+	}
+	blk, err := of.tx.AppendShared(of.filename)
+	if err == nil && blk.Number() == 0 {
+		// block 0 of a new file is the header, whose free list is empty
+		blk, err = of.tx.AppendShared(of.filename)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return blk.Number(), nil
+}
+
+// pop takes the first block of the free list, and returns 0 if the list is empty.
+// The next block of the head is read without the header latched, so the head is taken only if no other block
+// was taken since it was read, which the count of blocks taken in the header tells.
+func (of *overflowFile) pop() (int, error) {
+	for {
+		head, taken, generation, err := of.readHeader()
+		if err != nil || head == 0 {
+			return 0, err
+		}
+		var next int32
+		err = of.tx.ReadShared(file.NewBlockId(of.filename, int(head)), func(p buffer.ReadPage) {
+			next = p.GetInt(offsetNextChunk)
+		})
+		if err != nil {
+			return 0, err
+		}
+		var popped bool
+		err = of.tx.WriteShared(of.header(), func(header buffer.ReadWritePage) {
+			if header.GetInt(offsetFreeHead) != head || header.GetInt(offsetTaken) != taken ||
+				header.GetInt(offsetGeneration) != generation {
+				return
+			}
+			header.SetInt(offsetFreeHead, next)
+			header.SetInt(offsetTaken, taken+1)
+			popped = true
+		})
+		if err != nil {
+			return 0, err
+		}
+		if popped {
+			return int(head), nil
+		}
+	}
+}
+
+// readHeader returns the first block of the free list, the count of blocks taken from it and the generation of the file.
+// Like the other accesses to the header, it latches no other block meanwhile, as pinning one could wait
+// for a writer of the buffers that waits for the latch.
+func (of *overflowFile) readHeader() (head, taken, generation int32, err error) {
+	err = of.tx.ReadShared(of.header(), func(p buffer.ReadPage) {
+		head, taken, generation = p.GetInt(offsetFreeHead), p.GetInt(offsetTaken), p.GetInt(offsetGeneration)
+	})
+	return head, taken, generation, err
+}
+
+func (of *overflowFile) header() file.BlockId {
+	return file.NewBlockId(of.filename, 0)
+}
+
+// withBlock calls fn with the block pinned.
+func (of *overflowFile) withBlock(blknum int, fn func(blk file.BlockId) error) error {
+	blk := file.NewBlockId(of.filename, blknum)
+	if err := of.tx.Pin(blk); err != nil {
+		return err
+	}
+	defer of.tx.Unpin(blk)
+	return fn(blk)
+}
@@ -1,6 +1,11 @@
 package record
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/tx"
 )
@@ -10,17 +15,43 @@ type SlotFlag int
 const (
 	SLOT_EMPTY SlotFlag = 0
 	SLOT_USED  SlotFlag = 1
+	// SLOT_FORWARDED is the slot of a record that moved to another block. The slot points to where the record is now.
+	SLOT_FORWARDED SlotFlag = 2
+	// SLOT_MOVED holds a record that moved there from a forwarded slot. Scans skip it, and reach the record through its forwarded slot.
+	SLOT_MOVED SlotFlag = 3
 )
 
 const SLOT_INIT = -1
 
+const (
+	offsetSlotNum = 0
+	offsetFreeEnd = int32Bytes
+	// pageHeaderBytes is the size of the header of the page: the number of slots and the start of the records.
+	pageHeaderBytes = 2 * int32Bytes
+	// slotBytes is the size of a slot: the flag, and the position and length of the record.
+	slotBytes = 3 * int32Bytes
+)
+
+// overflowMarker takes the place of the length of a value stored in the overflow file. The first block and the length of the value follow it.
+const overflowMarker = -1
+
+// errNoRoom is returned when a record does not fit in its page, even once the page is compacted.
+var errNoRoom = errors.New("record: no room for the record in the block")
+
 /*
-RecordPage represents a page of records in a file.
-The format of the page is as follows:
-----------------------------------------
-| slot 0 | slot 1 | ... | slot n | ... |
-| 1 | R0 | 0 | R1 | ... | 0 | Rx | ... |
-----------------------------------------
+RecordPageImpl is a slotted page of records. The slots grow from the start of the block, and the records, laid out as LayoutImpl describes, from its end:
+------------------------------------------------------------------------------------------
+| slot num | free end | slot 0 | slot 1 | ... | slot n | free space | Rn | ... | R1 | R0 |
+------------------------------------------------------------------------------------------
+Each slot holds a flag, and the position and length of its record. A forwarded slot holds the block and slot of its record instead.
+The free end is where the records start; 0 means the end of the block, as in a block that was never formatted.
+
+A record is identified by its block and slot, which stay the same whatever the size of the record. A record that grows is rewritten
+elsewhere in the page, which is compacted first if the free space is not enough. If the page has no room left, the setter returns
+errNoRoom, and TableScanImpl moves the record to another block and forwards the slot to it.
+Deleting a record only empties its slot; the space of deleted records is reclaimed when the page is compacted.
+
+A variable-length value may be stored in the overflow file of the table instead, by TableScanImpl; the record then holds where it is.
 */
 type RecordPageImpl struct {
 	tx     tx.Transaction
@@ -40,73 +71,552 @@ func NewRecordPage(t tx.Transaction, b file.BlockId, l Layout) (RecordPage, erro
 }
 
 func (rp *RecordPageImpl) GetInt(slot int, field string) (int, error) {
-	pos := rp.offset(slot) + rp.layout.Offset(field)
+	pos, err := rp.fieldPos(slot, field)
+	if err != nil {
+		return 0, err
+	}
 	return rp.tx.GetInt(rp.blk, pos)
 }
 
 func (rp *RecordPageImpl) GetString(slot int, field string) (string, error) {
-	pos := rp.offset(slot) + rp.layout.Offset(field)
+	pos, err := rp.fieldPos(slot, field)
+	if err != nil {
+		return "", err
+	}
 	return rp.tx.GetString(rp.blk, pos)
 }
 
 func (rp *RecordPageImpl) SetInt(slot int, field string, val int) error {
-	pos := rp.offset(slot) + rp.layout.Offset(field)
+	pos, err := rp.fixedPos(slot, field)
+	if err != nil {
+		return err
+	}
 	return rp.tx.SetInt(rp.blk, pos, val, true)
 }
 
+// SetString sets the field to val, which must not be too long for the field, as checkLength tells.
+// It returns errNoRoom if the record grows too large for the page.
 func (rp *RecordPageImpl) SetString(slot int, field string, val string) error {
-	pos := rp.offset(slot) + rp.layout.Offset(field)
-	return rp.tx.SetString(rp.blk, pos, val, true)
+	return rp.SetBytes(slot, field, []byte(val))
 }
 
-func (rp *RecordPageImpl) Delete(slot int) error {
-	return rp.setFlag(slot, SLOT_EMPTY)
+func (rp *RecordPageImpl) GetInt64(slot int, field string) (int64, error) {
+	pos, err := rp.fieldPos(slot, field)
+	if err != nil {
+		return 0, err
+	}
+	return rp.tx.GetInt64(rp.blk, pos)
 }
 
-// Format initializes all the slots on the page to empty.
-func (rp *RecordPageImpl) Format() error {
-	slot := 0
-	schema := rp.layout.Schema()
-	for rp.isValidSlot(slot) {
-		if err := rp.tx.SetInt(rp.blk, rp.offset(slot), int(SLOT_EMPTY), false); err != nil {
+func (rp *RecordPageImpl) GetFloat64(slot int, field string) (float64, error) {
+	pos, err := rp.fieldPos(slot, field)
+	if err != nil {
+		return 0, err
+	}
+	return rp.tx.GetFloat64(rp.blk, pos)
+}
+
+func (rp *RecordPageImpl) GetBool(slot int, field string) (bool, error) {
+	pos, err := rp.fieldPos(slot, field)
+	if err != nil {
+		return false, err
+	}
+	return rp.tx.GetBool(rp.blk, pos)
+}
+
+func (rp *RecordPageImpl) GetTime(slot int, field string) (time.Time, error) {
+	pos, err := rp.fieldPos(slot, field)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return rp.tx.GetTime(rp.blk, pos)
+}
+
+func (rp *RecordPageImpl) GetBytes(slot int, field string) ([]byte, error) {
+	pos, err := rp.fieldPos(slot, field)
+	if err != nil {
+		return nil, err
+	}
+	return rp.tx.GetBytes(rp.blk, pos)
+}
+
+func (rp *RecordPageImpl) SetInt64(slot int, field string, val int64) error {
+	pos, err := rp.fixedPos(slot, field)
+	if err != nil {
+		return err
+	}
+	return rp.tx.SetInt64(rp.blk, pos, val, true)
+}
+
+func (rp *RecordPageImpl) SetFloat64(slot int, field string, val float64) error {
+	pos, err := rp.fixedPos(slot, field)
+	if err != nil {
+		return err
+	}
+	return rp.tx.SetFloat64(rp.blk, pos, val, true)
+}
+
+func (rp *RecordPageImpl) SetBool(slot int, field string, val bool) error {
+	pos, err := rp.fixedPos(slot, field)
+	if err != nil {
+		return err
+	}
+	return rp.tx.SetBool(rp.blk, pos, val, true)
+}
+
+func (rp *RecordPageImpl) SetTime(slot int, field string, val time.Time) error {
+	pos, err := rp.fixedPos(slot, field)
+	if err != nil {
+		return err
+	}
+	return rp.tx.SetTime(rp.blk, pos, val, true)
+}
+
+// SetBytes sets the field to val, which must not be too long for the field, as checkLength tells.
+// It returns errNoRoom if the record grows too large for the page.
+func (rp *RecordPageImpl) SetBytes(slot int, field string, val []byte) error {
+	if err := checkLength(rp.layout.Schema(), field, len(val)); err != nil {
+		return err
+	}
+	stored := file.NewPageFromBytes(make([]byte, alignInt32(file.MaxBytesLength(len(val)))))
+	stored.SetBytes(0, val)
+	return rp.setVariable(slot, field, stored.Contents().Bytes())
+}
+
+// checkLength returns an error if a value of n bytes is too long for the field: a VARCHAR takes the bytes file.MaxLength allows
+// for its length, a BLOB as many bytes as its length, and a TEXT any number of bytes.
+func checkLength(sch Schema, field string, n int) error {
+	typ, err := sch.Type(field)
+	if err != nil {
+		return err
+	}
+	length, err := sch.Length(field)
+	if err != nil {
+		return err
+	}
+	switch {
+	case typ == SCHEMA_TYPE_VARCHAR && file.MaxBytesLength(n) > file.MaxLength(length):
+		return fmt.Errorf("record: %d bytes do not fit in %s of %d characters", n, field, length)
+	case typ == SCHEMA_TYPE_BLOB && n > length:
+		return fmt.Errorf("record: %d bytes do not fit in %s of %d bytes", n, field, length)
+	}
+	return nil
+}
+
+// Overflowed returns the first block and the length of the value of the field if it is stored in the overflow file. first is 0 if the value is in the record.
+func (rp *RecordPageImpl) Overflowed(slot int, field string) (first, length int, err error) {
+	variable, err := rp.isVariable(field)
+	if err != nil || !variable {
+		return 0, 0, err
+	}
+	pos, err := rp.valuePos(slot, field)
+	if err != nil {
+		return 0, 0, err
+	}
+	marker, err := rp.tx.GetInt(rp.blk, pos)
+	if err != nil || marker != overflowMarker {
+		return 0, 0, err
+	}
+	if first, err = rp.tx.GetInt(rp.blk, pos+int32Bytes); err != nil {
+		return 0, 0, err
+	}
+	if length, err = rp.tx.GetInt(rp.blk, pos+2*int32Bytes); err != nil {
+		return 0, 0, err
+	}
+	if first <= 0 || length < 0 {
+		return 0, 0, fmt.Errorf("record: corrupt overflow reference of %s in slot %d of %v: block %d, length %d", field, slot, rp.blk, first, length)
+	}
+	return first, length, nil
+}
+
+// SetOverflowed makes the variable-length field refer to a value of length bytes stored in the overflow file from the first block, and clears its null flag.
+func (rp *RecordPageImpl) SetOverflowed(slot int, field string, first, length int) error {
+	stored := file.NewPageFromBytes(make([]byte, 3*int32Bytes))
+	stored.SetInt(0, overflowMarker)
+	stored.SetInt(int32Bytes, int32(first))
+	stored.SetInt(2*int32Bytes, int32(length))
+	return rp.setVariable(slot, field, stored.Contents().Bytes())
+}
+
+// fieldPos returns the position in the block of the value of the field of the record in the slot.
+// It returns an error for a value stored in the overflow file, which the caller reads from there.
+func (rp *RecordPageImpl) fieldPos(slot int, field string) (int, error) {
+	variable, err := rp.isVariable(field)
+	if err != nil {
+		return 0, err
+	}
+	if !variable {
+		pos, _, err := rp.record(slot)
+		return pos + rp.layout.Offset(field), err
+	}
+	pos, err := rp.valuePos(slot, field)
+	if err != nil {
+		return 0, err
+	}
+	marker, err := rp.tx.GetInt(rp.blk, pos)
+	if err != nil {
+		return 0, err
+	}
+	if marker == overflowMarker {
+		return 0, fmt.Errorf("record: the value of %s is in the overflow file", field)
+	}
+	return pos, nil
+}
+
+// valuePos returns the position in the block of the stored value of the variable-length field of the record in the slot.
+func (rp *RecordPageImpl) valuePos(slot int, field string) (int, error) {
+	pos, _, err := rp.record(slot)
+	if err != nil {
+		return 0, err
+	}
+	valPos, err := rp.tx.GetInt(rp.blk, pos+rp.layout.Offset(field))
+	if err != nil {
+		return 0, err
+	}
+	return pos + valPos, nil
+}
+
+// fixedPos clears the null flag of the fixed-size field, which is about to be set, and returns the position of its value.
+func (rp *RecordPageImpl) fixedPos(slot int, field string) (int, error) {
+	if err := rp.setNullFlag(slot, field, false); err != nil {
+		return 0, err
+	}
+	return rp.fieldPos(slot, field)
+}
+
+func (rp *RecordPageImpl) isVariable(field string) (bool, error) {
+	typ, err := rp.layout.Schema().Type(field)
+	if err != nil {
+		return false, err
+	}
+	return isVariable(typ), nil
+}
+
+// isVariable reports whether the values of the type vary in size, and so follow the fixed-length part of a record.
+func isVariable(typ SchemaType) bool {
+	return typ == SCHEMA_TYPE_VARCHAR || typ == SCHEMA_TYPE_BLOB || typ == SCHEMA_TYPE_TEXT
+}
+
+// IsNull reports whether the field of the record in the slot is NULL.
+func (rp *RecordPageImpl) IsNull(slot int, field string) (bool, error) {
+	pos, _, err := rp.record(slot)
+	if err != nil {
+		return false, err
+	}
+	offset, mask := rp.layout.NullFlag(field)
+	word, err := rp.tx.GetInt(rp.blk, pos+offset)
+	if err != nil {
+		return false, err
+	}
+	return word&mask != 0, nil
+}
+
+// SetNull sets the field of the record in the slot to NULL. The value of the field is left as it is.
+func (rp *RecordPageImpl) SetNull(slot int, field string) error {
+	return rp.setNullFlag(slot, field, true)
+}
+
+// setNullFlag sets or clears the null flag of the field, writing the bitmap word only if the flag changes.
+func (rp *RecordPageImpl) setNullFlag(slot int, field string, null bool) error {
+	recPos, _, err := rp.record(slot)
+	if err != nil {
+		return err
+	}
+	offset, mask := rp.layout.NullFlag(field)
+	pos := recPos + offset
+	word, err := rp.tx.GetInt(rp.blk, pos)
+	if err != nil {
+		return err
+	}
+	newWord := withNullFlag(word, mask, null)
+	if newWord == word {
+		return nil
+	}
+	return rp.tx.SetInt(rp.blk, pos, newWord, true)
+}
+
+// withNullFlag returns the bitmap word with the flag of the mask set or cleared. The word is an int32, whose sign bit is the last flag.
+func withNullFlag(word, mask int, null bool) int {
+	if null {
+		return int(int32(word) | int32(mask))
+	}
+	return int(int32(word) &^ int32(mask))
+}
+
+/*
+setVariable sets the variable-length field to the stored value, and clears its null flag. The stored value is either a value stored
+as file.Page.SetBytes does, or where the value is in the overflow file, padded to whole int32 words.
+The record is rebuilt with the new value: if it is no larger, it is rewritten in place; otherwise it is written in the free space
+of the page, which is compacted first if needed.
+*/
+func (rp *RecordPageImpl) setVariable(slot int, field string, stored []byte) error {
+	pos, length, err := rp.record(slot)
+	if err != nil {
+		return err
+	}
+	old, err := rp.read(pos, length)
+	if err != nil {
+		return err
+	}
+	rec, err := rp.rebuild(old, field, stored)
+	if err != nil {
+		return err
+	}
+	newLength := len(rec.Contents().Bytes())
+	flag, err := rp.flag(slot)
+	if err != nil {
+		return err
+	}
+	if newLength <= length {
+		if err := rp.write(pos, rec); err != nil {
 			return err
 		}
-		for _, field := range schema.Fields() {
-			pos := rp.offset(slot) + rp.layout.Offset(field)
-			typ, err := schema.Type(field)
-			if err != nil {
-				return err
-			}
-			switch typ {
-			case SCHEMA_TYPE_INTEGER:
-				if err := rp.tx.SetInt(rp.blk, pos, 0, false); err != nil {
-					return err
-				}
-			case SCHEMA_TYPE_VARCHAR:
-				if err := rp.tx.SetString(rp.blk, pos, "", false); err != nil {
-					return err
-				}
+		return rp.setSlot(slot, flag, pos, newLength)
+	}
+	room, err := rp.room(slot)
+	if err != nil {
+		return err
+	}
+	if room < newLength {
+		return errNoRoom
+	}
+	// detach the old record so that compacting the page reclaims its space
+	if err := rp.setSlot(slot, flag, pos, 0); err != nil {
+		return err
+	}
+	newPos, err := rp.reserve(newLength, 0)
+	if err != nil {
+		return err
+	}
+	if err := rp.write(newPos, rec); err != nil {
+		return err
+	}
+	return rp.setSlot(slot, flag, newPos, newLength)
+}
+
+// rebuild returns a copy of the record with the variable-length field set to the stored value. The values are laid out again in the order of the schema.
+func (rp *RecordPageImpl) rebuild(old *file.PageImpl, field string, stored []byte) (*file.PageImpl, error) {
+	fixed := rp.layout.SlotSize()
+	vals := make(map[string][]byte)
+	length := fixed
+	for _, fld := range rp.layout.Schema().Fields() {
+		variable, err := rp.isVariable(fld)
+		if err != nil {
+			return nil, err
+		}
+		if !variable {
+			continue
+		}
+		v := stored
+		if fld != field {
+			var err error
+			if v, err = storedValue(old, int(old.GetInt(rp.layout.Offset(fld)))); err != nil {
+				return nil, fmt.Errorf("record: %s of the record in %v: %w", fld, rp.blk, err)
 			}
 		}
-		slot++
+		vals[fld] = v
+		length += len(v)
+	}
+	rec := file.NewPageFromBytes(make([]byte, length))
+	copy(rec.Contents().Bytes(), old.Contents().Bytes()[:fixed])
+	pos := fixed
+	for _, fld := range rp.layout.Schema().Fields() {
+		v, ok := vals[fld]
+		if !ok {
+			continue
+		}
+		rec.SetInt(rp.layout.Offset(fld), int32(pos))
+		copy(rec.Contents().Bytes()[pos:], v)
+		pos += len(v)
+	}
+	offset, mask := rp.layout.NullFlag(field)
+	rec.SetInt(offset, int32(withNullFlag(int(rec.GetInt(offset)), mask, false)))
+	return rec, nil
+}
+
+// storedValue returns the bytes the variable-length value at pos of the record takes.
+// It fails if the stored length is negative, other than the overflow marker, or runs past the record.
+func storedValue(rec *file.PageImpl, pos int) ([]byte, error) {
+	b := rec.Contents().Bytes()
+	if pos < 0 || pos+int32Bytes > len(b) {
+		return nil, fmt.Errorf("%w: value at %d out of a record of %d bytes", file.ErrBadLength, pos, len(b))
+	}
+	length := int(rec.GetInt(pos))
+	end := pos + 3*int32Bytes
+	if length != overflowMarker {
+		if length < 0 {
+			return nil, fmt.Errorf("%w: length %d at %d", file.ErrBadLength, length, pos)
+		}
+		end = pos + alignInt32(file.MaxBytesLength(length))
+	}
+	if end > len(b) {
+		return nil, fmt.Errorf("%w: value at %d runs to %d past a record of %d bytes", file.ErrBadLength, pos, end, len(b))
+	}
+	return b[pos:end], nil
+}
+
+// emptyRecord returns a new record, whose fields are zero and whose variable-length values are empty.
+func (rp *RecordPageImpl) emptyRecord() (*file.PageImpl, error) {
+	var vars []string
+	for _, fld := range rp.layout.Schema().Fields() {
+		variable, err := rp.isVariable(fld)
+		if err != nil {
+			return nil, err
+		}
+		if variable {
+			vars = append(vars, fld)
+		}
+	}
+	fixed := rp.layout.SlotSize()
+	rec := file.NewPageFromBytes(make([]byte, fixed+len(vars)*file.MaxBytesLength(0)))
+	for i, fld := range vars {
+		rec.SetInt(rp.layout.Offset(fld), int32(fixed+i*file.MaxBytesLength(0)))
+	}
+	return rec, nil
+}
+
+// read returns a copy of the length bytes of the block at pos, which are whole int32 words.
+func (rp *RecordPageImpl) read(pos, length int) (*file.PageImpl, error) {
+	rec := file.NewPageFromBytes(make([]byte, length))
+	for i := 0; i < length; i += int32Bytes {
+		word, err := rp.tx.GetInt(rp.blk, pos+i)
+		if err != nil {
+			return nil, err
+		}
+		rec.SetInt(i, int32(word))
+	}
+	return rec, nil
+}
+
+// write copies the record to the block at pos, logging the int32 words that change.
+func (rp *RecordPageImpl) write(pos int, rec *file.PageImpl) error {
+	for i := 0; i < len(rec.Contents().Bytes()); i += int32Bytes {
+		if err := rp.setWord(pos+i, int(rec.GetInt(i))); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// NextAfter returns the next slot after the given slot.
+// setWord sets the int32 word at pos, logging the change if there is one.
+func (rp *RecordPageImpl) setWord(pos, val int) error {
+	cur, err := rp.tx.GetInt(rp.blk, pos)
+	if err != nil {
+		return err
+	}
+	if cur == val {
+		return nil
+	}
+	return rp.tx.SetInt(rp.blk, pos, val, true)
+}
+
+func (rp *RecordPageImpl) Delete(slot int) error {
+	return rp.setWord(rp.slotPos(slot), int(SLOT_EMPTY))
+}
+
+// Forward empties the slot and makes it point to rid, where its record moved.
+func (rp *RecordPageImpl) Forward(slot int, rid RID) error {
+	return rp.setSlot(slot, SLOT_FORWARDED, rid.BlockNumber(), rid.Slot())
+}
+
+// Forwarded returns where the record of the slot moved to, or nil if the slot holds its record.
+func (rp *RecordPageImpl) Forwarded(slot int) (RID, error) {
+	flag, err := rp.flag(slot)
+	if err != nil || flag != SLOT_FORWARDED {
+		return nil, err
+	}
+	blknum, slotNum, err := rp.record(slot)
+	if err != nil {
+		return nil, err
+	}
+	return NewRID(blknum, slotNum), nil
+}
+
+// MarkMoved marks the record in the slot as one that moved from a forwarded slot.
+func (rp *RecordPageImpl) MarkMoved(slot int) error {
+	return rp.setWord(rp.slotPos(slot), int(SLOT_MOVED))
+}
+
+// Format initializes the page to one with no slots.
+func (rp *RecordPageImpl) Format() error {
+	if err := rp.tx.SetInt(rp.blk, offsetSlotNum, 0, false); err != nil {
+		return err
+	}
+	return rp.tx.SetInt(rp.blk, offsetFreeEnd, rp.tx.BlockSize(), false)
+}
+
+// Clear empties the page, and logs the changes so that they can be undone.
+// It writes only the header of the page, so it works on a page that was laid out for another layout.
+func (rp *RecordPageImpl) Clear() error {
+	if err := rp.setWord(offsetSlotNum, 0); err != nil {
+		return err
+	}
+	return rp.setWord(offsetFreeEnd, rp.tx.BlockSize())
+}
+
+// NextAfter returns the next slot after the given slot that holds a record or is forwarded to one.
 // If no such slot is found, it returns -1.
 func (rp *RecordPageImpl) NextAfter(slot int) int {
-	return rp.searchAfter(slot, SLOT_USED)
+	slotNum, err := rp.slotNum()
+	if err != nil {
+		return SLOT_INIT
+	}
+	for sl := slot + 1; sl < slotNum; sl++ {
+		flag, err := rp.flag(sl)
+		if err != nil {
+			return SLOT_INIT
+		}
+		if flag == SLOT_USED || flag == SLOT_FORWARDED {
+			return sl
+		}
+	}
+	return SLOT_INIT
 }
 
-// InsertAfter inserts a new record after the given slot.
-// If no such slot is found, it returns -1.
+// InsertAfter inserts a new record into an empty slot after the given slot, or into a new slot.
+// If the page has no room for the record, it returns -1.
 func (rp *RecordPageImpl) InsertAfter(slot int) (int, error) {
-	newSlot := rp.searchAfter(slot, SLOT_EMPTY)
-	if newSlot >= 0 {
-		if err := rp.setFlag(newSlot, SLOT_USED); err != nil {
+	rec, err := rp.emptyRecord()
+	if err != nil {
+		return SLOT_INIT, err
+	}
+	length := len(rec.Contents().Bytes())
+	slotNum, err := rp.slotNum()
+	if err != nil {
+		return SLOT_INIT, err
+	}
+	newSlot, extra := slotNum, slotBytes
+	for sl := slot + 1; sl < slotNum; sl++ {
+		flag, err := rp.flag(sl)
+		if err != nil {
 			return SLOT_INIT, err
 		}
+		if flag == SLOT_EMPTY {
+			newSlot, extra = sl, 0
+			break
+		}
+	}
+	room, err := rp.room(SLOT_INIT)
+	if err != nil {
+		return SLOT_INIT, err
+	}
+	if room-extra < length {
+		return SLOT_INIT, nil
+	}
+	pos, err := rp.reserve(length, extra)
+	if err != nil {
+		return SLOT_INIT, err
+	}
+	if newSlot == slotNum {
+		if err := rp.tx.SetInt(rp.blk, offsetSlotNum, slotNum+1, true); err != nil {
+			return SLOT_INIT, err
+		}
+	}
+	if err := rp.write(pos, rec); err != nil {
+		return SLOT_INIT, err
+	}
+	if err := rp.setSlot(newSlot, SLOT_USED, pos, length); err != nil {
+		return SLOT_INIT, err
 	}
 	return newSlot, nil
 }
@@ -115,28 +625,167 @@ func (rp *RecordPageImpl) Block() file.BlockId {
 	return rp.blk
 }
 
-func (rp *RecordPageImpl) setFlag(slot int, flag SlotFlag) error {
-	return rp.tx.SetInt(rp.blk, rp.offset(slot), int(flag), true)
+// Free returns the number of bytes free for new slots and records, once the page is compacted.
+func (rp *RecordPageImpl) Free() (int, error) {
+	return rp.room(SLOT_INIT)
 }
 
-// searchAfter finds the next slot with the given flag.
-// If no such slot is found, it returns -1.
-func (rp *RecordPageImpl) searchAfter(slot int, flag SlotFlag) int {
-	sl := slot + 1
-	for rp.isValidSlot(sl) {
-		val, _ := rp.tx.GetInt(rp.blk, rp.offset(sl))
-		if val == int(flag) {
-			return sl
+// Empty reports whether no slot of the page holds a record or is forwarded.
+func (rp *RecordPageImpl) Empty() (bool, error) {
+	slotNum, err := rp.slotNum()
+	if err != nil {
+		return false, err
+	}
+	for sl := 0; sl < slotNum; sl++ {
+		flag, err := rp.flag(sl)
+		if err != nil || flag != SLOT_EMPTY {
+			return false, err
 		}
-		sl++
 	}
-	return SLOT_INIT
+	return true, nil
+}
+
+// room returns the number of bytes the records could take in addition to the current ones, not counting the record in the slot,
+// once the page is compacted.
+func (rp *RecordPageImpl) room(slot int) (int, error) {
+	slotNum, err := rp.slotNum()
+	if err != nil {
+		return 0, err
+	}
+	used := 0
+	for sl := 0; sl < slotNum; sl++ {
+		if sl == slot {
+			continue
+		}
+		_, length, err := rp.stored(sl)
+		if err != nil {
+			return 0, err
+		}
+		used += length
+	}
+	return rp.tx.BlockSize() - rp.slotPos(slotNum) - used, nil
+}
+
+// reserve takes size bytes from the free space of the page, compacting the page if needed, and returns their position.
+// extra bytes after the slots are kept free for a new slot.
+func (rp *RecordPageImpl) reserve(size, extra int) (int, error) {
+	slotNum, err := rp.slotNum()
+	if err != nil {
+		return 0, err
+	}
+	freeEnd, err := rp.freeEnd()
+	if err != nil {
+		return 0, err
+	}
+	if freeEnd-rp.slotPos(slotNum)-extra < size {
+		if freeEnd, err = rp.compact(); err != nil {
+			return 0, err
+		}
+	}
+	if freeEnd-rp.slotPos(slotNum)-extra < size {
+		return 0, errNoRoom
+	}
+	freeEnd -= size
+	if err := rp.tx.SetInt(rp.blk, offsetFreeEnd, freeEnd, true); err != nil {
+		return 0, err
+	}
+	return freeEnd, nil
+}
+
+// compact moves the records against the end of the block, so that the free space is in one piece, and returns the new free end.
+func (rp *RecordPageImpl) compact() (int, error) {
+	slotNum, err := rp.slotNum()
+	if err != nil {
+		return 0, err
+	}
+	type stored struct{ slot, pos, length int }
+	var recs []stored
+	for sl := 0; sl < slotNum; sl++ {
+		pos, length, err := rp.stored(sl)
+		if err != nil {
+			return 0, err
+		}
+		if length > 0 {
+			recs = append(recs, stored{sl, pos, length})
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].pos > recs[j].pos })
+	end := rp.tx.BlockSize()
+	for _, rec := range recs {
+		newPos := end - rec.length
+		end = newPos
+		if newPos == rec.pos {
+			continue
+		}
+		// the record moves toward the end of the block, so copy its last word first
+		for i := rec.length - int32Bytes; i >= 0; i -= int32Bytes {
+			word, err := rp.tx.GetInt(rp.blk, rec.pos+i)
+			if err != nil {
+				return 0, err
+			}
+			if err := rp.setWord(newPos+i, word); err != nil {
+				return 0, err
+			}
+		}
+		if err := rp.setWord(rp.slotPos(rec.slot)+int32Bytes, newPos); err != nil {
+			return 0, err
+		}
+	}
+	if err := rp.setWord(offsetFreeEnd, end); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
+
+func (rp *RecordPageImpl) slotNum() (int, error) {
+	return rp.tx.GetInt(rp.blk, offsetSlotNum)
+}
+
+func (rp *RecordPageImpl) freeEnd() (int, error) {
+	freeEnd, err := rp.tx.GetInt(rp.blk, offsetFreeEnd)
+	if err != nil {
+		return 0, err
+	}
+	if freeEnd == 0 {
+		return rp.tx.BlockSize(), nil
+	}
+	return freeEnd, nil
+}
+
+func (rp *RecordPageImpl) slotPos(slot int) int {
+	return pageHeaderBytes + slot*slotBytes
+}
+
+func (rp *RecordPageImpl) flag(slot int) (SlotFlag, error) {
+	flag, err := rp.tx.GetInt(rp.blk, rp.slotPos(slot))
+	return SlotFlag(flag), err
+}
+
+// record returns the position and length of the record in the slot, or the block and slot it is forwarded to.
+func (rp *RecordPageImpl) record(slot int) (pos, length int, err error) {
+	if pos, err = rp.tx.GetInt(rp.blk, rp.slotPos(slot)+int32Bytes); err != nil {
+		return 0, 0, err
+	}
+	if length, err = rp.tx.GetInt(rp.blk, rp.slotPos(slot)+2*int32Bytes); err != nil {
+		return 0, 0, err
+	}
+	return pos, length, nil
 }
 
-func (rp *RecordPageImpl) isValidSlot(slot int) bool {
-	return rp.offset(slot+1) <= rp.tx.BlockSize()
+// stored returns the position and length of the record stored in the slot. The length is 0 if the slot stores none.
+func (rp *RecordPageImpl) stored(slot int) (pos, length int, err error) {
+	flag, err := rp.flag(slot)
+	if err != nil || (flag != SLOT_USED && flag != SLOT_MOVED) {
+		return 0, 0, err
+	}
+	return rp.record(slot)
 }
 
-func (rp *RecordPageImpl) offset(slot int) int {
-	return slot * rp.layout.SlotSize()
+func (rp *RecordPageImpl) setSlot(slot int, flag SlotFlag, pos, length int) error {
+	for i, word := range []int{int(flag), pos, length} {
+		if err := rp.setWord(rp.slotPos(slot)+i*int32Bytes, word); err != nil {
+			return err
+		}
+	}
+	return nil
 }
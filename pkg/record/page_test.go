@@ -1,6 +1,7 @@
 package record
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/kj455/simple-db/pkg/buffer"
@@ -76,3 +77,153 @@ func TestRecordPage(t *testing.T) {
 	slot = recPage.NextAfter(SLOT_INIT)
 	assert.Equal(t, SLOT_INIT, slot)
 }
+
+func TestRecordPage_Null(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+	)
+	dir, cleanup := testutil.SetupDir("test_record_page_null")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, testFileName)
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize)})
+	tx, err := transaction.NewTransaction(fm, lm, bm, transaction.NewTxNumberGenerator())
+	assert.NoError(t, err)
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddStringField("B", 4)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	block, err := tx.Append(testFileName)
+	assert.NoError(t, err)
+	recPage, err := NewRecordPage(tx, block, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, recPage.Format())
+	slot, err := recPage.InsertAfter(SLOT_INIT)
+	assert.NoError(t, err)
+	isNull := func(field string) bool {
+		null, err := recPage.IsNull(slot, field)
+		assert.NoError(t, err)
+		return null
+	}
+
+	// a formatted slot holds no NULLs
+	assert.False(t, isNull("A"))
+	assert.False(t, isNull("B"))
+
+	assert.NoError(t, recPage.SetNull(slot, "B"))
+	assert.False(t, isNull("A"))
+	assert.True(t, isNull("B"))
+	assert.NoError(t, recPage.SetNull(slot, "A"))
+	assert.True(t, isNull("A"))
+
+	// setting a value clears the flag of that field only
+	assert.NoError(t, recPage.SetString(slot, "B", "rec"))
+	assert.True(t, isNull("A"))
+	assert.False(t, isNull("B"))
+	assert.NoError(t, tx.Commit())
+}
+
+func TestRecordPage_VariableLength(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+	)
+	dir, cleanup := testutil.SetupDir("test_record_page_variable_length")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, testFileName)
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize)})
+	tx, err := transaction.NewTransaction(fm, lm, bm, transaction.NewTxNumberGenerator())
+	assert.NoError(t, err)
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddStringField("B", 100)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	block, err := tx.Append(testFileName)
+	assert.NoError(t, err)
+	recPage, err := NewRecordPage(tx, block, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, recPage.Format())
+
+	// an empty varchar(100) takes only its length, so that a record takes 16 bytes and its slot 12
+	const recordNum = (blockSize - pageHeaderBytes) / (16 + slotBytes)
+	slot := SLOT_INIT
+	for i := 0; i < recordNum; i++ {
+		slot, err = recPage.InsertAfter(slot)
+		assert.NoError(t, err)
+		assert.Equal(t, i, slot)
+		assert.NoError(t, recPage.SetInt(slot, "A", i))
+	}
+	slot, err = recPage.InsertAfter(slot)
+	assert.NoError(t, err)
+	assert.Equal(t, SLOT_INIT, slot)
+
+	long := strings.Repeat("x", 100)
+	assert.ErrorIs(t, recPage.SetString(0, "B", long), errNoRoom)
+	assert.Error(t, recPage.SetString(0, "B", strings.Repeat(long, 5)))
+	// deleting records leaves room for the grown record once the page is compacted
+	for slot := 1; slot < 10; slot++ {
+		assert.NoError(t, recPage.Delete(slot))
+	}
+	assert.NoError(t, recPage.SetNull(0, "B"))
+	assert.NoError(t, recPage.SetString(0, "B", long))
+	assert.NoError(t, recPage.SetString(recordNum-1, "B", "last"))
+	get := func(slot int) (int, string) {
+		a, err := recPage.GetInt(slot, "A")
+		assert.NoError(t, err)
+		b, err := recPage.GetString(slot, "B")
+		assert.NoError(t, err)
+		return a, b
+	}
+	for slot := recPage.NextAfter(SLOT_INIT); slot >= 0; slot = recPage.NextAfter(slot) {
+		a, b := get(slot)
+		assert.Equal(t, slot, a)
+		switch slot {
+		case 0:
+			assert.Equal(t, long, b)
+		case recordNum - 1:
+			assert.Equal(t, "last", b)
+		default:
+			assert.Empty(t, b)
+		}
+	}
+	null, err := recPage.IsNull(0, "B")
+	assert.NoError(t, err)
+	assert.False(t, null)
+
+	// a record that shrinks stays in place, and an emptied slot is reused
+	assert.NoError(t, recPage.SetString(0, "B", "y"))
+	_, b := get(0)
+	assert.Equal(t, "y", b)
+	slot, err = recPage.InsertAfter(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, slot)
+	assert.NoError(t, tx.Commit())
+}
+
+func TestStoredValue(t *testing.T) {
+	t.Parallel()
+	rec := file.NewPage(32)
+	rec.SetBytes(0, []byte("abc"))
+	rec.SetInt(8, overflowMarker)
+	rec.SetInt(20, -8)
+	rec.SetInt(24, 32)
+
+	v, err := storedValue(rec, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, len(v))
+	v, err = storedValue(rec, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, len(v))
+	_, err = storedValue(rec, 20)
+	assert.ErrorIs(t, err, file.ErrBadLength)
+	_, err = storedValue(rec, 24)
+	assert.ErrorIs(t, err, file.ErrBadLength)
+}
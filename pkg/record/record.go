@@ -1,12 +1,25 @@
 package record
 
-import "github.com/kj455/simple-db/pkg/file"
+import (
+	"time"
+
+	"github.com/kj455/simple-db/pkg/file"
+)
 
 type SchemaType int
 
+// The length of a field is the maximum number of characters for SCHEMA_TYPE_VARCHAR, and of bytes for SCHEMA_TYPE_BLOB.
+// SCHEMA_TYPE_TEXT holds strings of any length, and other types have a fixed size; they ignore it.
 const (
-	SCHEMA_TYPE_INTEGER SchemaType = 1
-	SCHEMA_TYPE_VARCHAR SchemaType = 2
+	SCHEMA_TYPE_INTEGER   SchemaType = 1
+	SCHEMA_TYPE_VARCHAR   SchemaType = 2
+	SCHEMA_TYPE_BIGINT    SchemaType = 3
+	SCHEMA_TYPE_BOOLEAN   SchemaType = 4
+	SCHEMA_TYPE_DOUBLE    SchemaType = 5
+	SCHEMA_TYPE_DATE      SchemaType = 6
+	SCHEMA_TYPE_TIMESTAMP SchemaType = 7
+	SCHEMA_TYPE_BLOB      SchemaType = 8
+	SCHEMA_TYPE_TEXT      SchemaType = 9
 )
 
 // Schema holds a record's schema
@@ -25,6 +38,9 @@ type Schema interface {
 type Layout interface {
 	Schema() Schema
 	Offset(field string) int
+	// NullFlag returns where in a slot the flag telling whether the field is NULL is
+	NullFlag(field string) (offset int, mask int)
+	// SlotSize returns the size of the fixed-length part of a record, which the variable-length values follow
 	SlotSize() int
 }
 
@@ -33,13 +49,43 @@ type RecordPage interface {
 	GetString(slot int, field string) (string, error)
 	SetInt(slot int, field string, val int) error
 	SetString(slot int, field string, val string) error
+	GetInt64(slot int, field string) (int64, error)
+	GetFloat64(slot int, field string) (float64, error)
+	GetBool(slot int, field string) (bool, error)
+	GetTime(slot int, field string) (time.Time, error)
+	GetBytes(slot int, field string) ([]byte, error)
+	SetInt64(slot int, field string, val int64) error
+	SetFloat64(slot int, field string, val float64) error
+	SetBool(slot int, field string, val bool) error
+	SetTime(slot int, field string, val time.Time) error
+	SetBytes(slot int, field string, val []byte) error
+	IsNull(slot int, field string) (bool, error)
+	// SetNull sets the field to NULL. Setting a value to the field makes it non-NULL again
+	SetNull(slot int, field string) error
 	// Format initializes the record page
 	Format() error
+	// Clear empties the record page, logging the changes
+	Clear() error
 	Delete(slot int) error
 	// NextAfter returns the next slot after the given slot
 	NextAfter(slot int) int
 	// InsertAfter inserts a new record after the given slot
 	InsertAfter(slot int) (int, error)
+	// Forward empties the slot and makes it point to rid, where its record moved
+	Forward(slot int, rid RID) error
+	// Forwarded returns where the record of the slot moved to, or nil if the slot holds its record
+	Forwarded(slot int) (RID, error)
+	// MarkMoved marks the record in the slot as one that moved from a forwarded slot, which scans skip
+	MarkMoved(slot int) error
+	// Overflowed returns the first block and the length of the value of the field if it is stored in the overflow file of the table.
+	// first is 0 if the value is in the record
+	Overflowed(slot int, field string) (first, length int, err error)
+	// SetOverflowed makes the variable-length field refer to a value of length bytes stored in the overflow file from the first block
+	SetOverflowed(slot int, field string, first, length int) error
+	// Free returns the number of bytes free for new slots and records, once the page is compacted
+	Free() (int, error)
+	// Empty reports whether no slot of the page is in use
+	Empty() (bool, error)
 	Block() file.BlockId
 }
 
@@ -1,34 +1,51 @@
 package record
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/constant"
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/tx"
 )
 
+/*
+TableScanImpl scans the records of a table, in the order of their slots. The current record is in recordPage, at curSlot,
+unless the slot is forwarded: then dataPage and dataSlot tell where the record moved, and scans skip it there.
+A record moves when a value grows it too large for its page, so that its RID stays the same.
+Variable-length values larger than overflows allows are stored in the overflow file of the table, and read back from it transparently.
+Inserts find a block with room through the free space map of the table, which inserts, deletes and moves keep up to date.
+*/
 type TableScanImpl struct {
 	tx         tx.Transaction
 	layout     Layout
 	recordPage RecordPage
+	dataPage   RecordPage
+	overflow   *overflowFile
+	freeSpace  *freeSpaceMap
 	filename   string
 	curSlot    int
+	dataSlot   int
 }
 
 const TABLE_SUFFIX = ".tbl"
 
 func NewTableScan(tx tx.Transaction, table string, layout Layout) (*TableScanImpl, error) {
 	ts := &TableScanImpl{
-		tx:       tx,
-		layout:   layout,
-		filename: table + TABLE_SUFFIX,
+		tx:        tx,
+		layout:    layout,
+		overflow:  newOverflowFile(tx, table),
+		freeSpace: newFreeSpaceMap(tx, table),
+		filename:  table + TABLE_SUFFIX,
 	}
 	size, err := tx.Size(ts.filename)
 	if err != nil {
 		return nil, fmt.Errorf("record: failed to get size of %s: %w", ts.filename, err)
 	}
-	if size == 0 {
+	if size == 0 && !tx.IsReadOnly() {
 		err = ts.moveToNewBlock()
 	} else {
 		err = ts.moveToBlock(0)
@@ -55,38 +72,80 @@ func (ts *TableScanImpl) Next() bool {
 		}
 		ts.curSlot = ts.recordPage.NextAfter(ts.curSlot)
 	}
-	return true
+	return ts.follow() == nil
 }
 
 func (ts *TableScanImpl) GetInt(field string) (int, error) {
-	return ts.recordPage.GetInt(ts.curSlot, field)
+	return ts.dataPage.GetInt(ts.dataSlot, field)
 }
 
+// GetString returns the string value of the field in the current record, reading it from the overflow file if it is stored there.
 func (ts *TableScanImpl) GetString(field string) (string, error) {
-	return ts.recordPage.GetString(ts.curSlot, field)
+	val, err := ts.getBytes(field)
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+func (ts *TableScanImpl) getBytes(field string) ([]byte, error) {
+	first, length, err := ts.dataPage.Overflowed(ts.dataSlot, field)
+	if err != nil {
+		return nil, err
+	}
+	if first != 0 {
+		return ts.overflow.read(first, length)
+	}
+	return ts.dataPage.GetBytes(ts.dataSlot, field)
 }
 
+// GetVal returns the value of the field in the current record, which is NULL if the field is.
 func (ts *TableScanImpl) GetVal(field string) (*constant.Const, error) {
 	schemaType, err := ts.layout.Schema().Type(field)
 	if err != nil {
 		return nil, fmt.Errorf("record: failed to get type: %w", err)
 	}
+	null, err := ts.IsNull(field)
+	if err != nil {
+		return nil, err
+	}
+	if null {
+		return constant.NewNull(), nil
+	}
+	var kind constant.Kind
+	var v any
 	switch schemaType {
 	case SCHEMA_TYPE_INTEGER:
-		v, err := ts.GetInt(field)
-		if err != nil {
-			return nil, err
-		}
-		return constant.NewConstant(constant.KIND_INT, v)
-	case SCHEMA_TYPE_VARCHAR:
-		v, err := ts.GetString(field)
-		if err != nil {
-			return nil, err
-		}
-		return constant.NewConstant(constant.KIND_STR, v)
+		kind = constant.KIND_INT
+		v, err = ts.GetInt(field)
+	case SCHEMA_TYPE_VARCHAR, SCHEMA_TYPE_TEXT:
+		kind = constant.KIND_STR
+		v, err = ts.GetString(field)
+	case SCHEMA_TYPE_BIGINT:
+		kind = constant.KIND_INT64
+		v, err = ts.dataPage.GetInt64(ts.dataSlot, field)
+	case SCHEMA_TYPE_DOUBLE:
+		kind = constant.KIND_FLOAT64
+		v, err = ts.dataPage.GetFloat64(ts.dataSlot, field)
+	case SCHEMA_TYPE_BOOLEAN:
+		kind = constant.KIND_BOOL
+		v, err = ts.dataPage.GetBool(ts.dataSlot, field)
+	case SCHEMA_TYPE_DATE:
+		kind = constant.KIND_DATE
+		v, err = ts.dataPage.GetTime(ts.dataSlot, field)
+	case SCHEMA_TYPE_TIMESTAMP:
+		kind = constant.KIND_TIMESTAMP
+		v, err = ts.dataPage.GetTime(ts.dataSlot, field)
+	case SCHEMA_TYPE_BLOB:
+		kind = constant.KIND_BYTES
+		v, err = ts.getBytes(field)
 	default:
 		return nil, fmt.Errorf("record: unknown schema type %v", schemaType)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return constant.NewConstant(kind, v)
 }
 
 func (ts *TableScanImpl) HasField(field string) bool {
@@ -94,66 +153,418 @@ func (ts *TableScanImpl) HasField(field string) bool {
 }
 
 func (ts *TableScanImpl) Close() {
+	ts.releaseData()
+	ts.dataPage = nil
 	if ts.recordPage != nil {
 		ts.tx.Unpin(ts.recordPage.Block())
 	}
 }
 
 func (ts *TableScanImpl) SetInt(field string, val int) error {
-	return ts.recordPage.SetInt(ts.curSlot, field, val)
+	return ts.dataPage.SetInt(ts.dataSlot, field, val)
 }
 
+// SetString sets the field of the current record as SetVal does.
 func (ts *TableScanImpl) SetString(field string, val string) error {
-	return ts.recordPage.SetString(ts.curSlot, field, val)
+	cons, err := constant.NewConstant(constant.KIND_STR, val)
+	if err != nil {
+		return err
+	}
+	return ts.SetVal(field, cons)
+}
+
+// IsNull reports whether the field of the current record is NULL.
+func (ts *TableScanImpl) IsNull(field string) (bool, error) {
+	return ts.dataPage.IsNull(ts.dataSlot, field)
 }
 
+// SetNull sets the field of the current record to NULL, freeing its value if it is stored in the overflow file.
+func (ts *TableScanImpl) SetNull(field string) error {
+	if err := ts.freeOverflow(field); err != nil {
+		return err
+	}
+	return ts.dataPage.SetNull(ts.dataSlot, field)
+}
+
+// freeOverflow frees the value of the field of the current record if it is stored in the overflow file, and empties the field.
+func (ts *TableScanImpl) freeOverflow(field string) error {
+	first, _, err := ts.dataPage.Overflowed(ts.dataSlot, field)
+	if err != nil || first == 0 {
+		return err
+	}
+	if err := ts.overflow.free(first); err != nil {
+		return err
+	}
+	return ts.dataPage.SetBytes(ts.dataSlot, field, nil)
+}
+
+/*
+SetVal sets the field of the current record to the value, or to NULL if the value is NULL.
+Besides values of the kind of the field, an INT field takes a bigint in its range, a BIGINT field an int, a DOUBLE one any number,
+and DATE and TIMESTAMP fields either a date or a timestamp, which a DATE field truncates to the day.
+A large variable-length value is stored in the overflow file, and a record that no longer fits in its block is moved to another one.
+*/
 func (ts *TableScanImpl) SetVal(field string, val *constant.Const) error {
-	schemaType, err := ts.layout.Schema().Type(field)
+	sch := ts.layout.Schema()
+	schemaType, err := sch.Type(field)
 	if err != nil {
 		return err
 	}
+	set := func(rp RecordPage, slot int) error {
+		return setVal(rp, slot, sch, field, val)
+	}
+	if isVariable(schemaType) {
+		if err := ts.freeOverflow(field); err != nil {
+			return err
+		}
+		b, err := variableBytes(schemaType, val)
+		if err != nil {
+			return err
+		}
+		if err := checkLength(sch, field, len(b)); err != nil {
+			return err
+		}
+		if !val.IsNull() && overflows(ts.tx.BlockSize(), len(b)) {
+			first, err := ts.overflow.write(b)
+			if err != nil {
+				return err
+			}
+			set = func(rp RecordPage, slot int) error {
+				return rp.SetOverflowed(slot, field, first, len(b))
+			}
+		}
+	}
+	err = set(ts.dataPage, ts.dataSlot)
+	if !errors.Is(err, errNoRoom) {
+		return err
+	}
+	return ts.moveRecord(set)
+}
+
+// variableBytes returns the bytes of the value of a variable-length field, which are none for NULL.
+func variableBytes(schemaType SchemaType, val *constant.Const) ([]byte, error) {
+	if val.IsNull() {
+		return nil, nil
+	}
+	if schemaType == SCHEMA_TYPE_BLOB {
+		b, err := val.AsBytes()
+		if err != nil {
+			return nil, fmt.Errorf("record: failed to convert val to blob: %w", err)
+		}
+		return b, nil
+	}
+	str, err := val.AsString()
+	if err != nil {
+		return nil, fmt.Errorf("record: failed to convert val to string: %w", err)
+	}
+	return []byte(str), nil
+}
+
+// setVal sets the field of the record in the slot of the page as TableScanImpl.SetVal does, but returns errNoRoom if the record does not fit in the page.
+func setVal(rp RecordPage, slot int, sch Schema, field string, val *constant.Const) error {
+	schemaType, err := sch.Type(field)
+	if err != nil {
+		return err
+	}
+	if val.IsNull() {
+		return rp.SetNull(slot, field)
+	}
 	switch schemaType {
 	case SCHEMA_TYPE_INTEGER:
-		val, err := val.AsInt()
+		val, err := val.AsInt64()
 		if err != nil {
 			return fmt.Errorf("record: failed to convert val to int: %w", err)
 		}
-		return ts.SetInt(field, val)
-	case SCHEMA_TYPE_VARCHAR:
+		if val < math.MinInt32 || val > math.MaxInt32 {
+			return fmt.Errorf("record: %d is out of range for int field %s", val, field)
+		}
+		return rp.SetInt(slot, field, int(val))
+	case SCHEMA_TYPE_VARCHAR, SCHEMA_TYPE_TEXT:
 		val, err := val.AsString()
 		if err != nil {
 			return fmt.Errorf("record: failed to convert val to string: %w", err)
 		}
-		return ts.SetString(field, val)
+		return rp.SetString(slot, field, val)
+	case SCHEMA_TYPE_BIGINT:
+		val, err := val.AsInt64()
+		if err != nil {
+			return fmt.Errorf("record: failed to convert val to bigint: %w", err)
+		}
+		return rp.SetInt64(slot, field, val)
+	case SCHEMA_TYPE_DOUBLE:
+		val, err := val.AsFloat64()
+		if err != nil {
+			return fmt.Errorf("record: failed to convert val to double: %w", err)
+		}
+		return rp.SetFloat64(slot, field, val)
+	case SCHEMA_TYPE_BOOLEAN:
+		val, err := val.AsBool()
+		if err != nil {
+			return fmt.Errorf("record: failed to convert val to boolean: %w", err)
+		}
+		return rp.SetBool(slot, field, val)
+	case SCHEMA_TYPE_DATE, SCHEMA_TYPE_TIMESTAMP:
+		t, err := val.AsTime()
+		if err != nil {
+			return fmt.Errorf("record: failed to convert val to time: %w", err)
+		}
+		if schemaType == SCHEMA_TYPE_DATE {
+			y, m, d := t.Date()
+			t = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		}
+		return rp.SetTime(slot, field, t)
+	case SCHEMA_TYPE_BLOB:
+		val, err := val.AsBytes()
+		if err != nil {
+			return fmt.Errorf("record: failed to convert val to blob: %w", err)
+		}
+		return rp.SetBytes(slot, field, val)
 	}
 	return nil
 }
 
+/*
+Insert inserts a new record after the current one, or if the current block has no room, into the first block that has room
+as the free space map tells, appending a new block if none has. The map is only written when a block turns out to be full:
+inserts leave the entry of their block claiming more room than there is, which the next insert that finds the block full corrects.
+*/
 func (ts *TableScanImpl) Insert() error {
-	var err error
-	ts.curSlot, err = ts.recordPage.InsertAfter(ts.curSlot)
-	if err != nil {
+	if err := ts.insert(); err != nil {
 		return fmt.Errorf("record: table scan: insert: %w", err)
 	}
-	for ts.curSlot < 0 {
-		if ts.atLastBlock() {
+	return ts.follow()
+}
+
+func (ts *TableScanImpl) insert() error {
+	slot, err := ts.recordPage.InsertAfter(ts.curSlot)
+	if err != nil {
+		return err
+	}
+	for slot < 0 {
+		if err := ts.freeSpace.update(ts.recordPage); err != nil {
+			return err
+		}
+		size, err := ts.tx.Size(ts.filename)
+		if err != nil {
+			return err
+		}
+		blknum, err := ts.freeSpace.find(insertBytes(ts.layout), 0, size)
+		if err != nil {
+			return err
+		}
+		newBlock := blknum < 0
+		if newBlock {
 			err = ts.moveToNewBlock()
 		} else {
-			err = ts.moveToBlock(ts.recordPage.Block().Number() + 1)
+			err = ts.moveToBlock(blknum)
 		}
 		if err != nil {
-			return fmt.Errorf("record: table scan: insert: %w", err)
+			return err
 		}
-		ts.curSlot, err = ts.recordPage.InsertAfter(ts.curSlot)
-		if err != nil {
-			return fmt.Errorf("record: table scan: insert: %w", err)
+		if slot, err = ts.recordPage.InsertAfter(SLOT_INIT); err != nil {
+			return err
+		}
+		if slot < 0 && newBlock {
+			return fmt.Errorf("a record of %s does not fit in a block of %d bytes", ts.filename, ts.tx.BlockSize())
 		}
 	}
+	ts.curSlot = slot
 	return nil
 }
 
+// Clear empties every block of the table and of its overflow file, and moves before the first record.
+// It is how a table is rewritten for a new layout: the changes are logged, so rolling back restores the old records.
+func (ts *TableScanImpl) Clear() error {
+	if err := ts.overflow.clear(); err != nil {
+		return fmt.Errorf("record: table scan: clear: %w", err)
+	}
+	size, err := ts.tx.Size(ts.filename)
+	if err != nil {
+		return fmt.Errorf("record: table scan: clear: %w", err)
+	}
+	for blkNum := 0; blkNum < size; blkNum++ {
+		if err := ts.moveToBlock(blkNum); err != nil {
+			return fmt.Errorf("record: table scan: clear: %w", err)
+		}
+		if err := ts.recordPage.Clear(); err != nil {
+			return fmt.Errorf("record: table scan: clear: %w", err)
+		}
+		if err := ts.freeSpace.update(ts.recordPage); err != nil {
+			return fmt.Errorf("record: table scan: clear: %w", err)
+		}
+	}
+	return ts.moveToBlock(0)
+}
+
+// Shrink removes the empty blocks at the end of the table from its file when the transaction commits, keeping at least one block,
+// and returns how many there are. It moves before the first record.
+func (ts *TableScanImpl) Shrink() (int, error) {
+	size, err := ts.tx.Size(ts.filename)
+	if err != nil {
+		return 0, fmt.Errorf("record: table scan: shrink: %w", err)
+	}
+	blocks := size
+	for ; blocks > 1; blocks-- {
+		if err := ts.moveToBlock(blocks - 1); err != nil {
+			return 0, fmt.Errorf("record: table scan: shrink: %w", err)
+		}
+		empty, err := ts.recordPage.Empty()
+		if err != nil {
+			return 0, fmt.Errorf("record: table scan: shrink: %w", err)
+		}
+		if !empty {
+			break
+		}
+	}
+	if blocks < size {
+		ts.tx.TruncateFileOnCommit(ts.filename, blocks)
+	}
+	return size - blocks, ts.moveToBlock(0)
+}
+
+/*
+Compact moves the records of the last blocks of the table into the free space of the blocks before them, from the last block on,
+until a record has no room before its block, so that Shrink can then remove the blocks left empty. It moves before the first record.
+The records are moved one at a time with logged changes, and their values in the overflow file stay there.
+A record whose slot is in a block being emptied gets a slot of its own in the block it moves to, and thus a new RID;
+one that moved there from a forwarded slot before the block moves again, and the slot is forwarded to its new place.
+The free space map is corrected first, as the entries of rolled back inserts claim too little room.
+*/
+func (ts *TableScanImpl) Compact() error {
+	size, err := ts.tx.Size(ts.filename)
+	if err != nil {
+		return fmt.Errorf("record: table scan: compact: %w", err)
+	}
+	for blknum := 0; blknum < size; blknum++ {
+		if err := ts.moveToBlock(blknum); err != nil {
+			return fmt.Errorf("record: table scan: compact: %w", err)
+		}
+		if err := ts.freeSpace.update(ts.recordPage); err != nil {
+			return fmt.Errorf("record: table scan: compact: %w", err)
+		}
+	}
+	homes, err := ts.forwardedSlots()
+	if err != nil {
+		return fmt.Errorf("record: table scan: compact: %w", err)
+	}
+	for blknum := size - 1; blknum > 0; blknum-- {
+		emptied, err := ts.emptyBlock(blknum, homes)
+		if err != nil {
+			return fmt.Errorf("record: table scan: compact: %w", err)
+		}
+		if !emptied {
+			break
+		}
+	}
+	return ts.moveToBlock(0)
+}
+
+// forwardedSlots returns the forwarded slots of the table by the place of the moved record each is forwarded to.
+func (ts *TableScanImpl) forwardedSlots() (map[RIDImpl]RIDImpl, error) {
+	homes := make(map[RIDImpl]RIDImpl)
+	if err := ts.BeforeFirst(); err != nil {
+		return nil, err
+	}
+	for ts.Next() {
+		if ts.dataPage != ts.recordPage {
+			homes[RIDImpl{blknum: ts.dataPage.Block().Number(), slot: ts.dataSlot}] = RIDImpl{blknum: ts.recordPage.Block().Number(), slot: ts.curSlot}
+		}
+	}
+	return homes, nil
+}
+
+// emptyBlock moves the records of the block into the blocks before it, and reports whether they all had room there.
+// homes holds the forwarded slots of the table by the place of their record, as forwardedSlots returns, and is kept up to date.
+func (ts *TableScanImpl) emptyBlock(blknum int, homes map[RIDImpl]RIDImpl) (bool, error) {
+	if err := ts.moveToBlock(blknum); err != nil {
+		return false, err
+	}
+	for ts.curSlot = ts.recordPage.NextAfter(SLOT_INIT); ts.curSlot >= 0; ts.curSlot = ts.recordPage.NextAfter(ts.curSlot) {
+		if err := ts.follow(); err != nil {
+			return false, err
+		}
+		data := RIDImpl{blknum: ts.dataPage.Block().Number(), slot: ts.dataSlot}
+		rehomed, err := ts.rehome(blknum)
+		if err != nil || !rehomed {
+			return false, err
+		}
+		delete(homes, data)
+	}
+	// the records left are those that moved to the block from forwarded slots before it
+	var moved []RIDImpl
+	for data := range homes {
+		if data.blknum == blknum {
+			moved = append(moved, data)
+		}
+	}
+	slices.SortFunc(moved, func(a, b RIDImpl) int { return a.slot - b.slot })
+	for _, data := range moved {
+		home := homes[data]
+		if err := ts.MoveToRID(&home); err != nil {
+			return false, err
+		}
+		page, slot, err := ts.place(blknum, true, nil)
+		if err != nil || slot < 0 {
+			return false, err
+		}
+		if err := ts.forward(page, slot); err != nil {
+			return false, err
+		}
+		delete(homes, data)
+		homes[RIDImpl{blknum: page.Block().Number(), slot: slot}] = home
+	}
+	return true, nil
+}
+
+// rehome moves the current record to a slot of its own in a block before limit, freeing its slot and the moved record it was forwarded to,
+// and reports whether a block had room for it.
+func (ts *TableScanImpl) rehome(limit int) (bool, error) {
+	page, slot, err := ts.place(limit, false, nil)
+	if err != nil || slot < 0 {
+		return false, err
+	}
+	ts.tx.Unpin(page.Block())
+	if ts.dataPage != ts.recordPage {
+		if err := ts.dataPage.Delete(ts.dataSlot); err != nil {
+			return false, err
+		}
+		if err := ts.freeSpace.update(ts.dataPage); err != nil {
+			return false, err
+		}
+	}
+	if err := ts.recordPage.Delete(ts.curSlot); err != nil {
+		return false, err
+	}
+	ts.releaseData()
+	return true, ts.freeSpace.update(ts.recordPage)
+}
+
+// Delete deletes the current record, the values it stores in the overflow file, and the slot that is forwarded to it if it moved.
 func (ts *TableScanImpl) Delete() error {
-	return ts.recordPage.Delete(ts.curSlot)
+	for _, fld := range ts.layout.Schema().Fields() {
+		first, _, err := ts.dataPage.Overflowed(ts.dataSlot, fld)
+		if err != nil {
+			return err
+		}
+		if first == 0 {
+			continue
+		}
+		if err := ts.overflow.free(first); err != nil {
+			return err
+		}
+	}
+	if ts.dataPage != ts.recordPage {
+		if err := ts.dataPage.Delete(ts.dataSlot); err != nil {
+			return err
+		}
+		if err := ts.freeSpace.update(ts.dataPage); err != nil {
+			return err
+		}
+	}
+	if err := ts.recordPage.Delete(ts.curSlot); err != nil {
+		return err
+	}
+	return ts.freeSpace.update(ts.recordPage)
 }
 
 func (ts *TableScanImpl) MoveToRID(rid RID) (err error) {
@@ -164,13 +575,177 @@ func (ts *TableScanImpl) MoveToRID(rid RID) (err error) {
 		return fmt.Errorf("record: failed to move to rid: %w", err)
 	}
 	ts.curSlot = rid.Slot()
+	if err := ts.follow(); err != nil {
+		return fmt.Errorf("record: failed to move to rid: %w", err)
+	}
 	return nil
 }
 
+// GetRID returns the RID of the current record, which is that of its slot even if the record moved to another block.
 func (ts *TableScanImpl) GetRID() RID {
 	return NewRID(ts.recordPage.Block().Number(), ts.curSlot)
 }
 
+// follow makes the record of the current slot the current record, following the slot to the block the record moved to if it is forwarded.
+func (ts *TableScanImpl) follow() error {
+	ts.releaseData()
+	if ts.curSlot < 0 {
+		return nil
+	}
+	rid, err := ts.recordPage.Forwarded(ts.curSlot)
+	if err != nil || rid == nil {
+		return err
+	}
+	page, err := NewRecordPage(ts.tx, file.NewBlockId(ts.filename, rid.BlockNumber()), ts.layout)
+	if err != nil {
+		return err
+	}
+	ts.dataPage, ts.dataSlot = page, rid.Slot()
+	return nil
+}
+
+// releaseData unpins the block the current record moved to, if it did, and makes the current slot hold the current record again.
+func (ts *TableScanImpl) releaseData() {
+	if ts.dataPage != nil && ts.dataPage != ts.recordPage {
+		ts.tx.Unpin(ts.dataPage.Block())
+	}
+	ts.dataPage, ts.dataSlot = ts.recordPage, ts.curSlot
+}
+
+/*
+moveRecord moves the current record to another block, applying set to it there, and forwards the slot of the record to it.
+The record goes to the first other block that has room for it, as the free space map tells, or else to a new block.
+Its old place is freed: the record of the slot, or the moved record the slot was forwarded to.
+*/
+func (ts *TableScanImpl) moveRecord(set func(rp RecordPage, slot int) error) error {
+	size, err := ts.tx.Size(ts.filename)
+	if err != nil {
+		return err
+	}
+	page, slot, err := ts.place(size, true, set)
+	if err != nil {
+		return err
+	}
+	if slot < 0 {
+		blk, err := ts.tx.Append(ts.filename)
+		if err != nil {
+			return err
+		}
+		if page, slot, err = ts.insertCopy(blk, true, set); err != nil {
+			return err
+		}
+		if slot < 0 {
+			return fmt.Errorf("record: the record of %s does not fit in a block of %d bytes", ts.filename, ts.tx.BlockSize())
+		}
+	}
+	return ts.forward(page, slot)
+}
+
+// place inserts a copy of the current record, as insertCopy does, into the first block before limit that has room for it
+// as the free space map tells, other than the blocks of the current record. The slot is -1 if no block has room.
+func (ts *TableScanImpl) place(limit int, moved bool, set func(rp RecordPage, slot int) error) (RecordPage, int, error) {
+	for from := 0; ; {
+		blknum, err := ts.freeSpace.find(insertBytes(ts.layout), from, limit)
+		if err != nil || blknum < 0 {
+			return nil, SLOT_INIT, err
+		}
+		from = blknum + 1
+		if blknum == ts.recordPage.Block().Number() || blknum == ts.dataPage.Block().Number() {
+			continue
+		}
+		page, slot, err := ts.insertCopy(file.NewBlockId(ts.filename, blknum), moved, set)
+		if err != nil || slot >= 0 {
+			return page, slot, err
+		}
+	}
+}
+
+// forward makes the slot of the page, where a copy of the current record was inserted, the place of the record:
+// it frees the old place and forwards the slot of the record to the new one.
+func (ts *TableScanImpl) forward(page RecordPage, slot int) error {
+	if ts.dataPage != ts.recordPage {
+		if err := ts.dataPage.Delete(ts.dataSlot); err != nil {
+			return err
+		}
+		if err := ts.freeSpace.update(ts.dataPage); err != nil {
+			return err
+		}
+	}
+	if err := ts.recordPage.Forward(ts.curSlot, NewRID(page.Block().Number(), slot)); err != nil {
+		return err
+	}
+	if err := ts.freeSpace.update(ts.recordPage); err != nil {
+		return err
+	}
+	ts.releaseData()
+	ts.dataPage, ts.dataSlot = page, slot
+	return nil
+}
+
+// insertCopy inserts a copy of the current record into the block, as a moved record if moved is true, applies set to it if set is not nil,
+// and returns its page and slot. The slot is -1 if the block has no room for it, and the free space of the block is then recorded.
+func (ts *TableScanImpl) insertCopy(blk file.BlockId, moved bool, set func(rp RecordPage, slot int) error) (RecordPage, int, error) {
+	page, err := NewRecordPage(ts.tx, blk, ts.layout)
+	if err != nil {
+		return nil, SLOT_INIT, err
+	}
+	slot, err := page.InsertAfter(SLOT_INIT)
+	if err == nil && slot >= 0 {
+		if moved {
+			err = page.MarkMoved(slot)
+		}
+		for _, fld := range ts.layout.Schema().Fields() {
+			if err != nil {
+				break
+			}
+			err = ts.copyField(page, slot, fld)
+		}
+		if err == nil && set != nil {
+			err = set(page, slot)
+		}
+		if errors.Is(err, errNoRoom) {
+			err = page.Delete(slot)
+			slot = SLOT_INIT
+		}
+	}
+	if err == nil && slot >= 0 {
+		return page, slot, nil
+	}
+	var free int
+	if err == nil {
+		free, err = page.Free()
+	}
+	// the block is unpinned first, so that the map does not take one more buffer
+	ts.tx.Unpin(blk)
+	if err == nil {
+		err = ts.freeSpace.set(blk.Number(), free)
+	}
+	return nil, SLOT_INIT, err
+}
+
+// copyField copies the field of the current record to the record in the slot of the page. A value in the overflow file stays there.
+func (ts *TableScanImpl) copyField(rp RecordPage, slot int, field string) error {
+	null, err := ts.IsNull(field)
+	if err != nil {
+		return err
+	}
+	if null {
+		return rp.SetNull(slot, field)
+	}
+	first, length, err := ts.dataPage.Overflowed(ts.dataSlot, field)
+	if err != nil {
+		return err
+	}
+	if first != 0 {
+		return rp.SetOverflowed(slot, field, first, length)
+	}
+	val, err := ts.GetVal(field)
+	if err != nil {
+		return err
+	}
+	return setVal(rp, slot, ts.layout.Schema(), field, val)
+}
+
 func (ts *TableScanImpl) moveToBlock(blknum int) (err error) {
 	ts.Close()
 	blk := file.NewBlockId(ts.filename, blknum)
@@ -179,6 +754,7 @@ func (ts *TableScanImpl) moveToBlock(blknum int) (err error) {
 		return err
 	}
 	ts.curSlot = SLOT_INIT
+	ts.dataPage, ts.dataSlot = ts.recordPage, ts.curSlot
 	return nil
 }
 
@@ -196,10 +772,11 @@ func (ts *TableScanImpl) moveToNewBlock() error {
 		return err
 	}
 	ts.curSlot = SLOT_INIT
+	ts.dataPage, ts.dataSlot = ts.recordPage, ts.curSlot
 	return nil
 }
 
 func (ts *TableScanImpl) atLastBlock() bool {
 	size, _ := ts.tx.Size(ts.filename)
-	return ts.recordPage.Block().Number() == size-1
+	return ts.recordPage.Block().Number() >= size-1
 }
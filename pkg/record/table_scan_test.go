@@ -1,10 +1,15 @@
 package record
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/buffer"
+	"github.com/kj455/simple-db/pkg/constant"
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/kj455/simple-db/pkg/log"
 	"github.com/kj455/simple-db/pkg/testutil"
@@ -73,3 +78,933 @@ func TestTableScan(t *testing.T) {
 	}
 	assert.Equal(t, 10, count)
 }
+
+func TestTableScan_Phantom(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		logFileName = "log"
+		table       = "phantom"
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_phantom")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 4)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	lockTable := tx.NewLock(tx.WithWaitTime(10 * time.Millisecond))
+	newTx := func(level tx.IsolationLevel) tx.Transaction {
+		txn, err := tx.NewTransaction(fm, lm, bm, txNumGen, tx.WithTxLockTable(lockTable), tx.WithIsolationLevel(level))
+		assert.NoError(t, err)
+		return txn
+	}
+	sch := NewSchema()
+	sch.AddIntField("A")
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	slotsPerBlock := (blockSize - pageHeaderBytes) / (slotBytes + layout.SlotSize())
+
+	insert := func(txn tx.Transaction, val int) error {
+		scan, err := NewTableScan(txn, table, layout)
+		if err != nil {
+			return err
+		}
+		defer scan.Close()
+		if err := scan.Insert(); err != nil {
+			return err
+		}
+		return scan.SetInt("A", val)
+	}
+	count := func(txn tx.Transaction) int {
+		scan, err := NewTableScan(txn, table, layout)
+		assert.NoError(t, err)
+		defer scan.Close()
+		n := 0
+		for scan.Next() {
+			n++
+		}
+		return n
+	}
+
+	// leave a single free slot in the first block
+	setup := newTx(tx.ISOLATION_LEVEL_SERIALIZABLE)
+	for i := 0; i < slotsPerBlock-1; i++ {
+		assert.NoError(t, insert(setup, i))
+	}
+	assert.NoError(t, setup.Commit())
+
+	t.Run("serializable scan blocks inserts into a free slot", func(t *testing.T) {
+		reader := newTx(tx.ISOLATION_LEVEL_SERIALIZABLE)
+		assert.Equal(t, slotsPerBlock-1, count(reader))
+
+		writer := newTx(tx.ISOLATION_LEVEL_SERIALIZABLE)
+		assert.Error(t, insert(writer, 100))
+		assert.NoError(t, writer.Rollback())
+
+		assert.Equal(t, slotsPerBlock-1, count(reader))
+		assert.NoError(t, reader.Commit())
+	})
+
+	// fill the first block so that the next insert appends a new block
+	filler := newTx(tx.ISOLATION_LEVEL_SERIALIZABLE)
+	assert.NoError(t, insert(filler, slotsPerBlock))
+	assert.NoError(t, filler.Commit())
+
+	t.Run("serializable scan blocks inserts into a new block", func(t *testing.T) {
+		reader := newTx(tx.ISOLATION_LEVEL_SERIALIZABLE)
+		assert.Equal(t, slotsPerBlock, count(reader))
+
+		writer := newTx(tx.ISOLATION_LEVEL_SERIALIZABLE)
+		assert.Error(t, insert(writer, 100))
+		assert.NoError(t, writer.Rollback())
+
+		assert.Equal(t, slotsPerBlock, count(reader))
+		assert.NoError(t, reader.Commit())
+	})
+
+	t.Run("repeatable read scan sees phantoms in a new block", func(t *testing.T) {
+		reader := newTx(tx.ISOLATION_LEVEL_REPEATABLE_READ)
+		before := count(reader)
+
+		writer := newTx(tx.ISOLATION_LEVEL_SERIALIZABLE)
+		assert.NoError(t, insert(writer, 100))
+		assert.NoError(t, writer.Commit())
+
+		assert.Equal(t, before+1, count(reader))
+		assert.NoError(t, reader.Commit())
+	})
+}
+
+func TestTableScan_Clear(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+		logFileName  = "log"
+		recordNum    = 30
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_clear")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize), buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := tx.NewTxNumberGenerator()
+	newLayout := func(strLen int) Layout {
+		sch := NewSchema()
+		sch.AddIntField("A")
+		sch.AddStringField("B", strLen)
+		layout, err := NewLayoutFromSchema(sch)
+		assert.NoError(t, err)
+		return layout
+	}
+	narrow, wide := newLayout(4), newLayout(20)
+	count := func(txn tx.Transaction, layout Layout) int {
+		scan, err := NewTableScan(txn, testFileName, layout)
+		assert.NoError(t, err)
+		defer scan.Close()
+		n := 0
+		for scan.Next() {
+			n++
+		}
+		return n
+	}
+
+	writer, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err := NewTableScan(writer, testFileName, narrow)
+	assert.NoError(t, err)
+	for i := 0; i < recordNum; i++ {
+		assert.NoError(t, scan.Insert())
+		assert.NoError(t, scan.SetInt("A", i))
+		assert.NoError(t, scan.SetString("B", fmt.Sprintf("r%d", i)))
+	}
+	scan.Close()
+	assert.NoError(t, writer.Commit())
+
+	// the blocks were written with another layout, which the cleared table no longer shows
+	clearer, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(clearer, testFileName, wide)
+	assert.NoError(t, err)
+	assert.NoError(t, scan.Clear())
+	assert.False(t, scan.Next())
+	assert.NoError(t, scan.Insert())
+	assert.NoError(t, scan.SetString("B", "wide record"))
+	scan.Close()
+	assert.Equal(t, 1, count(clearer, wide))
+	assert.NoError(t, clearer.Rollback())
+
+	reader, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(reader, testFileName, narrow)
+	assert.NoError(t, err)
+	for i := 0; i < recordNum; i++ {
+		assert.True(t, scan.Next())
+		b, err := scan.GetString("B")
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("r%d", i), b)
+	}
+	assert.False(t, scan.Next())
+	scan.Close()
+	assert.NoError(t, reader.Commit())
+}
+
+func TestTableScan_Types(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_types")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, testFileName)
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize), buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := tx.NewTxNumberGenerator()
+	txn, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+
+	sch := NewSchema()
+	sch.AddField("big", SCHEMA_TYPE_BIGINT, 0)
+	sch.AddField("ok", SCHEMA_TYPE_BOOLEAN, 0)
+	sch.AddField("ratio", SCHEMA_TYPE_DOUBLE, 0)
+	sch.AddField("day", SCHEMA_TYPE_DATE, 0)
+	sch.AddField("at", SCHEMA_TYPE_TIMESTAMP, 0)
+	sch.AddField("data", SCHEMA_TYPE_BLOB, 3)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	// the fixed-length part holds the null bitmap, 4 fields of 8 bytes, a padded boolean and the position of the blob
+	assert.Equal(t, 4+8*4+4+4, layout.SlotSize())
+
+	mustConst := func(kind constant.Kind, val any) *constant.Const {
+		c, err := constant.NewConstant(kind, val)
+		assert.NoError(t, err)
+		return c
+	}
+	at := time.Date(2024, 2, 29, 13, 4, 5, 123456000, time.UTC)
+	scan, err := NewTableScan(txn, testFileName, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, scan.Insert())
+	// the fields take values of narrower kinds too
+	assert.NoError(t, scan.SetVal("big", mustConst(constant.KIND_INT, 7)))
+	assert.NoError(t, scan.SetVal("ok", mustConst(constant.KIND_BOOL, true)))
+	assert.NoError(t, scan.SetVal("ratio", mustConst(constant.KIND_INT, 2)))
+	assert.NoError(t, scan.SetVal("day", mustConst(constant.KIND_TIMESTAMP, at)))
+	assert.NoError(t, scan.SetVal("at", mustConst(constant.KIND_TIMESTAMP, at)))
+	assert.NoError(t, scan.SetVal("data", mustConst(constant.KIND_BYTES, []byte{1, 2, 3})))
+	assert.Error(t, scan.SetVal("data", mustConst(constant.KIND_BYTES, []byte{1, 2, 3, 4})))
+	assert.Error(t, scan.SetVal("ok", mustConst(constant.KIND_INT, 1)))
+	assert.NoError(t, scan.Insert())
+	assert.NoError(t, scan.SetVal("big", mustConst(constant.KIND_INT64, int64(-1)<<40)))
+	assert.NoError(t, scan.SetVal("ratio", mustConst(constant.KIND_FLOAT64, 0.5)))
+	assert.NoError(t, scan.SetNull("data"))
+	scan.Close()
+	assert.NoError(t, txn.Commit())
+
+	txn, err = tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(txn, testFileName, layout)
+	assert.NoError(t, err)
+	want := []map[string]*constant.Const{
+		{
+			"big":   mustConst(constant.KIND_INT64, int64(7)),
+			"ok":    mustConst(constant.KIND_BOOL, true),
+			"ratio": mustConst(constant.KIND_FLOAT64, 2.0),
+			"day":   mustConst(constant.KIND_DATE, at),
+			"at":    mustConst(constant.KIND_TIMESTAMP, at),
+			"data":  mustConst(constant.KIND_BYTES, []byte{1, 2, 3}),
+		},
+		{
+			"big":   mustConst(constant.KIND_INT64, int64(-1)<<40),
+			"ok":    mustConst(constant.KIND_BOOL, false),
+			"ratio": mustConst(constant.KIND_FLOAT64, 0.5),
+			"day":   mustConst(constant.KIND_DATE, time.Unix(0, 0)),
+			"at":    mustConst(constant.KIND_TIMESTAMP, time.Unix(0, 0)),
+			"data":  constant.NewNull(),
+		},
+	}
+	for _, row := range want {
+		assert.True(t, scan.Next())
+		for _, fld := range sch.Fields() {
+			val, err := scan.GetVal(fld)
+			assert.NoError(t, err)
+			assert.Equal(t, row[fld].Kind(), val.Kind(), fld)
+			assert.True(t, row[fld].Equals(val), "%s: %s != %s", fld, row[fld].ToString(), val.ToString())
+		}
+	}
+	assert.False(t, scan.Next())
+	scan.Close()
+	assert.NoError(t, txn.Commit())
+}
+
+func TestTableScan_Forward(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+		logFileName  = "log"
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_forward")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 3)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddStringField("B", 100)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	// read returns the values of B by A, checking that each record is scanned once
+	read := func(txn tx.Transaction) map[int]string {
+		scan, err := NewTableScan(txn, testFileName, layout)
+		assert.NoError(t, err)
+		defer scan.Close()
+		vals := make(map[int]string)
+		for scan.Next() {
+			a, err := scan.GetInt("A")
+			assert.NoError(t, err)
+			b, err := scan.GetString("B")
+			assert.NoError(t, err)
+			assert.NotContains(t, vals, a)
+			vals[a] = b
+		}
+		return vals
+	}
+
+	// fill the first block with records of empty strings
+	const recordNum = (blockSize - pageHeaderBytes) / (16 + slotBytes)
+	writer, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err := NewTableScan(writer, testFileName, layout)
+	assert.NoError(t, err)
+	for i := 0; i < recordNum; i++ {
+		assert.NoError(t, scan.Insert())
+		assert.NoError(t, scan.SetInt("A", i))
+	}
+	scan.Close()
+	assert.NoError(t, writer.Commit())
+
+	updater, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(updater, testFileName, layout)
+	assert.NoError(t, err)
+	var rid RID
+	for scan.Next() {
+		a, err := scan.GetInt("A")
+		assert.NoError(t, err)
+		if a == 3 {
+			rid = scan.GetRID()
+			break
+		}
+	}
+	// the grown record moves to a new block, and keeps its RID
+	long := strings.Repeat("x", 90)
+	assert.NoError(t, scan.SetString("B", long))
+	assert.True(t, rid.Equals(scan.GetRID()))
+	size, err := updater.Size(testFileName + TABLE_SUFFIX)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+	assert.NoError(t, scan.MoveToRID(rid))
+	b, err := scan.GetString("B")
+	assert.NoError(t, err)
+	assert.Equal(t, long, b)
+	assert.NoError(t, scan.SetInt("A", 30))
+	scan.Close()
+	vals := read(updater)
+	assert.Len(t, vals, recordNum)
+	assert.Equal(t, long, vals[30])
+
+	// deleting the record frees its slot and the moved record
+	scan, err = NewTableScan(updater, testFileName, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, scan.MoveToRID(rid))
+	assert.NoError(t, scan.Delete())
+	scan.Close()
+	vals = read(updater)
+	assert.Len(t, vals, recordNum-1)
+	assert.NotContains(t, vals, 30)
+	assert.NoError(t, updater.Rollback())
+
+	reader, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	vals = read(reader)
+	assert.Len(t, vals, recordNum)
+	assert.Equal(t, "", vals[3])
+	assert.NoError(t, reader.Commit())
+}
+
+func TestTableScan_Overflow(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+		logFileName  = "log"
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_overflow")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 4)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddField("B", SCHEMA_TYPE_TEXT, 0)
+	sch.AddField("C", SCHEMA_TYPE_BLOB, 1000)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	text := strings.Repeat("abcdefghij", 100)
+	blob := []byte(strings.Repeat("\x00\xff", 300))
+	overflowSize := func(txn tx.Transaction) int {
+		size, err := txn.Size(testFileName + OVERFLOW_SUFFIX)
+		assert.NoError(t, err)
+		return size
+	}
+
+	writer, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err := NewTableScan(writer, testFileName, layout)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, scan.Insert())
+		assert.NoError(t, scan.SetInt("A", i))
+		assert.NoError(t, scan.SetString("B", text))
+		val, err := constant.NewConstant(constant.KIND_BYTES, blob)
+		assert.NoError(t, err)
+		assert.NoError(t, scan.SetVal("C", val))
+	}
+	scan.Close()
+	assert.NoError(t, writer.Commit())
+
+	// the values are reassembled from their chunks
+	updater, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	// a header, 5 chunks for each text and 3 for each blob
+	assert.Equal(t, 17, overflowSize(updater))
+	scan, err = NewTableScan(updater, testFileName, layout)
+	assert.NoError(t, err)
+	assert.True(t, scan.Next())
+	b, err := scan.GetString("B")
+	assert.NoError(t, err)
+	assert.Equal(t, text, b)
+	c, err := scan.GetVal("C")
+	assert.NoError(t, err)
+	assert.Equal(t, blob, c.AnyValue())
+
+	// the chunks freed by deleting a record and shortening a value are not reused before the transaction commits
+	assert.NoError(t, scan.Delete())
+	assert.True(t, scan.Next())
+	assert.NoError(t, scan.SetString("B", "short"))
+	assert.NoError(t, scan.Insert())
+	assert.NoError(t, scan.SetInt("A", 2))
+	assert.NoError(t, scan.SetString("B", text+text))
+	assert.Equal(t, 27, overflowSize(updater))
+	scan.Close()
+	scan, err = NewTableScan(updater, testFileName, layout)
+	assert.NoError(t, err)
+	vals := make(map[int]string)
+	for scan.Next() {
+		a, err := scan.GetInt("A")
+		assert.NoError(t, err)
+		vals[a], err = scan.GetString("B")
+		assert.NoError(t, err)
+	}
+	scan.Close()
+	assert.Equal(t, map[int]string{1: "short", 2: text + text}, vals)
+	assert.NoError(t, updater.Rollback())
+
+	// rolling back restores the values and their chunks
+	reader, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(reader, testFileName, layout)
+	assert.NoError(t, err)
+	count := 0
+	for scan.Next() {
+		b, err := scan.GetString("B")
+		assert.NoError(t, err)
+		assert.Equal(t, text, b)
+		c, err := scan.GetVal("C")
+		assert.NoError(t, err)
+		assert.Equal(t, blob, c.AnyValue())
+		count++
+	}
+	scan.Close()
+	assert.Equal(t, 2, count)
+	assert.NoError(t, reader.Commit())
+
+	// once committed, the freed chunks hold the new values
+	deleter, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(deleter, testFileName, layout)
+	assert.NoError(t, err)
+	assert.True(t, scan.Next())
+	assert.NoError(t, scan.Delete())
+	assert.True(t, scan.Next())
+	assert.NoError(t, scan.SetString("B", "short"))
+	scan.Close()
+	assert.NoError(t, deleter.Commit())
+	inserter, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(inserter, testFileName, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, scan.Insert())
+	assert.NoError(t, scan.SetInt("A", 2))
+	assert.NoError(t, scan.SetString("B", text+text))
+	scan.Close()
+	assert.Equal(t, 27, overflowSize(inserter))
+	scan, err = NewTableScan(inserter, testFileName, layout)
+	assert.NoError(t, err)
+	vals = make(map[int]string)
+	for scan.Next() {
+		a, err := scan.GetInt("A")
+		assert.NoError(t, err)
+		vals[a], err = scan.GetString("B")
+		assert.NoError(t, err)
+	}
+	scan.Close()
+	assert.Equal(t, map[int]string{1: "short", 2: text + text}, vals)
+	assert.NoError(t, inserter.Commit())
+}
+
+func TestTableScan_Concurrent(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+		logFileName  = "log"
+		workers      = 3
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_concurrent")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 4*workers)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	lockTable := tx.NewLock(tx.WithWaitTime(time.Second))
+	newTx := func() tx.Transaction {
+		txn, err := tx.NewTransaction(fm, lm, bm, txNumGen, tx.WithTxLockTable(lockTable))
+		assert.NoError(t, err)
+		return txn
+	}
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddField("B", SCHEMA_TYPE_TEXT, 0)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	text := strings.Repeat("abcdefghij", 100)
+	overflowSize := func() int {
+		size, err := fm.BlockNum(testFileName + OVERFLOW_SUFFIX)
+		assert.NoError(t, err)
+		return size
+	}
+
+	// fill a block for each worker, with values that take as much room as a reference to the overflow file
+	writer := newTx()
+	scan, err := NewTableScan(writer, testFileName, layout)
+	assert.NoError(t, err)
+	for i := 0; scan.GetRID().BlockNumber() < workers; i++ {
+		assert.NoError(t, scan.Insert())
+		assert.NoError(t, scan.SetInt("A", i))
+		assert.NoError(t, scan.SetString("B", "12345678"))
+	}
+	scan.Close()
+	assert.NoError(t, writer.Commit())
+
+	// each worker changes its own block, and commits only once all have made their changes,
+	// which they could not if the free space map or the overflow free list were locked until commit
+	concurrently := func(work func(scan *TableScanImpl, w int) error) {
+		var changed, done sync.WaitGroup
+		changed.Add(workers)
+		done.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer done.Done()
+				txn := newTx()
+				scan, err := NewTableScan(txn, testFileName, layout)
+				assert.NoError(t, err)
+				err = work(scan, w)
+				scan.Close()
+				changed.Done()
+				changed.Wait()
+				if !assert.NoError(t, err) {
+					assert.NoError(t, txn.Rollback())
+					return
+				}
+				assert.NoError(t, txn.Commit())
+			}()
+		}
+		done.Wait()
+	}
+	setB := func(scan *TableScanImpl, w int, val string) error {
+		if err := scan.MoveToRID(NewRID(w, 0)); err != nil {
+			return err
+		}
+		return scan.SetString("B", val)
+	}
+	var deleted [workers]int
+	concurrently(func(scan *TableScanImpl, w int) error {
+		if err := setB(scan, w, text); err != nil {
+			return err
+		}
+		if err := scan.MoveToRID(NewRID(w, 1)); err != nil {
+			return err
+		}
+		if deleted[w], err = scan.GetInt("A"); err != nil {
+			return err
+		}
+		if err := scan.Delete(); err != nil {
+			return err
+		}
+		if err := scan.Insert(); err != nil {
+			return err
+		}
+		if scan.GetRID().BlockNumber() != w {
+			return fmt.Errorf("inserted into block %d instead of %d", scan.GetRID().BlockNumber(), w)
+		}
+		return scan.SetInt("A", -1-w)
+	})
+	// a header and 5 chunks for each text
+	assert.Equal(t, 1+5*workers, overflowSize())
+
+	// the chunks freed by the committed transactions hold the new values
+	concurrently(func(scan *TableScanImpl, w int) error {
+		return setB(scan, w, "short")
+	})
+	concurrently(func(scan *TableScanImpl, w int) error {
+		return setB(scan, w, text)
+	})
+	assert.Equal(t, 1+5*workers, overflowSize())
+
+	reader := newTx()
+	scan, err = NewTableScan(reader, testFileName, layout)
+	assert.NoError(t, err)
+	for w := 0; w < workers; w++ {
+		assert.NoError(t, scan.MoveToRID(NewRID(w, 0)))
+		b, err := scan.GetString("B")
+		assert.NoError(t, err)
+		assert.Equal(t, text, b)
+	}
+	vals := make(map[int]bool)
+	assert.NoError(t, scan.BeforeFirst())
+	for scan.Next() {
+		a, err := scan.GetInt("A")
+		assert.NoError(t, err)
+		vals[a] = true
+	}
+	scan.Close()
+	for w := 0; w < workers; w++ {
+		assert.True(t, vals[-1-w])
+		assert.False(t, vals[deleted[w]])
+	}
+	assert.NoError(t, reader.Commit())
+}
+
+func TestTableScan_OverflowWithWriter(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+		logFileName  = "log"
+		workers      = 4
+		rounds       = 50
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_overflow_with_writer")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 4*workers)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs, buffer.WithBackgroundWriter(time.Millisecond, 0))
+	t.Cleanup(func() { assert.NoError(t, bm.Close()) })
+	txNumGen := tx.NewTxNumberGenerator()
+	lockTable := tx.NewLock(tx.WithWaitTime(time.Second))
+	newTx := func() tx.Transaction {
+		txn, err := tx.NewTransaction(fm, lm, bm, txNumGen, tx.WithTxLockTable(lockTable))
+		assert.NoError(t, err)
+		return txn
+	}
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddField("B", SCHEMA_TYPE_TEXT, 0)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	text := strings.Repeat("abcdefghij", 100)
+
+	writer := newTx()
+	scan, err := NewTableScan(writer, testFileName, layout)
+	assert.NoError(t, err)
+	for i := 0; scan.GetRID().BlockNumber() < workers; i++ {
+		assert.NoError(t, scan.Insert())
+		assert.NoError(t, scan.SetInt("A", i))
+		assert.NoError(t, scan.SetString("B", "12345678"))
+	}
+	scan.Close()
+	assert.NoError(t, writer.Commit())
+
+	// the workers take blocks from the overflow free list and add them to it while the writer and the commits flush the buffers
+	setB := func(w int, val string) error {
+		txn := newTx()
+		scan, err := NewTableScan(txn, testFileName, layout)
+		if err != nil {
+			return errors.Join(err, txn.Rollback())
+		}
+		defer scan.Close()
+		if err := scan.MoveToRID(NewRID(w, 0)); err != nil {
+			return errors.Join(err, txn.Rollback())
+		}
+		if err := scan.SetString("B", val); err != nil {
+			return errors.Join(err, txn.Rollback())
+		}
+		return txn.Commit()
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				if !assert.NoError(t, setB(w, text)) || !assert.NoError(t, setB(w, "short")) {
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("overflow writes did not finish alongside the background writer")
+	}
+
+	reader := newTx()
+	scan, err = NewTableScan(reader, testFileName, layout)
+	assert.NoError(t, err)
+	for w := 0; w < workers; w++ {
+		assert.NoError(t, scan.MoveToRID(NewRID(w, 0)))
+		b, err := scan.GetString("B")
+		assert.NoError(t, err)
+		assert.Equal(t, "short", b)
+	}
+	scan.Close()
+	assert.NoError(t, reader.Commit())
+	size, err := fm.BlockNum(testFileName + OVERFLOW_SUFFIX)
+	assert.NoError(t, err)
+	// the chunks freed in a round are taken again in the next ones
+	assert.LessOrEqual(t, size, 1+5*2*workers)
+}
+
+func TestTableScan_Compact(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+		logFileName  = "log"
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_compact")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 8)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	sch := NewSchema()
+	sch.AddIntField("A")
+	sch.AddField("B", SCHEMA_TYPE_TEXT, 0)
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	text := strings.Repeat("abcdefghij", 100)
+	size := func(filename string) int {
+		n, err := fm.BlockNum(filename)
+		assert.NoError(t, err)
+		return n
+	}
+	records := func(scan *TableScanImpl) map[int]string {
+		vals := make(map[int]string)
+		assert.NoError(t, scan.BeforeFirst())
+		for scan.Next() {
+			a, err := scan.GetInt("A")
+			assert.NoError(t, err)
+			vals[a], err = scan.GetString("B")
+			assert.NoError(t, err)
+		}
+		return vals
+	}
+
+	// fill blocks, make records grow out of their blocks, and delete most of them
+	writer, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err := NewTableScan(writer, testFileName, layout)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, scan.Insert())
+		assert.NoError(t, scan.SetInt("A", i))
+		assert.NoError(t, scan.SetString("B", fmt.Sprintf("b%d", i)))
+	}
+	want := make(map[int]string)
+	assert.NoError(t, scan.BeforeFirst())
+	for scan.Next() {
+		a, err := scan.GetInt("A")
+		assert.NoError(t, err)
+		switch {
+		case a%10 == 0:
+			want[a] = fmt.Sprintf("b%d", a)
+		case a%10 == 5:
+			want[a] = strings.Repeat(fmt.Sprint(a%10), 80)
+			assert.NoError(t, scan.SetString("B", want[a]))
+		case a == 1:
+			want[a] = text
+			assert.NoError(t, scan.SetString("B", text))
+		default:
+			assert.NoError(t, scan.Delete())
+		}
+	}
+	forwarded, err := scan.forwardedSlots()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, forwarded)
+	assert.Equal(t, want, records(scan))
+	scan.Close()
+	assert.NoError(t, writer.Commit())
+	loaded, overflowSize := size(testFileName+TABLE_SUFFIX), size(testFileName+OVERFLOW_SUFFIX)
+
+	// the records move into the first blocks, and the values in the overflow file stay there;
+	// a compaction rolled back before leaves the free space map claiming too little room in the first blocks, which does not matter
+	compact := func() (tx.Transaction, int) {
+		vacuum, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		scan, err = NewTableScan(vacuum, testFileName, layout)
+		assert.NoError(t, err)
+		assert.NoError(t, scan.Compact())
+		freed, err := scan.Shrink()
+		assert.NoError(t, err)
+		return vacuum, freed
+	}
+	vacuum, rolledBack := compact()
+	scan.Close()
+	assert.NoError(t, vacuum.Rollback())
+	vacuum, freed := compact()
+	assert.Greater(t, freed, 0)
+	assert.Equal(t, rolledBack, freed)
+	assert.Equal(t, want, records(scan))
+	scan.Close()
+	assert.NoError(t, vacuum.Commit())
+	assert.Equal(t, loaded-freed, size(testFileName+TABLE_SUFFIX))
+	assert.Equal(t, overflowSize, size(testFileName+OVERFLOW_SUFFIX))
+
+	reader, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(reader, testFileName, layout)
+	assert.NoError(t, err)
+	assert.Equal(t, want, records(scan))
+	forwarded, err = scan.forwardedSlots()
+	assert.NoError(t, err)
+	for data, home := range forwarded {
+		assert.Less(t, data.blknum, loaded-freed)
+		assert.Less(t, home.blknum, loaded-freed)
+	}
+	scan.Close()
+	assert.NoError(t, reader.Commit())
+}
+
+func TestTableScan_FreeSpace(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize    = 400
+		testFileName = "file"
+		logFileName  = "log"
+	)
+	dir, cleanup := testutil.SetupDir("test_table_scan_free_space")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, logFileName)
+	assert.NoError(t, err)
+	buffs := make([]buffer.Buffer, 3)
+	for i := range buffs {
+		buffs[i] = buffer.NewBuffer(fm, lm, blockSize)
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := tx.NewTxNumberGenerator()
+	sch := NewSchema()
+	sch.AddIntField("A")
+	layout, err := NewLayoutFromSchema(sch)
+	assert.NoError(t, err)
+	tableSize := func(txn tx.Transaction) int {
+		size, err := txn.Size(testFileName + TABLE_SUFFIX)
+		assert.NoError(t, err)
+		return size
+	}
+
+	// fill 5 blocks
+	const recordsPerBlock = (blockSize - pageHeaderBytes) / (slotBytes + 8)
+	writer, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err := NewTableScan(writer, testFileName, layout)
+	assert.NoError(t, err)
+	for i := 0; i < 5*recordsPerBlock; i++ {
+		assert.NoError(t, scan.Insert())
+		assert.NoError(t, scan.SetInt("A", i))
+	}
+	assert.Equal(t, 5, tableSize(writer))
+	scan.Close()
+	assert.NoError(t, writer.Commit())
+
+	// a new scan inserts into the block where a record was deleted, and then into a new block
+	updater, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(updater, testFileName, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, scan.MoveToRID(NewRID(2, 3)))
+	assert.NoError(t, scan.Delete())
+	scan.Close()
+	scan, err = NewTableScan(updater, testFileName, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, scan.Insert())
+	assert.Equal(t, NewRID(2, 3), scan.GetRID())
+	assert.NoError(t, scan.Insert())
+	assert.Equal(t, NewRID(5, 0), scan.GetRID())
+	assert.Equal(t, 6, tableSize(updater))
+	scan.Close()
+	assert.NoError(t, updater.Rollback())
+
+	// rolling back restores the map: the deleted record is back, and the blocks are full again
+	inserter, err := tx.NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	scan, err = NewTableScan(inserter, testFileName, layout)
+	assert.NoError(t, err)
+	assert.NoError(t, scan.Insert())
+	assert.Equal(t, 5, scan.GetRID().BlockNumber())
+	scan.Close()
+	assert.NoError(t, inserter.Commit())
+}
@@ -2,6 +2,7 @@ package tx
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/kj455/simple-db/pkg/file"
 )
@@ -17,27 +18,95 @@ const (
 1. Before reading a block, acquire a shared lock on it.
 2. Before modifying a block, acquire an exclusive lock on it.
 3. Release all locks after a commit or rollback.
+
+Locks are hierarchical: database -> file -> block.
+Before locking a block, IS (for S) or IX (for X) is taken on the database and on the file of the block.
+When a transaction holds more block locks on a file than the escalation threshold,
+they are replaced by a single S or X lock on the file.
+
+How long shared locks are held depends on the isolation level:
+  - READ UNCOMMITTED: no shared locks are taken.
+  - READ COMMITTED: shared locks are released right after each read.
+  - REPEATABLE READ: shared locks on blocks are held until the end, the end-of-file marker is not locked.
+  - SERIALIZABLE: every shared lock is held until the end.
 */
 type ConcurrencyMgrImpl struct {
-	l     Lock
-	Locks map[file.BlockId]LockType
+	l                   Lock
+	owner               int
+	isolation           IsolationLevel
+	escalationThreshold int
+	Locks               map[file.BlockId]LockType
+	Granules            map[Granule]LockMode
+	blockLockNums       map[string]int
+	keys                blockIdInterner
+}
+
+// DEFAULT_ESCALATION_THRESHOLD is the number of block locks on a file above which they are escalated to a file lock.
+const DEFAULT_ESCALATION_THRESHOLD = 1000
+
+// lockOwnerSeq identifies the concurrency managers in the lock tables.
+var lockOwnerSeq atomic.Int64
+
+type ConcurrencyMgrOption func(*ConcurrencyMgrImpl)
+
+// WithLockTable makes the concurrency manager share the given lock table.
+// Transactions only see each other's locks when they share a lock table.
+func WithLockTable(l Lock) ConcurrencyMgrOption {
+	return func(cm *ConcurrencyMgrImpl) {
+		cm.l = l
+	}
 }
 
-func NewConcurrencyMgr() *ConcurrencyMgrImpl {
-	return &ConcurrencyMgrImpl{
-		l:     NewLock(),
-		Locks: make(map[file.BlockId]LockType),
+func WithIsolation(level IsolationLevel) ConcurrencyMgrOption {
+	return func(cm *ConcurrencyMgrImpl) {
+		cm.isolation = level
 	}
 }
 
+// WithEscalationThreshold sets the number of block locks on a file above which they are escalated.
+// A threshold of 0 or less disables the escalation.
+func WithEscalationThreshold(n int) ConcurrencyMgrOption {
+	return func(cm *ConcurrencyMgrImpl) {
+		cm.escalationThreshold = n
+	}
+}
+
+func NewConcurrencyMgr(opts ...ConcurrencyMgrOption) *ConcurrencyMgrImpl {
+	cm := &ConcurrencyMgrImpl{
+		owner:               int(lockOwnerSeq.Add(1)),
+		isolation:           DEFAULT_ISOLATION_LEVEL,
+		escalationThreshold: DEFAULT_ESCALATION_THRESHOLD,
+		Locks:               make(map[file.BlockId]LockType),
+		Granules:            make(map[Granule]LockMode),
+		blockLockNums:       make(map[string]int),
+		keys:                make(blockIdInterner),
+	}
+	for _, opt := range opts {
+		opt(cm)
+	}
+	if cm.l == nil {
+		cm.l = NewLock()
+	}
+	return cm
+}
+
 func (cm *ConcurrencyMgrImpl) SLock(blk file.BlockId) error {
-	if _, exists := cm.Locks[blk]; exists {
+	switch {
+	case cm.isolation == ISOLATION_LEVEL_READ_UNCOMMITTED:
+		return nil
+	case cm.isolation == ISOLATION_LEVEL_REPEATABLE_READ && blk.Number() == END_OF_FILE:
 		return nil
 	}
-	if err := cm.l.SLock(blk); err != nil {
-		return fmt.Errorf("concurrency: SLock: %v", err)
+	if cm.Granules[FileGranule(blk.Filename())].Covers(LOCK_MODE_S) {
+		return nil
 	}
-	cm.Locks[blk] = LOCK_TYPE_S
+	if err := cm.lockAncestors(blk.Filename(), LOCK_MODE_IS); err != nil {
+		return err
+	}
+	if err := cm.sLock(blk); err != nil {
+		return err
+	}
+	cm.escalate(blk.Filename())
 	return nil
 }
 
@@ -45,27 +114,165 @@ func (cm *ConcurrencyMgrImpl) XLock(blk file.BlockId) error {
 	if cm.HasXLock(blk) {
 		return nil
 	}
-	if err := cm.SLock(blk); err != nil {
+	if err := cm.lockAncestors(blk.Filename(), LOCK_MODE_IX); err != nil {
+		return err
+	}
+	blk = cm.keys.intern(blk)
+	// The lock table expects the requester to already hold an S lock, whatever the isolation level.
+	if err := cm.sLock(blk); err != nil {
 		return fmt.Errorf("concurrency: SLock before XLock: %v", err)
 	}
 	if err := cm.l.XLock(blk); err != nil {
 		return fmt.Errorf("concurrency: XLock: %v", err)
 	}
 	cm.Locks[blk] = LOCK_TYPE_X
+	cm.escalate(blk.Filename())
 	return nil
 }
 
+// ReleaseRead releases the S lock taken for a single read when the isolation level does not keep read locks.
+func (cm *ConcurrencyMgrImpl) ReleaseRead(blk file.BlockId) {
+	if cm.isolation.holdsReadLocks() {
+		return
+	}
+	blk = cm.keys.intern(blk)
+	if cm.Locks[blk] != LOCK_TYPE_S {
+		return
+	}
+	cm.unlockBlock(blk)
+}
+
+// LockFile locks the whole file in the given mode. The locks on its blocks are kept.
+func (cm *ConcurrencyMgrImpl) LockFile(filename string, mode LockMode) error {
+	if !mode.Valid() {
+		return ErrUnknownLockMode
+	}
+	if err := cm.lockGranule(GRANULE_DATABASE, mode.Intention()); err != nil {
+		return err
+	}
+	return cm.lockGranule(FileGranule(filename), mode)
+}
+
 func (cm *ConcurrencyMgrImpl) Release() {
 	for blk := range cm.Locks {
 		cm.l.Unlock(blk)
 		delete(cm.Locks, blk)
 	}
+	// release from the leaves to the root
+	for g := range cm.Granules {
+		if g != GRANULE_DATABASE {
+			cm.l.UnlockGranule(cm.owner, g)
+		}
+	}
+	if _, ok := cm.Granules[GRANULE_DATABASE]; ok {
+		cm.l.UnlockGranule(cm.owner, GRANULE_DATABASE)
+	}
+	cm.Granules = make(map[Granule]LockMode)
+	cm.blockLockNums = make(map[string]int)
+	cm.keys = make(blockIdInterner)
 }
 
 func (cm *ConcurrencyMgrImpl) HasXLock(blk file.BlockId) bool {
-	lockType, exists := cm.Locks[blk]
+	if cm.Granules[FileGranule(blk.Filename())].Covers(LOCK_MODE_X) {
+		return true
+	}
+	key, ok := cm.keys[blk.String()]
+	if !ok {
+		return false
+	}
+	lockType, exists := cm.Locks[key]
 	if !exists {
 		return false
 	}
 	return lockType == LOCK_TYPE_X
 }
+
+func (cm *ConcurrencyMgrImpl) SetIsolationLevel(level IsolationLevel) {
+	cm.isolation = level
+}
+
+func (cm *ConcurrencyMgrImpl) IsolationLevel() IsolationLevel {
+	return cm.isolation
+}
+
+func (cm *ConcurrencyMgrImpl) sLock(blk file.BlockId) error {
+	blk = cm.keys.intern(blk)
+	if _, exists := cm.Locks[blk]; exists {
+		return nil
+	}
+	if err := cm.l.SLock(blk); err != nil {
+		return fmt.Errorf("concurrency: SLock: %v", err)
+	}
+	cm.Locks[blk] = LOCK_TYPE_S
+	cm.blockLockNums[blk.Filename()]++
+	return nil
+}
+
+func (cm *ConcurrencyMgrImpl) unlockBlock(blk file.BlockId) {
+	cm.l.Unlock(blk)
+	delete(cm.Locks, blk)
+	delete(cm.keys, blk.String())
+	cm.blockLockNums[blk.Filename()]--
+	if cm.blockLockNums[blk.Filename()] <= 0 {
+		delete(cm.blockLockNums, blk.Filename())
+	}
+}
+
+// lockAncestors takes the intention lock on the database and the file before a block of the file is locked.
+func (cm *ConcurrencyMgrImpl) lockAncestors(filename string, intention LockMode) error {
+	if err := cm.lockGranule(GRANULE_DATABASE, intention); err != nil {
+		return err
+	}
+	return cm.lockGranule(FileGranule(filename), intention)
+}
+
+func (cm *ConcurrencyMgrImpl) lockGranule(g Granule, mode LockMode) error {
+	held := cm.Granules[g]
+	if held.Covers(mode) {
+		return nil
+	}
+	if err := cm.l.LockGranule(cm.owner, g, mode); err != nil {
+		return fmt.Errorf("concurrency: lock %s in %s mode: %v", g, mode, err)
+	}
+	cm.Granules[g] = held.Join(mode)
+	return nil
+}
+
+// escalate replaces the block locks on the file with a single file lock once there are too many of them.
+// The escalation is skipped while other transactions hold conflicting locks on the file, and retried on the next block lock.
+func (cm *ConcurrencyMgrImpl) escalate(filename string) {
+	if cm.escalationThreshold <= 0 || cm.blockLockNums[filename] <= cm.escalationThreshold {
+		return
+	}
+	mode := LOCK_MODE_S
+	var blocks []file.BlockId
+	for blk, lockType := range cm.Locks {
+		if blk.Filename() != filename {
+			continue
+		}
+		if lockType == LOCK_TYPE_X {
+			mode = LOCK_MODE_X
+		}
+		blocks = append(blocks, blk)
+	}
+	g := FileGranule(filename)
+	if !cm.l.TryLockGranule(cm.owner, g, mode) {
+		return
+	}
+	cm.Granules[g] = cm.Granules[g].Join(mode)
+	for _, blk := range blocks {
+		cm.unlockBlock(blk)
+	}
+}
+
+// blockIdInterner maps equal block ids to a single instance so that they can be used as one map key.
+type blockIdInterner map[string]file.BlockId
+
+func (bi blockIdInterner) intern(blk file.BlockId) file.BlockId {
+	key := blk.String()
+	if b, ok := bi[key]; ok {
+		return b
+	}
+	bi[key] = blk
+	return blk
+}
@@ -2,6 +2,7 @@ package tx
 
 import (
 	"testing"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/file"
 	"github.com/stretchr/testify/assert"
@@ -61,3 +62,181 @@ func TestConcurrencyMgr_XLock(t *testing.T) {
 		assert.Equal(t, 0, len(concurMgr.Locks))
 	})
 }
+
+func TestConcurrencyMgr_Isolation(t *testing.T) {
+	t.Parallel()
+	const filename = "test_concurrency_isolation"
+	block := file.NewBlockId(filename, 1)
+	eof := file.NewBlockId(filename, END_OF_FILE)
+	t.Run("read uncommitted takes no S lock", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr(WithIsolation(ISOLATION_LEVEL_READ_UNCOMMITTED))
+		assert.NoError(t, cm.SLock(block))
+		assert.Equal(t, 0, len(cm.Locks))
+
+		assert.NoError(t, cm.XLock(block))
+		assert.True(t, cm.HasXLock(block))
+	})
+	t.Run("read committed releases S lock after read", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr(WithIsolation(ISOLATION_LEVEL_READ_COMMITTED))
+		assert.NoError(t, cm.SLock(block))
+		assert.Equal(t, LOCK_TYPE_S, cm.Locks[block])
+
+		cm.ReleaseRead(file.NewBlockId(filename, 1))
+		assert.Equal(t, 0, len(cm.Locks))
+
+		assert.NoError(t, cm.XLock(block))
+		cm.ReleaseRead(block)
+		assert.True(t, cm.HasXLock(block))
+	})
+	t.Run("repeatable read does not lock end of file", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr(WithIsolation(ISOLATION_LEVEL_REPEATABLE_READ))
+		assert.NoError(t, cm.SLock(eof))
+		assert.NoError(t, cm.SLock(block))
+		cm.ReleaseRead(block)
+		assert.Equal(t, 1, len(cm.Locks))
+		assert.Equal(t, LOCK_TYPE_S, cm.Locks[block])
+	})
+	t.Run("serializable locks end of file", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr()
+		assert.Equal(t, ISOLATION_LEVEL_SERIALIZABLE, cm.IsolationLevel())
+		assert.NoError(t, cm.SLock(eof))
+		assert.Equal(t, LOCK_TYPE_S, cm.Locks[eof])
+	})
+}
+
+func TestConcurrencyMgr_SharedLockTable(t *testing.T) {
+	t.Parallel()
+	const filename = "test_concurrency_shared_lock_table"
+	l := NewLock(WithWaitTime(10 * time.Millisecond))
+	writer := NewConcurrencyMgr(WithLockTable(l))
+	reader := NewConcurrencyMgr(WithLockTable(l))
+
+	assert.NoError(t, writer.XLock(file.NewBlockId(filename, 0)))
+	// equal blocks conflict even when they are different instances
+	assert.Error(t, reader.SLock(file.NewBlockId(filename, 0)))
+
+	reader.SetIsolationLevel(ISOLATION_LEVEL_READ_UNCOMMITTED)
+	assert.NoError(t, reader.SLock(file.NewBlockId(filename, 0)))
+
+	writer.Release()
+	reader.SetIsolationLevel(ISOLATION_LEVEL_SERIALIZABLE)
+	assert.NoError(t, reader.SLock(file.NewBlockId(filename, 0)))
+}
+
+func TestConcurrencyMgr_Hierarchy(t *testing.T) {
+	t.Parallel()
+	const filename = "test_concurrency_hierarchy"
+	t.Run("intention locks on the ancestors", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr()
+		assert.NoError(t, cm.SLock(file.NewBlockId(filename, 0)))
+		assert.Equal(t, LOCK_MODE_IS, cm.Granules[GRANULE_DATABASE])
+		assert.Equal(t, LOCK_MODE_IS, cm.Granules[FileGranule(filename)])
+
+		assert.NoError(t, cm.XLock(file.NewBlockId(filename, 1)))
+		assert.Equal(t, LOCK_MODE_IX, cm.Granules[GRANULE_DATABASE])
+		assert.Equal(t, LOCK_MODE_IX, cm.Granules[FileGranule(filename)])
+
+		cm.Release()
+		assert.Equal(t, 0, len(cm.Granules))
+	})
+	t.Run("file locks conflict with block locks of other transactions", func(t *testing.T) {
+		t.Parallel()
+		l := NewLock(WithWaitTime(10 * time.Millisecond))
+		reader := NewConcurrencyMgr(WithLockTable(l))
+		writer := NewConcurrencyMgr(WithLockTable(l))
+
+		assert.NoError(t, reader.LockFile(filename, LOCK_MODE_S))
+		assert.Equal(t, LOCK_MODE_IS, reader.Granules[GRANULE_DATABASE])
+		// the file lock covers the blocks
+		assert.NoError(t, reader.SLock(file.NewBlockId(filename, 0)))
+		assert.Equal(t, 0, len(reader.Locks))
+
+		assert.Error(t, writer.XLock(file.NewBlockId(filename, 1)))
+		assert.NoError(t, writer.XLock(file.NewBlockId("other_"+filename, 0)))
+
+		reader.Release()
+		assert.NoError(t, writer.XLock(file.NewBlockId(filename, 1)))
+		assert.Error(t, reader.LockFile(filename, LOCK_MODE_S))
+		assert.ErrorIs(t, reader.LockFile(filename, LOCK_MODE_NONE), ErrUnknownLockMode)
+	})
+	t.Run("exclusive file lock", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr()
+		assert.NoError(t, cm.LockFile(filename, LOCK_MODE_X))
+		assert.Equal(t, LOCK_MODE_IX, cm.Granules[GRANULE_DATABASE])
+		assert.True(t, cm.HasXLock(file.NewBlockId(filename, 3)))
+		assert.NoError(t, cm.XLock(file.NewBlockId(filename, 3)))
+		assert.Equal(t, 0, len(cm.Locks))
+	})
+}
+
+func TestConcurrencyMgr_Escalation(t *testing.T) {
+	t.Parallel()
+	const (
+		filename  = "test_concurrency_escalation"
+		threshold = 3
+	)
+	t.Run("shared", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr(WithEscalationThreshold(threshold))
+		other := file.NewBlockId("other_"+filename, 0)
+		assert.NoError(t, cm.SLock(other))
+		for i := 0; i < threshold; i++ {
+			assert.NoError(t, cm.SLock(file.NewBlockId(filename, i)))
+		}
+		assert.Equal(t, threshold+1, len(cm.Locks))
+		assert.Equal(t, LOCK_MODE_IS, cm.Granules[FileGranule(filename)])
+
+		assert.NoError(t, cm.SLock(file.NewBlockId(filename, threshold)))
+		assert.Equal(t, LOCK_MODE_S, cm.Granules[FileGranule(filename)])
+		// only the locks on the other file remain
+		assert.Equal(t, 1, len(cm.Locks))
+		assert.Equal(t, LOCK_TYPE_S, cm.Locks[other])
+
+		// writes after the escalation still lock blocks
+		assert.NoError(t, cm.XLock(file.NewBlockId(filename, 0)))
+		assert.Equal(t, LOCK_MODE_SIX, cm.Granules[FileGranule(filename)])
+		assert.Equal(t, 2, len(cm.Locks))
+	})
+	t.Run("exclusive", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr(WithEscalationThreshold(threshold))
+		assert.NoError(t, cm.XLock(file.NewBlockId(filename, 0)))
+		for i := 1; i <= threshold; i++ {
+			assert.NoError(t, cm.SLock(file.NewBlockId(filename, i)))
+		}
+		assert.Equal(t, LOCK_MODE_X, cm.Granules[FileGranule(filename)])
+		assert.Equal(t, 0, len(cm.Locks))
+		assert.True(t, cm.HasXLock(file.NewBlockId(filename, 100)))
+	})
+	t.Run("skipped while other transactions hold conflicting locks", func(t *testing.T) {
+		t.Parallel()
+		l := NewLock(WithWaitTime(10 * time.Millisecond))
+		cm := NewConcurrencyMgr(WithLockTable(l), WithEscalationThreshold(threshold))
+		other := NewConcurrencyMgr(WithLockTable(l))
+		assert.NoError(t, other.XLock(file.NewBlockId(filename, 100)))
+		for i := 0; i <= threshold; i++ {
+			assert.NoError(t, cm.SLock(file.NewBlockId(filename, i)))
+		}
+		assert.Equal(t, LOCK_MODE_IS, cm.Granules[FileGranule(filename)])
+		assert.Equal(t, threshold+1, len(cm.Locks))
+
+		other.Release()
+		assert.NoError(t, cm.SLock(file.NewBlockId(filename, threshold+1)))
+		assert.Equal(t, LOCK_MODE_S, cm.Granules[FileGranule(filename)])
+		assert.Equal(t, 0, len(cm.Locks))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+		cm := NewConcurrencyMgr(WithEscalationThreshold(0))
+		for i := 0; i <= 10; i++ {
+			assert.NoError(t, cm.SLock(file.NewBlockId(filename, i)))
+		}
+		assert.Equal(t, 11, len(cm.Locks))
+	})
+}
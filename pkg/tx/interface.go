@@ -3,6 +3,8 @@
 package tx
 
 import (
+	"time"
+
 	"github.com/kj455/simple-db/pkg/buffer"
 	"github.com/kj455/simple-db/pkg/file"
 )
@@ -12,19 +14,64 @@ type Transaction interface {
 	Rollback() error
 	Recover() error
 
+	// Savepoint marks the current point of the transaction so that later changes can be undone without ending it.
+	Savepoint(name string) error
+	RollbackToSavepoint(name string) error
+	ReleaseSavepoint(name string) error
+
 	Pin(block file.BlockId) error
 	Unpin(block file.BlockId)
 	GetInt(block file.BlockId, offset int) (int, error)
 	GetString(block file.BlockId, offset int) (string, error)
-	// SetInt sets the value of the specified block at the specified offset.
+	// SetInt sets the value of the specified block at the specified offset, which must fit in a signed 32-bit integer.
 	// If okToLog is true, then the method logs the change.
 	SetInt(block file.BlockId, offset int, val int, okToLog bool) error
 	SetString(block file.BlockId, offset int, val string, okToLog bool) error
+	// The accessors of the other value types work like GetInt and SetInt. Times are stored in UTC with microsecond precision.
+	GetInt64(block file.BlockId, offset int) (int64, error)
+	GetFloat64(block file.BlockId, offset int) (float64, error)
+	GetBool(block file.BlockId, offset int) (bool, error)
+	GetTime(block file.BlockId, offset int) (time.Time, error)
+	GetBytes(block file.BlockId, offset int) ([]byte, error)
+	SetInt64(block file.BlockId, offset int, val int64, okToLog bool) error
+	SetFloat64(block file.BlockId, offset int, val float64, okToLog bool) error
+	SetBool(block file.BlockId, offset int, val bool, okToLog bool) error
+	SetTime(block file.BlockId, offset int, val time.Time, okToLog bool) error
+	SetBytes(block file.BlockId, offset int, val []byte, okToLog bool) error
 	AvailableBuffs() int
 
+	// Size and Append lock the end-of-file marker of the file, a block numbered END_OF_FILE,
+	// so that appending a block conflicts with the transactions that have read the size of the file.
 	Size(filename string) (int, error)
 	Append(filename string) (file.BlockId, error)
 	BlockSize() int
+	// LockFile locks the whole file in the given mode until the transaction ends.
+	LockFile(filename string, mode LockMode) error
+	// RemoveFileOnCommit removes the file once the transaction has committed, unless the transaction writes to it again.
+	RemoveFileOnCommit(filename string)
+	// TruncateFileOnCommit shortens the file to its first blocks once the transaction has committed.
+	TruncateFileOnCommit(filename string, blocks int)
+	// OnCommit calls fn once the transaction has committed. Rolling back cancels it.
+	OnCommit(fn func() error)
+	// OnEnd calls fn once the transaction has committed or rolled back. Rolling back to a savepoint does not cancel it.
+	OnEnd(fn func())
+
+	// ReadShared, WriteShared and AppendShared access the blocks that transactions share without isolation,
+	// such as the free space map of a table: they take no lock but a latch held for the call, and the changes are not logged,
+	// so a rollback does not undo them. get and set must not access other blocks, as pinning a block with a latch held
+	// could wait for a flush of the buffers that waits for the latch.
+	ReadShared(block file.BlockId, get func(p buffer.ReadPage)) error
+	WriteShared(block file.BlockId, set func(p buffer.ReadWritePage)) error
+	AppendShared(filename string) (file.BlockId, error)
+
+	// SetIsolationLevel changes the isolation level used by the subsequent reads of the transaction.
+	SetIsolationLevel(level IsolationLevel) error
+	IsolationLevel() IsolationLevel
+	// SetReadOnly makes the transaction reject every modification.
+	SetReadOnly(readOnly bool)
+	IsReadOnly() bool
+	// Started reports whether the transaction has read or written anything: pinned a block, or locked or sized a file.
+	Started() bool
 }
 
 // RecoveryMgr is an interface for recovery manager - undo only
@@ -32,20 +79,35 @@ type RecoveryMgr interface {
 	Commit() error
 	Rollback() error
 	Recover() error
+	Savepoint(name string) error
+	RollbackToSavepoint(name string) error
+	ReleaseSavepoint(name string) error
 	SetInt(buff buffer.Buffer, offset int, oldVal int) (int, error)
 	SetString(buff buffer.Buffer, offset int, oldVal string) (int, error)
+	// SetValue logs an old value of the other types: int64, float64, bool, time.Time or []byte.
+	SetValue(buff buffer.Buffer, offset int, oldVal any) (int, error)
 }
 
 type ConcurrencyMgr interface {
 	SLock(blk file.BlockId) error
 	XLock(blk file.BlockId) error
+	// ReleaseRead is called after each read so that short read locks can be released.
+	ReleaseRead(blk file.BlockId)
+	// LockFile locks the whole file in the given mode, after taking the intention lock on the database.
+	LockFile(filename string, mode LockMode) error
 	Release()
+	SetIsolationLevel(level IsolationLevel)
+	IsolationLevel() IsolationLevel
 }
 
 type Lock interface {
 	SLock(block file.BlockId) error
 	XLock(block file.BlockId) error
 	Unlock(block file.BlockId)
+	// LockGranule locks a database or file granule in the given mode on behalf of the owner.
+	LockGranule(owner int, granule Granule, mode LockMode) error
+	TryLockGranule(owner int, granule Granule, mode LockMode) bool
+	UnlockGranule(owner int, granule Granule)
 }
 
 /*
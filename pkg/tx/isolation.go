@@ -0,0 +1,52 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+)
+
+// IsolationLevel controls how long a transaction holds its read locks.
+type IsolationLevel int
+
+const (
+	// ISOLATION_LEVEL_READ_UNCOMMITTED reads blocks without taking any S lock.
+	ISOLATION_LEVEL_READ_UNCOMMITTED IsolationLevel = iota + 1
+	// ISOLATION_LEVEL_READ_COMMITTED takes a short S lock that is released right after each read.
+	ISOLATION_LEVEL_READ_COMMITTED
+	// ISOLATION_LEVEL_REPEATABLE_READ holds S locks on blocks until commit, but does not lock the end of file.
+	ISOLATION_LEVEL_REPEATABLE_READ
+	// ISOLATION_LEVEL_SERIALIZABLE holds S locks on blocks and the end of file until commit.
+	ISOLATION_LEVEL_SERIALIZABLE
+)
+
+const DEFAULT_ISOLATION_LEVEL = ISOLATION_LEVEL_SERIALIZABLE
+
+var (
+	ErrReadOnly              = errors.New("tx: transaction is read only")
+	ErrUnknownIsolationLevel = errors.New("tx: unknown isolation level")
+)
+
+func (l IsolationLevel) String() string {
+	switch l {
+	case ISOLATION_LEVEL_READ_UNCOMMITTED:
+		return "read uncommitted"
+	case ISOLATION_LEVEL_READ_COMMITTED:
+		return "read committed"
+	case ISOLATION_LEVEL_REPEATABLE_READ:
+		return "repeatable read"
+	case ISOLATION_LEVEL_SERIALIZABLE:
+		return "serializable"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(l))
+	}
+}
+
+// Valid reports whether the level is one of the supported isolation levels.
+func (l IsolationLevel) Valid() bool {
+	return l >= ISOLATION_LEVEL_READ_UNCOMMITTED && l <= ISOLATION_LEVEL_SERIALIZABLE
+}
+
+// holdsReadLocks reports whether S locks are kept until the transaction ends.
+func (l IsolationLevel) holdsReadLocks() bool {
+	return l >= ISOLATION_LEVEL_REPEATABLE_READ
+}
@@ -15,6 +15,8 @@ const (
 
 type LockImpl struct {
 	locks       map[file.BlockId]lockState
+	keys        blockIdInterner
+	granules    map[Granule]map[int]LockMode
 	mu          *sync.Mutex
 	cond        *sync.Cond
 	maxWaitTime time.Duration
@@ -38,6 +40,8 @@ func WithWaitTime(d time.Duration) LockOption {
 func NewLock(options ...LockOption) *LockImpl {
 	l := &LockImpl{
 		locks:       make(map[file.BlockId]lockState),
+		keys:        make(blockIdInterner),
+		granules:    make(map[Granule]map[int]LockMode),
 		maxWaitTime: DEFAULT_MAX_WAIT_TIME,
 		time:        ttime.NewTime(),
 		mu:          &sync.Mutex{},
@@ -52,7 +56,11 @@ func NewLock(options ...LockOption) *LockImpl {
 func (l *LockImpl) SLock(block file.BlockId) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	block = l.intern(block)
 	now := l.time.Now()
+	if l.hasXlock(block) {
+		defer l.wakeAfterMaxWait()()
+	}
 	for l.hasXlock(block) && !l.hasWaitedTooLong(now) {
 		l.cond.Wait()
 	}
@@ -71,8 +79,12 @@ func (l *LockImpl) SLock(block file.BlockId) error {
 func (l *LockImpl) XLock(block file.BlockId) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	block = l.intern(block)
 
 	now := l.time.Now()
+	if l.hasOtherSLocks(block) {
+		defer l.wakeAfterMaxWait()()
+	}
 	// Concurrency manager will always obtain an SLock on the block before requesting the XLock.
 	// So, a value higher than 1 indicates that some other transaction also has a lock on this block.
 	for l.hasOtherSLocks(block) && !l.hasWaitedTooLong(now) {
@@ -88,6 +100,7 @@ func (l *LockImpl) XLock(block file.BlockId) error {
 func (l *LockImpl) Unlock(block file.BlockId) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	block = l.intern(block)
 
 	state := l.getLockState(block)
 	if state.IsMultipleSLocked() {
@@ -95,9 +108,90 @@ func (l *LockImpl) Unlock(block file.BlockId) {
 		return
 	}
 	delete(l.locks, block)
+	delete(l.keys, block.String())
+	l.cond.Broadcast()
+}
+
+// LockGranule locks the granule in the given mode for the owner, waiting while other owners hold conflicting locks.
+// A lock already held by the owner is upgraded to the weakest mode covering both.
+func (l *LockImpl) LockGranule(owner int, granule Granule, mode LockMode) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	want := l.granuleMode(granule, owner).Join(mode)
+	now := l.time.Now()
+	if l.hasGranuleConflict(granule, owner, want) {
+		defer l.wakeAfterMaxWait()()
+	}
+	for l.hasGranuleConflict(granule, owner, want) && !l.hasWaitedTooLong(now) {
+		l.cond.Wait()
+	}
+	if l.hasGranuleConflict(granule, owner, want) {
+		return fmt.Errorf("lock: LockGranule: %s has locks conflicting with %s", granule, want)
+	}
+	l.setGranuleMode(granule, owner, want)
+	return nil
+}
+
+// TryLockGranule is LockGranule without waiting. It reports whether the lock was granted.
+func (l *LockImpl) TryLockGranule(owner int, granule Granule, mode LockMode) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	want := l.granuleMode(granule, owner).Join(mode)
+	if l.hasGranuleConflict(granule, owner, want) {
+		return false
+	}
+	l.setGranuleMode(granule, owner, want)
+	return true
+}
+
+// UnlockGranule releases the lock of the owner on the granule.
+func (l *LockImpl) UnlockGranule(owner int, granule Granule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	holders, ok := l.granules[granule]
+	if !ok {
+		return
+	}
+	delete(holders, owner)
+	if len(holders) == 0 {
+		delete(l.granules, granule)
+	}
 	l.cond.Broadcast()
 }
 
+func (l *LockImpl) granuleMode(granule Granule, owner int) LockMode {
+	return l.granules[granule][owner]
+}
+
+func (l *LockImpl) setGranuleMode(granule Granule, owner int, mode LockMode) {
+	if l.granules == nil {
+		l.granules = make(map[Granule]map[int]LockMode)
+	}
+	holders, ok := l.granules[granule]
+	if !ok {
+		holders = make(map[int]LockMode)
+		l.granules[granule] = holders
+	}
+	holders[owner] = mode
+}
+
+func (l *LockImpl) hasGranuleConflict(granule Granule, owner int, mode LockMode) bool {
+	for other, held := range l.granules[granule] {
+		if other != owner && !mode.Compatible(held) {
+			return true
+		}
+	}
+	return false
+}
+
+// intern returns the block id instance used as the key for blocks equal to block.
+func (l *LockImpl) intern(block file.BlockId) file.BlockId {
+	if l.keys == nil {
+		l.keys = make(blockIdInterner)
+	}
+	return l.keys.intern(block)
+}
+
 func (lt *LockImpl) hasXlock(block file.BlockId) bool {
 	return lt.getLockState(block).IsXLocked()
 }
@@ -114,6 +208,15 @@ func (lt *LockImpl) getLockState(block file.BlockId) lockState {
 	return state
 }
 
+// wakeAfterMaxWait wakes up the waiters once the max wait time has passed so that they can give up.
+// It returns a function that cancels the wake up.
+func (l *LockImpl) wakeAfterMaxWait() func() {
+	timer := time.AfterFunc(l.maxWaitTime, l.cond.Broadcast)
+	return func() {
+		timer.Stop()
+	}
+}
+
 func (l *LockImpl) hasWaitedTooLong(startTime time.Time) bool {
 	return l.time.Since(startTime) > l.maxWaitTime
 }
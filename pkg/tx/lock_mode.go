@@ -0,0 +1,133 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+LockMode is the mode of a lock on a granule of the lock hierarchy: database -> file -> block.
+Before locking a granule in S (resp. X) mode, a transaction must hold IS (resp. IX) or stronger on all its ancestors.
+
+Compatibility of the modes:
+
+	     IS  IX  S   SIX X
+	IS   o   o   o   o   -
+	IX   o   o   -   -   -
+	S    o   -   o   -   -
+	SIX  o   -   -   -   -
+	X    -   -   -   -   -
+*/
+type LockMode int
+
+const (
+	LOCK_MODE_NONE LockMode = iota
+	// LOCK_MODE_IS announces S locks on some descendants.
+	LOCK_MODE_IS
+	// LOCK_MODE_IX announces X locks on some descendants.
+	LOCK_MODE_IX
+	// LOCK_MODE_S locks the granule and all its descendants in shared mode.
+	LOCK_MODE_S
+	// LOCK_MODE_SIX is S and IX held together.
+	LOCK_MODE_SIX
+	// LOCK_MODE_X locks the granule and all its descendants in exclusive mode.
+	LOCK_MODE_X
+)
+
+var ErrUnknownLockMode = errors.New("tx: unknown lock mode")
+
+func (m LockMode) String() string {
+	switch m {
+	case LOCK_MODE_NONE:
+		return "NONE"
+	case LOCK_MODE_IS:
+		return "IS"
+	case LOCK_MODE_IX:
+		return "IX"
+	case LOCK_MODE_S:
+		return "S"
+	case LOCK_MODE_SIX:
+		return "SIX"
+	case LOCK_MODE_X:
+		return "X"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(m))
+	}
+}
+
+// Valid reports whether the mode is one that can be requested.
+func (m LockMode) Valid() bool {
+	return m >= LOCK_MODE_IS && m <= LOCK_MODE_X
+}
+
+// Compatible reports whether a lock in mode m can be granted while another transaction holds a lock in mode other.
+func (m LockMode) Compatible(other LockMode) bool {
+	if m == LOCK_MODE_NONE || other == LOCK_MODE_NONE {
+		return true
+	}
+	switch m {
+	case LOCK_MODE_IS:
+		return other != LOCK_MODE_X
+	case LOCK_MODE_IX:
+		return other == LOCK_MODE_IS || other == LOCK_MODE_IX
+	case LOCK_MODE_S:
+		return other == LOCK_MODE_IS || other == LOCK_MODE_S
+	case LOCK_MODE_SIX:
+		return other == LOCK_MODE_IS
+	default:
+		return false
+	}
+}
+
+// Covers reports whether holding m grants everything that other grants.
+func (m LockMode) Covers(other LockMode) bool {
+	switch other {
+	case LOCK_MODE_NONE:
+		return true
+	case LOCK_MODE_IS:
+		return m != LOCK_MODE_NONE
+	case LOCK_MODE_IX:
+		return m == LOCK_MODE_IX || m == LOCK_MODE_SIX || m == LOCK_MODE_X
+	case LOCK_MODE_S:
+		return m == LOCK_MODE_S || m == LOCK_MODE_SIX || m == LOCK_MODE_X
+	case LOCK_MODE_SIX:
+		return m == LOCK_MODE_SIX || m == LOCK_MODE_X
+	default:
+		return m == LOCK_MODE_X
+	}
+}
+
+// Join returns the weakest mode that covers both m and other, i.e. the mode of a lock upgraded from m by requesting other.
+func (m LockMode) Join(other LockMode) LockMode {
+	if m.Covers(other) {
+		return m
+	}
+	if other.Covers(m) {
+		return other
+	}
+	// S and IX are the only modes that do not cover each other.
+	return LOCK_MODE_SIX
+}
+
+// Intention returns the mode that must be held on the ancestors before locking a granule in mode m.
+func (m LockMode) Intention() LockMode {
+	switch m {
+	case LOCK_MODE_IS, LOCK_MODE_S:
+		return LOCK_MODE_IS
+	case LOCK_MODE_NONE:
+		return LOCK_MODE_NONE
+	default:
+		return LOCK_MODE_IX
+	}
+}
+
+// Granule is a lockable unit above the block level of the lock hierarchy.
+type Granule string
+
+// GRANULE_DATABASE is the root of the lock hierarchy.
+const GRANULE_DATABASE Granule = "database"
+
+// FileGranule returns the granule of a file, the parent of all its blocks.
+func FileGranule(filename string) Granule {
+	return Granule("file:" + filename)
+}
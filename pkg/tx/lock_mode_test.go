@@ -0,0 +1,55 @@
+package tx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockMode_Compatible(t *testing.T) {
+	t.Parallel()
+	modes := []LockMode{LOCK_MODE_IS, LOCK_MODE_IX, LOCK_MODE_S, LOCK_MODE_SIX, LOCK_MODE_X}
+	// rows are the requested modes and columns are the held modes, in the order of modes
+	expect := [][]bool{
+		{true, true, true, true, false},
+		{true, true, false, false, false},
+		{true, false, true, false, false},
+		{true, false, false, false, false},
+		{false, false, false, false, false},
+	}
+	for i, requested := range modes {
+		for j, held := range modes {
+			assert.Equal(t, expect[i][j], requested.Compatible(held), "%s requested while %s held", requested, held)
+		}
+		assert.True(t, requested.Compatible(LOCK_MODE_NONE))
+	}
+}
+
+func TestLockMode_Join(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		a, b   LockMode
+		expect LockMode
+	}{
+		{LOCK_MODE_NONE, LOCK_MODE_IS, LOCK_MODE_IS},
+		{LOCK_MODE_IS, LOCK_MODE_IX, LOCK_MODE_IX},
+		{LOCK_MODE_IS, LOCK_MODE_S, LOCK_MODE_S},
+		{LOCK_MODE_IX, LOCK_MODE_S, LOCK_MODE_SIX},
+		{LOCK_MODE_S, LOCK_MODE_IX, LOCK_MODE_SIX},
+		{LOCK_MODE_SIX, LOCK_MODE_IS, LOCK_MODE_SIX},
+		{LOCK_MODE_SIX, LOCK_MODE_X, LOCK_MODE_X},
+		{LOCK_MODE_X, LOCK_MODE_S, LOCK_MODE_X},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expect, tt.a.Join(tt.b), "%s join %s", tt.a, tt.b)
+	}
+}
+
+func TestLockMode_Intention(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, LOCK_MODE_IS, LOCK_MODE_S.Intention())
+	assert.Equal(t, LOCK_MODE_IS, LOCK_MODE_IS.Intention())
+	assert.Equal(t, LOCK_MODE_IX, LOCK_MODE_X.Intention())
+	assert.Equal(t, LOCK_MODE_IX, LOCK_MODE_SIX.Intention())
+	assert.Equal(t, LOCK_MODE_IX, LOCK_MODE_IX.Intention())
+}
@@ -263,3 +263,49 @@ func TestUnlock(t *testing.T) {
 		})
 	}
 }
+
+func TestLockGranule(t *testing.T) {
+	t.Parallel()
+	const (
+		owner1 = 1
+		owner2 = 2
+	)
+	granule := FileGranule("test_lock_granule")
+	t.Run("compatible modes", func(t *testing.T) {
+		t.Parallel()
+		l := NewLock(WithWaitTime(10 * time.Millisecond))
+		assert.NoError(t, l.LockGranule(owner1, granule, LOCK_MODE_IS))
+		assert.NoError(t, l.LockGranule(owner2, granule, LOCK_MODE_IX))
+		assert.NoError(t, l.LockGranule(owner1, granule, LOCK_MODE_IX))
+		assert.Equal(t, LOCK_MODE_IX, l.granules[granule][owner1])
+		// S can not be granted while the other owner holds IX
+		assert.Error(t, l.LockGranule(owner1, granule, LOCK_MODE_S))
+		assert.Equal(t, LOCK_MODE_IX, l.granules[granule][owner1])
+	})
+	t.Run("conflicting modes", func(t *testing.T) {
+		t.Parallel()
+		l := NewLock(WithWaitTime(10 * time.Millisecond))
+		assert.NoError(t, l.LockGranule(owner1, granule, LOCK_MODE_S))
+		assert.Error(t, l.LockGranule(owner2, granule, LOCK_MODE_IX))
+		assert.False(t, l.TryLockGranule(owner2, granule, LOCK_MODE_X))
+		assert.True(t, l.TryLockGranule(owner2, granule, LOCK_MODE_IS))
+
+		// an upgrade to SIX conflicts with the S lock of nobody but the owner itself
+		l.UnlockGranule(owner2, granule)
+		assert.NoError(t, l.LockGranule(owner1, granule, LOCK_MODE_IX))
+		assert.Equal(t, LOCK_MODE_SIX, l.granules[granule][owner1])
+	})
+	t.Run("unlock wakes up waiters", func(t *testing.T) {
+		t.Parallel()
+		l := NewLock(WithWaitTime(time.Second))
+		assert.NoError(t, l.LockGranule(owner1, granule, LOCK_MODE_X))
+		done := make(chan error)
+		go func() {
+			done <- l.LockGranule(owner2, granule, LOCK_MODE_IS)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		l.UnlockGranule(owner1, granule)
+		assert.NoError(t, <-done)
+		assert.Equal(t, map[int]LockMode{owner2: LOCK_MODE_IS}, l.granules[granule])
+	})
+}
@@ -32,6 +32,6 @@ func (r *CheckpointRecord) String() string {
 func WriteCheckpointRecordToLog(lm log.LogMgr) (int, error) {
 	record := make([]byte, OffsetTxNum)
 	p := file.NewPageFromBytes(record)
-	p.SetInt(0, uint32(OP_CHECKPOINT))
+	p.SetInt(0, int32(OP_CHECKPOINT))
 	return lm.Append(record)
 }
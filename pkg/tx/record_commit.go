@@ -39,7 +39,7 @@ func WriteCommitRecordToLog(lm log.LogMgr, txNum int) (int, error) {
 	const txNumSize = 4
 	record := make([]byte, OffsetTxNum+txNumSize)
 	p := file.NewPageFromBytes(record)
-	p.SetInt(0, uint32(OP_COMMIT))
-	p.SetInt(4, uint32(txNum))
+	p.SetInt(0, int32(OP_COMMIT))
+	p.SetInt(4, int32(txNum))
 	return lm.Append(record)
 }
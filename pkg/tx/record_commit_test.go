@@ -13,8 +13,8 @@ func TestNewCommitRecord(t *testing.T) {
 	t.Parallel()
 	const txNum = 1
 	page := file.NewPage(8)
-	page.SetInt(OffsetOp, uint32(OP_COMMIT))
-	page.SetInt(OffsetTxNum, uint32(txNum))
+	page.SetInt(OffsetOp, int32(OP_COMMIT))
+	page.SetInt(OffsetTxNum, int32(txNum))
 
 	record := NewCommitRecord(page)
 
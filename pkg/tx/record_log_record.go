@@ -2,6 +2,7 @@ package tx
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/kj455/simple-db/pkg/file"
 )
@@ -15,8 +16,47 @@ const (
 	OP_ROLLBACK
 	OP_SET_INT
 	OP_SET_STRING
+	OP_SAVEPOINT
+	OP_SET_INT64
+	OP_SET_FLOAT64
+	OP_SET_BOOL
+	OP_SET_TIME
+	OP_SET_BYTES
 )
 
+var ErrUnknownLogRecord = errors.New("tx: unknown log record type")
+
+func (op Op) String() string {
+	switch op {
+	case OP_CHECKPOINT:
+		return "CHECKPOINT"
+	case OP_START:
+		return "START"
+	case OP_COMMIT:
+		return "COMMIT"
+	case OP_ROLLBACK:
+		return "ROLLBACK"
+	case OP_SET_INT:
+		return "SET_INT"
+	case OP_SET_STRING:
+		return "SET_STRING"
+	case OP_SAVEPOINT:
+		return "SAVEPOINT"
+	case OP_SET_INT64:
+		return "SET_INT64"
+	case OP_SET_FLOAT64:
+		return "SET_FLOAT64"
+	case OP_SET_BOOL:
+		return "SET_BOOL"
+	case OP_SET_TIME:
+		return "SET_TIME"
+	case OP_SET_BYTES:
+		return "SET_BYTES"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(op))
+	}
+}
+
 const (
 	OffsetOp    = 0
 	OffsetTxNum = 4
@@ -29,8 +69,14 @@ type LogRecord interface {
 }
 
 func NewLogRecord(bytes []byte) (LogRecord, error) {
+	if len(bytes) < OffsetTxNum {
+		return nil, fmt.Errorf("%w: record of %d bytes", ErrUnknownLogRecord, len(bytes))
+	}
 	p := file.NewPageFromBytes(bytes)
 	op := Op(p.GetInt(OffsetOp))
+	if err := checkLengths(p, op, len(bytes)); err != nil {
+		return nil, fmt.Errorf("tx: corrupt %s log record: %w", op, err)
+	}
 	switch op {
 	case OP_CHECKPOINT:
 		return NewCheckpointRecord(), nil
@@ -44,7 +90,31 @@ func NewLogRecord(bytes []byte) (LogRecord, error) {
 		return NewSetIntRecord(p), nil
 	case OP_SET_STRING:
 		return NewSetStringRecord(p), nil
+	case OP_SAVEPOINT:
+		return NewSavepointRecord(p), nil
+	case OP_SET_INT64, OP_SET_FLOAT64, OP_SET_BOOL, OP_SET_TIME, OP_SET_BYTES:
+		return NewSetValueRecord(p)
 	default:
-		return nil, errors.New("transaction: unknown record type")
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLogRecord, op)
+	}
+}
+
+// checkLengths checks the lengths of the strings and byte strings of a log record of size bytes,
+// which are signed and so may be negative as well as too large if the record is corrupt.
+func checkLengths(p *file.PageImpl, op Op, size int) error {
+	switch op {
+	case OP_SAVEPOINT:
+		return file.CheckBytes(p, offsetSavepointName, size)
+	case OP_SET_INT, OP_SET_STRING, OP_SET_INT64, OP_SET_FLOAT64, OP_SET_BOOL, OP_SET_TIME, OP_SET_BYTES:
+		fnPos := OffsetTxNum + 4
+		if err := file.CheckBytes(p, fnPos, size); err != nil {
+			return err
+		}
+		if op != OP_SET_STRING && op != OP_SET_BYTES {
+			return nil
+		}
+		// the value follows the filename, the block number and the offset
+		return file.CheckBytes(p, fnPos+file.MaxLength(int(p.GetInt(fnPos)))+8, size)
 	}
+	return nil
 }
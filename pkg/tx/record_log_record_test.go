@@ -1,6 +1,7 @@
 package tx
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/kj455/simple-db/pkg/file"
@@ -20,7 +21,7 @@ func TestNewLogRecord(t *testing.T) {
 			name: "CHECKPOINT",
 			args: func() []byte {
 				p := file.NewPage(size)
-				p.SetInt(0, uint32(OP_CHECKPOINT))
+				p.SetInt(0, int32(OP_CHECKPOINT))
 				return p.Contents().Bytes()
 			}(),
 			expect: OP_CHECKPOINT,
@@ -29,7 +30,7 @@ func TestNewLogRecord(t *testing.T) {
 			name: "START",
 			args: func() []byte {
 				p := file.NewPage(size)
-				p.SetInt(0, uint32(OP_START))
+				p.SetInt(0, int32(OP_START))
 				return p.Contents().Bytes()
 			}(),
 			expect: OP_START,
@@ -38,7 +39,7 @@ func TestNewLogRecord(t *testing.T) {
 			name: "COMMIT",
 			args: func() []byte {
 				p := file.NewPage(size)
-				p.SetInt(0, uint32(OP_COMMIT))
+				p.SetInt(0, int32(OP_COMMIT))
 				return p.Contents().Bytes()
 			}(),
 			expect: OP_COMMIT,
@@ -47,7 +48,7 @@ func TestNewLogRecord(t *testing.T) {
 			name: "ROLLBACK",
 			args: func() []byte {
 				p := file.NewPage(size)
-				p.SetInt(0, uint32(OP_ROLLBACK))
+				p.SetInt(0, int32(OP_ROLLBACK))
 				return p.Contents().Bytes()
 			}(),
 			expect: OP_ROLLBACK,
@@ -56,7 +57,7 @@ func TestNewLogRecord(t *testing.T) {
 			name: "SET_INT",
 			args: func() []byte {
 				p := file.NewPage(size)
-				p.SetInt(0, uint32(OP_SET_INT))
+				p.SetInt(0, int32(OP_SET_INT))
 				return p.Contents().Bytes()
 			}(),
 			expect: OP_SET_INT,
@@ -65,30 +66,73 @@ func TestNewLogRecord(t *testing.T) {
 			name: "SET_STRING",
 			args: func() []byte {
 				p := file.NewPage(size)
-				p.SetInt(0, uint32(OP_SET_STRING))
+				p.SetInt(0, int32(OP_SET_STRING))
 				return p.Contents().Bytes()
 			}(),
 			expect: OP_SET_STRING,
 		},
+		{
+			name: "SAVEPOINT",
+			args: func() []byte {
+				p := file.NewPage(size)
+				p.SetInt(0, int32(OP_SAVEPOINT))
+				return p.Contents().Bytes()
+			}(),
+			expect: OP_SAVEPOINT,
+		},
 		{
 			name: "default",
 			args: func() []byte {
 				p := file.NewPage(size)
-				p.SetInt(0, uint32(100))
+				p.SetInt(0, int32(100))
 				return p.Contents().Bytes()
 			}(),
 			expectErr: true,
 		},
+		{
+			name:      "too short",
+			args:      []byte{0, 0},
+			expectErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			got, err := NewLogRecord(tt.args)
 			if tt.expectErr {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrUnknownLogRecord)
 				return
 			}
 			assert.Equal(t, tt.expect, got.Op())
 		})
 	}
 }
+
+func TestNewLogRecord_BadLength(t *testing.T) {
+	t.Parallel()
+	const size = 128
+	tests := []struct {
+		name   string
+		op     Op
+		offset int
+	}{
+		{name: "SET_INT filename", op: OP_SET_INT, offset: OffsetTxNum + 4},
+		{name: "SET_BYTES filename", op: OP_SET_BYTES, offset: OffsetTxNum + 4},
+		{name: "SET_STRING value", op: OP_SET_STRING, offset: OffsetTxNum + 4 + file.MaxLength(0) + 8},
+		{name: "SAVEPOINT name", op: OP_SAVEPOINT, offset: offsetSavepointName},
+	}
+	for _, tt := range tests {
+		for _, length := range []int32{-8, size} {
+			t.Run(fmt.Sprintf("%s %d", tt.name, length), func(t *testing.T) {
+				t.Parallel()
+				p := file.NewPage(size)
+				p.SetInt(OffsetOp, int32(tt.op))
+				p.SetInt(tt.offset, length)
+
+				_, err := NewLogRecord(p.Contents().Bytes())
+
+				assert.ErrorIs(t, err, file.ErrBadLength)
+			})
+		}
+	}
+}
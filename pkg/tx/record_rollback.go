@@ -40,7 +40,7 @@ func WriteRollbackRecordToLog(lm log.LogMgr, txNum int) (int, error) {
 	length := OffsetTxNum + txNumSize
 	record := make([]byte, length)
 	p := file.NewPageFromBytes(record)
-	p.SetInt(0, uint32(OP_ROLLBACK))
-	p.SetInt(OffsetTxNum, uint32(txNum))
+	p.SetInt(0, int32(OP_ROLLBACK))
+	p.SetInt(OffsetTxNum, int32(txNum))
 	return lm.Append(record)
 }
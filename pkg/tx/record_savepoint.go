@@ -0,0 +1,69 @@
+package tx
+
+import (
+	"fmt"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/log"
+)
+
+/*
+----------------------------------
+|  0  |   4   |  8  |  12        |
+----------------------------------
+| op  | txNum | id  | name       |
+----------------------------------
+*/
+type SavepointRecord struct {
+	txNum int
+	id    int
+	name  string
+}
+
+const (
+	offsetSavepointId   = OffsetTxNum + 4
+	offsetSavepointName = offsetSavepointId + 4
+)
+
+func NewSavepointRecord(p file.Page) *SavepointRecord {
+	return &SavepointRecord{
+		txNum: int(p.GetInt(OffsetTxNum)),
+		id:    int(p.GetInt(offsetSavepointId)),
+		name:  p.GetString(offsetSavepointName),
+	}
+}
+
+func (r *SavepointRecord) Op() Op {
+	return OP_SAVEPOINT
+}
+
+func (r *SavepointRecord) TxNum() int {
+	return r.txNum
+}
+
+// Id distinguishes savepoints of a transaction that share the same name.
+func (r *SavepointRecord) Id() int {
+	return r.id
+}
+
+func (r *SavepointRecord) Name() string {
+	return r.name
+}
+
+func (r *SavepointRecord) Undo(tx Transaction) error {
+	return nil
+}
+
+func (r *SavepointRecord) String() string {
+	return fmt.Sprintf("<SAVEPOINT %d %d %s>", r.txNum, r.id, r.name)
+}
+
+func WriteSavepointRecordToLog(lm log.LogMgr, txNum, id int, name string) (int, error) {
+	record := make([]byte, offsetSavepointName+file.MaxLength(len(name)))
+	p := file.NewPageFromBytes(record)
+	p.SetInt(OffsetOp, int32(OP_SAVEPOINT))
+	p.SetInt(OffsetTxNum, int32(txNum))
+	p.SetInt(offsetSavepointId, int32(id))
+	p.SetString(offsetSavepointName, name)
+	return lm.Append(record)
+}
@@ -0,0 +1,53 @@
+package tx
+
+import (
+	"testing"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/log"
+	"github.com/kj455/simple-db/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSavepointRecord(t *testing.T) {
+	t.Parallel()
+	page := file.NewPage(64)
+	page.SetInt(OffsetOp, int32(OP_SAVEPOINT))
+	page.SetInt(OffsetTxNum, 1)
+	page.SetInt(offsetSavepointId, 2)
+	page.SetString(offsetSavepointName, "sp")
+
+	record := NewSavepointRecord(page)
+
+	assert.Equal(t, OP_SAVEPOINT, record.Op())
+	assert.Equal(t, 1, record.TxNum())
+	assert.Equal(t, 2, record.Id())
+	assert.Equal(t, "sp", record.Name())
+	assert.NoError(t, record.Undo(nil))
+	assert.Equal(t, "<SAVEPOINT 1 2 sp>", record.String())
+}
+
+func TestWriteSavepointRecordToLog(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize = 400
+		fileName  = "file"
+	)
+	dir, cleanup := testutil.SetupDir("test_write_savepoint_record_to_log")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fileMgr, fileName)
+	assert.NoError(t, err)
+
+	lsn, err := WriteSavepointRecordToLog(lm, 1, 2, "sp")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, lsn)
+	iter, err := lm.Iterator()
+	assert.NoError(t, err)
+	bytes, err := iter.Next()
+	assert.NoError(t, err)
+	rec, err := NewLogRecord(bytes)
+	assert.NoError(t, err)
+	assert.Equal(t, "<SAVEPOINT 1 2 sp>", rec.(*SavepointRecord).String())
+}
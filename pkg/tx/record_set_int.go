@@ -50,6 +50,20 @@ func (r *SetIntRecord) TxNum() int {
 	return r.txNum
 }
 
+// Block returns the block the record modified.
+func (r *SetIntRecord) Block() file.BlockId {
+	return r.block
+}
+
+func (r *SetIntRecord) Offset() int {
+	return r.offset
+}
+
+// Value returns the value at the offset before the modification.
+func (r *SetIntRecord) Value() int {
+	return r.val
+}
+
 func (r *SetIntRecord) Undo(tx Transaction) error {
 	if err := tx.Pin(r.block); err != nil {
 		return err
@@ -73,11 +87,11 @@ func WriteSetIntRecordToLog(lm log.LogMgr, txNum int, block file.BlockId, offset
 	valPos := offPos + 4
 	rec := make([]byte, valPos+4)
 	p := file.NewPageFromBytes(rec)
-	p.SetInt(0, uint32(OP_SET_INT))
-	p.SetInt(tpos, uint32(txNum))
+	p.SetInt(0, int32(OP_SET_INT))
+	p.SetInt(tpos, int32(txNum))
 	p.SetString(fnPos, block.Filename())
-	p.SetInt(bnPos, uint32(block.Number()))
-	p.SetInt(offPos, uint32(offset))
-	p.SetInt(valPos, uint32(val))
+	p.SetInt(bnPos, int32(block.Number()))
+	p.SetInt(offPos, int32(offset))
+	p.SetInt(valPos, int32(val))
 	return lm.Append(rec)
 }
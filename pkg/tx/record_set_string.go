@@ -50,6 +50,20 @@ func (r *SetStringRecord) TxNum() int {
 	return r.txNum
 }
 
+// Block returns the block the record modified.
+func (r *SetStringRecord) Block() file.BlockId {
+	return r.block
+}
+
+func (r *SetStringRecord) Offset() int {
+	return r.offset
+}
+
+// Value returns the value at the offset before the modification.
+func (r *SetStringRecord) Value() string {
+	return r.val
+}
+
 func (r *SetStringRecord) Undo(tx Transaction) error {
 	if err := tx.Pin(r.block); err != nil {
 		return err
@@ -75,11 +89,11 @@ func WriteSetStringRecordToLog(lm log.LogMgr, txNum int, block file.BlockId, off
 	recordLen := vpos + file.MaxLength(len(val))
 	record := make([]byte, recordLen)
 	p := file.NewPageFromBytes(record)
-	p.SetInt(0, uint32(OP_SET_STRING))
-	p.SetInt(tpos, uint32(txNum))
+	p.SetInt(0, int32(OP_SET_STRING))
+	p.SetInt(tpos, int32(txNum))
 	p.SetString(fpos, block.Filename())
-	p.SetInt(bpos, uint32(block.Number()))
-	p.SetInt(opos, uint32(offset))
+	p.SetInt(bpos, int32(block.Number()))
+	p.SetInt(opos, int32(offset))
 	p.SetString(vpos, val)
 	return lm.Append(record)
 }
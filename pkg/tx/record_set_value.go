@@ -0,0 +1,165 @@
+package tx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/log"
+)
+
+/*
+SetValueRecord is the log record of a change to a value of the types added after int and string.
+The op tells the type of the value, and so how it is stored:
+------------------------------------------------
+|   0  |   4   |  8   | n     | n+4    | n+8   |
+------------------------------------------------
+|  op  | txNum | file | block | offset | value |
+------------------------------------------------
+The value takes 8 bytes for OP_SET_INT64, OP_SET_FLOAT64 and OP_SET_TIME, 1 byte for OP_SET_BOOL,
+and a length followed by the bytes for OP_SET_BYTES.
+*/
+type SetValueRecord struct {
+	op     Op
+	txNum  int
+	offset int
+	val    any
+	block  file.BlockId
+}
+
+func NewSetValueRecord(p file.Page) (*SetValueRecord, error) {
+	const byteSize = 4
+	op := Op(p.GetInt(OffsetOp))
+	txNum := p.GetInt(OffsetTxNum)
+	fnPos := OffsetTxNum + byteSize
+	filename := p.GetString(fnPos)
+	bnPos := fnPos + file.MaxLength(len(filename))
+	blockNum := p.GetInt(bnPos)
+	offPos := bnPos + byteSize
+	offset := p.GetInt(offPos)
+	valPos := offPos + byteSize
+	var val any
+	switch op {
+	case OP_SET_INT64:
+		val = p.GetInt64(valPos)
+	case OP_SET_FLOAT64:
+		val = p.GetFloat64(valPos)
+	case OP_SET_BOOL:
+		val = p.GetBool(valPos)
+	case OP_SET_TIME:
+		val = p.GetTime(valPos)
+	case OP_SET_BYTES:
+		val = p.GetBytes(valPos)
+	default:
+		return nil, fmt.Errorf("%w: %s is not a set value record", ErrUnknownLogRecord, op)
+	}
+	return &SetValueRecord{
+		op:     op,
+		txNum:  int(txNum),
+		offset: int(offset),
+		val:    val,
+		block:  file.NewBlockId(filename, int(blockNum)),
+	}, nil
+}
+
+func (r *SetValueRecord) Op() Op {
+	return r.op
+}
+
+func (r *SetValueRecord) TxNum() int {
+	return r.txNum
+}
+
+// Block returns the block the record modified.
+func (r *SetValueRecord) Block() file.BlockId {
+	return r.block
+}
+
+func (r *SetValueRecord) Offset() int {
+	return r.offset
+}
+
+// Value returns the value at the offset before the modification: an int64, float64, bool, time.Time or []byte, by the op.
+func (r *SetValueRecord) Value() any {
+	return r.val
+}
+
+func (r *SetValueRecord) Undo(tx Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	// don't log the undo
+	var err error
+	switch val := r.val.(type) {
+	case int64:
+		err = tx.SetInt64(r.block, r.offset, val, false)
+	case float64:
+		err = tx.SetFloat64(r.block, r.offset, val, false)
+	case bool:
+		err = tx.SetBool(r.block, r.offset, val, false)
+	case time.Time:
+		err = tx.SetTime(r.block, r.offset, val, false)
+	case []byte:
+		err = tx.SetBytes(r.block, r.offset, val, false)
+	}
+	if err != nil {
+		return err
+	}
+	tx.Unpin(r.block)
+	return nil
+}
+
+func (r *SetValueRecord) String() string {
+	return fmt.Sprintf("<%s %d %s %d %v>", r.op, r.txNum, r.block, r.offset, r.val)
+}
+
+// WriteSetValueRecordToLog logs val, whose type decides the op of the record.
+func WriteSetValueRecordToLog(lm log.LogMgr, txNum int, block file.BlockId, offset int, val any) (int, error) {
+	op, valLen, err := setValueOp(val)
+	if err != nil {
+		return 0, err
+	}
+	tpos := OffsetTxNum
+	fpos := tpos + 4
+	bpos := fpos + file.MaxLength(len(block.Filename()))
+	opos := bpos + 4
+	vpos := opos + 4
+	rec := make([]byte, vpos+valLen)
+	p := file.NewPageFromBytes(rec)
+	p.SetInt(0, int32(op))
+	p.SetInt(tpos, int32(txNum))
+	p.SetString(fpos, block.Filename())
+	p.SetInt(bpos, int32(block.Number()))
+	p.SetInt(opos, int32(offset))
+	switch val := val.(type) {
+	case int64:
+		p.SetInt64(vpos, val)
+	case float64:
+		p.SetFloat64(vpos, val)
+	case bool:
+		p.SetBool(vpos, val)
+	case time.Time:
+		p.SetTime(vpos, val)
+	case []byte:
+		p.SetBytes(vpos, val)
+	}
+	return lm.Append(rec)
+}
+
+// setValueOp returns the op of the record logging val, and the number of bytes val takes in it.
+func setValueOp(val any) (Op, int, error) {
+	switch val := val.(type) {
+	case int64:
+		return OP_SET_INT64, 8, nil
+	case float64:
+		return OP_SET_FLOAT64, 8, nil
+	case bool:
+		return OP_SET_BOOL, 1, nil
+	case time.Time:
+		return OP_SET_TIME, 8, nil
+	case []byte:
+		return OP_SET_BYTES, file.MaxBytesLength(len(val)), nil
+	default:
+		return 0, 0, fmt.Errorf("tx: unsupported value type %T", val)
+	}
+}
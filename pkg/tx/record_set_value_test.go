@@ -0,0 +1,65 @@
+package tx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/log"
+	"github.com/kj455/simple-db/pkg/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteSetValueRecordToLog(t *testing.T) {
+	t.Parallel()
+	const (
+		txNum        = 1
+		filename     = "filename"
+		blockNum     = 2
+		offset       = 3
+		testFileName = "file"
+		blockSize    = 400
+	)
+	dir, cleanup := testutil.SetupDir("test_write_set_value_record_to_log")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fileMgr, testFileName)
+	assert.NoError(t, err)
+	block := file.NewBlockId(filename, blockNum)
+	tests := []struct {
+		val any
+		op  Op
+	}{
+		{val: int64(-1 << 40), op: OP_SET_INT64},
+		{val: 3.25, op: OP_SET_FLOAT64},
+		{val: true, op: OP_SET_BOOL},
+		{val: time.Date(2024, 2, 29, 13, 4, 5, 123456000, time.UTC), op: OP_SET_TIME},
+		{val: []byte{0, 1, 2}, op: OP_SET_BYTES},
+	}
+	for _, tt := range tests {
+		_, err := WriteSetValueRecordToLog(lm, txNum, block, offset, tt.val)
+		assert.NoError(t, err)
+	}
+	_, err = WriteSetValueRecordToLog(lm, txNum, block, offset, "string")
+	assert.Error(t, err)
+
+	iter, err := lm.Iterator()
+	assert.NoError(t, err)
+	// the log is read from the newest record
+	for i := len(tests) - 1; i >= 0; i-- {
+		assert.True(t, iter.HasNext())
+		bytes, err := iter.Next()
+		assert.NoError(t, err)
+		rec, err := NewLogRecord(bytes)
+		assert.NoError(t, err)
+		setValueRecord, ok := rec.(*SetValueRecord)
+		assert.True(t, ok)
+		assert.Equal(t, tests[i].op, setValueRecord.Op())
+		assert.Equal(t, txNum, setValueRecord.TxNum())
+		assert.Equal(t, filename, setValueRecord.Block().Filename())
+		assert.Equal(t, blockNum, setValueRecord.Block().Number())
+		assert.Equal(t, offset, setValueRecord.Offset())
+		assert.Equal(t, tests[i].val, setValueRecord.Value())
+	}
+	assert.False(t, iter.HasNext())
+}
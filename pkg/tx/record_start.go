@@ -39,7 +39,7 @@ func WriteStartRecordToLog(lm log.LogMgr, txNum int) (lsn int, err error) {
 	const txNumSize = 4
 	record := make([]byte, OffsetTxNum+txNumSize)
 	p := file.NewPageFromBytes(record)
-	p.SetInt(OffsetOp, uint32(OP_START))
-	p.SetInt(OffsetTxNum, uint32(txNum))
+	p.SetInt(OffsetOp, int32(OP_START))
+	p.SetInt(OffsetTxNum, int32(txNum))
 	return lm.Append(record)
 }
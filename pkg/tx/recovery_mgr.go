@@ -1,6 +1,7 @@
 package tx
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/kj455/simple-db/pkg/buffer"
@@ -8,12 +9,22 @@ import (
 )
 
 type RecoveryMgrImpl struct {
-	logMgr log.LogMgr
-	bufMgr buffer.BufferMgr
-	tx     Transaction
-	txNum  int
+	logMgr     log.LogMgr
+	bufMgr     buffer.BufferMgr
+	tx         Transaction
+	txNum      int
+	savepoints []savepoint
+	nextSpId   int
 }
 
+// savepoint is an active savepoint of the transaction, newest last.
+type savepoint struct {
+	id   int
+	name string
+}
+
+var ErrSavepointNotFound = errors.New("recovery: savepoint not found")
+
 func NewRecoveryMgr(tx Transaction, txNum int, lm log.LogMgr, bm buffer.BufferMgr) (*RecoveryMgrImpl, error) {
 	rm := &RecoveryMgrImpl{
 		logMgr: lm,
@@ -30,6 +41,7 @@ func NewRecoveryMgr(tx Transaction, txNum int, lm log.LogMgr, bm buffer.BufferMg
 
 // Commit writes a commit record to the log and flushes the buffer
 func (rm *RecoveryMgrImpl) Commit() error {
+	rm.savepoints = nil
 	err := rm.bufMgr.FlushAll(rm.txNum)
 	if err != nil {
 		return fmt.Errorf("recovery: failed to flush buffer: %v", err)
@@ -61,7 +73,7 @@ func (rm *RecoveryMgrImpl) Rollback() error {
 	return nil
 }
 
-// Recover recovers modifications made by uncommitted transactions
+// Recover recovers modifications made by uncommitted transactions, then writes a checkpoint and truncates the log.
 func (rm *RecoveryMgrImpl) Recover() error {
 	if err := rm.recover(); err != nil {
 		return fmt.Errorf("recovery: failed to recover: %v", err)
@@ -69,14 +81,72 @@ func (rm *RecoveryMgrImpl) Recover() error {
 	if err := rm.bufMgr.FlushAll(rm.txNum); err != nil {
 		return fmt.Errorf("recovery: failed to flush buffer: %v", err)
 	}
-	lsn, err := WriteCommitRecordToLog(rm.logMgr, rm.txNum)
-	if err != nil {
+	if _, err := WriteCommitRecordToLog(rm.logMgr, rm.txNum); err != nil {
 		return fmt.Errorf("recovery: failed to write commit record to log: %v", err)
 	}
-	rm.logMgr.Flush(lsn)
+	// no other transaction is running during recovery, so the checkpoint is quiescent and the older records are not needed
+	lsn, err := WriteCheckpointRecordToLog(rm.logMgr)
+	if err != nil {
+		return fmt.Errorf("recovery: failed to write checkpoint record to log: %v", err)
+	}
+	if err := rm.logMgr.Flush(lsn); err != nil {
+		return fmt.Errorf("recovery: failed to flush log: %v", err)
+	}
+	if err := rm.logMgr.Truncate(lsn); err != nil {
+		return fmt.Errorf("recovery: failed to truncate log: %v", err)
+	}
+	return nil
+}
+
+// Savepoint writes a savepoint record to the log so that the changes made after it can be undone by RollbackToSavepoint.
+// A savepoint with the same name as an existing one hides the older one until it is released.
+func (rm *RecoveryMgrImpl) Savepoint(name string) error {
+	rm.nextSpId++
+	if _, err := WriteSavepointRecordToLog(rm.logMgr, rm.txNum, rm.nextSpId, name); err != nil {
+		return fmt.Errorf("recovery: failed to write savepoint record to log: %v", err)
+	}
+	rm.savepoints = append(rm.savepoints, savepoint{id: rm.nextSpId, name: name})
 	return nil
 }
 
+// RollbackToSavepoint undoes the changes logged after the named savepoint.
+// The savepoint stays active while the savepoints created after it are destroyed.
+func (rm *RecoveryMgrImpl) RollbackToSavepoint(name string) error {
+	idx, ok := rm.findSavepoint(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSavepointNotFound, name)
+	}
+	sp := rm.savepoints[idx]
+	err := rm.undoUntil(func(rec LogRecord) bool {
+		spRec, ok := rec.(*SavepointRecord)
+		return ok && spRec.Id() == sp.id
+	})
+	if err != nil {
+		return fmt.Errorf("recovery: failed to rollback to savepoint %s: %v", name, err)
+	}
+	rm.savepoints = rm.savepoints[:idx+1]
+	return nil
+}
+
+// ReleaseSavepoint destroys the named savepoint and the savepoints created after it, keeping their changes.
+func (rm *RecoveryMgrImpl) ReleaseSavepoint(name string) error {
+	idx, ok := rm.findSavepoint(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrSavepointNotFound, name)
+	}
+	rm.savepoints = rm.savepoints[:idx]
+	return nil
+}
+
+func (rm *RecoveryMgrImpl) findSavepoint(name string) (int, bool) {
+	for i := len(rm.savepoints) - 1; i >= 0; i-- {
+		if rm.savepoints[i].name == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 // SetInt writes old value to log
 func (rm *RecoveryMgrImpl) SetInt(buff buffer.Buffer, offset int, oldVal int) (int, error) {
 	return WriteSetIntRecordToLog(rm.logMgr, rm.txNum, buff.Block(), offset, int(oldVal))
@@ -87,8 +157,21 @@ func (rm *RecoveryMgrImpl) SetString(buff buffer.Buffer, offset int, oldVal stri
 	return WriteSetStringRecordToLog(rm.logMgr, rm.txNum, buff.Block(), offset, oldVal)
 }
 
+// SetValue writes old value, of one of the types of SetValueRecord, to log
+func (rm *RecoveryMgrImpl) SetValue(buff buffer.Buffer, offset int, oldVal any) (int, error) {
+	return WriteSetValueRecordToLog(rm.logMgr, rm.txNum, buff.Block(), offset, oldVal)
+}
+
 // rollback iterates through the log records. Each time it finds a log record for that transaction, it calls the record’s undo method. It stops when it encounters the start record for that transaction.
 func (rm *RecoveryMgrImpl) rollback() error {
+	rm.savepoints = nil
+	return rm.undoUntil(func(rec LogRecord) bool {
+		return rec.Op() == OP_START
+	})
+}
+
+// undoUntil undoes the log records of the transaction from the newest one until stop returns true.
+func (rm *RecoveryMgrImpl) undoUntil(stop func(rec LogRecord) bool) error {
 	iter, err := rm.logMgr.Iterator()
 	if err != nil {
 		return err
@@ -105,7 +188,7 @@ func (rm *RecoveryMgrImpl) rollback() error {
 		if rec.TxNum() != rm.txNum {
 			continue
 		}
-		if rec.Op() == OP_START {
+		if stop(rec) {
 			return nil
 		}
 		if err := rec.Undo(rm.tx); err != nil {
@@ -1,10 +1,12 @@
 package tx
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/kj455/simple-db/pkg/buffer"
 	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/file/filetest"
 	"github.com/kj455/simple-db/pkg/log"
 	"github.com/kj455/simple-db/pkg/testutil"
 	"github.com/stretchr/testify/assert"
@@ -38,7 +40,7 @@ func TestRecoveryMgr_Rollback(t *testing.T) {
 	assert.Equal(t, OP_SET_INT, recs[4].Op())
 	assert.Equal(t, OP_START, recs[5].Op())
 
-	assert.Equal(t, uint32(1), buf.Contents().GetInt(100))
+	assert.Equal(t, int32(1), buf.Contents().GetInt(100))
 	assert.Equal(t, "test", buf.Contents().GetString(200))
 }
 
@@ -65,14 +67,15 @@ func TestRecoveryMgr_Recover(t *testing.T) {
 	assert.NoError(t, err)
 	recs := newLogRecordsFromIter(iter)
 
-	assert.Equal(t, OP_COMMIT, recs[0].Op())
-	assert.Equal(t, OP_SET_INT, recs[1].Op())
-	assert.Equal(t, OP_CHECKPOINT, recs[2].Op())
-	assert.Equal(t, OP_COMMIT, recs[3].Op())
-	assert.Equal(t, OP_SET_STRING, recs[4].Op())
-	assert.Equal(t, OP_SET_INT, recs[5].Op())
+	assert.Equal(t, OP_CHECKPOINT, recs[0].Op())
+	assert.Equal(t, OP_COMMIT, recs[1].Op())
+	assert.Equal(t, OP_SET_INT, recs[2].Op())
+	assert.Equal(t, OP_CHECKPOINT, recs[3].Op())
+	assert.Equal(t, OP_COMMIT, recs[4].Op())
+	assert.Equal(t, OP_SET_STRING, recs[5].Op())
+	assert.Equal(t, OP_SET_INT, recs[6].Op())
 
-	assert.Equal(t, uint32(2), buf.Contents().GetInt(100))
+	assert.Equal(t, int32(2), buf.Contents().GetInt(100))
 	assert.Equal(t, "", buf.Contents().GetString(200))
 }
 
@@ -106,3 +109,109 @@ func newLogRecordsFromIter(iter log.LogIterator) []LogRecord {
 	}
 	return recs
 }
+
+func TestRecoveryMgr_CrashDurability(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		logFileName = "log"
+		dataFile    = "data"
+	)
+	block := file.NewBlockId(dataFile, 0)
+	restart := func(fm file.FileMgr) (log.LogMgr, buffer.BufferMgr) {
+		lm, err := log.NewLogMgr(fm, logFileName)
+		assert.NoError(t, err)
+		bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize), buffer.NewBuffer(fm, lm, blockSize)})
+		return lm, bm
+	}
+	readAfterRecovery := func(fm file.FileMgr, lm log.LogMgr, bm buffer.BufferMgr) int {
+		txNumGen := NewTxNumberGenerator()
+		tx, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Recover())
+		reader, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, reader.Pin(block))
+		val, err := reader.GetInt(block, 0)
+		assert.NoError(t, err)
+		assert.NoError(t, reader.Commit())
+		return val
+	}
+
+	t.Run("committed changes survive a crash", func(t *testing.T) {
+		t.Parallel()
+		fm := filetest.NewFaultyFileMgr(blockSize)
+		lm, bm := restart(fm)
+		txNumGen := NewTxNumberGenerator()
+		committed, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, committed.Pin(block))
+		assert.NoError(t, committed.SetInt(block, 0, 1, true))
+		fm.ResetOps()
+		assert.NoError(t, committed.Commit())
+
+		// the data block is synced before the commit record is written
+		ops := fm.Ops()
+		dataSync := slices.Index(ops, filetest.Op{Kind: filetest.OP_KIND_SYNC, Filename: dataFile, Block: -1})
+		lastLogWrite := -1
+		for i, op := range ops {
+			if op.Kind == filetest.OP_KIND_WRITE && op.Filename == logFileName {
+				lastLogWrite = i
+			}
+		}
+		assert.GreaterOrEqual(t, dataSync, 0)
+		assert.Less(t, dataSync, lastLogWrite)
+		assert.Equal(t, filetest.Op{Kind: filetest.OP_KIND_SYNC, Filename: logFileName, Block: -1}, ops[len(ops)-1])
+
+		uncommitted, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, uncommitted.Pin(block))
+		assert.NoError(t, uncommitted.SetInt(block, 0, 2, true))
+		// the buffer is written out, with its log records, before the transaction ends
+		assert.NoError(t, bm.FlushAll(uncommitted.txNum))
+
+		fm.Crash()
+		lm, bm = restart(fm)
+		assert.Equal(t, 1, readAfterRecovery(fm, lm, bm))
+	})
+	t.Run("failed sync of the data fails the commit", func(t *testing.T) {
+		t.Parallel()
+		fm := filetest.NewFaultyFileMgr(blockSize)
+		lm, bm := restart(fm)
+		tx, err := NewTransaction(fm, lm, bm, NewTxNumberGenerator())
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Pin(block))
+		assert.NoError(t, tx.SetInt(block, 0, 1, true))
+		fm.InjectFault(filetest.FailOn(filetest.OP_KIND_SYNC, dataFile))
+
+		assert.ErrorContains(t, tx.Commit(), filetest.ErrInjected.Error())
+
+		fm.Crash()
+		lm, bm = restart(fm)
+		assert.Equal(t, 0, readAfterRecovery(fm, lm, bm))
+	})
+	t.Run("torn log tail is dropped", func(t *testing.T) {
+		t.Parallel()
+		fm := filetest.NewFaultyFileMgr(blockSize)
+		lm, bm := restart(fm)
+		tx, err := NewTransaction(fm, lm, bm, NewTxNumberGenerator())
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Pin(block))
+		assert.NoError(t, tx.SetInt(block, 0, 1, true))
+		assert.NoError(t, tx.Commit())
+
+		// tear the newest record of the log, the commit record, as a crash during its write would
+		blockNum, err := fm.BlockNum(logFileName)
+		assert.NoError(t, err)
+		tail := file.NewBlockId(logFileName, blockNum-1)
+		page := file.NewPage(blockSize)
+		assert.NoError(t, fm.Read(tail, page))
+		page.Contents().Bytes()[int(page.GetInt(0))+log.OFFSET_SIZE+log.RECORD_HEADER_SIZE] ^= 0xff
+		assert.NoError(t, fm.Write(tail, page))
+		assert.NoError(t, fm.Sync(logFileName))
+
+		fm.Crash()
+		lm, bm = restart(fm)
+		assert.Equal(t, 0, readAfterRecovery(fm, lm, bm))
+	})
+}
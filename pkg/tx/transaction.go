@@ -1,7 +1,11 @@
 package tx
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"time"
 
 	"github.com/kj455/simple-db/pkg/buffer"
 	"github.com/kj455/simple-db/pkg/file"
@@ -15,13 +19,90 @@ type TransactionImpl struct {
 	bm          buffer.BufferMgr
 	fm          file.FileMgr
 	txNum       int
+	readOnly    bool
+	// started tells whether the transaction has accessed a file
+	started bool
+	// removals are the files to remove or truncate once the transaction commits, and commitActions the functions to call then;
+	// removalMarks holds the number of both at each active savepoint
+	removals      []removal
+	commitActions []func() error
+	removalMarks  []removalMark
+	// endActions are the functions to call once the transaction has ended, whichever way
+	endActions []func()
+	// shared are the blocks the transaction has written with WriteShared
+	shared []file.BlockId
+}
+
+// removal is a file to remove, or to truncate to its first blocks if blocks is not negative.
+type removal struct {
+	filename string
+	blocks   int
+}
+
+type removalMark struct {
+	savepoint string
+	n         int
+	actions   int
 }
 
 const END_OF_FILE = -1
 
-func NewTransaction(fm file.FileMgr, lm log.LogMgr, bm buffer.BufferMgr, txNumGen TxNumberGenerator) (*TransactionImpl, error) {
+var ErrIntOutOfRange = errors.New("tx: integer out of the 32-bit range")
+
+type transactionConfig struct {
+	lockTable           Lock
+	isolation           IsolationLevel
+	readOnly            bool
+	escalationThreshold *int
+}
+
+type TransactionOption func(*transactionConfig)
+
+// WithTxLockTable makes the transaction share the lock table with other transactions created with the same table.
+func WithTxLockTable(l Lock) TransactionOption {
+	return func(c *transactionConfig) {
+		c.lockTable = l
+	}
+}
+
+func WithIsolationLevel(level IsolationLevel) TransactionOption {
+	return func(c *transactionConfig) {
+		c.isolation = level
+	}
+}
+
+func WithReadOnly(readOnly bool) TransactionOption {
+	return func(c *transactionConfig) {
+		c.readOnly = readOnly
+	}
+}
+
+// WithTxEscalationThreshold sets the number of block locks on a file above which they are escalated to a file lock.
+func WithTxEscalationThreshold(n int) TransactionOption {
+	return func(c *transactionConfig) {
+		c.escalationThreshold = &n
+	}
+}
+
+func NewTransaction(fm file.FileMgr, lm log.LogMgr, bm buffer.BufferMgr, txNumGen TxNumberGenerator, opts ...TransactionOption) (*TransactionImpl, error) {
+	cfg := &transactionConfig{
+		isolation: DEFAULT_ISOLATION_LEVEL,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if !cfg.isolation.Valid() {
+		return nil, ErrUnknownIsolationLevel
+	}
 	txNum := txNumGen.Next()
-	cm := NewConcurrencyMgr()
+	cmOpts := []ConcurrencyMgrOption{WithIsolation(cfg.isolation)}
+	if cfg.lockTable != nil {
+		cmOpts = append(cmOpts, WithLockTable(cfg.lockTable))
+	}
+	if cfg.escalationThreshold != nil {
+		cmOpts = append(cmOpts, WithEscalationThreshold(*cfg.escalationThreshold))
+	}
+	cm := NewConcurrencyMgr(cmOpts...)
 	rm, err := NewRecoveryMgr(nil, txNum, lm, bm)
 	if err != nil {
 		return nil, fmt.Errorf("tx: failed to create recovery manager: %w", err)
@@ -32,30 +113,117 @@ func NewTransaction(fm file.FileMgr, lm log.LogMgr, bm buffer.BufferMgr, txNumGe
 		recoveryMgr: rm,
 		concurMgr:   cm,
 		txNum:       txNum,
+		readOnly:    cfg.readOnly,
 		buffs:       NewBufferList(bm),
 	}
 	rm.tx = tx
 	return tx, nil
 }
 
+/*
+Commit commits the transaction, then calls the functions passed to OnCommit, removes or truncates the files passed to
+RemoveFileOnCommit and TruncateFileOnCommit, and releases the locks.
+The shared blocks the transaction has written are on disk before its commit record, so that no committed change depends on a lost one.
+The files are removed after the commit record is on disk; a crash in between leaves them on disk, unused.
+*/
 func (t *TransactionImpl) Commit() error {
+	if err := t.flushShared(); err != nil {
+		return fmt.Errorf("tx: failed to commit: %w", err)
+	}
 	if err := t.recoveryMgr.Commit(); err != nil {
 		return fmt.Errorf("tx: failed to commit: %w", err)
 	}
-	t.concurMgr.Release()
 	t.buffs.UnpinAll()
-	return nil
+	err := errors.Join(t.runCommitActions(), t.removeFiles())
+	t.shared = nil
+	t.concurMgr.Release()
+	t.runEndActions()
+	return err
 }
 
 func (t *TransactionImpl) Rollback() error {
 	if err := t.recoveryMgr.Rollback(); err != nil {
 		return fmt.Errorf("tx: failed to rollback: %w", err)
 	}
+	t.removals, t.commitActions, t.removalMarks, t.shared = nil, nil, nil, nil
 	t.concurMgr.Release()
 	t.buffs.UnpinAll()
+	t.runEndActions()
 	return nil
 }
 
+// OnCommit calls fn once the transaction has committed, before its locks are released. Rolling back, to a savepoint taken
+// before this call or entirely, cancels it. fn can only change the database with WriteShared, as the commit is already logged.
+func (t *TransactionImpl) OnCommit(fn func() error) {
+	t.commitActions = append(t.commitActions, fn)
+}
+
+// OnEnd calls fn once the transaction has committed or rolled back, after its locks are released.
+// Unlike OnCommit, rolling back to a savepoint does not cancel it.
+func (t *TransactionImpl) OnEnd(fn func()) {
+	t.endActions = append(t.endActions, fn)
+}
+
+func (t *TransactionImpl) runEndActions() {
+	actions := t.endActions
+	t.endActions = nil
+	for _, fn := range actions {
+		fn()
+	}
+}
+
+func (t *TransactionImpl) runCommitActions() error {
+	var errs []error
+	for _, fn := range t.commitActions {
+		if err := fn(); err != nil {
+			errs = append(errs, fmt.Errorf("tx: committed, but failed to finish: %w", err))
+		}
+	}
+	t.commitActions = nil
+	return errors.Join(errs...)
+}
+
+// RemoveFileOnCommit removes the file when the transaction commits. Rolling back, to a savepoint taken before this call or entirely, cancels it.
+// The caller must hold an exclusive lock on the file, so that no other transaction has modified blocks of it.
+// If the transaction writes to the file afterwards, as a new table of the same name does, the file is truncated after the blocks written instead.
+func (t *TransactionImpl) RemoveFileOnCommit(filename string) {
+	t.removals = append(t.removals, removal{filename: filename, blocks: -1})
+}
+
+// TruncateFileOnCommit shortens the file to its first blocks when the transaction commits, as RemoveFileOnCommit removes a file.
+// The blocks removed must hold nothing that is still needed once the transaction has committed; those the transaction writes to afterwards are kept.
+func (t *TransactionImpl) TruncateFileOnCommit(filename string, blocks int) {
+	t.removals = append(t.removals, removal{filename: filename, blocks: blocks})
+}
+
+// keep makes the removals and truncations of the file of the block that are scheduled for commit keep the block, which the transaction writes to.
+// A removal becomes a truncation, as the file is in use again.
+func (t *TransactionImpl) keep(block file.BlockId) {
+	for i, r := range t.removals {
+		if r.filename == block.Filename() && r.blocks <= block.Number() {
+			t.removals[i].blocks = block.Number() + 1
+		}
+	}
+}
+
+func (t *TransactionImpl) removeFiles() error {
+	var errs []error
+	for _, r := range t.removals {
+		t.bm.Discard(r.filename)
+		if r.blocks < 0 {
+			if err := t.fm.Remove(r.filename); err != nil {
+				errs = append(errs, fmt.Errorf("tx: committed, but failed to remove %s: %w", r.filename, err))
+			}
+			continue
+		}
+		if err := t.fm.Truncate(r.filename, r.blocks); err != nil {
+			errs = append(errs, fmt.Errorf("tx: committed, but failed to truncate %s: %w", r.filename, err))
+		}
+	}
+	t.removals, t.removalMarks = nil, nil
+	return errors.Join(errs...)
+}
+
 func (t *TransactionImpl) Recover() error {
 	if err := t.bm.FlushAll(t.txNum); err != nil {
 		return fmt.Errorf("tx: failed to flush all: %w", err)
@@ -66,7 +234,49 @@ func (t *TransactionImpl) Recover() error {
 	return nil
 }
 
+func (t *TransactionImpl) Savepoint(name string) error {
+	if err := t.recoveryMgr.Savepoint(name); err != nil {
+		return fmt.Errorf("tx: failed to create savepoint: %w", err)
+	}
+	t.removalMarks = append(t.removalMarks, removalMark{savepoint: name, n: len(t.removals), actions: len(t.commitActions)})
+	return nil
+}
+
+// RollbackToSavepoint undoes the changes made after the savepoint. Locks acquired since then are kept.
+func (t *TransactionImpl) RollbackToSavepoint(name string) error {
+	if err := t.recoveryMgr.RollbackToSavepoint(name); err != nil {
+		return fmt.Errorf("tx: failed to rollback to savepoint: %w", err)
+	}
+	if idx, ok := t.findRemovalMark(name); ok {
+		t.removals = t.removals[:t.removalMarks[idx].n]
+		t.commitActions = t.commitActions[:t.removalMarks[idx].actions]
+		t.removalMarks = t.removalMarks[:idx+1]
+	}
+	return nil
+}
+
+func (t *TransactionImpl) ReleaseSavepoint(name string) error {
+	if err := t.recoveryMgr.ReleaseSavepoint(name); err != nil {
+		return fmt.Errorf("tx: failed to release savepoint: %w", err)
+	}
+	if idx, ok := t.findRemovalMark(name); ok {
+		t.removalMarks = t.removalMarks[:idx]
+	}
+	return nil
+}
+
+// findRemovalMark returns the newest mark of the savepoint, following the recovery manager in hiding older savepoints of the same name.
+func (t *TransactionImpl) findRemovalMark(name string) (int, bool) {
+	for i := len(t.removalMarks) - 1; i >= 0; i-- {
+		if t.removalMarks[i].savepoint == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
 func (t *TransactionImpl) Pin(block file.BlockId) error {
+	t.started = true
 	return t.buffs.Pin(block)
 }
 
@@ -78,6 +288,7 @@ func (t *TransactionImpl) GetInt(block file.BlockId, offset int) (int, error) {
 	if err := t.concurMgr.SLock(block); err != nil {
 		return 0, fmt.Errorf("tx: failed to get int: %w", err)
 	}
+	defer t.concurMgr.ReleaseRead(block)
 	buff, ok := t.buffs.GetBuffer(block)
 	if !ok {
 		return 0, fmt.Errorf("tx: buffer not found for block %v", block)
@@ -90,18 +301,30 @@ func (t *TransactionImpl) GetString(block file.BlockId, offset int) (string, err
 	if err := t.concurMgr.SLock(block); err != nil {
 		return "", fmt.Errorf("tx: failed to get string: %w", err)
 	}
+	defer t.concurMgr.ReleaseRead(block)
 	buff, ok := t.buffs.GetBuffer(block)
 	if !ok {
 		return "", fmt.Errorf("tx: buffer not found for block %v", block)
 	}
+	if err := file.CheckBytes(buff.Contents(), offset, t.BlockSize()); err != nil {
+		return "", fmt.Errorf("tx: block %v: %w", block, err)
+	}
 	val := buff.Contents().GetString(offset)
 	return val, nil
 }
 
+// SetInt stores val as a signed 32-bit integer, and returns ErrIntOutOfRange instead of truncating a value that does not fit.
 func (t *TransactionImpl) SetInt(block file.BlockId, offset int, val int, okToLog bool) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	if val < math.MinInt32 || val > math.MaxInt32 {
+		return fmt.Errorf("%w: %d", ErrIntOutOfRange, val)
+	}
 	if err := t.concurMgr.XLock(block); err != nil {
 		return fmt.Errorf("tx: failed to XLock block %v: %w", block, err)
 	}
+	t.keep(block)
 	buff, ok := t.buffs.GetBuffer(block)
 	if !ok {
 		return fmt.Errorf("tx: buffer not found for block %v", block)
@@ -116,23 +339,28 @@ func (t *TransactionImpl) SetInt(block file.BlockId, offset int, val int, okToLo
 		}
 	}
 	buff.WriteContents(t.txNum, lsn, func(p buffer.ReadWritePage) {
-		p.SetInt(offset, uint32(val))
+		p.SetInt(offset, int32(val))
 	})
 	return nil
 }
 
 func (t *TransactionImpl) SetString(block file.BlockId, offset int, val string, okToLog bool) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
 	if err := t.concurMgr.XLock(block); err != nil {
 		return fmt.Errorf("tx: failed to XLock block %v: %w", block, err)
 	}
+	t.keep(block)
 	buff, ok := t.buffs.GetBuffer(block)
 	if !ok {
 		return fmt.Errorf("tx: buffer not found for block %v", block)
 	}
 	var lsn int = -1
 	if okToLog {
+		oldVal := buff.Contents().GetString(offset)
 		var err error
-		lsn, err = t.recoveryMgr.SetString(buff, offset, val)
+		lsn, err = t.recoveryMgr.SetString(buff, offset, oldVal)
 		if err != nil {
 			return fmt.Errorf("tx: failed to set string: %w", err)
 		}
@@ -143,12 +371,235 @@ func (t *TransactionImpl) SetString(block file.BlockId, offset int, val string,
 	return nil
 }
 
+func (t *TransactionImpl) GetInt64(block file.BlockId, offset int) (int64, error) {
+	var val int64
+	err := t.read(block, func(p buffer.ReadPage) {
+		val = p.GetInt64(offset)
+	})
+	return val, err
+}
+
+func (t *TransactionImpl) GetFloat64(block file.BlockId, offset int) (float64, error) {
+	var val float64
+	err := t.read(block, func(p buffer.ReadPage) {
+		val = p.GetFloat64(offset)
+	})
+	return val, err
+}
+
+func (t *TransactionImpl) GetBool(block file.BlockId, offset int) (bool, error) {
+	var val bool
+	err := t.read(block, func(p buffer.ReadPage) {
+		val = p.GetBool(offset)
+	})
+	return val, err
+}
+
+func (t *TransactionImpl) GetTime(block file.BlockId, offset int) (time.Time, error) {
+	var val time.Time
+	err := t.read(block, func(p buffer.ReadPage) {
+		val = p.GetTime(offset)
+	})
+	return val, err
+}
+
+// GetBytes returns a copy of the bytes, which stays valid after the block is unpinned.
+func (t *TransactionImpl) GetBytes(block file.BlockId, offset int) ([]byte, error) {
+	var val []byte
+	var checkErr error
+	err := t.read(block, func(p buffer.ReadPage) {
+		if checkErr = file.CheckBytes(p, offset, t.BlockSize()); checkErr == nil {
+			val = append([]byte{}, p.GetBytes(offset)...)
+		}
+	})
+	if err == nil && checkErr != nil {
+		err = fmt.Errorf("tx: block %v: %w", block, checkErr)
+	}
+	return val, err
+}
+
+func (t *TransactionImpl) SetInt64(block file.BlockId, offset int, val int64, okToLog bool) error {
+	return t.write(block, offset, okToLog, func(p buffer.ReadPage) any {
+		return p.GetInt64(offset)
+	}, func(p buffer.ReadWritePage) {
+		p.SetInt64(offset, val)
+	})
+}
+
+func (t *TransactionImpl) SetFloat64(block file.BlockId, offset int, val float64, okToLog bool) error {
+	return t.write(block, offset, okToLog, func(p buffer.ReadPage) any {
+		return p.GetFloat64(offset)
+	}, func(p buffer.ReadWritePage) {
+		p.SetFloat64(offset, val)
+	})
+}
+
+func (t *TransactionImpl) SetBool(block file.BlockId, offset int, val bool, okToLog bool) error {
+	return t.write(block, offset, okToLog, func(p buffer.ReadPage) any {
+		return p.GetBool(offset)
+	}, func(p buffer.ReadWritePage) {
+		p.SetBool(offset, val)
+	})
+}
+
+func (t *TransactionImpl) SetTime(block file.BlockId, offset int, val time.Time, okToLog bool) error {
+	return t.write(block, offset, okToLog, func(p buffer.ReadPage) any {
+		return p.GetTime(offset)
+	}, func(p buffer.ReadWritePage) {
+		p.SetTime(offset, val)
+	})
+}
+
+func (t *TransactionImpl) SetBytes(block file.BlockId, offset int, val []byte, okToLog bool) error {
+	return t.write(block, offset, okToLog, func(p buffer.ReadPage) any {
+		return append([]byte{}, p.GetBytes(offset)...)
+	}, func(p buffer.ReadWritePage) {
+		p.SetBytes(offset, val)
+	})
+}
+
+// read calls get with the page of the block, holding a shared lock on the block while it does.
+func (t *TransactionImpl) read(block file.BlockId, get func(p buffer.ReadPage)) error {
+	if err := t.concurMgr.SLock(block); err != nil {
+		return fmt.Errorf("tx: failed to SLock block %v: %w", block, err)
+	}
+	defer t.concurMgr.ReleaseRead(block)
+	buff, ok := t.buffs.GetBuffer(block)
+	if !ok {
+		return fmt.Errorf("tx: buffer not found for block %v", block)
+	}
+	get(buff.Contents())
+	return nil
+}
+
+// write calls set with the page of the block, after taking an exclusive lock on the block.
+// If okToLog is true, it first logs the value that old reads at the offset.
+func (t *TransactionImpl) write(block file.BlockId, offset int, okToLog bool, old func(p buffer.ReadPage) any, set func(p buffer.ReadWritePage)) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	if err := t.concurMgr.XLock(block); err != nil {
+		return fmt.Errorf("tx: failed to XLock block %v: %w", block, err)
+	}
+	t.keep(block)
+	buff, ok := t.buffs.GetBuffer(block)
+	if !ok {
+		return fmt.Errorf("tx: buffer not found for block %v", block)
+	}
+	var lsn int = -1
+	if okToLog {
+		var err error
+		lsn, err = t.recoveryMgr.SetValue(buff, offset, old(buff.Contents()))
+		if err != nil {
+			return fmt.Errorf("tx: failed to log old value: %w", err)
+		}
+	}
+	buff.WriteContents(t.txNum, lsn, set)
+	return nil
+}
+
+// ReadShared calls get with the page of the block, latched for the call but not locked. A block past the end of the file reads as zeros.
+func (t *TransactionImpl) ReadShared(block file.BlockId, get func(p buffer.ReadPage)) error {
+	t.started = true
+	size, err := t.fm.BlockNum(block.Filename())
+	if err != nil {
+		return fmt.Errorf("tx: failed to get size: %w", err)
+	}
+	if block.Number() >= size {
+		get(file.NewPage(t.fm.BlockSize()))
+		return nil
+	}
+	buff, err := t.bm.Pin(block)
+	if err != nil {
+		return fmt.Errorf("tx: failed to pin block %v: %w", block, err)
+	}
+	defer t.bm.Unpin(buff)
+	buff.Latch()
+	defer buff.Unlatch()
+	get(buff.Contents())
+	return nil
+}
+
+// WriteShared calls set with the page of the block, latched for the call but not locked, and does not log the change.
+// It first appends the blocks up to the block to the file; racing appends may add more, which read as zeros.
+func (t *TransactionImpl) WriteShared(block file.BlockId, set func(p buffer.ReadWritePage)) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	t.started = true
+	size, err := t.fm.BlockNum(block.Filename())
+	if err != nil {
+		return fmt.Errorf("tx: failed to get size: %w", err)
+	}
+	for ; size <= block.Number(); size++ {
+		if _, err := t.fm.Append(block.Filename()); err != nil {
+			return fmt.Errorf("tx: failed to append: %w", err)
+		}
+	}
+	t.keep(block)
+	buff, err := t.bm.Pin(block)
+	if err != nil {
+		return fmt.Errorf("tx: failed to pin block %v: %w", block, err)
+	}
+	defer t.bm.Unpin(buff)
+	buff.WriteContents(t.txNum, -1, set)
+	if !slices.ContainsFunc(t.shared, block.Equals) {
+		t.shared = append(t.shared, block)
+	}
+	return nil
+}
+
+// AppendShared adds a new block to the end of the file without locking its end-of-file marker,
+// for the files whose blocks no scan reads in order.
+func (t *TransactionImpl) AppendShared(filename string) (file.BlockId, error) {
+	if t.readOnly {
+		return nil, ErrReadOnly
+	}
+	t.started = true
+	block, err := t.fm.Append(filename)
+	if err != nil {
+		return nil, fmt.Errorf("tx: failed to append: %w", err)
+	}
+	return block, nil
+}
+
+// flushShared writes the shared blocks the transaction has written to disk, which its own commit would miss
+// once another transaction has written them after it.
+func (t *TransactionImpl) flushShared() error {
+	var flushed []buffer.Buffer
+	for _, block := range t.shared {
+		buff, err := t.bm.Pin(block)
+		if err != nil {
+			return fmt.Errorf("tx: failed to pin block %v: %w", block, err)
+		}
+		defer t.bm.Unpin(buff)
+		if err := buff.Flush(); err != nil {
+			return err
+		}
+		flushed = append(flushed, buff)
+	}
+	synced := make(map[string]bool)
+	for _, buff := range flushed {
+		if filename := buff.Block().Filename(); !synced[filename] {
+			if err := buff.Sync(); err != nil {
+				return err
+			}
+			synced[filename] = true
+		}
+	}
+	return nil
+}
+
 // Size returns the number of blocks in the specified file.
+// It S locks the end-of-file marker of the file so that, at SERIALIZABLE, no other transaction can append a block
+// to the file until this one ends. This keeps the blocks seen by a scan from growing, i.e. prevents phantoms.
 func (t *TransactionImpl) Size(filename string) (int, error) {
+	t.started = true
 	dummy := file.NewBlockId(filename, END_OF_FILE)
 	if err := t.concurMgr.SLock(dummy); err != nil {
 		return 0, fmt.Errorf("tx: failed to SLock dummy block: %w", err)
 	}
+	defer t.concurMgr.ReleaseRead(dummy)
 	len, err := t.fm.BlockNum(filename)
 	if err != nil {
 		return 0, fmt.Errorf("tx: failed to get size: %w", err)
@@ -156,7 +607,13 @@ func (t *TransactionImpl) Size(filename string) (int, error) {
 	return len, nil
 }
 
+// Append adds a new block to the end of the file.
+// It X locks the end-of-file marker of the file, so it waits for the transactions that have read the size of the file.
 func (t *TransactionImpl) Append(filename string) (file.BlockId, error) {
+	if t.readOnly {
+		return nil, ErrReadOnly
+	}
+	t.started = true
 	dummy := file.NewBlockId(filename, END_OF_FILE)
 	if err := t.concurMgr.XLock(dummy); err != nil {
 		return nil, fmt.Errorf("tx: failed to XLock dummy block: %w", err)
@@ -168,6 +625,14 @@ func (t *TransactionImpl) Append(filename string) (file.BlockId, error) {
 	return block, nil
 }
 
+func (t *TransactionImpl) LockFile(filename string, mode LockMode) error {
+	t.started = true
+	if err := t.concurMgr.LockFile(filename, mode); err != nil {
+		return fmt.Errorf("tx: failed to lock file %s: %w", filename, err)
+	}
+	return nil
+}
+
 func (t *TransactionImpl) BlockSize() int {
 	return t.fm.BlockSize()
 }
@@ -175,3 +640,27 @@ func (t *TransactionImpl) BlockSize() int {
 func (t *TransactionImpl) AvailableBuffs() int {
 	return t.bm.AvailableNum()
 }
+
+func (t *TransactionImpl) SetIsolationLevel(level IsolationLevel) error {
+	if !level.Valid() {
+		return ErrUnknownIsolationLevel
+	}
+	t.concurMgr.SetIsolationLevel(level)
+	return nil
+}
+
+func (t *TransactionImpl) IsolationLevel() IsolationLevel {
+	return t.concurMgr.IsolationLevel()
+}
+
+func (t *TransactionImpl) SetReadOnly(readOnly bool) {
+	t.readOnly = readOnly
+}
+
+func (t *TransactionImpl) IsReadOnly() bool {
+	return t.readOnly
+}
+
+func (t *TransactionImpl) Started() bool {
+	return t.started
+}
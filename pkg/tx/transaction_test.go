@@ -1,12 +1,16 @@
 package tx
 
 import (
+	"errors"
+	"math"
+	"slices"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/kj455/simple-db/pkg/buffer"
 	"github.com/kj455/simple-db/pkg/file"
+	"github.com/kj455/simple-db/pkg/file/filetest"
 	"github.com/kj455/simple-db/pkg/log"
 	"github.com/kj455/simple-db/pkg/testutil"
 	"github.com/stretchr/testify/assert"
@@ -166,8 +170,8 @@ func TestTransaction_Concurrency(t *testing.T) {
 
 	buffs[0].AssignToBlock(blk1)
 	buffs[1].AssignToBlock(blk2)
-	assert.Equal(t, uint32(100), buffs[0].Contents().GetInt(0))
-	assert.Equal(t, uint32(200), buffs[1].Contents().GetInt(0))
+	assert.Equal(t, int32(100), buffs[0].Contents().GetInt(0))
+	assert.Equal(t, int32(200), buffs[1].Contents().GetInt(0))
 }
 
 func TestTransaction_Size(t *testing.T) {
@@ -230,3 +234,683 @@ func TestTransaction_Append(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, block.Equals(file.NewBlockId(fileName, 0)))
 }
+
+func TestTransaction_ReadOnly(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		fileName    = "test_transaction_read_only"
+		logFileName = "test_transaction_read_only_log"
+	)
+	dir, cleanup := testutil.SetupDir("test_transaction_read_only")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	buf := buffer.NewBuffer(fileMgr, logMgr, blockSize)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buf})
+	tx, err := NewTransaction(fileMgr, logMgr, bm, NewTxNumberGenerator(), WithReadOnly(true))
+	assert.NoError(t, err)
+	block := file.NewBlockId(fileName, 0)
+	assert.NoError(t, tx.Pin(block))
+
+	assert.ErrorIs(t, tx.SetInt(block, 0, 1, true), ErrReadOnly)
+	assert.ErrorIs(t, tx.SetString(block, 0, "one", true), ErrReadOnly)
+	_, err = tx.Append(fileName)
+	assert.ErrorIs(t, err, ErrReadOnly)
+	_, err = tx.GetInt(block, 0)
+	assert.NoError(t, err)
+
+	tx.SetReadOnly(false)
+	assert.NoError(t, tx.SetInt(block, 0, 1, true))
+	assert.NoError(t, tx.Commit())
+}
+
+func TestTransaction_Started(t *testing.T) {
+	t.Parallel()
+	const blockSize = 400
+	fm := filetest.NewFaultyFileMgr(blockSize)
+	lm, err := log.NewLogMgr(fm, "log")
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+
+	for name, access := range map[string]func(tx Transaction) error{
+		"pin":  func(tx Transaction) error { return tx.Pin(file.NewBlockId("data", 0)) },
+		"size": func(tx Transaction) error { _, err := tx.Size("data"); return err },
+		"lock": func(tx Transaction) error { return tx.LockFile("data", LOCK_MODE_S) },
+	} {
+		tx, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Savepoint("sp"))
+		assert.False(t, tx.Started(), name)
+		assert.NoError(t, access(tx), name)
+		assert.True(t, tx.Started(), name)
+		assert.NoError(t, tx.Rollback())
+	}
+}
+
+func TestTransaction_IsolationLevel(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		fileName    = "test_transaction_isolation_level"
+		logFileName = "test_transaction_isolation_level_log"
+	)
+	dir, cleanup := testutil.SetupDir("test_transaction_isolation_level")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	buffs := []buffer.Buffer{
+		buffer.NewBuffer(fileMgr, logMgr, blockSize),
+		buffer.NewBuffer(fileMgr, logMgr, blockSize),
+	}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := NewTxNumberGenerator()
+	lockTable := NewLock(WithWaitTime(10 * time.Millisecond))
+	block := file.NewBlockId(fileName, 0)
+
+	writer, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Pin(block))
+	assert.NoError(t, writer.SetInt(block, 0, 42, true))
+
+	reader, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable), WithIsolationLevel(ISOLATION_LEVEL_READ_UNCOMMITTED))
+	assert.NoError(t, err)
+	assert.Equal(t, ISOLATION_LEVEL_READ_UNCOMMITTED, reader.IsolationLevel())
+	assert.NoError(t, reader.Pin(block))
+	val, err := reader.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, val) // dirty read
+
+	assert.NoError(t, reader.SetIsolationLevel(ISOLATION_LEVEL_READ_COMMITTED))
+	_, err = reader.GetInt(block, 0)
+	assert.Error(t, err)
+	assert.ErrorIs(t, reader.SetIsolationLevel(IsolationLevel(0)), ErrUnknownIsolationLevel)
+
+	assert.NoError(t, writer.Commit())
+	val, err = reader.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, val)
+
+	// the short S lock of the read committed reader does not block a new writer
+	writer2, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable))
+	assert.NoError(t, err)
+	assert.NoError(t, writer2.Pin(block))
+	assert.NoError(t, writer2.SetInt(block, 0, 43, true))
+	assert.NoError(t, writer2.Commit())
+	assert.NoError(t, reader.Commit())
+
+	_, err = NewTransaction(fileMgr, logMgr, bm, txNumGen, WithIsolationLevel(IsolationLevel(100)))
+	assert.ErrorIs(t, err, ErrUnknownIsolationLevel)
+}
+
+func TestTransaction_Savepoint(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		fileName    = "test_transaction_savepoint"
+		logFileName = "test_transaction_savepoint_log"
+	)
+	dir, cleanup := testutil.SetupDir("test_transaction_savepoint")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	buf := buffer.NewBuffer(fileMgr, logMgr, blockSize)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buf})
+	txNumGen := NewTxNumberGenerator()
+	block := file.NewBlockId(fileName, 0)
+
+	tx, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Pin(block))
+	assert.NoError(t, tx.SetInt(block, 0, 1, true))
+	assert.NoError(t, tx.Savepoint("a"))
+	assert.NoError(t, tx.SetInt(block, 0, 2, true))
+	assert.NoError(t, tx.SetString(block, 10, "two", true))
+	assert.NoError(t, tx.Savepoint("b"))
+	assert.NoError(t, tx.SetInt(block, 0, 3, true))
+
+	// partial rollback keeps the savepoint itself
+	assert.NoError(t, tx.RollbackToSavepoint("b"))
+	val, err := tx.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, val)
+	assert.NoError(t, tx.SetInt(block, 0, 4, true))
+	assert.NoError(t, tx.RollbackToSavepoint("b"))
+	val, err = tx.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, val)
+
+	// rolling back to an older savepoint discards the newer ones
+	assert.NoError(t, tx.RollbackToSavepoint("a"))
+	val, err = tx.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+	str, err := tx.GetString(block, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, "", str)
+	assert.ErrorIs(t, tx.RollbackToSavepoint("b"), ErrSavepointNotFound)
+
+	// released savepoints can no longer be rolled back to
+	assert.NoError(t, tx.ReleaseSavepoint("a"))
+	assert.ErrorIs(t, tx.RollbackToSavepoint("a"), ErrSavepointNotFound)
+	assert.ErrorIs(t, tx.ReleaseSavepoint("a"), ErrSavepointNotFound)
+
+	// a full rollback after a partial one restores the original value
+	assert.NoError(t, tx.Rollback())
+	tx2, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx2.Pin(block))
+	val, err = tx2.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, val)
+	assert.NoError(t, tx2.Commit())
+}
+
+func TestTransaction_SavepointDuplicateName(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		fileName    = "test_transaction_savepoint_duplicate"
+		logFileName = "test_transaction_savepoint_duplicate_log"
+	)
+	dir, cleanup := testutil.SetupDir("test_transaction_savepoint_duplicate")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	buf := buffer.NewBuffer(fileMgr, logMgr, blockSize)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buf})
+	block := file.NewBlockId(fileName, 0)
+
+	tx, err := NewTransaction(fileMgr, logMgr, bm, NewTxNumberGenerator())
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Pin(block))
+	assert.NoError(t, tx.Savepoint("sp"))
+	assert.NoError(t, tx.SetInt(block, 0, 1, true))
+	assert.NoError(t, tx.Savepoint("sp"))
+	assert.NoError(t, tx.SetInt(block, 0, 2, true))
+
+	// the most recent savepoint with the name wins
+	assert.NoError(t, tx.RollbackToSavepoint("sp"))
+	val, err := tx.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	// releasing it exposes the older one
+	assert.NoError(t, tx.ReleaseSavepoint("sp"))
+	assert.NoError(t, tx.RollbackToSavepoint("sp"))
+	val, err = tx.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, val)
+	assert.NoError(t, tx.Commit())
+}
+
+func TestTransaction_EndOfFileLock(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize   = 400
+		fileName    = "test_transaction_end_of_file_lock"
+		logFileName = "test_transaction_end_of_file_lock_log"
+	)
+	dir, cleanup := testutil.SetupDir("test_transaction_end_of_file_lock")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, logFileName)
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fileMgr, logMgr, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+	lockTable := NewLock(WithWaitTime(10 * time.Millisecond))
+
+	t.Run("serializable", func(t *testing.T) {
+		reader, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable))
+		assert.NoError(t, err)
+		size, err := reader.Size(fileName)
+		assert.NoError(t, err)
+
+		writer, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable))
+		assert.NoError(t, err)
+		_, err = writer.Append(fileName)
+		assert.Error(t, err)
+		assert.NoError(t, writer.Rollback())
+
+		got, err := reader.Size(fileName)
+		assert.NoError(t, err)
+		assert.Equal(t, size, got)
+		assert.NoError(t, reader.Commit())
+	})
+	t.Run("appending blocks readers of the size", func(t *testing.T) {
+		writer, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable))
+		assert.NoError(t, err)
+		_, err = writer.Append(fileName)
+		assert.NoError(t, err)
+
+		reader, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable))
+		assert.NoError(t, err)
+		_, err = reader.Size(fileName)
+		assert.Error(t, err)
+		assert.NoError(t, writer.Commit())
+		assert.NoError(t, reader.Commit())
+	})
+	t.Run("repeatable read", func(t *testing.T) {
+		reader, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable), WithIsolationLevel(ISOLATION_LEVEL_REPEATABLE_READ))
+		assert.NoError(t, err)
+		size, err := reader.Size(fileName)
+		assert.NoError(t, err)
+
+		writer, err := NewTransaction(fileMgr, logMgr, bm, txNumGen, WithTxLockTable(lockTable))
+		assert.NoError(t, err)
+		_, err = writer.Append(fileName)
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Commit())
+
+		got, err := reader.Size(fileName)
+		assert.NoError(t, err)
+		assert.Equal(t, size+1, got)
+		assert.NoError(t, reader.Commit())
+	})
+}
+
+func TestTransaction_RemoveFileOnCommit(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize = 400
+		logFile   = "log"
+		dataFile  = "data"
+	)
+	fm := filetest.NewFaultyFileMgr(blockSize)
+	lm, err := log.NewLogMgr(fm, logFile)
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize), buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+	hasFile := func() bool {
+		files, err := fm.Files()
+		assert.NoError(t, err)
+		return slices.Contains(files, dataFile)
+	}
+	// setup writes a value to the file and keeps its block in the buffer pool
+	setup := func() {
+		tx, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		blk, err := tx.Append(dataFile)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Pin(blk))
+		assert.NoError(t, tx.SetInt(blk, 0, 1, true))
+		assert.NoError(t, tx.Commit())
+	}
+
+	t.Run("rollback keeps the file", func(t *testing.T) {
+		setup()
+		tx, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		tx.RemoveFileOnCommit(dataFile)
+		assert.NoError(t, tx.Rollback())
+		assert.True(t, hasFile())
+	})
+	t.Run("rollback to an earlier savepoint keeps the file", func(t *testing.T) {
+		tx, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Savepoint("before"))
+		tx.RemoveFileOnCommit(dataFile)
+		assert.NoError(t, tx.RollbackToSavepoint("before"))
+		assert.NoError(t, tx.Savepoint("after"))
+		assert.NoError(t, tx.ReleaseSavepoint("after"))
+		assert.NoError(t, tx.Commit())
+		assert.True(t, hasFile())
+	})
+	t.Run("commit removes the file and its cached blocks", func(t *testing.T) {
+		tx, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, tx.Savepoint("before"))
+		tx.RemoveFileOnCommit(dataFile)
+		assert.NoError(t, tx.ReleaseSavepoint("before"))
+		assert.True(t, hasFile())
+		assert.NoError(t, tx.Commit())
+		assert.False(t, hasFile())
+
+		// a new file of the same name does not see the old contents
+		reader, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		blk, err := reader.Append(dataFile)
+		assert.NoError(t, err)
+		assert.NoError(t, reader.Pin(blk))
+		val, err := reader.GetInt(blk, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, val)
+		assert.NoError(t, reader.Commit())
+	})
+	t.Run("writing the file again keeps it", func(t *testing.T) {
+		setup()
+		tx, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		tx.RemoveFileOnCommit(dataFile)
+		blk := file.NewBlockId(dataFile, 1)
+		assert.NoError(t, tx.Pin(blk))
+		assert.NoError(t, tx.SetInt(blk, 0, 7, true))
+		assert.NoError(t, tx.Commit())
+		assert.True(t, hasFile())
+		size, err := fm.BlockNum(dataFile)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, size)
+
+		reader, err := NewTransaction(fm, lm, bm, txNumGen)
+		assert.NoError(t, err)
+		assert.NoError(t, reader.Pin(blk))
+		val, err := reader.GetInt(blk, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, val)
+		assert.NoError(t, reader.Commit())
+	})
+}
+
+func TestTransaction_OnCommit(t *testing.T) {
+	t.Parallel()
+	const blockSize = 400
+	fm := filetest.NewFaultyFileMgr(blockSize)
+	lm, err := log.NewLogMgr(fm, "log")
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+	var called []string
+	record := func(name string) func() error {
+		return func() error {
+			called = append(called, name)
+			return nil
+		}
+	}
+
+	tx, err := NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	tx.OnCommit(record("rolled back"))
+	assert.NoError(t, tx.Rollback())
+
+	tx, err = NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	tx.OnCommit(record("first"))
+	assert.NoError(t, tx.Savepoint("sp"))
+	tx.OnCommit(record("after savepoint"))
+	assert.NoError(t, tx.RollbackToSavepoint("sp"))
+	tx.OnCommit(record("second"))
+	assert.Empty(t, called)
+	assert.NoError(t, tx.Commit())
+	assert.Equal(t, []string{"first", "second"}, called)
+
+	// a failure is reported once the transaction has committed
+	tx, err = NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	errFailed := errors.New("failed")
+	tx.OnCommit(func() error { return errFailed })
+	assert.ErrorIs(t, tx.Commit(), errFailed)
+}
+
+func TestTransaction_OnEnd(t *testing.T) {
+	t.Parallel()
+	const blockSize = 400
+	fm := filetest.NewFaultyFileMgr(blockSize)
+	lm, err := log.NewLogMgr(fm, "log")
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+	var called []string
+	record := func(name string) func() {
+		return func() {
+			called = append(called, name)
+		}
+	}
+
+	tx, err := NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	tx.OnEnd(record("rolled back"))
+	assert.NoError(t, tx.Rollback())
+	assert.Equal(t, []string{"rolled back"}, called)
+
+	// rolling back to a savepoint does not cancel it, and it runs after the commit actions
+	tx, err = NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Savepoint("sp"))
+	tx.OnEnd(record("ended"))
+	tx.OnCommit(func() error {
+		called = append(called, "committed")
+		return nil
+	})
+	assert.NoError(t, tx.RollbackToSavepoint("sp"))
+	tx.OnCommit(func() error {
+		called = append(called, "committed")
+		return nil
+	})
+	assert.NoError(t, tx.Commit())
+	assert.Equal(t, []string{"rolled back", "committed", "ended"}, called)
+}
+
+func TestTransaction_Shared(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize = 400
+		dataFile  = "data"
+	)
+	dir, cleanup := testutil.SetupDir("test_transaction_shared")
+	t.Cleanup(cleanup)
+	fm := file.NewFileMgr(dir, blockSize)
+	lm, err := log.NewLogMgr(fm, "log")
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize), buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+	lockTable := NewLock(WithWaitTime(100 * time.Millisecond))
+	blk := file.NewBlockId(dataFile, 2)
+	readShared := func(tx Transaction, offset int) int {
+		var val int
+		assert.NoError(t, tx.ReadShared(blk, func(p buffer.ReadPage) {
+			val = int(p.GetInt(offset))
+		}))
+		return val
+	}
+	writeShared := func(tx Transaction, offset, val int) {
+		assert.NoError(t, tx.WriteShared(blk, func(p buffer.ReadWritePage) {
+			p.SetInt(offset, int32(val))
+		}))
+	}
+
+	tx1, err := NewTransaction(fm, lm, bm, txNumGen, WithTxLockTable(lockTable))
+	assert.NoError(t, err)
+	tx2, err := NewTransaction(fm, lm, bm, txNumGen, WithTxLockTable(lockTable))
+	assert.NoError(t, err)
+	// a block past the end of the file reads as zeros, and writing it appends the blocks up to it
+	assert.Equal(t, 0, readShared(tx1, 0))
+	writeShared(tx1, 0, 1)
+	size, err := fm.BlockNum(dataFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+
+	// the other transaction reads and writes the block without waiting for the first to end
+	assert.Equal(t, 1, readShared(tx2, 0))
+	writeShared(tx2, 4, 2)
+
+	// the commit writes the block to disk, although the other transaction wrote it last
+	assert.NoError(t, tx1.Commit())
+	page := file.NewPage(blockSize)
+	assert.NoError(t, fm.Read(blk, page))
+	assert.Equal(t, int32(1), page.GetInt(0))
+	assert.Equal(t, int32(2), page.GetInt(4))
+
+	// rolling back does not undo a shared change
+	assert.NoError(t, tx2.Rollback())
+	tx3, err := NewTransaction(fm, lm, bm, txNumGen, WithTxLockTable(lockTable))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, readShared(tx3, 4))
+	assert.NoError(t, tx3.Commit())
+}
+
+func TestTransaction_TruncateFileOnCommit(t *testing.T) {
+	t.Parallel()
+	const (
+		blockSize = 400
+		logFile   = "log"
+		dataFile  = "data"
+	)
+	fm := filetest.NewFaultyFileMgr(blockSize)
+	lm, err := log.NewLogMgr(fm, logFile)
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fm, lm, blockSize), buffer.NewBuffer(fm, lm, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+	writer, err := NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		blk, err := writer.Append(dataFile)
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Pin(blk))
+		assert.NoError(t, writer.SetInt(blk, 0, i+1, true))
+		writer.Unpin(blk)
+	}
+	assert.NoError(t, writer.Commit())
+
+	tx, err := NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	tx.TruncateFileOnCommit(dataFile, 1)
+	assert.NoError(t, tx.Rollback())
+	size, err := fm.BlockNum(dataFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, size)
+
+	// a block written after the truncation was scheduled is kept
+	tx, err = NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	tx.TruncateFileOnCommit(dataFile, 1)
+	blk := file.NewBlockId(dataFile, 1)
+	assert.NoError(t, tx.Pin(blk))
+	assert.NoError(t, tx.SetInt(blk, 0, 5, true))
+	assert.NoError(t, tx.Commit())
+	size, err = fm.BlockNum(dataFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, size)
+
+	tx, err = NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	tx.TruncateFileOnCommit(dataFile, 1)
+	assert.NoError(t, tx.Commit())
+	size, err = fm.BlockNum(dataFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, size)
+
+	// the kept block is intact, and a new block does not see the old contents
+	reader, err := NewTransaction(fm, lm, bm, txNumGen)
+	assert.NoError(t, err)
+	var kept file.BlockId = file.NewBlockId(dataFile, 0)
+	assert.NoError(t, reader.Pin(kept))
+	val, err := reader.GetInt(kept, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+	appended, err := reader.Append(dataFile)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, appended.Number())
+	assert.NoError(t, reader.Pin(appended))
+	val, err = reader.GetInt(appended, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, val)
+	assert.NoError(t, reader.Commit())
+}
+
+func TestTransaction_Values(t *testing.T) {
+	t.Parallel()
+	const blockSize = 400
+	dir, cleanup := testutil.SetupDir("test_transaction_values")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, "test_transaction_values_log")
+	assert.NoError(t, err)
+	buffs := []buffer.Buffer{buffer.NewBuffer(fileMgr, logMgr, blockSize)}
+	bm := buffer.NewBufferMgr(buffs)
+	txNumGen := NewTxNumberGenerator()
+	block := file.NewBlockId("test_transaction_values", 0)
+	ts := time.Date(2024, 2, 29, 13, 4, 5, 123456000, time.UTC)
+	set := func(txn Transaction, i int64, f float64, b bool, tm time.Time, bs []byte) {
+		assert.NoError(t, txn.SetInt64(block, 0, i, true))
+		assert.NoError(t, txn.SetFloat64(block, 8, f, true))
+		assert.NoError(t, txn.SetBool(block, 16, b, true))
+		assert.NoError(t, txn.SetTime(block, 20, tm, true))
+		assert.NoError(t, txn.SetBytes(block, 28, bs, true))
+	}
+	check := func(txn Transaction, i int64, f float64, b bool, tm time.Time, bs []byte) {
+		gotInt, err := txn.GetInt64(block, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, i, gotInt)
+		gotFloat, err := txn.GetFloat64(block, 8)
+		assert.NoError(t, err)
+		assert.Equal(t, f, gotFloat)
+		gotBool, err := txn.GetBool(block, 16)
+		assert.NoError(t, err)
+		assert.Equal(t, b, gotBool)
+		gotTime, err := txn.GetTime(block, 20)
+		assert.NoError(t, err)
+		assert.True(t, tm.Equal(gotTime), "%v != %v", tm, gotTime)
+		gotBytes, err := txn.GetBytes(block, 28)
+		assert.NoError(t, err)
+		assert.Equal(t, bs, gotBytes)
+	}
+
+	tx1, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx1.Pin(block))
+	set(tx1, -1<<40, 1.5, true, ts, []byte{1, 2, 3})
+	assert.NoError(t, tx1.Commit())
+
+	// the changes are undone from the log records
+	tx2, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx2.Pin(block))
+	set(tx2, 7, -0.25, false, time.Unix(0, 0), []byte{9})
+	check(tx2, 7, -0.25, false, time.Unix(0, 0), []byte{9})
+	assert.NoError(t, tx2.Rollback())
+
+	tx3, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx3.Pin(block))
+	check(tx3, -1<<40, 1.5, true, ts, []byte{1, 2, 3})
+	assert.NoError(t, tx3.Commit())
+}
+
+func TestTransaction_SignedInt(t *testing.T) {
+	t.Parallel()
+	const blockSize = 400
+	dir, cleanup := testutil.SetupDir("test_transaction_signed_int")
+	t.Cleanup(cleanup)
+	fileMgr := file.NewFileMgr(dir, blockSize)
+	logMgr, err := log.NewLogMgr(fileMgr, "test_transaction_signed_int_log")
+	assert.NoError(t, err)
+	bm := buffer.NewBufferMgr([]buffer.Buffer{buffer.NewBuffer(fileMgr, logMgr, blockSize)})
+	txNumGen := NewTxNumberGenerator()
+	block := file.NewBlockId("test_transaction_signed_int", 0)
+
+	tx1, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx1.Pin(block))
+	assert.NoError(t, tx1.SetInt(block, 0, math.MinInt32, true))
+	assert.ErrorIs(t, tx1.SetInt(block, 0, math.MaxInt32+1, true), ErrIntOutOfRange)
+	assert.ErrorIs(t, tx1.SetInt(block, 0, math.MinInt32-1, true), ErrIntOutOfRange)
+	assert.NoError(t, tx1.Commit())
+
+	// the old negative value is logged and restored as it was
+	tx2, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx2.Pin(block))
+	assert.NoError(t, tx2.SetInt(block, 0, -1, true))
+	val, err := tx2.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, val)
+	assert.NoError(t, tx2.Rollback())
+
+	tx3, err := NewTransaction(fileMgr, logMgr, bm, txNumGen)
+	assert.NoError(t, err)
+	assert.NoError(t, tx3.Pin(block))
+	val, err = tx3.GetInt(block, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, math.MinInt32, val)
+	// read as a length, the negative value is refused rather than sliced with
+	_, err = tx3.GetString(block, 0)
+	assert.ErrorIs(t, err, file.ErrBadLength)
+	_, err = tx3.GetBytes(block, 0)
+	assert.ErrorIs(t, err, file.ErrBadLength)
+	assert.NoError(t, tx3.Commit())
+}